@@ -0,0 +1,55 @@
+package pqueue
+
+import "time"
+
+// BoostFunc raises priority of the item which has been waiting in
+// the queue for given time, by changing anything its Less or the
+// queue's RankFunc depends on.
+type BoostFunc func(item QueueItem, waited time.Duration)
+
+// WithAging calls boost for every pending item each given interval
+// and puts the items back in order, so long waiting items of low
+// priority eventually get dequeued even under a constant stream of
+// high priority items. Ranked queues score the items again. Aging
+// runs in a background goroutine until the queue is closed.
+func WithAging(interval time.Duration, boost BoostFunc) Option {
+	return func(q *Queue) {
+		q.starts = append(q.starts, func() {
+			timer := q.clock.NewTimer(interval)
+			go func() {
+				defer timer.Stop()
+				for range timer.C() {
+					if !q.age(boost) {
+						return
+					}
+					timer.Reset(interval)
+				}
+			}()
+		})
+	}
+}
+
+// age boosts all the ready entries and restores the ordering. It
+// returns false once the queue is closed.
+func (q *Queue) age(boost BoostFunc) bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.closed {
+		return false
+	}
+	if q.items.Len() == 0 {
+		return true
+	}
+	now := q.now()
+	for _, e := range q.items.list() {
+		boost(e.item, now.Sub(e.waitingSince()))
+	}
+	if q.rank != nil {
+		state := q.state()
+		for _, e := range q.items.list() {
+			e.score = q.rank(e.item, state)
+		}
+	}
+	q.items.init()
+	return true
+}
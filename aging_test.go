@@ -0,0 +1,31 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAging(t *testing.T) {
+	boost := func(item QueueItem, waited time.Duration) {
+		if waited > 0 {
+			item.(*DummyTask).priority--
+		}
+	}
+	q := NewWithOptions(WithAging(1e7, boost))
+	defer q.Close()
+	old := NewDummyTask(10)
+	q.Enqueue(old)
+	time.Sleep(1e8)
+	q.Enqueue(NewDummyTask(5))
+	if task := q.Dequeue(); task != old {
+		t.Errorf("Expected long waiting item to be dequeued first")
+	}
+}
+
+func TestAgingStopsOnClose(t *testing.T) {
+	q := New(0)
+	q.Close()
+	if q.age(func(QueueItem, time.Duration) {}) {
+		t.Errorf("Expected aging to stop on closed queue")
+	}
+}
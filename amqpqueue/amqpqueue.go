@@ -0,0 +1,180 @@
+// Package amqpqueue bridges a pqueue.Queue and RabbitMQ, through
+// github.com/rabbitmq/amqp091-go, so the queue can be a prioritizing
+// buffer in front of an AMQP exchange. Publish drains the queue to
+// the exchange, best items first, with publisher confirms limiting
+// how many messages are in flight, and Consume fills the queue from
+// AMQP deliveries.
+package amqpqueue
+
+import (
+	"context"
+	"sync"
+
+	pqueue "github.com/mileusna/gopqueue"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Channel is the part of *amqp.Channel Publish uses.
+type Channel interface {
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// Options configure how items are published.
+type Options struct {
+	// Exchange the messages are published to.
+	Exchange string
+	// Key returns the routing key of the item, the empty key is
+	// used when it's nil.
+	Key func(pqueue.QueueItem) string
+	// Encode turns the item to a message.
+	Encode func(pqueue.QueueItem) (amqp.Publishing, error)
+	// Window is the most messages published and not confirmed
+	// yet, 64 when 0. Once it's reached, publishing waits.
+	Window int
+}
+
+// Publish takes items from the queue with Lease and publishes them
+// to the exchange, until the context is done or the queue is closed
+// and drained. Items are acked to the queue once the broker confirms
+// their messages, and nacked back to the queue when it refuses them,
+// so no item is lost on the way. The channel is put in confirm mode.
+// Once done leasing, Publish waits for the confirms of the messages
+// in flight, and returns the error which has stopped it.
+func Publish(ctx context.Context, q *pqueue.Queue, ch Channel, opts Options) error {
+	window := opts.Window
+	if window <= 0 {
+		window = 64
+	}
+	if err := ch.Confirm(false); err != nil {
+		return err
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, window))
+
+	var (
+		mu       sync.Mutex
+		inflight = make(map[uint64]*pqueue.Delivery)
+		free     = make(chan struct{}, window)
+		done     = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		for c := range confirms {
+			mu.Lock()
+			d := inflight[c.DeliveryTag]
+			delete(inflight, c.DeliveryTag)
+			mu.Unlock()
+			if d == nil {
+				continue
+			}
+			if c.Ack {
+				d.Ack()
+			} else {
+				d.Nack()
+			}
+			<-free
+		}
+		// channel closed, messages in flight won't be confirmed
+		mu.Lock()
+		defer mu.Unlock()
+		for tag, d := range inflight {
+			d.Nack()
+			delete(inflight, tag)
+		}
+	}()
+
+	err := publish(ctx, q, ch, opts, &mu, inflight, free, done)
+	// wait until every message in flight is confirmed
+	for n := 0; n < window; n++ {
+		select {
+		case free <- struct{}{}:
+		case <-done:
+			return err
+		}
+	}
+	return err
+}
+
+func publish(ctx context.Context, q *pqueue.Queue, ch Channel, opts Options, mu *sync.Mutex, inflight map[uint64]*pqueue.Delivery, free chan struct{}, done chan struct{}) error {
+	var tag uint64
+	for {
+		select {
+		case free <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return amqp.ErrClosed
+		}
+		d, err := q.Lease(ctx)
+		if err != nil {
+			<-free
+			if err == pqueue.ErrClosed {
+				return nil
+			}
+			return err
+		}
+		msg, err := opts.Encode(d.Item)
+		if err != nil {
+			d.Nack()
+			<-free
+			return err
+		}
+		key := ""
+		if opts.Key != nil {
+			key = opts.Key(d.Item)
+		}
+		tag += 1
+		mu.Lock()
+		inflight[tag] = d
+		mu.Unlock()
+		if err := ch.PublishWithContext(ctx, opts.Exchange, key, false, false, msg); err != nil {
+			mu.Lock()
+			delete(inflight, tag)
+			mu.Unlock()
+			d.Nack()
+			<-free
+			return err
+		}
+	}
+}
+
+// Consume puts items of given deliveries, eg. of *amqp.Channel's
+// Consume, to the queue with EnqueueUnique, until the deliveries are
+// closed or the context is done. Deliveries are acked once their
+// items are in the queue, or are duplicates, and rejected when they
+// can't be decoded. While the queue is full Consume waits.
+func Consume(ctx context.Context, q *pqueue.Queue, deliveries <-chan amqp.Delivery, decode func(amqp.Delivery) (pqueue.QueueItem, error)) error {
+	for {
+		var d amqp.Delivery
+		var ok bool
+		select {
+		case d, ok = <-deliveries:
+			if !ok {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		item, err := decode(d)
+		if err != nil {
+			d.Reject(false)
+			continue
+		}
+		for {
+			err = q.EnqueueUnique(item)
+			if err != pqueue.ErrQueueFull {
+				break
+			}
+			if err = q.WaitForSpace(ctx, 1); err != nil {
+				d.Nack(false, true)
+				return err
+			}
+		}
+		if err != nil && err != pqueue.ErrDuplicate {
+			d.Nack(false, true)
+			return err
+		}
+		d.Ack(false)
+	}
+}
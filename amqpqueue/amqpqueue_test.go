@@ -0,0 +1,162 @@
+package amqpqueue
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+// fakeChannel confirms published messages from the test, refusing
+// the ones told.
+type fakeChannel struct {
+	mu        sync.Mutex
+	confirm   chan amqp.Confirmation
+	tag       uint64
+	published []string
+	refuse    map[string]bool
+	hold      bool
+	held      []amqp.Confirmation
+}
+
+func (c *fakeChannel) Confirm(noWait bool) error {
+	return nil
+}
+
+func (c *fakeChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.confirm = confirm
+	return confirm
+}
+
+func (c *fakeChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tag += 1
+	name := string(msg.Body)
+	refused := c.refuse[name]
+	delete(c.refuse, name)
+	if !refused {
+		c.published = append(c.published, exchange+"/"+key+"/"+name)
+	}
+	conf := amqp.Confirmation{DeliveryTag: c.tag, Ack: !refused}
+	if c.hold {
+		c.held = append(c.held, conf)
+	} else {
+		go func() { c.confirm <- conf }()
+	}
+	return nil
+}
+
+func (c *fakeChannel) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hold = false
+	for _, conf := range c.held {
+		c.confirm <- conf
+	}
+	c.held = nil
+}
+
+var options = Options{
+	Exchange: "tasks",
+	Key: func(item pqueue.QueueItem) string {
+		return "p" + string(rune('0'+item.(*task).Priority))
+	},
+	Encode: func(item pqueue.QueueItem) (amqp.Publishing, error) {
+		return amqp.Publishing{Body: []byte(item.(*task).Name)}, nil
+	},
+	Window: 2,
+}
+
+func TestPublish(t *testing.T) {
+	q := pqueue.New(0)
+	for i, x := range []int{3, 1, 2} {
+		q.Enqueue(&task{Name: string(rune('a' + i)), Priority: x})
+	}
+	ch := &fakeChannel{refuse: map[string]bool{"b": true}, hold: true}
+	errs := make(chan error)
+	go func() {
+		errs <- Publish(context.Background(), q, ch, options)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if q.Leased() != 2 {
+		t.Errorf("Expected window of 2 messages in flight, given %d", q.Leased())
+	}
+	ch.release()
+	time.Sleep(50 * time.Millisecond)
+	q.Close()
+	if err := <-errs; err != nil {
+		t.Errorf("Expected no error, given %v", err)
+	}
+	expected := []string{"tasks/p2/c", "tasks/p1/b", "tasks/p3/a"}
+	if len(ch.published) != 3 {
+		t.Fatalf("Expected refused message published again, given %v", ch.published)
+	}
+	for i, name := range expected {
+		if ch.published[i] != name {
+			t.Errorf("Expected %s, given %s", name, ch.published[i])
+		}
+	}
+	if q.Len() != 0 || q.Leased() != 0 {
+		t.Errorf("Expected all items confirmed")
+	}
+}
+
+type acknowledger struct {
+	acked, rejected []uint64
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	a.rejected = append(a.rejected, tag)
+	return nil
+}
+
+func TestConsume(t *testing.T) {
+	ack := &acknowledger{}
+	deliveries := make(chan amqp.Delivery, 4)
+	for i, body := range []string{`{"Name":"a","Priority":2}`, `bad`, `{"Name":"b","Priority":1}`, `{"Name":"a","Priority":2}`} {
+		deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: uint64(i + 1), Body: []byte(body)}
+	}
+	close(deliveries)
+	q := pqueue.New(0)
+	err := Consume(context.Background(), q, deliveries, func(d amqp.Delivery) (pqueue.QueueItem, error) {
+		t := &task{}
+		return t, json.Unmarshal(d.Body, t)
+	})
+	if err != nil {
+		t.Errorf("Expected no error, given %v", err)
+	}
+	if q.Len() != 2 || q.Dequeue().(*task).Name != "b" {
+		t.Errorf("Expected 2 unique items in priority order")
+	}
+	if len(ack.acked) != 3 || len(ack.rejected) != 1 || ack.rejected[0] != 2 {
+		t.Errorf("Expected bad delivery rejected, the rest acked, given %v %v", ack.acked, ack.rejected)
+	}
+}
@@ -0,0 +1,61 @@
+package pqueue
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// approxLen holds the cached length on its own cache line, so
+// readers polling it don't fight with writers of the queue.
+type approxLen struct {
+	_       [64]byte
+	n       int64
+	running int32
+	_       [56]byte
+}
+
+// TrackApproxLen starts refreshing the length returned by ApproxLen
+// every given interval, in a background goroutine. Call returned
+// function to stop it.
+func (q *Queue) TrackApproxLen(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	q.refreshApproxLen()
+	atomic.StoreInt32(&q.approx.running, 1)
+	timer := q.clock.NewTimer(interval)
+	go func() {
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C():
+				q.refreshApproxLen()
+				timer.Reset(interval)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&once, 0, 1) {
+			atomic.StoreInt32(&q.approx.running, 0)
+			close(done)
+		}
+	}
+}
+
+// ApproxLen returns number of enqueued elements as seen by the
+// last refresh started with TrackApproxLen. It's only eventually
+// consistent and meant for coarse monitoring, where it can be
+// polled very often without touching the queue itself. When
+// tracking is not running it returns Len.
+func (q *Queue) ApproxLen() int {
+	if atomic.LoadInt32(&q.approx.running) == 0 {
+		return q.Len()
+	}
+	return int(atomic.LoadInt64(&q.approx.n))
+}
+
+func (q *Queue) refreshApproxLen() {
+	n := q.Len()
+	atomic.StoreInt64(&q.approx.n, int64(n))
+}
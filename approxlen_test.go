@@ -0,0 +1,90 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApproxLen(t *testing.T) {
+	q := New(0)
+	q.Enqueue(NewDummyTask(1))
+	stop := q.TrackApproxLen(time.Hour)
+	q.Enqueue(NewDummyTask(2))
+	if q.ApproxLen() != 1 {
+		t.Errorf("Expected approximate len to be refreshed lazily, given %d", q.ApproxLen())
+	}
+	stop()
+	stop()
+	if q.ApproxLen() != 2 {
+		t.Errorf("Expected exact len when not tracking, given %d", q.ApproxLen())
+	}
+}
+
+func TestApproxLenRefresh(t *testing.T) {
+	q := New(0)
+	stop := q.TrackApproxLen(time.Millisecond)
+	defer stop()
+	q.Enqueue(NewDummyTask(1))
+	deadline := time.Now().Add(time.Second)
+	for q.ApproxLen() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if q.ApproxLen() != 1 {
+		t.Errorf("Expected approximate len to be refreshed")
+	}
+}
+
+func TestApproxLenClock(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock))
+	stop := q.TrackApproxLen(time.Minute)
+	defer stop()
+	q.Enqueue(NewDummyTask(1))
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for q.ApproxLen() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if q.ApproxLen() != 1 {
+		t.Errorf("Expected approximate len to be refreshed once the clock moves")
+	}
+}
+
+func benchmarkLenPolling(b *testing.B, read func(q *Queue) int) {
+	q := New(0)
+	stop := q.TrackApproxLen(5 * time.Millisecond)
+	defer stop()
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				q.Enqueue(NewDummyTask(1))
+				q.Dequeue()
+			}
+		}
+	}()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			read(q)
+		}
+	})
+	b.StopTimer()
+	close(done)
+}
+
+// Len isn't synchronized with writers, so the exact length
+// is read with Fullness.
+func BenchmarkLenPolling(b *testing.B) {
+	benchmarkLenPolling(b, func(q *Queue) int {
+		n, _, _ := q.Fullness()
+		return n
+	})
+}
+
+func BenchmarkApproxLenPolling(b *testing.B) {
+	benchmarkLenPolling(b, (*Queue).ApproxLen)
+}
@@ -0,0 +1,98 @@
+package pqueue
+
+import (
+	"hash/maphash"
+	"math"
+)
+
+// bloom is a history kept in a bloom filter. It takes a fixed,
+// small amount of memory no matter how many ids are added, but
+// may tell that an id has been seen when it hasn't. Single ids
+// can't be forgotten and ids can't be listed.
+type bloom struct {
+	bits  []uint64
+	k     int
+	n     int
+	seeds [2]maphash.Seed
+}
+
+func newBloom(expectedIDs int, fpRate float64) *bloom {
+	if expectedIDs < 1 {
+		expectedIDs = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	// optimal number of bits and hash functions
+	m := math.Ceil(-float64(expectedIDs) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(expectedIDs) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloom{
+		bits:  make([]uint64, (int(m)+63)/64),
+		k:     k,
+		seeds: [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+	}
+}
+
+// locations calls fn with every bit index of the id.
+func (b *bloom) locations(id interface{}, fn func(i uint64) bool) bool {
+	h1 := maphash.Comparable(b.seeds[0], id)
+	h2 := maphash.Comparable(b.seeds[1], id) | 1
+	m := uint64(len(b.bits)) * 64
+	for i := 0; i < b.k; i += 1 {
+		if !fn((h1 + uint64(i)*h2) % m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloom) Add(id interface{}) {
+	b.locations(id, func(i uint64) bool {
+		b.bits[i/64] |= 1 << (i % 64)
+		return true
+	})
+	b.n += 1
+}
+
+func (b *bloom) Seen(id interface{}) bool {
+	return b.locations(id, func(i uint64) bool {
+		return b.bits[i/64]&(1<<(i%64)) != 0
+	})
+}
+
+func (b *bloom) Touch(id interface{}) {}
+
+// Remove does nothing, ids can't be removed from bloom filter.
+func (b *bloom) Remove(id interface{}) {}
+
+func (b *bloom) Clear() {
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+	b.n = 0
+}
+
+// Len returns number of ids added, duplicates included.
+func (b *bloom) Len() int {
+	return b.n
+}
+
+func (b *bloom) Reserve(n int) {}
+
+// Each does nothing, ids can't be listed from bloom filter.
+func (b *bloom) Each(fn func(id interface{})) {}
+
+// WithBloomHistory keeps the history in a bloom filter sized for
+// expectedIDs ids with given false positive rate, instead of a map.
+// It takes far less memory for huge numbers of ids, but now and then
+// EnqueueUnique refuses an item that hasn't been enqueued before.
+// Such history can't forget single ids, so RemoveFromHistory does
+// nothing, and ids are not exported with the queue state.
+func WithBloomHistory(expectedIDs int, fpRate float64) Option {
+	return func(q *Queue) {
+		q.history = newBloom(expectedIDs, fpRate)
+	}
+}
@@ -0,0 +1,28 @@
+package pqueue
+
+import "testing"
+
+func TestBloomHistory(t *testing.T) {
+	q := NewWithOptions(WithBloomHistory(1000, 0.01))
+	for i := 0; i < 1000; i += 1 {
+		q.EnqueueUnique(&stateTask{Name: string(rune(i)), Priority: i})
+	}
+	for i := 0; i < 1000; i += 1 {
+		if err := q.EnqueueUnique(&stateTask{Name: string(rune(i)), Priority: i}); err == nil {
+			t.Fatalf("Expected bloom history never to miss a seen id")
+		}
+	}
+	falsePositives := 0
+	for i := 1000; i < 11000; i += 1 {
+		if q.IdExists(i) {
+			falsePositives += 1
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("Expected about 1%% false positives, %d of 10000 given", falsePositives)
+	}
+	q.ClearHistory()
+	if q.IdExists(string(rune(0))) {
+		t.Errorf("Expected history to be cleared")
+	}
+}
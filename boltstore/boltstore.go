@@ -0,0 +1,228 @@
+// Package boltstore provides a priority queue keeping item bodies
+// on disk, in a bbolt database. Only a small index of priorities
+// and ids stays in memory, so the queue can grow far beyond RAM
+// and survives process restarts.
+package boltstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+
+	pqueue "github.com/mileusna/gopqueue"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	itemsBucket   = []byte("items")
+	historyBucket = []byte("history")
+)
+
+// ErrCorrupt is returned when stored item can't be read back.
+var ErrCorrupt = errors.New("Corrupt stored item")
+
+// Options configure how items are stored and ordered.
+type Options struct {
+	// Encode and Decode turn items to bytes and back.
+	Encode func(pqueue.QueueItem) ([]byte, error)
+	Decode func([]byte) (pqueue.QueueItem, error)
+	// Priority of the item, items with lower priority are
+	// dequeued first. Items of equal priority are dequeued in
+	// the order they have been enqueued.
+	Priority func(pqueue.QueueItem) int64
+}
+
+// Queue is a priority queue keeping items in a bbolt database.
+type Queue struct {
+	db    *bolt.DB
+	opts  Options
+	index *pqueue.Queue
+}
+
+// ref is what the queue keeps in memory for every stored item.
+type ref struct {
+	key      uint64
+	priority int64
+	id       interface{}
+}
+
+func (r *ref) Less(other interface{}) bool {
+	o := other.(*ref)
+	if r.priority != o.priority {
+		return r.priority < o.priority
+	}
+	return r.key < o.key
+}
+
+func (r *ref) Id() interface{} {
+	return r.id
+}
+
+// Open opens the database at given path, creating it if needed,
+// and loads the index of the items stored in it. History ids are
+// stored encoded with encoding/gob, so ids of other than basic
+// types have to be registered with gob.Register.
+func Open(path string, opts Options) (q *Queue, err error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return
+	}
+	q = &Queue{db: db, opts: opts, index: pqueue.New(0)}
+	err = db.Update(func(tx *bolt.Tx) error {
+		items, err := tx.CreateBucketIfNotExists(itemsBucket)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.CreateBucketIfNotExists(historyBucket); err != nil {
+			return err
+		}
+		return items.ForEach(func(k, v []byte) error {
+			if len(k) != 8 || len(v) < 8 {
+				return ErrCorrupt
+			}
+			item, err := opts.Decode(v[8:])
+			if err != nil {
+				return err
+			}
+			return q.index.Enqueue(&ref{
+				key:      binary.BigEndian.Uint64(k),
+				priority: int64(binary.BigEndian.Uint64(v)),
+				id:       item.Id(),
+			})
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return
+}
+
+// Enqueue stores given item and puts it to the queue.
+func (q *Queue) Enqueue(item pqueue.QueueItem) error {
+	return q.enqueue(item, false)
+}
+
+// EnqueueUnique stores and enqueues the item only if it hasn't
+// already been in queue, otherwise it returns pqueue.ErrDuplicate.
+func (q *Queue) EnqueueUnique(item pqueue.QueueItem) error {
+	return q.enqueue(item, true)
+}
+
+func (q *Queue) enqueue(item pqueue.QueueItem, unique bool) error {
+	data, err := q.opts.Encode(item)
+	if err != nil {
+		return err
+	}
+	id, err := encodeID(item.Id())
+	if err != nil {
+		return err
+	}
+	r := &ref{priority: q.opts.Priority(item), id: item.Id()}
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		history := tx.Bucket(historyBucket)
+		if unique && history.Get(id) != nil {
+			return pqueue.ErrDuplicate
+		}
+		items := tx.Bucket(itemsBucket)
+		seq, err := items.NextSequence()
+		if err != nil {
+			return err
+		}
+		r.key = seq
+		key := binary.BigEndian.AppendUint64(nil, seq)
+		value := binary.BigEndian.AppendUint64(nil, uint64(r.priority))
+		if err = items.Put(key, append(value, data...)); err != nil {
+			return err
+		}
+		return history.Put(id, []byte{})
+	})
+	if err != nil {
+		return err
+	}
+	return q.index.Enqueue(r)
+}
+
+// Dequeue takes an item from the queue and deletes it from the
+// database. If queue is empty then it blocks waiting for at least
+// one item. Once the queue is closed and drained it returns nil
+// and pqueue.ErrClosed.
+func (q *Queue) Dequeue() (pqueue.QueueItem, error) {
+	x := q.index.Dequeue()
+	if x == nil {
+		return nil, pqueue.ErrClosed
+	}
+	return q.take(x.(*ref))
+}
+
+// TryDequeue takes an item from the queue without blocking. It
+// returns nil item when the queue is empty.
+func (q *Queue) TryDequeue() (pqueue.QueueItem, error) {
+	x, ok := q.index.TryDequeue()
+	if !ok {
+		return nil, nil
+	}
+	return q.take(x.(*ref))
+}
+
+// take reads the stored item and deletes it.
+func (q *Queue) take(r *ref) (item pqueue.QueueItem, err error) {
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		items := tx.Bucket(itemsBucket)
+		key := binary.BigEndian.AppendUint64(nil, r.key)
+		v := items.Get(key)
+		if len(v) < 8 {
+			return ErrCorrupt
+		}
+		// v is valid only within the transaction
+		if item, err = q.opts.Decode(bytes.Clone(v[8:])); err != nil {
+			return err
+		}
+		return items.Delete(key)
+	})
+	return
+}
+
+// IdExists tells if the item with given id has ever been stored.
+func (q *Queue) IdExists(id interface{}) (ok bool, err error) {
+	key, err := encodeID(id)
+	if err != nil {
+		return
+	}
+	err = q.db.View(func(tx *bolt.Tx) error {
+		ok = tx.Bucket(historyBucket).Get(key) != nil
+		return nil
+	})
+	return
+}
+
+// ClearHistory clears queue history so the items can be enqueued
+// with EnqueueUnique again.
+func (q *Queue) ClearHistory() error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(historyBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(historyBucket)
+		return err
+	})
+}
+
+// Len returns number of enqueued items.
+func (q *Queue) Len() int {
+	return q.index.Len()
+}
+
+// Close wakes up goroutines blocked in Dequeue and closes the
+// database.
+func (q *Queue) Close() error {
+	q.index.Close()
+	return q.db.Close()
+}
+
+func encodeID(id interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&id)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,77 @@
+package boltstore
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+var options = Options{
+	Encode: func(item pqueue.QueueItem) ([]byte, error) {
+		return json.Marshal(item)
+	},
+	Decode: func(data []byte) (pqueue.QueueItem, error) {
+		t := &task{}
+		return t, json.Unmarshal(data, t)
+	},
+	Priority: func(item pqueue.QueueItem) int64 {
+		return int64(item.(*task).Priority)
+	},
+}
+
+func TestQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	q, err := Open(path, options)
+	if err != nil {
+		t.Fatalf("Expected database to be opened, given %v", err)
+	}
+	for i, x := range []int{3, 1, 2, 1} {
+		q.Enqueue(&task{Name: string(rune('a' + i)), Priority: x})
+	}
+	if err := q.EnqueueUnique(&task{Name: "a", Priority: 0}); err != pqueue.ErrDuplicate {
+		t.Errorf("Expected stored history to deduplicate")
+	}
+	if item, err := q.Dequeue(); err != nil || item.(*task).Name != "b" {
+		t.Errorf("Expected to dequeue the top item, given %v", err)
+	}
+	q.Close()
+
+	q, err = Open(path, options)
+	if err != nil {
+		t.Fatalf("Expected database to be reopened, given %v", err)
+	}
+	defer q.Close()
+	if q.Len() != 3 {
+		t.Errorf("Expected 3 items to be stored, %d stored", q.Len())
+	}
+	for _, name := range []string{"d", "c", "a"} {
+		if item, err := q.TryDequeue(); err != nil || item.(*task).Name != name {
+			t.Errorf("Expected to dequeue %s, given %v", name, item)
+		}
+	}
+	if item, _ := q.TryDequeue(); item != nil {
+		t.Errorf("Expected queue to be empty")
+	}
+	if ok, _ := q.IdExists("b"); !ok {
+		t.Errorf("Expected history to survive restart")
+	}
+	q.ClearHistory()
+	if ok, _ := q.IdExists("b"); ok {
+		t.Errorf("Expected history to be cleared")
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/mileusna/gopqueue/boltstore
+
+go 1.24
+
+require (
+	github.com/mileusna/gopqueue v0.0.0-20261014081040-eb84ca7ebd43
+	go.etcd.io/bbolt v1.4.0
+)
+
+require golang.org/x/sys v0.29.0 // indirect
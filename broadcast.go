@@ -0,0 +1,86 @@
+package pqueue
+
+import (
+	"slices"
+	"sync"
+)
+
+// Broadcast delivers every published item to every subscriber,
+// rather than to just one consumer. Each subscriber has a queue of
+// its own, so it takes items in priority order at its own pace,
+// without waiting for the others.
+type Broadcast struct {
+	mu     sync.Mutex
+	subs   []*Queue
+	opts   []Option
+	closed bool
+}
+
+// NewBroadcast creates a broadcast whose subscriber queues are
+// created by NewWithOptions with given options. With a limit set,
+// publishing fails with ErrQueueFull for the subscribers whose
+// queues are full, the others still get the item.
+func NewBroadcast(opts ...Option) *Broadcast {
+	return &Broadcast{opts: opts}
+}
+
+// Subscribe returns a new subscriber queue, which gets every item
+// published from now on. Subscribers dequeue from it as from any
+// queue, and Unsubscribe once they are done.
+func (b *Broadcast) Subscribe() *Queue {
+	q := NewWithOptions(b.opts...)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		q.Close()
+	} else {
+		b.subs = append(b.subs, q)
+	}
+	return q
+}
+
+// Unsubscribe stops publishing to the subscriber queue and closes it.
+func (b *Broadcast) Unsubscribe(q *Queue) {
+	b.mu.Lock()
+	b.subs = slices.DeleteFunc(b.subs, func(s *Queue) bool {
+		return s == q
+	})
+	b.mu.Unlock()
+	q.Close()
+}
+
+// Publish puts the item to every subscriber queue. It returns the
+// first error of enqueueing, eg. ErrQueueFull, or ErrClosed once the
+// broadcast is closed.
+func (b *Broadcast) Publish(item QueueItem) (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	for _, q := range b.subs {
+		if e := q.Enqueue(item); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Subscribers returns number of the subscribers.
+func (b *Broadcast) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close closes all the subscriber queues, which can still be
+// drained, and stops publishing.
+func (b *Broadcast) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, q := range b.subs {
+		q.Close()
+	}
+	b.subs = nil
+}
@@ -0,0 +1,42 @@
+package pqueue
+
+import "testing"
+
+func TestBroadcast(t *testing.T) {
+	b := NewBroadcast()
+	early := b.Subscribe()
+	b.Publish(&stateTask{"b", 2})
+	late := b.Subscribe()
+	b.Publish(&stateTask{"c", 3})
+	b.Publish(&stateTask{"a", 1})
+
+	for _, name := range []string{"a", "b", "c"} {
+		if task := early.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected %s, given %s", name, task.Name)
+		}
+	}
+	for _, name := range []string{"a", "c"} {
+		if task := late.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected %s for late subscriber, given %s", name, task.Name)
+		}
+	}
+
+	b.Unsubscribe(early)
+	if b.Subscribers() != 1 {
+		t.Errorf("Expected 1 subscriber, given %d", b.Subscribers())
+	}
+	b.Publish(&stateTask{"d", 4})
+	if early.Len() != 0 || late.Len() != 1 {
+		t.Errorf("Expected item only to remaining subscriber")
+	}
+	b.Close()
+	if err := b.Publish(&stateTask{"e", 5}); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, given %v", err)
+	}
+	if task := late.Dequeue().(*stateTask); task.Name != "d" {
+		t.Errorf("Expected closed subscriber to drain, given %s", task.Name)
+	}
+	if late.Dequeue() != nil {
+		t.Errorf("Expected nil from drained subscriber")
+	}
+}
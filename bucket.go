@@ -0,0 +1,199 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+	"sync"
+)
+
+// BucketQueue is a priority queue for items of few integer priority
+// levels, like packet schedulers use. Every level is a FIFO ring,
+// so both Enqueue and Dequeue take constant time, not depending on
+// the number of items. Items of lower level are dequeued first, and
+// items of the same level in the order they have been enqueued.
+// Item's Less is not used at all.
+type BucketQueue struct {
+	Limit int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	level   func(QueueItem) int
+	rings   []ring
+	used    []uint64 // bitmap of non-empty rings
+	n       int
+	history DedupStore
+	closed  bool
+}
+
+// ring is a growable FIFO of items.
+type ring struct {
+	buf  []QueueItem
+	head int
+	n    int
+}
+
+func (r *ring) push(item QueueItem) {
+	if r.n == len(r.buf) {
+		buf := make([]QueueItem, max(8, 2*len(r.buf)))
+		for i := 0; i < r.n; i++ {
+			buf[i] = r.buf[(r.head+i)%len(r.buf)]
+		}
+		r.buf, r.head = buf, 0
+	}
+	r.buf[(r.head+r.n)%len(r.buf)] = item
+	r.n++
+}
+
+func (r *ring) pop() QueueItem {
+	item := r.buf[r.head]
+	r.buf[r.head] = nil
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+	return item
+}
+
+// errLevel is returned for items of level out of range.
+var errLevel = errors.New("Priority level out of range")
+
+// NewBucket creates a bucket queue of given number of levels, from
+// 0 to levels-1, taking a limit as a parameter. If 0 given, then
+// queue will be unlimited. Level of every item is given by level.
+func NewBucket(levels, max int, level func(QueueItem) int) *BucketQueue {
+	b := &BucketQueue{
+		Limit:   max,
+		level:   level,
+		rings:   make([]ring, levels),
+		used:    make([]uint64, (levels+63)/64),
+		history: newHistory(),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Enqueue puts given item to the queue.
+func (b *BucketQueue) Enqueue(item QueueItem) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.enqueue(item)
+}
+
+// EnqueueUnique puts item in queue only if it hasn't already been
+// in queue, see Queue.EnqueueUnique.
+func (b *BucketQueue) EnqueueUnique(item QueueItem) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.history.Seen(item.Id()) {
+		return ErrDuplicate
+	}
+	return b.enqueue(item)
+}
+
+func (b *BucketQueue) enqueue(item QueueItem) error {
+	if b.closed {
+		return ErrClosed
+	}
+	if b.Limit > 0 && b.n >= b.Limit {
+		return ErrQueueFull
+	}
+	l := b.level(item)
+	if l < 0 || l >= len(b.rings) {
+		return errLevel
+	}
+	b.history.Add(item.Id())
+	b.rings[l].push(item)
+	b.used[l/64] |= 1 << (l % 64)
+	b.n++
+	b.cond.Signal()
+	return nil
+}
+
+// Dequeue takes an item from the queue, blocking while it's empty.
+// Once the queue is closed and drained it returns nil.
+func (b *BucketQueue) Dequeue() QueueItem {
+	item, _ := b.DequeueContext(context.Background())
+	return item
+}
+
+// DequeueContext takes an item from the queue, blocking while the
+// queue is empty until the context is done. Once the queue is
+// closed and drained it returns ErrClosed.
+func (b *BucketQueue) DequeueContext(ctx context.Context) (QueueItem, error) {
+	stop := context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.cond.Broadcast()
+	})
+	defer stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.n == 0 {
+		if b.closed {
+			return nil, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b.cond.Wait()
+	}
+	return b.pop(), nil
+}
+
+// TryDequeue takes an item from the queue without blocking. It
+// returns false when the queue is empty.
+func (b *BucketQueue) TryDequeue() (QueueItem, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.n == 0 {
+		return nil, false
+	}
+	return b.pop(), true
+}
+
+// Peek returns the item that would be dequeued next, without
+// taking it from the queue.
+func (b *BucketQueue) Peek() (QueueItem, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.n == 0 {
+		return nil, false
+	}
+	r := &b.rings[b.first()]
+	return r.buf[r.head], true
+}
+
+func (b *BucketQueue) pop() QueueItem {
+	l := b.first()
+	r := &b.rings[l]
+	item := r.pop()
+	if r.n == 0 {
+		b.used[l/64] &^= 1 << (l % 64)
+	}
+	b.n--
+	return item
+}
+
+// first returns the lowest non-empty level.
+func (b *BucketQueue) first() int {
+	for i, w := range b.used {
+		if w != 0 {
+			return i*64 + bits.TrailingZeros64(w)
+		}
+	}
+	return -1
+}
+
+// Len returns number of enqueued items.
+func (b *BucketQueue) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.n
+}
+
+// Close closes the queue, see Queue.Close.
+func (b *BucketQueue) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
@@ -0,0 +1,80 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+)
+
+func taskLevel(item QueueItem) int {
+	return item.(*stateTask).Priority
+}
+
+func TestBucketQueue(t *testing.T) {
+	b := NewBucket(100, 0, taskLevel)
+	for i, x := range []int{70, 3, 70, 0, 99, 3} {
+		b.Enqueue(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	if err := b.Enqueue(&stateTask{Name: "x", Priority: 100}); err == nil {
+		t.Errorf("Expected level out of range to be refused")
+	}
+	if err := b.EnqueueUnique(&stateTask{Name: "a", Priority: 1}); err == nil {
+		t.Errorf("Expected duplicate not to be added")
+	}
+	if item, _ := b.Peek(); item.(*stateTask).Name != "d" {
+		t.Errorf("Expected to peek the lowest level")
+	}
+	for _, name := range []string{"d", "b", "f", "a", "c", "e"} {
+		if task := b.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+	if _, ok := b.TryDequeue(); ok || b.Len() != 0 {
+		t.Errorf("Expected queue to be empty")
+	}
+}
+
+func TestBucketRingGrowth(t *testing.T) {
+	b := NewBucket(2, 0, taskLevel)
+	for i := 0; i < 100; i++ {
+		b.Enqueue(&stateTask{Name: string(rune(i)), Priority: 1})
+		if i%3 == 0 {
+			b.Dequeue()
+		}
+	}
+	for b.Len() > 1 {
+		first, second := b.Dequeue().(*stateTask), b.Dequeue().(*stateTask)
+		if first.Name >= second.Name {
+			t.Fatalf("Expected FIFO order within a level")
+		}
+	}
+}
+
+func TestBucketLimitClose(t *testing.T) {
+	b := NewBucket(4, 1, taskLevel)
+	b.Enqueue(&stateTask{Name: "a", Priority: 1})
+	if err := b.Enqueue(&stateTask{Name: "b", Priority: 1}); err == nil {
+		t.Errorf("Expected limit to be respected")
+	}
+	b.Close()
+	if b.Dequeue() == nil {
+		t.Errorf("Expected pending item from closed queue")
+	}
+	if _, err := b.DequeueContext(context.Background()); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, given %v", err)
+	}
+}
+
+func BenchmarkBucketQueue(b *testing.B) {
+	q := NewBucket(256, 0, func(item QueueItem) int { return item.(*DummyTask).priority })
+	tasks := make([]*DummyTask, 256)
+	for i := range tasks {
+		tasks[i] = NewDummyTask(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Enqueue(tasks[i%256])
+		if i%2 == 1 {
+			q.TryDequeue()
+		}
+	}
+}
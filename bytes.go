@@ -0,0 +1,43 @@
+package pqueue
+
+// Sizer is implemented by items which tell their size in bytes,
+// counted against the byte budget set with WithMaxBytes.
+type Sizer interface {
+	Size() int
+}
+
+// WithMaxBytes limits the total size of pending items, delayed ones
+// included, to n bytes, whether there is an item count limit too or
+// not. Items which don't implement Sizer count as 0 bytes. A full
+// queue treats new items like it does when the count limit is
+// reached, according to the overflow policy. An item bigger than
+// the whole budget is only taken by empty queue.
+func WithMaxBytes(n int) Option {
+	return func(q *Queue) {
+		q.maxBytes = n
+	}
+}
+
+// Bytes returns the total size of pending items, see Sizer.
+func (q *Queue) Bytes() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.bytes
+}
+
+// sizeOf returns the size of the item, 0 when it's not a Sizer.
+func sizeOf(item QueueItem) int {
+	if s, ok := item.(Sizer); ok {
+		return s.Size()
+	}
+	return 0
+}
+
+// fits tells if there is room for an item of given size, both by
+// the count limit and the byte budget.
+func (q *Queue) fits(size int) bool {
+	if q.full() {
+		return false
+	}
+	return q.maxBytes <= 0 || q.bytes == 0 || q.bytes+size <= q.maxBytes
+}
@@ -0,0 +1,59 @@
+package pqueue
+
+import "testing"
+
+type sizedTask struct {
+	DummyTask
+	size int
+}
+
+func (t *sizedTask) Less(other interface{}) bool {
+	return t.priority < other.(*sizedTask).priority
+}
+
+func (t *sizedTask) Size() int {
+	return t.size
+}
+
+func newSizedTask(priority, size int) *sizedTask {
+	return &sizedTask{DummyTask: *NewDummyTask(priority), size: size}
+}
+
+func TestMaxBytes(t *testing.T) {
+	q := NewWithOptions(WithMaxBytes(100))
+	for _, x := range []int{40, 50} {
+		if err := q.Enqueue(newSizedTask(x, x)); err != nil {
+			t.Errorf("Expected item to fit, given %v", err)
+		}
+	}
+	if err := q.Enqueue(newSizedTask(20, 20)); err != ErrQueueFull {
+		t.Errorf("Expected item over the budget refused, given %v", err)
+	}
+	if q.Bytes() != 90 {
+		t.Errorf("Expected 90 bytes, given %d", q.Bytes())
+	}
+	q.Dequeue()
+	if q.Bytes() != 50 {
+		t.Errorf("Expected 50 bytes, given %d", q.Bytes())
+	}
+	q.Clear(false)
+	if err := q.Enqueue(newSizedTask(1, 500)); err != nil || q.Bytes() != 500 {
+		t.Errorf("Expected big item taken by empty queue, given %v", err)
+	}
+}
+
+func TestMaxBytesDropLowest(t *testing.T) {
+	q := NewWithOptions(WithMaxBytes(100))
+	q.SetOverflowPolicy(DropLowest)
+	for _, x := range []int{3, 2, 1} {
+		q.Enqueue(newSizedTask(x, 40))
+	}
+	if q.Len() != 2 || q.Bytes() != 80 {
+		t.Fatalf("Expected lowest priority item dropped, given %d items", q.Len())
+	}
+	for _, x := range []int{1, 2} {
+		if p := q.Dequeue().(*sizedTask).priority; p != x {
+			t.Errorf("Expected priority %d, given %d", x, p)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package pqueue
+
+// Cancel takes all the pending items with given id out of the queue,
+// delayed ones included, eg. when the user aborts the operation they
+// are for, and reports them by Removed events and OnCancel callbacks.
+// Items already dequeued or leased aren't affected. It returns false
+// when no such item is waiting in the queue.
+func (q *Queue) Cancel(id interface{}) bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	entries := append([]*entry(nil), q.pending(id)...)
+	for _, e := range entries {
+		q.remove(e)
+		q.hooks.fire(q.hooks.cancel, e.item)
+		q.emit(Removed, e.item)
+	}
+	return len(entries) > 0
+}
+
+// OnCancel registers fn to be called for every item taken out of the
+// queue by Cancel, see OnEnqueue.
+func (q *Queue) OnCancel(fn func(QueueItem)) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.hooks.cancel = append(q.hooks.cancel, fn)
+}
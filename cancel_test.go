@@ -0,0 +1,38 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCancel(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock))
+	var cancelled []QueueItem
+	q.OnCancel(func(item QueueItem) {
+		cancelled = append(cancelled, item)
+	})
+	q.Enqueue(&stateTask{"a", 1})
+	q.EnqueueAfter(&stateTask{"a", 2}, time.Minute)
+	q.Enqueue(&stateTask{"b", 3})
+
+	if !q.Cancel("a") {
+		t.Errorf("Expected a cancelled")
+	}
+	if q.Cancel("a") || q.Cancel("x") {
+		t.Errorf("Expected nothing to cancel")
+	}
+	q.WaitHooks()
+	if len(cancelled) != 2 {
+		t.Errorf("Expected OnCancel called for both a items, given %d", len(cancelled))
+	}
+	if q.Len() != 1 {
+		t.Errorf("Expected b left, given %d items", q.Len())
+	}
+	if item, _ := q.TryDequeue(); item.(*stateTask).Name != "b" {
+		t.Errorf("Expected b, given %v", item)
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+}
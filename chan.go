@@ -0,0 +1,42 @@
+package pqueue
+
+import "context"
+
+// Chan returns a channel, with buffer of given size, fed with items
+// dequeued in a background goroutine, so consumers can select on it
+// together with other channels. The channel is closed when the
+// context is done or the queue is closed and drained. An item which
+// has been dequeued, but not sent when the context is done, is put
+// back to the queue in its old place. Buffered items are not.
+func (q *Queue) Chan(ctx context.Context, buf int) <-chan QueueItem {
+	ch := make(chan QueueItem, buf)
+	go func() {
+		defer close(ch)
+		for {
+			e, err := q.dequeueContext(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- e.item:
+			case <-ctx.Done():
+				q.cond.L.Lock()
+				q.push(e)
+				q.logInflight(e)
+				q.signal()
+				q.cond.L.Unlock()
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// dequeueContext is DequeueContext returning the whole entry.
+func (q *Queue) dequeueContext(ctx context.Context) (*entry, error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	stop := context.AfterFunc(ctx, q.broadcast)
+	defer stop()
+	return q.dequeueEntry(ctx.Err)
+}
@@ -0,0 +1,77 @@
+package pqueue
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChan(t *testing.T) {
+	q := New(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := q.Chan(ctx, 0)
+	for _, x := range []int{2, 1} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	for _, x := range []int{1, 2} {
+		select {
+		case item := <-ch:
+			if task := item.(*DummyTask); task.priority != x {
+				t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected item on the channel")
+		}
+	}
+	task := NewDummyTask(3)
+	q.Enqueue(task)
+	time.Sleep(5e7)
+	cancel()
+	time.Sleep(5e7)
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected channel to be closed")
+	}
+	if item, ok := q.TryDequeue(); !ok || item != task {
+		t.Errorf("Expected unsent item to be put back")
+	}
+}
+
+func TestChanClosed(t *testing.T) {
+	q := New(0)
+	q.Enqueue(NewDummyTask(1))
+	q.Close()
+	ch := q.Chan(context.Background(), 1)
+	if _, ok := <-ch; !ok {
+		t.Errorf("Expected pending item before the channel is closed")
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected channel to be closed")
+	}
+}
+
+func TestChanWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := New(0)
+	w, err := q.OpenWAL(path, decodeStateTask, 0)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := q.Chan(ctx, 0)
+	q.Enqueue(&stateTask{Name: "a", Priority: 1})
+	time.Sleep(5e7)
+	cancel()
+	time.Sleep(5e7)
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected channel to be closed")
+	}
+	w.Close()
+	r := New(0)
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	if r.Len() != 1 {
+		t.Errorf("Expected unsent item put back in the log, given %d items", r.Len())
+	}
+}
@@ -0,0 +1,88 @@
+package pqueue
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithAutoCheckpoint writes a snapshot of the queue, see Snapshot, to
+// the file at given path every given interval, and once more when the
+// queue is closed, so the queue can be brought back with Restore
+// after the process restarts. The snapshot is written to a temporary
+// file first and renamed to the path, so the path always holds a
+// whole snapshot. With keep above 1 up to keep-1 older checkpoints
+// are kept too, as path.1, path.2 and so on, the newest first.
+// Failed checkpoints are reported by LogPersistence log events.
+func WithAutoCheckpoint(interval time.Duration, path string, keep int) Option {
+	return func(q *Queue) {
+		c := &checkpointer{q: q, path: path, keep: keep}
+		q.closers = append(q.closers, c.checkpoint)
+		q.starts = append(q.starts, func() {
+			timer := q.clock.NewTimer(interval)
+			go func() {
+				defer timer.Stop()
+				for range timer.C() {
+					q.mu.RLock()
+					closed := q.closed
+					q.mu.RUnlock()
+					if closed {
+						return
+					}
+					c.checkpoint()
+					timer.Reset(interval)
+				}
+			}()
+		})
+	}
+}
+
+type checkpointer struct {
+	mu   sync.Mutex
+	q    *Queue
+	path string
+	keep int
+}
+
+// checkpoint writes the snapshot, reporting the failure.
+func (c *checkpointer) checkpoint() {
+	if err := c.write(); err != nil {
+		c.q.cond.L.Lock()
+		c.q.logEvent(LogPersistence, nil, err)
+		c.q.cond.L.Unlock()
+	}
+}
+
+func (c *checkpointer) write() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tmp := c.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	if err = c.q.Snapshot(f); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return
+	}
+	for i := c.keep - 1; i > 0; i -= 1 {
+		// missing older checkpoints are fine
+		os.Rename(c.older(i-1), c.older(i))
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// older returns path of the i-th older checkpoint.
+func (c *checkpointer) older(i int) string {
+	if i == 0 {
+		return c.path
+	}
+	return fmt.Sprintf("%s.%d", c.path, i)
+}
@@ -0,0 +1,51 @@
+package pqueue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// restored returns number of items restored from the checkpoint.
+func restored(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Expected checkpoint at %s, given %v", path, err)
+	}
+	defer f.Close()
+	q := New(0)
+	if err := q.Restore(f, decodeStateTask); err != nil {
+		t.Fatalf("Expected checkpoint to be restored, given %v", err)
+	}
+	return q.Len()
+}
+
+func TestAutoCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.snap")
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock), WithAutoCheckpoint(time.Minute, path, 2))
+	q.Enqueue(&stateTask{"a", 1})
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Expected checkpoint written on time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	q.Enqueue(&stateTask{"b", 2})
+	q.Close()
+	if n := restored(t, path); n != 2 {
+		t.Errorf("Expected 2 items checkpointed on close, given %d", n)
+	}
+	if n := restored(t, path+".1"); n != 1 {
+		t.Errorf("Expected 1 item in older checkpoint, given %d", n)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary file left, given %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package pqueue
+
+// WithClassLimits limits how many items of every priority class,
+// given by class, eg. "high" and "low", may be pending in the queue,
+// delayed ones included, besides the queue limit, so bulk background
+// work can't crowd out room kept for interactive work. Items of a
+// full class are rejected like items of a full queue are, and
+// EnqueueContext waits for room in the class. Classes without limit
+// aren't limited. The class of an item is taken when it's enqueued.
+func WithClassLimits(class func(QueueItem) string, limits map[string]int) Option {
+	return func(q *Queue) {
+		q.classOf = class
+		q.classLimits = limits
+		q.classes = make(map[string]int)
+	}
+}
+
+// ClassLen returns number of pending items of given class, see
+// WithClassLimits.
+func (q *Queue) ClassLen(class string) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.classes[class]
+}
+
+// classFull tells if the item's class has reached its limit.
+func (q *Queue) classFull(item QueueItem) bool {
+	if q.classOf == nil {
+		return false
+	}
+	class := q.classOf(item)
+	limit, ok := q.classLimits[class]
+	return ok && q.classes[class] >= limit
+}
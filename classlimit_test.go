@@ -0,0 +1,53 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClassLimits(t *testing.T) {
+	class := func(item QueueItem) string {
+		if item.(*DummyTask).priority >= 10 {
+			return "low"
+		}
+		return "high"
+	}
+	q := NewWithOptions(WithLimit(5), WithClassLimits(class, map[string]int{"low": 2}))
+	for _, p := range []int{10, 11, 12} {
+		q.Enqueue(NewDummyTask(p))
+	}
+	if q.Len() != 2 || q.ClassLen("low") != 2 {
+		t.Errorf("Expected 2 low items, given %d", q.ClassLen("low"))
+	}
+	if s := q.Stats(); s.Rejected != 1 {
+		t.Errorf("Expected 1 rejected item, given %d", s.Rejected)
+	}
+	for _, p := range []int{1, 2, 3} {
+		if err := q.Enqueue(NewDummyTask(p)); err != nil {
+			t.Errorf("Expected high item enqueued, given %v", err)
+		}
+	}
+	if err := q.Enqueue(NewDummyTask(4)); err != ErrQueueFull {
+		t.Errorf("Expected queue limit still applies, given %v", err)
+	}
+
+	q = NewWithOptions(WithClassLimits(class, map[string]int{"low": 1}))
+	q.Enqueue(NewDummyTask(10))
+	done := make(chan error)
+	go func() {
+		done <- q.EnqueueContext(context.Background(), NewDummyTask(11))
+	}()
+	select {
+	case err := <-done:
+		t.Errorf("Expected EnqueueContext waiting for room in class, given %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	q.Dequeue()
+	if err := <-done; err != nil {
+		t.Errorf("Expected item enqueued once class has room, given %v", err)
+	}
+	if q.ClassLen("low") != 1 {
+		t.Errorf("Expected 1 low item, given %d", q.ClassLen("low"))
+	}
+}
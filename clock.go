@@ -0,0 +1,70 @@
+package pqueue
+
+import "time"
+
+// Clock tells the time and makes the timers used by everything time
+// based in the queue: delayed items, retry backoff, leases, expiry,
+// deadlines, aging, dedup TTL, rate limiting and schedules, so all
+// of it can be tested with a fake clock, see WithClock.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a timer which sends the time on its
+	// channel once given duration passes, like time.NewTimer.
+	NewTimer(d time.Duration) Timer
+	// AfterFunc returns a timer which calls f in its own
+	// goroutine once given duration passes, like time.AfterFunc.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer made by Clock, like time.Timer. Timers made by
+// AfterFunc may have no channel.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// WithClock makes the queue tell the time with given clock instead
+// of the system one.
+func WithClock(c Clock) Option {
+	return func(q *Queue) {
+		q.clock = c
+		if h, ok := q.history.(*history); ok {
+			h.now = c.Now
+		}
+	}
+}
+
+// systemClock is the Clock of package time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// Now returns the current time of the queue clock, see WithClock,
+// eg. for middleware measuring how long items have waited.
+func (q *Queue) Now() time.Time {
+	return q.now()
+}
+
+// now returns the current time of the queue clock.
+func (q *Queue) now() time.Time {
+	return q.clock.Now()
+}
@@ -0,0 +1,116 @@
+package pqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock moves only when told to.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	c     *fakeClock
+	at    time.Time
+	ch    chan time.Time
+	f     func()
+	armed bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	return c.timer(d, nil)
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.timer(d, f)
+}
+
+func (c *fakeClock) timer(d time.Duration, f func()) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: c, at: c.now.Add(d), ch: make(chan time.Time, 1), f: f, armed: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock and fires the timers which are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	for _, t := range c.timers {
+		if t.armed && !t.at.After(c.now) {
+			t.armed = false
+			due = append(due, t)
+		}
+	}
+	now := c.now
+	c.mu.Unlock()
+	for _, t := range due {
+		if t.f != nil {
+			t.f()
+		} else {
+			t.ch <- now
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	armed := t.armed
+	t.armed = false
+	return armed
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	armed := t.armed
+	t.at, t.armed = t.c.now.Add(d), true
+	return armed
+}
+
+func TestClock(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock), WithVisibilityTimeout(time.Minute), WithDedupTTL(time.Hour))
+	q.EnqueueAfter(&stateTask{Name: "x", Priority: 1}, time.Hour)
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected delayed item not to be ready")
+	}
+	clock.Advance(time.Hour)
+	d, err := q.Lease(context.Background())
+	if err != nil {
+		t.Fatalf("Expected delayed item to be ready once the clock moves, given %v", err)
+	}
+	clock.Advance(time.Minute)
+	if d.Ack() != ErrLeaseExpired || q.Len() != 1 {
+		t.Errorf("Expected lease to expire once the clock moves")
+	}
+
+	task := &stateTask{Name: "a"}
+	q.EnqueueUnique(task)
+	clock.Advance(time.Hour)
+	if err := q.EnqueueUnique(task); err != nil {
+		t.Errorf("Expected dedup TTL to follow the clock")
+	}
+}
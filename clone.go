@@ -0,0 +1,107 @@
+package pqueue
+
+import "maps"
+
+// Clone returns a copy of the queue, made while the queue is locked,
+// with the same pending items, delayed ones included, in the same
+// order, the same history, retry attempts and settings, so it can be
+// used eg. to try out scheduling without touching the live queue.
+// Items themselves are shared, not copied. The copy has no
+// write-ahead log, event listeners, hooks, middleware, mirrors,
+// aging, leased items or processed ids. History kept in a DedupStore
+// other than the built-in ones is copied to the default history, if
+// it can be listed at all, and items kept in Storage are copied to
+// the built-in heap. Items spilled with WithSpill are decoded again
+// and the copy keeps them in memory.
+func (q *Queue) Clone() *Queue {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	c := New(q.Limit)
+	c.history = q.cloneHistory()
+	*c.items = sorter{ranked: q.items.ranked, stable: q.items.stable, descending: q.items.descending, itemLess: q.items.itemLess}
+	c.rank = q.rank
+	c.clock = q.clock
+	c.hasher = q.hasher
+	c.normalize = q.normalize
+	c.seq = q.seq
+	c.overflow = q.overflow
+	c.maxBytes = q.maxBytes
+	c.producerShare = q.producerShare
+	c.ackProcessed = q.ackProcessed
+	c.randomTop = q.randomTop
+	c.classOf = q.classOf
+	c.classLimits = q.classLimits
+	c.classes = make(map[string]int)
+	c.politeness = q.politeness
+	c.politeLast = maps.Clone(q.politeLast)
+	c.maxDuplicates = q.maxDuplicates
+	c.occurrences = maps.Clone(q.occurrences)
+	c.compressor = q.compressor
+	c.keys = q.keys
+	if q.retry != nil {
+		retry := *q.retry
+		c.retry = &retry
+	}
+	for id, n := range q.attempts {
+		c.attempts[id] = n
+	}
+	c.visibility = q.visibility
+	c.logger = q.logger
+	c.maxDeliveries = q.maxDeliveries
+	c.deadLetter = q.deadLetter
+	c.paused = q.paused
+	c.stats = q.stats
+	c.latency = q.latency.clone()
+	if q.rates != nil {
+		r := *q.rates
+		c.rates = &r
+	}
+
+	entries := q.items.list()
+	c.items.entries = make([]*entry, len(entries))
+	for i, e := range entries {
+		x := *e
+		x.elem = Element{}
+		c.items.entries[i] = &x
+		c.track(&x)
+	}
+	if q.items.ext != nil {
+		// the copy keeps its items in the built-in heap
+		c.items.init()
+	}
+	for _, e := range q.delayed {
+		x := *e
+		x.delayed = false
+		c.push(&x)
+	}
+	spilled, err := q.spilled()
+	if err != nil {
+		q.logEvent(LogPersistence, nil, err)
+	}
+	for _, e := range spilled {
+		c.push(e)
+	}
+	return c
+}
+
+// cloneHistory returns a copy of the history store.
+func (q *Queue) cloneHistory() DedupStore {
+	switch s := q.history.(type) {
+	case *history:
+		h := newHistory()
+		h.ttl, h.max, h.swept, h.now = s.ttl, s.max, s.swept, s.now
+		for el := s.order.Back(); el != nil; el = el.Prev() {
+			x := *el.Value.(*historyEntry)
+			x.pending = nil
+			h.ids[x.id] = h.order.PushFront(&x)
+		}
+		return h
+	case *bloom:
+		b := *s
+		b.bits = append([]uint64(nil), s.bits...)
+		return &b
+	}
+	h := newHistory()
+	q.historyEach(h.Add)
+	return h
+}
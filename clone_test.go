@@ -0,0 +1,35 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	q := NewWithOptions(WithStableOrder(), WithLimit(10))
+	for i, x := range []int{2, 1, 2, 1} {
+		q.EnqueueUnique(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	q.EnqueueAfter(&stateTask{Name: "e", Priority: 0}, time.Hour)
+	q.Retry(&stateTask{Name: "f", Priority: 3})
+	c := q.Clone()
+
+	if c.Len() != q.Len() || c.Delayed() != 1 || c.Limit != 10 || c.Attempts("f") != 1 {
+		t.Errorf("Expected clone to have the same items and settings")
+	}
+	c.RemoveFromHistory("a")
+	if !q.IdExists("a") || c.IdExists("a") || !c.IdExists("b") {
+		t.Errorf("Expected clone to have its own history")
+	}
+	for _, name := range []string{"b", "d", "a", "c", "f"} {
+		if task := c.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s from the clone, given %s", name, task.Name)
+		}
+	}
+	if q.Len() != 6 {
+		t.Errorf("Expected dequeueing the clone to leave the queue, %d items left", q.Len())
+	}
+	if task := q.Dequeue().(*stateTask); task.Name != "b" {
+		t.Errorf("Expected to dequeue b from the queue, given %s", task.Name)
+	}
+}
@@ -0,0 +1,236 @@
+// Command pqueuectl looks at queues from the terminal, either ones
+// persisted with Snapshot or a write-ahead log, or the queue of a
+// running service through its queueadmin handler.
+//
+// Usage:
+//
+//	pqueuectl [flags] snapshot FILE count|list
+//	pqueuectl [flags] wal FILE count|list
+//	pqueuectl [flags] admin URL status|count|list|pause|resume
+//	pqueuectl [flags] admin URL remove ID
+//	pqueuectl [flags] admin URL reprioritize ID PRIORITY
+//	pqueuectl [flags] admin URL clear [keep-history]
+//
+// Persisted items are opaque to pqueuectl, so they are listed as
+// they are stored, in order of their arrival, as text when they are
+// valid UTF-8, otherwise in hex. Files are never changed, the log is
+// replayed from a copy.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "pqueuectl:", err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage: pqueuectl [flags] snapshot|wal FILE count|list, or admin URL status|count|list|pause|resume|remove ID|reprioritize ID PRIORITY|clear")
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("pqueuectl", flag.ContinueOnError)
+	gzip := flags.Bool("gzip", false, "snapshot is compressed with gzip")
+	key := flags.String("key", "", "hex AES key the snapshot or log is encrypted with")
+	limit := flags.Int("limit", 0, "most items to list, all when 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) < 3 {
+		return errUsage
+	}
+	var opts []pqueue.Option
+	opts = append(opts, pqueue.WithStableOrder())
+	if *gzip {
+		opts = append(opts, pqueue.WithCompression(pqueue.Gzip))
+	}
+	if *key != "" {
+		k, err := hex.DecodeString(*key)
+		if err != nil {
+			return fmt.Errorf("bad key: %v", err)
+		}
+		opts = append(opts, pqueue.WithEncryption(pqueue.StaticKey(k)))
+	}
+	switch args[0] {
+	case "snapshot", "wal":
+		q, err := open(args[0], args[1], opts)
+		if err != nil {
+			return err
+		}
+		return local(q, args[2:], *limit, out)
+	case "admin":
+		return admin(args[1], args[2:], *limit, out)
+	}
+	return errUsage
+}
+
+// rawItem is a persisted item as it's stored.
+type rawItem struct {
+	data []byte
+	id   int
+}
+
+func (r *rawItem) Less(other interface{}) bool {
+	// arrival order, kept by stable order
+	return false
+}
+
+func (r *rawItem) Id() interface{} {
+	return r.id
+}
+
+func (r *rawItem) MarshalBinary() ([]byte, error) {
+	return r.data, nil
+}
+
+// open loads the snapshot or the log at path to a queue of raw items.
+func open(kind, path string, opts []pqueue.Option) (*pqueue.Queue, error) {
+	n := 0
+	decode := func(data []byte) (pqueue.QueueItem, error) {
+		n += 1
+		return &rawItem{data: data, id: n}, nil
+	}
+	q := pqueue.NewWithOptions(opts...)
+	if kind == "snapshot" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return q, q.Restore(f, decode)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(os.TempDir(), fmt.Sprintf("pqueuectl-%d.wal", os.Getpid()))
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	w, err := q.OpenWAL(tmp, decode, 0)
+	if err != nil {
+		return nil, err
+	}
+	return q, w.Close()
+}
+
+func local(q *pqueue.Queue, cmd []string, limit int, out io.Writer) error {
+	switch cmd[0] {
+	case "count":
+		fmt.Fprintln(out, q.Len())
+	case "list":
+		for i, item := range q.Items() {
+			if limit > 0 && i >= limit {
+				break
+			}
+			fmt.Fprintln(out, show(item.(*rawItem).data))
+		}
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+func show(data []byte) string {
+	if utf8.Valid(data) {
+		return string(bytes.ReplaceAll(data, []byte("\n"), []byte(`\n`)))
+	}
+	return hex.EncodeToString(data)
+}
+
+func admin(base string, cmd []string, limit int, out io.Writer) error {
+	base = strings.TrimSuffix(base, "/")
+	switch cmd[0] {
+	case "status":
+		return call(http.MethodGet, base+"/", out)
+	case "count":
+		var buf bytes.Buffer
+		if err := call(http.MethodGet, base+"/", &buf); err != nil {
+			return err
+		}
+		var status struct{ Len int }
+		if err := json.Unmarshal(buf.Bytes(), &status); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, status.Len)
+		return nil
+	case "list":
+		target := base + "/items"
+		if limit > 0 {
+			target += "?limit=" + strconv.Itoa(limit)
+		}
+		return call(http.MethodGet, target, out)
+	case "pause", "resume":
+		return call(http.MethodPost, base+"/"+cmd[0], out)
+	case "clear":
+		target := base + "/clear"
+		if len(cmd) > 1 && cmd[1] == "keep-history" {
+			target += "?keepHistory=true"
+		}
+		return call(http.MethodPost, target, out)
+	case "remove":
+		if len(cmd) < 2 {
+			return errUsage
+		}
+		return call(http.MethodDelete, base+"/items/"+url.PathEscape(cmd[1]), out)
+	case "reprioritize":
+		if len(cmd) < 3 {
+			return errUsage
+		}
+		if _, err := strconv.Atoi(cmd[2]); err != nil {
+			return fmt.Errorf("bad priority: %v", err)
+		}
+		return call(http.MethodPost, base+"/items/"+url.PathEscape(cmd[1])+"/priority?priority="+cmd[2], out)
+	}
+	return errUsage
+}
+
+// call makes the request and prints its JSON response indented.
+func call(method, target string, out io.Writer) error {
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if json.Indent(&buf, body, "", "  ") != nil {
+		_, err = out.Write(body)
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(out)
+	return err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/mileusna/gopqueue/queueadmin"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+func (t *task) MarshalBinary() ([]byte, error) {
+	return []byte(t.Name), nil
+}
+
+func decode(data []byte) (pqueue.QueueItem, error) {
+	return &task{Name: string(data)}, nil
+}
+
+func ctl(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, &out); err != nil {
+		t.Fatalf("Expected %v to succeed, given %v", args, err)
+	}
+	return out.String()
+}
+
+func TestSnapshot(t *testing.T) {
+	key := "000102030405060708090a0b0c0d0e0f"
+	path := filepath.Join(t.TempDir(), "queue.snap")
+	q := pqueue.NewWithOptions(pqueue.WithCompression(pqueue.Gzip), pqueue.WithEncryption(pqueue.StaticKey([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})))
+	q.Enqueue(&task{"b", 2})
+	q.Enqueue(&task{"a", 1})
+	f, _ := os.Create(path)
+	if err := q.Snapshot(f); err != nil {
+		t.Fatalf("Expected snapshot, given %v", err)
+	}
+	f.Close()
+	if out := ctl(t, "-gzip", "-key", key, "snapshot", path, "count"); out != "2\n" {
+		t.Errorf("Expected count of 2, given %q", out)
+	}
+	if out := ctl(t, "-gzip", "-key", key, "snapshot", path, "list"); out != "b\na\n" {
+		t.Errorf("Expected items in arrival order, given %q", out)
+	}
+	if err := run([]string{"snapshot", path, "list"}, &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected snapshot not to be read without the key")
+	}
+}
+
+func TestWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := pqueue.New(0)
+	w, _ := q.OpenWAL(path, decode, 0)
+	q.Enqueue(&task{"a", 1})
+	q.Enqueue(&task{"b", 2})
+	q.Dequeue()
+	w.Close()
+	before, _ := os.ReadFile(path)
+	if out := ctl(t, "wal", path, "list"); out != "b\n" {
+		t.Errorf("Expected pending b, given %q", out)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Errorf("Expected log untouched")
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	q := pqueue.New(0)
+	q.Enqueue(&task{"a", 1})
+	q.Enqueue(&task{"b", 2})
+	srv := httptest.NewServer(queueadmin.NewHandler(q, queueadmin.Options{
+		SetPriority: func(item pqueue.QueueItem, priority int) {
+			item.(*task).Priority = priority
+		},
+	}))
+	defer srv.Close()
+	if out := ctl(t, "admin", srv.URL, "count"); out != "2\n" {
+		t.Errorf("Expected count of 2, given %q", out)
+	}
+	if out := ctl(t, "admin", srv.URL, "list"); !strings.Contains(out, `"Name": "a"`) {
+		t.Errorf("Expected listed items, given %q", out)
+	}
+	ctl(t, "admin", srv.URL, "remove", "a")
+	ctl(t, "admin", srv.URL, "pause")
+	if q.Len() != 1 || !q.Paused() {
+		t.Errorf("Expected a removed and queue paused")
+	}
+	if err := run([]string{"admin", srv.URL, "remove", "a"}, &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected error removing missing item")
+	}
+	q.Enqueue(&task{"c", 3})
+	ctl(t, "admin", srv.URL, "reprioritize", "c", "0")
+	if item, _ := q.Peek(); item.(*task).Name != "c" {
+		t.Errorf("Expected c reprioritized to the front, given %v", item)
+	}
+	if err := run([]string{"admin", srv.URL, "reprioritize", "c", "x"}, &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected error for bad priority")
+	}
+}
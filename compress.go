@@ -0,0 +1,41 @@
+package pqueue
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// Compressor compresses snapshots, see WithCompression. Compressors
+// of other formats, eg. zstd, can be plugged in by implementing it.
+type Compressor interface {
+	// NewWriter returns a writer compressing to w, which is
+	// flushed by closing it.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Gzip is the compressor of the gzip format, of default level.
+var Gzip Compressor = GzipLevel(gzip.DefaultCompression)
+
+// GzipLevel is the compressor of the gzip format, of given level,
+// see compress/gzip.
+type GzipLevel int
+
+func (l GzipLevel) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, int(l))
+}
+
+func (l GzipLevel) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// WithCompression makes Snapshot write compressed snapshots with
+// given compressor, and Restore read them back. Snapshots written
+// without compression can't be restored with it, and the other way
+// round. The write-ahead log isn't compressed.
+func WithCompression(c Compressor) Option {
+	return func(q *Queue) {
+		q.compressor = c
+	}
+}
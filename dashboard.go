@@ -0,0 +1,121 @@
+package pqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// dashboardTop is how many of the best pending items the dashboard
+// shows.
+const dashboardTop = 10
+
+// DashboardStatus is what the dashboard's stats.json returns.
+type DashboardStatus struct {
+	Len       int      `json:"len"`
+	Leased    int      `json:"leased"`
+	OldestAge float64  `json:"oldestAgeSeconds"`
+	DedupRate float64  `json:"dedupRate"`
+	Stats     Stats    `json:"stats"`
+	Top       []string `json:"top"`
+}
+
+// DashboardHandler returns an http.Handler serving a small web page
+// of the queue: a live graph of its length, age of the oldest item,
+// the share of items refused as duplicates, and the best pending
+// items, formatted with fmt. The page polls stats.json next to it
+// every second, and it keeps the graph itself, so nothing is stored
+// when nobody's looking. Like queueadmin, it doesn't authenticate
+// anybody.
+func (q *Queue) DashboardHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(dashboardPage))
+	})
+	mux.HandleFunc("GET /stats.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(q.dashboardStatus())
+	})
+	return mux
+}
+
+func (q *Queue) dashboardStatus() DashboardStatus {
+	s := DashboardStatus{
+		Stats:     q.Stats(),
+		Leased:    q.Leased(),
+		OldestAge: q.OldestAge().Seconds(),
+		Top:       []string{},
+	}
+	s.Len = s.Stats.Len
+	if offered := s.Stats.Enqueued + s.Stats.Duplicates; offered > 0 {
+		s.DedupRate = float64(s.Stats.Duplicates) / float64(offered)
+	}
+	q.mu.RLock()
+	top := q.top(dashboardTop)
+	q.mu.RUnlock()
+	for _, e := range top {
+		s.Top = append(s.Top, fmt.Sprint(e.item))
+	}
+	return s
+}
+
+var dashboardPage = strings.TrimSpace(`
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Queue</title>
+<style>
+body { font: 14px sans-serif; margin: 2em; color: #222; }
+.tiles { display: flex; gap: 2em; margin-bottom: 1.5em; }
+.tile b { display: block; font-size: 28px; }
+svg { border: 1px solid #ccc; background: #fafafa; }
+polyline { fill: none; stroke: #36c; stroke-width: 2; }
+ol { font-family: monospace; }
+</style>
+</head>
+<body>
+<div class="tiles">
+<div class="tile">length<b id="len">-</b></div>
+<div class="tile">leased<b id="leased">-</b></div>
+<div class="tile">oldest item<b id="age">-</b></div>
+<div class="tile">duplicates<b id="dedup">-</b></div>
+<div class="tile">enqueued<b id="enqueued">-</b></div>
+<div class="tile">dequeued<b id="dequeued">-</b></div>
+</div>
+<svg id="graph" width="600" height="150"><polyline id="line"/></svg>
+<h3>Top pending items</h3>
+<ol id="top"></ol>
+<script>
+var lens = [];
+function text(id, v) { document.getElementById(id).textContent = v; }
+function poll() {
+	fetch("stats.json").then(function(r) { return r.json(); }).then(function(s) {
+		text("len", s.len);
+		text("leased", s.leased);
+		text("age", s.oldestAgeSeconds.toFixed(1) + " s");
+		text("dedup", (100 * s.dedupRate).toFixed(1) + " %");
+		text("enqueued", s.stats.Enqueued);
+		text("dequeued", s.stats.Dequeued);
+		lens.push(s.len);
+		if (lens.length > 120) lens.shift();
+		var max = Math.max.apply(null, lens) || 1;
+		document.getElementById("line").setAttribute("points", lens.map(function(n, i) {
+			return (i * 5) + "," + (145 - 140 * n / max);
+		}).join(" "));
+		var top = document.getElementById("top");
+		top.innerHTML = "";
+		s.top.forEach(function(item) {
+			var li = document.createElement("li");
+			li.textContent = item;
+			top.appendChild(li);
+		});
+	}).finally(function() { setTimeout(poll, 1000); });
+}
+poll();
+</script>
+</body>
+</html>
+`)
@@ -0,0 +1,44 @@
+package pqueue
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	q := New(0)
+	for i, x := range []int{5, 3, 8, 1, 9, 2, 7, 4, 6, 0, 11, 10} {
+		q.EnqueueUnique(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	q.EnqueueUnique(&stateTask{Name: "a", Priority: 5})
+	q.EnqueueUnique(&stateTask{Name: "b", Priority: 3})
+	h := q.DashboardHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), "stats.json") {
+		t.Errorf("Expected the page polling stats")
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/stats.json", nil))
+	var s DashboardStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("Expected JSON, given %v", err)
+	}
+	if s.Len != 12 || s.DedupRate != 2.0/14 {
+		t.Errorf("Expected length and dedup rate, given %+v", s)
+	}
+	if len(s.Top) != 10 || s.Top[0] != "&{j 0}" {
+		t.Fatalf("Expected 10 top items, given %v", s.Top)
+	}
+	var priorities []int
+	for _, e := range q.top(10) {
+		priorities = append(priorities, e.item.(*stateTask).Priority)
+	}
+	if !reflect.DeepEqual(priorities, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("Expected best items in order, given %v", priorities)
+	}
+}
@@ -0,0 +1,40 @@
+package pqueue
+
+// WithDeadLetter moves leased items to given dead-letter queue once
+// they have been delivered maxDeliveries times without being acked,
+// instead of putting them back to the queue again. Items running
+// out of retries with Retry are moved there too, which is all that's
+// moved when maxDeliveries is 0.
+func WithDeadLetter(maxDeliveries int, dlq *Queue) Option {
+	return func(q *Queue) {
+		q.maxDeliveries = maxDeliveries
+		q.deadLetter = func(item QueueItem) {
+			dlq.Enqueue(item)
+		}
+	}
+}
+
+// WithDeadLetterFunc is like WithDeadLetter, but calls given
+// function with the dead items instead of moving them to a queue.
+// It's called without the queue locked.
+func WithDeadLetterFunc(maxDeliveries int, fn func(QueueItem)) Option {
+	return func(q *Queue) {
+		q.maxDeliveries = maxDeliveries
+		q.deadLetter = fn
+	}
+}
+
+// dead tells if the entry has been delivered too many times to go
+// back to the queue.
+func (q *Queue) dead(e *entry) bool {
+	return q.maxDeliveries > 0 && e.deliveries >= q.maxDeliveries
+}
+
+// bury hands the dead item over to the dead-letter queue or
+// function, if there is one. Must be called without the queue
+// locked.
+func (q *Queue) bury(item QueueItem) {
+	if q.deadLetter != nil {
+		q.deadLetter(item)
+	}
+}
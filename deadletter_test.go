@@ -0,0 +1,55 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	dlq := New(0)
+	q := NewWithOptions(WithDeadLetter(3, dlq))
+	task := NewDummyTask(1)
+	q.Enqueue(task)
+	for i := 0; i < 3; i += 1 {
+		d, err := q.Lease(context.Background())
+		if err != nil || d.Item != task {
+			t.Fatalf("Expected task to be delivered %d times", i+1)
+		}
+		d.Nack()
+	}
+	if q.Len() != 0 || dlq.Len() != 1 || dlq.Dequeue() != task {
+		t.Errorf("Expected task to be moved to dead-letter queue")
+	}
+}
+
+func TestDeadLetterExpired(t *testing.T) {
+	dead := make(chan QueueItem, 1)
+	q := NewWithOptions(
+		WithVisibilityTimeout(10*time.Millisecond),
+		WithDeadLetterFunc(1, func(item QueueItem) { dead <- item }),
+	)
+	task := NewDummyTask(1)
+	q.Enqueue(task)
+	q.Lease(context.Background())
+	select {
+	case item := <-dead:
+		if item != task {
+			t.Errorf("Expected expired task to be dead")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected expired task to be passed to dead-letter func")
+	}
+}
+
+func TestDeadLetterRetriesExhausted(t *testing.T) {
+	dlq := New(0)
+	q := NewWithOptions(WithDeadLetter(0, dlq), WithRetryPolicy(0, 0, 0))
+	task := NewDummyTask(1)
+	if _, err := q.Retry(task); err != ErrRetriesExhausted {
+		t.Errorf("Expected retries to be exhausted, given %v", err)
+	}
+	if dlq.Len() != 1 {
+		t.Errorf("Expected exhausted task to be moved to dead-letter queue")
+	}
+}
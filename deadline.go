@@ -0,0 +1,65 @@
+package pqueue
+
+import (
+	"math"
+	"time"
+)
+
+// Deadliner items have to be dequeued by some time. Zero time means
+// the item has no deadline.
+type Deadliner interface {
+	Deadline() time.Time
+}
+
+// DeadlineRank scores items by their deadline, so the item due first
+// is dequeued first. Items which don't implement Deadliner, or have
+// no deadline, come after all the items which have one.
+func DeadlineRank(item QueueItem, state QueueState) int64 {
+	x, ok := item.(Deadliner)
+	if !ok {
+		return math.MaxInt64
+	}
+	at := x.Deadline()
+	if at.IsZero() {
+		return math.MaxInt64
+	}
+	return at.UnixNano()
+}
+
+// WithDeadlines turns earliest deadline first scheduling on, ordering
+// the queue by DeadlineRank. With stable order, items with the same
+// deadline are dequeued in the order they have been enqueued. When
+// late isn't nil, it's registered with OnLate.
+func WithDeadlines(late func(QueueItem)) Option {
+	return func(q *Queue) {
+		WithRank(DeadlineRank)(q)
+		if late != nil {
+			q.hooks.late = append(q.hooks.late, late)
+		}
+	}
+}
+
+// NewEDF creates a queue which dequeues the item with the earliest
+// deadline first, see WithDeadlines.
+func NewEDF(max int, late func(QueueItem)) *Queue {
+	return NewWithOptions(WithLimit(max), WithDeadlines(late))
+}
+
+// OnLate registers fn to be called for every Deadliner item dequeued
+// after its deadline has passed, see OnEnqueue. Late items are still
+// handed out, as the queue can't tell if they are worth processing;
+// make them Expirable to have them dropped instead.
+func (q *Queue) OnLate(fn func(QueueItem)) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.hooks.late = append(q.hooks.late, fn)
+}
+
+func isLate(item QueueItem, now time.Time) bool {
+	x, ok := item.(Deadliner)
+	if !ok {
+		return false
+	}
+	at := x.Deadline()
+	return !at.IsZero() && now.After(at)
+}
@@ -0,0 +1,37 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+type deadlineTask struct {
+	name string
+	due  time.Time
+}
+
+func (t *deadlineTask) Less(other interface{}) bool { return false }
+func (t *deadlineTask) Id() interface{}             { return t.name }
+func (t *deadlineTask) Deadline() time.Time         { return t.due }
+
+func TestDeadlines(t *testing.T) {
+	var late []string
+	q := NewWithOptions(WithStableOrder(), WithDeadlines(func(item QueueItem) {
+		late = append(late, item.(*deadlineTask).name)
+	}))
+	now := time.Now()
+	q.Enqueue(&deadlineTask{"none", time.Time{}})
+	q.Enqueue(&deadlineTask{"later", now.Add(time.Hour)})
+	q.Enqueue(&deadlineTask{"past", now.Add(-time.Second)})
+	q.Enqueue(&deadlineTask{"soon", now.Add(time.Minute)})
+	q.Enqueue(&deadlineTask{"soon2", now.Add(time.Minute)})
+	for _, name := range []string{"past", "soon", "soon2", "later", "none"} {
+		if task := q.Dequeue().(*deadlineTask); task.name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.name)
+		}
+	}
+	q.WaitHooks()
+	if len(late) != 1 || late[0] != "past" {
+		t.Errorf("Expected only past item to be reported late, given %v", late)
+	}
+}
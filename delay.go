@@ -0,0 +1,112 @@
+package pqueue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// timeHeap orders delayed entries by the time they become ready.
+type timeHeap []*entry
+
+func (h timeHeap) Len() int {
+	return len(h)
+}
+
+func (h timeHeap) Less(i, j int) bool {
+	if h[i].readyAt.Equal(h[j].readyAt) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].readyAt.Before(h[j].readyAt)
+}
+
+func (h timeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timeHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *timeHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	e.index = -1
+	return e
+}
+
+// EnqueueAfter puts given item to the queue, but it can't be
+// dequeued before given duration passes. Dequeue waits for it
+// like it waits on empty queue. Delayed items are counted by Len
+// and against the limit, but overflow policy never drops them.
+// Items still delayed when the queue is closed are not dequeued.
+func (q *Queue) EnqueueAfter(item QueueItem, d time.Duration) error {
+	return q.EnqueueAt(item, q.now().Add(d))
+}
+
+// EnqueueAt puts given item to the queue, but it can't be dequeued
+// before given time, see EnqueueAfter.
+func (q *Queue) EnqueueAt(item QueueItem, t time.Time) error {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.enqueueEntry(&entry{item: item, id: item.Id(), readyAt: t})
+}
+
+// pushDelayed puts the entry aside until it's ready.
+func (q *Queue) pushDelayed(e *entry) {
+	e.delayed = true
+	heap.Push(&q.delayed, e)
+	if e.index == 0 {
+		q.armDelayTimer()
+	}
+}
+
+// promote moves delayed entries which are ready to the queue. Must
+// be called with the queue locked.
+func (q *Queue) promote() {
+	now := q.now()
+	for len(q.delayed) > 0 && !q.delayed[0].readyAt.After(now) {
+		e := heap.Pop(&q.delayed).(*entry)
+		e.delayed = false
+		if q.rank != nil {
+			e.score = q.rank(e.item, q.state())
+		}
+		q.items.push(e)
+		q.signal()
+	}
+	q.armDelayTimer()
+}
+
+// armDelayTimer sets the timer to promote the first delayed entry
+// once it's ready.
+func (q *Queue) armDelayTimer() {
+	if len(q.delayed) == 0 {
+		if q.delayTimer != nil {
+			q.delayTimer.Stop()
+		}
+		return
+	}
+	d := q.delayed[0].readyAt.Sub(q.now())
+	if q.delayTimer == nil {
+		q.delayTimer = q.clock.AfterFunc(d, func() {
+			q.cond.L.Lock()
+			defer q.cond.L.Unlock()
+			q.promote()
+		})
+	} else {
+		q.delayTimer.Reset(d)
+	}
+}
+
+// Delayed returns number of enqueued items which are not ready to
+// be dequeued yet.
+func (q *Queue) Delayed() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.delayed)
+}
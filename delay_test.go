@@ -0,0 +1,81 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnqueueAfter(t *testing.T) {
+	q := New(0)
+	q.EnqueueAfter(NewDummyTask(1), 1e8)
+	q.Enqueue(NewDummyTask(5))
+	if q.Len() != 2 || q.Delayed() != 1 {
+		t.Errorf("Expected delayed item to be counted, given %d, %d delayed", q.Len(), q.Delayed())
+	}
+	if task, ok := q.TryDequeue(); !ok || task.(*DummyTask).priority != 5 {
+		t.Errorf("Expected to dequeue ready item first")
+	}
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected delayed item not to be dequeued before it's ready")
+	}
+	start := time.Now()
+	if task := q.Dequeue(); task.(*DummyTask).priority != 1 {
+		t.Errorf("Expected to dequeue delayed item")
+	}
+	if time.Since(start) < 5e7 {
+		t.Errorf("Expected Dequeue to wait for delayed item")
+	}
+}
+
+func TestEnqueueAtOrder(t *testing.T) {
+	q := New(0)
+	now := time.Now()
+	q.EnqueueAt(NewDummyTask(1), now.Add(6e7))
+	q.EnqueueAt(NewDummyTask(2), now.Add(2e7))
+	q.EnqueueAt(NewDummyTask(3), now.Add(-1))
+	for _, x := range []int{3, 2, 1} {
+		if task := q.Dequeue().(*DummyTask); task.priority != x {
+			t.Errorf("Expected to dequeue %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestRemoveDelayed(t *testing.T) {
+	q := New(0)
+	task := NewDummyTask(1)
+	q.EnqueueAfter(task, time.Hour)
+	if _, ok := q.Remove(task); !ok || !q.IsEmpty() {
+		t.Errorf("Expected delayed item to be removed")
+	}
+}
+
+func TestRemoveFirstDelayed(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock))
+	first, second := NewDummyTask(1), NewDummyTask(2)
+	q.EnqueueAfter(first, time.Hour)
+	q.EnqueueAfter(second, 2*time.Hour)
+	q.Remove(first)
+	if timer := q.delayTimer.(*fakeTimer); !timer.at.Equal(clock.Now().Add(2 * time.Hour)) {
+		t.Errorf("Expected timer to be set for the next delayed item, given %v", timer.at.Sub(clock.Now()))
+	}
+	q.Remove(second)
+	if q.delayTimer.(*fakeTimer).armed {
+		t.Errorf("Expected timer to be stopped once no item is delayed")
+	}
+}
+
+func TestDelayedState(t *testing.T) {
+	q := New(0)
+	q.EnqueueAfter(&stateTask{Name: "a", Priority: 1}, 5e7)
+	q.Enqueue(&stateTask{Name: "b", Priority: 2})
+	r := ImportState(q.ExportState())
+	if r.Delayed() != 1 {
+		t.Errorf("Expected delayed item to stay delayed")
+	}
+	for _, name := range []string{"b", "a"} {
+		if task := r.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+}
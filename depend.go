@@ -0,0 +1,65 @@
+package pqueue
+
+// Dependent items wait for other items: they aren't dequeued until
+// every id DependsOn returns has been marked processed, see
+// MarkProcessed, so simple DAGs of tasks can run on the queue. With
+// WithDependencies acking a delivery marks its item processed too.
+// Waiting items stay in the queue, Peek and Each still see them.
+type Dependent interface {
+	DependsOn() []interface{}
+}
+
+// WithDependencies makes Ack mark the acked item processed, so items
+// which depend on it can be dequeued.
+func WithDependencies() Option {
+	return func(q *Queue) {
+		q.ackProcessed = true
+	}
+}
+
+// Blocked tells if the item waits for an item it depends on.
+func (q *Queue) Blocked(item QueueItem) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.blocked(item)
+}
+
+// blocked tells if the item depends on an item not processed yet.
+func (q *Queue) blocked(item QueueItem) bool {
+	d, ok := item.(Dependent)
+	if !ok {
+		return false
+	}
+	for _, id := range d.DependsOn() {
+		if q.processed == nil || !q.processed.Seen(q.processedKey(id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// holding tells if any item may be held back, see heldBack.
+func (q *Queue) holding() bool {
+	return len(q.busy) > 0 || q.dependents > 0 || len(q.politeLast) > 0
+}
+
+// heldBack tells if the item can't be dequeued yet, because its
+// group is busy, it waits for an item it depends on or an item of
+// its key has been dequeued within the polite delay.
+func (q *Queue) heldBack(item QueueItem) bool {
+	return len(q.busy) > 0 && q.busy[groupOf(item)] ||
+		q.dependents > 0 && q.blocked(item) ||
+		len(q.politeLast) > 0 && q.impolite(item)
+}
+
+// markProcessed marks the id processed, waking the waiters which may
+// be after the items depending on it. Must be called locked.
+func (q *Queue) markProcessed(id interface{}) {
+	if q.processed == nil {
+		q.processed = newHistory()
+	}
+	q.processed.Add(q.processedKey(id))
+	if q.dependents > 0 {
+		q.wakeAll()
+	}
+}
@@ -0,0 +1,77 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type depTask struct {
+	stateTask
+	deps []interface{}
+}
+
+func (dt *depTask) DependsOn() []interface{} {
+	return dt.deps
+}
+
+func (dt *depTask) Less(other interface{}) bool {
+	return dt.Priority < other.(*depTask).Priority
+}
+
+func TestDependencies(t *testing.T) {
+	q := NewWithOptions(WithDependencies())
+	q.Enqueue(&depTask{stateTask{"c", 1}, []interface{}{"a", "b"}})
+	q.Enqueue(&depTask{stateTask{"b", 2}, []interface{}{"a"}})
+	q.Enqueue(&depTask{stateTask{"a", 3}, nil})
+
+	if !q.Blocked(&depTask{stateTask{"c", 1}, []interface{}{"a", "b"}}) {
+		t.Errorf("Expected c blocked")
+	}
+	ctx := context.Background()
+	d, _ := q.Lease(ctx)
+	if name := d.Item.(*depTask).Name; name != "a" {
+		t.Errorf("Expected a first, given %s", name)
+	}
+	if item, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected nothing while a is not acked, given %v", item)
+	}
+	if q.Len() != 2 {
+		t.Errorf("Expected blocked items still in queue, given %d", q.Len())
+	}
+
+	got := make(chan string)
+	go func() {
+		d, _ := q.Lease(ctx)
+		got <- d.Item.(*depTask).Name
+		d.Ack()
+	}()
+	select {
+	case name := <-got:
+		t.Errorf("Expected blocked item not dequeued before ack, given %s", name)
+	case <-time.After(20 * time.Millisecond):
+	}
+	d.Ack()
+	if name := <-got; name != "b" {
+		t.Errorf("Expected b once a is acked, given %s", name)
+	}
+	item, _ := q.DequeueContext(ctx)
+	if name := item.(*depTask).Name; name != "c" {
+		t.Errorf("Expected c once b is acked, given %s", name)
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+}
+
+func TestDependenciesMarkProcessed(t *testing.T) {
+	q := New(0)
+	q.Enqueue(&depTask{stateTask{"b", 1}, []interface{}{"a"}})
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected b blocked")
+	}
+	q.MarkProcessed("a")
+	if item, ok := q.TryDequeue(); !ok || item.(*depTask).Name != "b" {
+		t.Errorf("Expected b once a is processed, given %v", item)
+	}
+}
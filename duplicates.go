@@ -0,0 +1,51 @@
+package pqueue
+
+// WithMaxDuplicates lets EnqueueUnique enqueue an item with the same
+// id up to n times in all, counted while the id stays in the history,
+// eg. for retry pipelines which may send an item again a few times.
+// Once the id is forgotten the count starts again. Items enqueued
+// with Enqueue count too. It has no effect with n of 1 or less.
+func WithMaxDuplicates(n int) Option {
+	return func(q *Queue) {
+		q.maxDuplicates = n
+		q.occurrences = make(map[interface{}]int)
+	}
+}
+
+// Occurrences returns how many times the item with given id has been
+// enqueued while the id has been in the history, see
+// WithMaxDuplicates. It's 0 without WithMaxDuplicates.
+func (q *Queue) Occurrences(id interface{}) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	id = q.historyKey(id)
+	if !q.idExists(id) {
+		return 0
+	}
+	return q.occurrences[id]
+}
+
+// occurred counts the enqueue of the item with given history id,
+// before the id is added to the history. Must be called with the
+// queue locked.
+func (q *Queue) occurred(id interface{}) {
+	if len(q.occurrences) >= q.occurrencesPrune {
+		// forget the counts of the ids gone from the history
+		for id := range q.occurrences {
+			if !q.idExists(id) {
+				delete(q.occurrences, id)
+			}
+		}
+		q.occurrencesPrune = 2*len(q.occurrences) + 64
+	}
+	if !q.idExists(id) {
+		q.occurrences[id] = 0
+	}
+	q.occurrences[id] += 1
+}
+
+// duplicateAllowed tells if the item with given history id, already
+// in the history, may still be enqueued by EnqueueUnique.
+func (q *Queue) duplicateAllowed(id interface{}) bool {
+	return q.maxDuplicates > 1 && q.occurrences[id] < q.maxDuplicates
+}
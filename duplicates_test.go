@@ -0,0 +1,28 @@
+package pqueue
+
+import "testing"
+
+func TestMaxDuplicates(t *testing.T) {
+	q := NewWithOptions(WithMaxDuplicates(3))
+	for i := 0; i < 3; i++ {
+		if err := q.EnqueueUnique(&stateTask{"a", i}); err != nil {
+			t.Errorf("Expected occurrence %d enqueued, given %v", i+1, err)
+		}
+	}
+	if err := q.EnqueueUnique(&stateTask{"a", 3}); err != ErrDuplicate {
+		t.Errorf("Expected 4th occurrence refused, given %v", err)
+	}
+	if n := q.Occurrences("a"); n != 3 {
+		t.Errorf("Expected 3 occurrences, given %d", n)
+	}
+	q.RemoveFromHistory("a")
+	if n := q.Occurrences("a"); n != 0 {
+		t.Errorf("Expected no occurrences once forgotten, given %d", n)
+	}
+	if err := q.EnqueueUnique(&stateTask{"a", 4}); err != nil || q.Occurrences("a") != 1 {
+		t.Errorf("Expected count started again, given %d", q.Occurrences("a"))
+	}
+	if q.Len() != 4 {
+		t.Errorf("Expected 4 items, given %d", q.Len())
+	}
+}
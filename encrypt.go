@@ -0,0 +1,203 @@
+package pqueue
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrDecrypt is returned when encrypted snapshot or write-ahead log
+// record can't be decrypted, because of a wrong key or tampering.
+var ErrDecrypt = errors.New("Can't decrypt")
+
+// KeyProvider gives the AES key, of 16, 24 or 32 bytes, snapshots
+// and the write-ahead log are encrypted with, see WithEncryption. The
+// key is asked for on every Snapshot, Restore and OpenWAL.
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// StaticKey is the KeyProvider of a fixed key.
+type StaticKey []byte
+
+func (k StaticKey) Key() ([]byte, error) {
+	return k, nil
+}
+
+// WithEncryption makes Snapshot and the write-ahead log encrypt
+// everything they write with AES-GCM, with the key given by the
+// provider, and Restore and OpenWAL decrypt it back. Snapshots are
+// encrypted after compression, in chunks, so a truncated snapshot
+// is told apart from a complete one. Every log record is encrypted
+// on its own.
+func WithEncryption(keys KeyProvider) Option {
+	return func(q *Queue) {
+		q.keys = keys
+	}
+}
+
+// aead returns the cipher of the current key, or nil when the queue
+// isn't encrypted.
+func (q *Queue) aead() (cipher.AEAD, error) {
+	if q.keys == nil {
+		return nil, nil
+	}
+	key, err := q.keys.Key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the record with a random nonce put in front of it.
+func seal(aead cipher.AEAD, rec []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(rec)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, rec, nil), nil
+}
+
+// unseal decrypts the record sealed by seal.
+func unseal(aead cipher.AEAD, rec []byte) ([]byte, error) {
+	n := aead.NonceSize()
+	if len(rec) < n {
+		return nil, ErrDecrypt
+	}
+	rec, err := aead.Open(nil, rec[:n], rec[n:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return rec, nil
+}
+
+// chunkSize is how much of the snapshot is encrypted at once.
+const chunkSize = 64 << 10
+
+// Encrypted stream is a random nonce prefix followed by chunks of
+// 4 bytes of sealed length and the sealed chunk. Nonce of a chunk is
+// the prefix followed by the chunk's number, and the last chunk is
+// sealed with additional data telling it's the last.
+var (
+	moreChunks = []byte{0}
+	lastChunk  = []byte{1}
+)
+
+type encWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	nonce  []byte
+	n      uint32
+	buf    []byte
+	sealed []byte
+}
+
+func newEncWriter(w io.Writer, aead cipher.AEAD) (*encWriter, error) {
+	ew := &encWriter{w: w, aead: aead, nonce: make([]byte, aead.NonceSize())}
+	prefix := ew.nonce[:len(ew.nonce)-4]
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	ew.buf = make([]byte, 0, chunkSize)
+	return ew, nil
+}
+
+func (ew *encWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if len(ew.buf) == chunkSize {
+			if err = ew.flush(moreChunks); err != nil {
+				return
+			}
+		}
+		m := copy(ew.buf[len(ew.buf):chunkSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return
+}
+
+// Close writes the last chunk, it doesn't close the underlying
+// writer.
+func (ew *encWriter) Close() error {
+	return ew.flush(lastChunk)
+}
+
+func (ew *encWriter) flush(last []byte) error {
+	binary.BigEndian.PutUint32(ew.nonce[len(ew.nonce)-4:], ew.n)
+	ew.n += 1
+	ew.sealed = ew.aead.Seal(append(ew.sealed[:0], 0, 0, 0, 0), ew.nonce, ew.buf, last)
+	binary.LittleEndian.PutUint32(ew.sealed, uint32(len(ew.sealed)-4))
+	ew.buf = ew.buf[:0]
+	_, err := ew.w.Write(ew.sealed)
+	return err
+}
+
+type encReader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	nonce []byte
+	n     uint32
+	buf   []byte
+	last  bool
+}
+
+func newEncReader(r io.Reader, aead cipher.AEAD) (*encReader, error) {
+	er := &encReader{r: r, aead: aead, nonce: make([]byte, aead.NonceSize())}
+	if _, err := io.ReadFull(r, er.nonce[:len(er.nonce)-4]); err != nil {
+		return nil, ErrDecrypt
+	}
+	return er, nil
+}
+
+func (er *encReader) Read(p []byte) (n int, err error) {
+	for len(er.buf) == 0 {
+		if er.last {
+			return 0, io.EOF
+		}
+		if err = er.next(); err != nil {
+			return
+		}
+	}
+	n = copy(p, er.buf)
+	er.buf = er.buf[n:]
+	return
+}
+
+func (er *encReader) next() error {
+	var header [4]byte
+	if _, err := io.ReadFull(er.r, header[:]); err != nil {
+		// truncated before the last chunk
+		return ErrDecrypt
+	}
+	size := binary.LittleEndian.Uint32(header[:])
+	if size > chunkSize+uint32(er.aead.Overhead()) {
+		return ErrDecrypt
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(er.r, sealed); err != nil {
+		return ErrDecrypt
+	}
+	binary.BigEndian.PutUint32(er.nonce[len(er.nonce)-4:], er.n)
+	er.n += 1
+	// not decrypted in place, failed Open clears its output
+	var err error
+	if er.buf, err = er.aead.Open(er.buf[:0], er.nonce, sealed, moreChunks); err == nil {
+		return nil
+	}
+	if er.buf, err = er.aead.Open(er.buf[:0], er.nonce, sealed, lastChunk); err == nil {
+		er.last = true
+		return nil
+	}
+	return ErrDecrypt
+}
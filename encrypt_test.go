@@ -0,0 +1,68 @@
+package pqueue
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedSnapshot(t *testing.T) {
+	key := StaticKey("0123456789abcdef0123456789abcdef")
+	q := NewWithOptions(WithEncryption(key), WithCompression(Gzip))
+	for i := 0; i < 5000; i++ {
+		q.Enqueue(&stateTask{Name: "secret" + strings.Repeat("x", i%50), Priority: i})
+	}
+	var buf bytes.Buffer
+	if err := q.Snapshot(&buf); err != nil {
+		t.Fatalf("Expected snapshot to be written, given %v", err)
+	}
+	data := buf.Bytes()
+	if bytes.Contains(data, []byte("secret")) {
+		t.Errorf("Expected no plaintext in the snapshot")
+	}
+
+	r := NewWithOptions(WithEncryption(key), WithCompression(Gzip))
+	if err := r.Restore(bytes.NewReader(data), decodeStateTask); err != nil {
+		t.Fatalf("Expected snapshot to be restored, given %v", err)
+	}
+	if r.Len() != 5000 {
+		t.Errorf("Expected all items restored, given %d", r.Len())
+	}
+	wrong := NewWithOptions(WithEncryption(StaticKey("fedcba9876543210fedcba9876543210")), WithCompression(Gzip))
+	if err := wrong.Restore(bytes.NewReader(data), decodeStateTask); err != ErrDecrypt {
+		t.Errorf("Expected ErrDecrypt with wrong key, given %v", err)
+	}
+	if err := r.Restore(bytes.NewReader(data[:len(data)-1]), decodeStateTask); err == nil {
+		t.Errorf("Expected truncated snapshot to fail")
+	}
+}
+
+func TestEncryptedWAL(t *testing.T) {
+	key := StaticKey("0123456789abcdef")
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := NewWithOptions(WithEncryption(key))
+	w, err := q.OpenWAL(path, decodeStateTask, 0)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	q.Enqueue(&stateTask{Name: "secret-a", Priority: 2})
+	q.Enqueue(&stateTask{Name: "secret-b", Priority: 1})
+	q.Dequeue()
+	w.Close()
+	if data, _ := os.ReadFile(path); bytes.Contains(data, []byte("secret")) {
+		t.Errorf("Expected no plaintext in the log")
+	}
+
+	r := NewWithOptions(WithEncryption(key))
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	if task := r.Dequeue().(*stateTask); task.Name != "secret-a" || !r.IsEmpty() {
+		t.Errorf("Expected only secret-a recovered")
+	}
+	if _, err := New(0).OpenWAL(path, decodeStateTask, 0); err == nil {
+		t.Errorf("Expected encrypted log not to be read without the key")
+	}
+}
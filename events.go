@@ -0,0 +1,81 @@
+package pqueue
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventKind tells what happened to an item.
+type EventKind int
+
+const (
+	// Enqueued is emitted when an item is put to the queue.
+	Enqueued EventKind = iota
+	// Dequeued is emitted when an item is taken from the queue.
+	Dequeued
+	// Dropped is emitted when an item didn't make it to the
+	// queue or was thrown out of it, eg. because of the limit.
+	Dropped
+	// Updated is emitted when a pending item is changed in place.
+	Updated
+	// Removed is emitted when a pending item is removed from the
+	// queue without being dequeued.
+	Removed
+	// Expired is emitted when an Expirable item is dropped at
+	// dequeue because it expired.
+	Expired
+)
+
+var eventKindNames = []string{"enqueued", "dequeued", "dropped", "updated", "removed", "expired"}
+
+func (k EventKind) String() string {
+	if k < 0 || int(k) >= len(eventKindNames) {
+		return "unknown"
+	}
+	return eventKindNames[k]
+}
+
+// Event describes a single change in item's lifecycle.
+type Event struct {
+	Kind EventKind
+	Item QueueItem
+	Time time.Time
+}
+
+// Events returns a channel that receives an event for everything
+// happening to the queue items. Events are sent without blocking,
+// so when the buffer of given size is full the event is dropped
+// and counted (see EventsDropped). Slow consumers lose events.
+func (q *Queue) Events(buf int) <-chan Event {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	ch := make(chan Event, buf)
+	q.events = append(q.events, ch)
+	return ch
+}
+
+// EventsDropped returns number of events lost because of a full
+// listener buffer.
+func (q *Queue) EventsDropped() uint64 {
+	return atomic.LoadUint64(&q.eventsDropped)
+}
+
+// emit sends event to all the listeners. Must be called with
+// the queue locked.
+func (q *Queue) emit(kind EventKind, item QueueItem) {
+	q.count(kind, item)
+	if len(q.events) == 0 && len(q.mirrors) == 0 {
+		return
+	}
+	ev := Event{Kind: kind, Item: item, Time: q.now()}
+	for _, m := range q.mirrors {
+		m.add(ev)
+	}
+	for _, ch := range q.events {
+		select {
+		case ch <- ev:
+		default:
+			atomic.AddUint64(&q.eventsDropped, 1)
+		}
+	}
+}
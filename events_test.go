@@ -0,0 +1,69 @@
+package pqueue
+
+import "testing"
+
+func expectEvent(t *testing.T, events <-chan Event, kind EventKind, item QueueItem) {
+	select {
+	case ev := <-events:
+		if ev.Kind != kind || ev.Item != item {
+			t.Errorf("Expected %s event, given %s", kind, ev.Kind)
+		}
+		if ev.Time.IsZero() {
+			t.Errorf("Expected event time to be set")
+		}
+	default:
+		t.Errorf("Expected %s event", kind)
+	}
+}
+
+func TestEventsEnqueuedAndDequeued(t *testing.T) {
+	q := New(0)
+	events := q.Events(10)
+	task := NewDummyTask(1)
+	q.Enqueue(task)
+	expectEvent(t, events, Enqueued, task)
+	q.Dequeue()
+	expectEvent(t, events, Dequeued, task)
+}
+
+func TestEventsDropped(t *testing.T) {
+	q := New(1)
+	events := q.Events(10)
+	first, second := NewDummyTask(1), NewDummyTask(2)
+	q.Enqueue(first)
+	q.Enqueue(second)
+	expectEvent(t, events, Enqueued, first)
+	expectEvent(t, events, Dropped, second)
+}
+
+func TestEventsFullBuffer(t *testing.T) {
+	q := New(0)
+	events := q.Events(1)
+	for _, x := range []int{1, 2, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	if len(events) != 1 {
+		t.Errorf("Expected to buffer only 1 event, %d buffered", len(events))
+	}
+	if q.EventsDropped() != 2 {
+		t.Errorf("Expected to drop 2 events, %d dropped", q.EventsDropped())
+	}
+}
+
+func TestEventsRemoved(t *testing.T) {
+	q := New(0)
+	task := NewDummyTask(1)
+	q.Enqueue(task)
+	events := q.Events(10)
+	q.Remove(task.Id())
+	expectEvent(t, events, Removed, task)
+}
+
+func TestEventsUpdated(t *testing.T) {
+	q := New(0)
+	task := NewDummyTask(1)
+	q.Enqueue(task)
+	events := q.Events(10)
+	q.UpdatePriority(task.Id(), func(QueueItem) {})
+	expectEvent(t, events, Updated, task)
+}
@@ -1,6 +1,6 @@
 package main
 
-import pqueue "github.com/nu7hatch/gopqueue"
+import pqueue "github.com/mileusna/gopqueue"
 
 type Task struct {
 	Name     string
@@ -11,6 +11,10 @@ func (t *Task) Less(other interface{}) bool {
 	return t.priority < other.(*Task).priority
 }
 
+func (t *Task) Id() interface{} {
+	return t.Name
+}
+
 func main() {
 	q := pqueue.New(0)
 	q.Enqueue(&Task{"one", 10})
@@ -0,0 +1,65 @@
+package pqueue
+
+import "time"
+
+// Expirable items stop being worth processing at some point. Items
+// which implement it are not dequeued after the time ExpiresAt
+// returns, they are dropped from the queue instead and reported by
+// Expired event. Zero time means the item never expires.
+type Expirable interface {
+	ExpiresAt() time.Time
+}
+
+// Expired returns number of items dropped because they expired
+// before they were dequeued.
+func (q *Queue) Expired() uint64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.stats.Expired
+}
+
+// next pops the top entry which has not expired, or a random one of
+// the best ones with WithRandomTop, dropping the expired ones on the
+// way. It returns nil when there is none.
+func (q *Queue) next() *entry {
+	if len(q.delayed) > 0 && !q.delayed[0].readyAt.After(q.now()) {
+		// don't wait for the timer to make the item ready
+		q.promote()
+	}
+	if q.spillLen() > 0 {
+		q.pageIn()
+	}
+	if q.randomTop > 1 {
+		return q.pick()
+	}
+	var held []*entry
+	defer func() {
+		for _, e := range held {
+			q.items.push(e)
+		}
+	}()
+	for {
+		if q.holding() && q.items.Len() > 0 && q.heldBack(q.items.first().item) {
+			// held back until its group's leased item is acked, the
+			// items it depends on are processed or the polite delay
+			// of its key passes
+			held = append(held, q.items.pop())
+			continue
+		}
+		e := q.pop()
+		if e == nil || !isExpired(e.item, q.now()) {
+			return e
+		}
+		q.logEvent(LogExpired, e.item, nil)
+		q.emit(Expired, e.item)
+	}
+}
+
+func isExpired(item QueueItem, now time.Time) bool {
+	x, ok := item.(Expirable)
+	if !ok {
+		return false
+	}
+	at := x.ExpiresAt()
+	return !at.IsZero() && !now.Before(at)
+}
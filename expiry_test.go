@@ -0,0 +1,52 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+type expiringTask struct {
+	DummyTask
+	expires time.Time
+}
+
+func (et *expiringTask) Less(other interface{}) bool {
+	return et.priority < other.(*expiringTask).priority
+}
+
+func (et *expiringTask) Id() interface{} {
+	return et
+}
+
+func (et *expiringTask) ExpiresAt() time.Time {
+	return et.expires
+}
+
+func TestExpiredSkipped(t *testing.T) {
+	q := New(0)
+	events := q.Events(8)
+	stale := &expiringTask{DummyTask{1}, time.Now().Add(-time.Second)}
+	fresh := &expiringTask{DummyTask{2}, time.Now().Add(time.Hour)}
+	forever := &expiringTask{DummyTask: DummyTask{3}}
+	q.Enqueue(stale)
+	q.Enqueue(fresh)
+	q.Enqueue(forever)
+	if items := q.DequeueN(3); len(items) != 2 || items[0] != fresh || items[1] != forever {
+		t.Errorf("Expected expired item to be skipped, given %v", items)
+	}
+	if q.Expired() != 1 {
+		t.Errorf("Expected 1 expired item, given %d", q.Expired())
+	}
+	expectEvent(t, events, Enqueued, stale)
+	expectEvent(t, events, Enqueued, fresh)
+	expectEvent(t, events, Enqueued, forever)
+	expectEvent(t, events, Expired, stale)
+}
+
+func TestExpiredOnly(t *testing.T) {
+	q := New(0)
+	q.Enqueue(&expiringTask{DummyTask{1}, time.Now().Add(-time.Second)})
+	if _, ok := q.TryDequeue(); ok || !q.IsEmpty() {
+		t.Errorf("Expected expired item to be dropped")
+	}
+}
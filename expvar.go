@@ -0,0 +1,12 @@
+package pqueue
+
+import "expvar"
+
+// PublishExpvar publishes the queue Stats under given name with the
+// expvar package, so they show up on the /debug/vars endpoint. Like
+// expvar.Publish, it panics if the name is already taken.
+func (q *Queue) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return q.Stats()
+	}))
+}
@@ -0,0 +1,20 @@
+package pqueue
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	q := New(0)
+	q.PublishExpvar("pqueue_test")
+	q.Enqueue(NewDummyTask(1))
+	var s Stats
+	if err := json.Unmarshal([]byte(expvar.Get("pqueue_test").String()), &s); err != nil {
+		t.Fatalf("Expected stats to be published as JSON, given %v", err)
+	}
+	if s.Len != 1 || s.Enqueued != 1 {
+		t.Errorf("Expected published stats to follow the queue, given %+v", s)
+	}
+}
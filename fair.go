@@ -0,0 +1,54 @@
+package pqueue
+
+import "sync"
+
+// join puts the consumer at the end of the line of waiting ones.
+func (q *Queue) join() *sync.Cond {
+	w := sync.NewCond(q.mu)
+	q.waiters = append(q.waiters, w)
+	return w
+}
+
+// leave takes the consumer out of the line. When the first one
+// leaves, the next one is woken up if there are items left for it.
+func (q *Queue) leave(w *sync.Cond) {
+	for i, x := range q.waiters {
+		if x == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			if i == 0 && q.items.Len() > 0 {
+				q.signal()
+			}
+			return
+		}
+	}
+}
+
+// turn tells if the consumer may take an item: either it's first
+// in line, or there is no line and it hasn't joined one.
+func (q *Queue) turn(w *sync.Cond) bool {
+	if w == nil {
+		return len(q.waiters) == 0
+	}
+	return q.waiters[0] == w
+}
+
+// signal wakes up the consumer waiting longest.
+func (q *Queue) signal() {
+	if len(q.waiters) > 0 {
+		q.waiters[0].Signal()
+	}
+	for _, n := range q.watchers {
+		n.wake()
+	}
+}
+
+// wakeAll wakes up all the waiting consumers so they can check why
+// they wait.
+func (q *Queue) wakeAll() {
+	for _, w := range q.waiters {
+		w.Signal()
+	}
+	for _, n := range q.watchers {
+		n.wake()
+	}
+}
@@ -0,0 +1,52 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFairWaiters(t *testing.T) {
+	q := New(0)
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			q.Dequeue()
+			order <- i
+		}()
+		// let the consumer line up
+		for {
+			q.cond.L.Lock()
+			n := len(q.waiters)
+			q.cond.L.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		q.Enqueue(NewDummyTask(i))
+		if x := <-order; x != i {
+			t.Errorf("Expected consumer %d to be served, given %d", i, x)
+		}
+	}
+}
+
+func TestFairWaitersBurst(t *testing.T) {
+	q := New(0)
+	done := make(chan QueueItem, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			done <- q.Dequeue()
+		}()
+	}
+	time.Sleep(2e7)
+	q.EnqueueAll([]QueueItem{NewDummyTask(1), NewDummyTask(2), NewDummyTask(3)})
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected all the consumers to be served")
+		}
+	}
+}
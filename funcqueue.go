@@ -0,0 +1,109 @@
+package pqueue
+
+import "context"
+
+// FuncQueue is a priority queue of values of any type, ordered by
+// given function instead of the QueueItem interface, so values of
+// types the caller doesn't own can be enqueued:
+//
+//	q := pqueue.NewFunc(func(a, b time.Time) bool { return a.Before(b) }, nil)
+//	q.Enqueue(time.Now())
+//
+// It's a thin wrapper around Queue, which does all the work.
+type FuncQueue[T any] struct {
+	q    *Queue
+	less func(a, b T) bool
+	id   func(T) interface{}
+}
+
+// funcItem adapts a value to QueueItem.
+type funcItem[T any] struct {
+	v T
+	f *FuncQueue[T]
+}
+
+func (it *funcItem[T]) Less(other interface{}) bool {
+	return it.f.less(it.v, other.(*funcItem[T]).v)
+}
+
+func (it *funcItem[T]) Id() interface{} {
+	if it.f.id == nil {
+		return it
+	}
+	return it.f.id(it.v)
+}
+
+// NewFunc creates an unlimited queue of values ordered by less. Ids
+// used for deduplication are given by id. Without it, every value
+// is unique.
+func NewFunc[T any](less func(a, b T) bool, id func(T) interface{}, opts ...Option) *FuncQueue[T] {
+	return &FuncQueue[T]{q: NewWithOptions(opts...), less: less, id: id}
+}
+
+// Untyped returns the underlying queue, for features not wrapped by
+// FuncQueue. Its items are not the enqueued values themselves.
+func (f *FuncQueue[T]) Untyped() *Queue {
+	return f.q
+}
+
+// Enqueue puts given value to the queue.
+func (f *FuncQueue[T]) Enqueue(v T) error {
+	return f.q.Enqueue(&funcItem[T]{v, f})
+}
+
+// EnqueueUnique puts value in queue only if its id hasn't already
+// been in queue.
+func (f *FuncQueue[T]) EnqueueUnique(v T) error {
+	return f.q.EnqueueUnique(&funcItem[T]{v, f})
+}
+
+// Dequeue takes a value from the queue, blocking while it's empty.
+// Once the queue is closed and drained it returns zero value and
+// false.
+func (f *FuncQueue[T]) Dequeue() (v T, ok bool) {
+	item, ok := f.q.Dequeue().(*funcItem[T])
+	if ok {
+		v = item.v
+	}
+	return
+}
+
+// DequeueContext takes a value from the queue, blocking while the
+// queue is empty until the context is done.
+func (f *FuncQueue[T]) DequeueContext(ctx context.Context) (v T, err error) {
+	item, err := f.q.DequeueContext(ctx)
+	if err == nil {
+		v = item.(*funcItem[T]).v
+	}
+	return
+}
+
+// TryDequeue takes a value from the queue without blocking. It
+// returns false when the queue is empty.
+func (f *FuncQueue[T]) TryDequeue() (v T, ok bool) {
+	item, ok := f.q.TryDequeue()
+	if ok {
+		v = item.(*funcItem[T]).v
+	}
+	return
+}
+
+// Peek returns the value that would be dequeued next, without
+// taking it from the queue.
+func (f *FuncQueue[T]) Peek() (v T, ok bool) {
+	item, ok := f.q.Peek()
+	if ok {
+		v = item.(*funcItem[T]).v
+	}
+	return
+}
+
+// Len returns number of enqueued values.
+func (f *FuncQueue[T]) Len() int {
+	return f.q.Len()
+}
+
+// Close closes the queue.
+func (f *FuncQueue[T]) Close() {
+	f.q.Close()
+}
@@ -0,0 +1,41 @@
+package pqueue
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFuncQueue(t *testing.T) {
+	q := NewFunc(func(a, b string) bool { return len(a) < len(b) }, func(s string) interface{} {
+		return strings.ToLower(s)
+	})
+	for _, s := range []string{"ccc", "a", "bb"} {
+		q.Enqueue(s)
+	}
+	if err := q.EnqueueUnique("A"); err == nil {
+		t.Errorf("Expected duplicate id not to be added")
+	}
+	if s, ok := q.Peek(); !ok || s != "a" {
+		t.Errorf("Expected to peek the shortest string, given %q", s)
+	}
+	for _, expected := range []string{"a", "bb", "ccc"} {
+		if s, _ := q.Dequeue(); s != expected {
+			t.Errorf("Expected to dequeue %q, given %q", expected, s)
+		}
+	}
+	q.Close()
+	if _, ok := q.Dequeue(); ok {
+		t.Errorf("Expected nothing from closed queue")
+	}
+}
+
+func TestFuncQueueWithoutID(t *testing.T) {
+	q := NewFunc(func(a, b int) bool { return a < b }, nil)
+	q.EnqueueUnique(1)
+	if err := q.EnqueueUnique(1); err != nil {
+		t.Errorf("Expected values without id to be unique")
+	}
+	if q.Len() != 2 {
+		t.Errorf("Expected 2 values, given %d", q.Len())
+	}
+}
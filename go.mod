@@ -0,0 +1,3 @@
+module github.com/mileusna/gopqueue
+
+go 1.24
@@ -0,0 +1,29 @@
+package pqueue
+
+// Grouped items belong to a group, eg. of a customer's orders,
+// whose items are never processed at the same time: while an item
+// of the group is leased with Lease, the other items of the group
+// aren't dequeued, until the leased one is acked, nacked or its
+// visibility timeout passes. Items of different groups still go in
+// parallel. Empty group means the item isn't grouped.
+//
+// Items taken with Dequeue aren't acked, so they don't hold their
+// group back, but they do wait for a leased item of their group.
+// Peek and Each still see held items, PeekReady doesn't.
+type Grouped interface {
+	Group() string
+}
+
+func groupOf(item QueueItem) string {
+	if g, ok := item.(Grouped); ok {
+		return g.Group()
+	}
+	return ""
+}
+
+// Busy tells if an item of given group is leased and not acked yet.
+func (q *Queue) Busy(group string) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.busy[group]
+}
@@ -0,0 +1,63 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type groupTask struct {
+	stateTask
+	group string
+}
+
+func (gt *groupTask) Group() string {
+	return gt.group
+}
+
+func (gt *groupTask) Less(other interface{}) bool {
+	return gt.Priority < other.(*groupTask).Priority
+}
+
+func TestGroups(t *testing.T) {
+	q := New(0)
+	q.Enqueue(&groupTask{stateTask{"a1", 1}, "a"})
+	q.Enqueue(&groupTask{stateTask{"a2", 2}, "a"})
+	q.Enqueue(&groupTask{stateTask{"b1", 3}, "b"})
+	q.Enqueue(&groupTask{stateTask{"x", 4}, ""})
+
+	ctx := context.Background()
+	a1, _ := q.Lease(ctx)
+	if !q.Busy("a") {
+		t.Errorf("Expected group a busy")
+	}
+	for _, name := range []string{"b1", "x"} {
+		d, _ := q.Lease(ctx)
+		if task := d.Item.(*groupTask); task.Name != name {
+			t.Errorf("Expected %s while group a is busy, given %s", name, task.Name)
+		}
+		d.Ack()
+	}
+	if q.Len() != 1 {
+		t.Errorf("Expected held item still in queue, given %d", q.Len())
+	}
+
+	got := make(chan string)
+	go func() {
+		d, _ := q.Lease(ctx)
+		got <- d.Item.(*groupTask).Name
+		d.Ack()
+	}()
+	select {
+	case name := <-got:
+		t.Errorf("Expected held item not dequeued before ack, given %s", name)
+	case <-time.After(20 * time.Millisecond):
+	}
+	a1.Ack()
+	if name := <-got; name != "a2" {
+		t.Errorf("Expected a2 once a1 is acked, given %s", name)
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+}
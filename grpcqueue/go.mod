@@ -0,0 +1,16 @@
+module github.com/mileusna/gopqueue/grpcqueue
+
+go 1.24
+
+require (
+	github.com/mileusna/gopqueue v0.0.0-20261014081040-eb84ca7ebd43
+	google.golang.org/grpc v1.68.0
+	google.golang.org/protobuf v1.35.2
+)
+
+require (
+	golang.org/x/net v0.29.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.18.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240903143218-8af14fe29dc1 // indirect
+)
@@ -0,0 +1,90 @@
+// Package grpcqueue serves a pqueue.Queue over gRPC, so processes
+// written in any language can share one queue. Items travel as
+// opaque payloads with their id and priority, see queue.proto.
+// Clients are created with NewQueueClient.
+package grpcqueue
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative queue.proto
+
+import (
+	"context"
+	"errors"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Less orders items by their priority, lower first.
+func (x *Item) Less(other interface{}) bool {
+	return x.Priority < other.(*Item).Priority
+}
+
+// Id returns the item id, used by EnqueueUnique.
+func (x *Item) Id() interface{} {
+	return x.ItemId
+}
+
+// Server is the QueueServer serving a queue of *Item. Create the
+// queue with stable order to get items of equal priority in the
+// order they have been enqueued.
+type Server struct {
+	UnimplementedQueueServer
+	q *pqueue.Queue
+}
+
+// NewServer returns the server for given queue.
+func NewServer(q *pqueue.Queue) *Server {
+	return &Server{q: q}
+}
+
+// Enqueue puts the item to the queue.
+func (s *Server) Enqueue(ctx context.Context, req *EnqueueRequest) (*EnqueueResponse, error) {
+	if req.Item == nil {
+		return nil, status.Error(codes.InvalidArgument, "Missing item")
+	}
+	if err := s.q.Enqueue(req.Item); err != nil {
+		return nil, statusError(err)
+	}
+	return &EnqueueResponse{Added: true}, nil
+}
+
+// EnqueueUnique puts the item to the queue if its id hasn't been
+// in the queue already.
+func (s *Server) EnqueueUnique(ctx context.Context, req *EnqueueRequest) (*EnqueueResponse, error) {
+	if req.Item == nil {
+		return nil, status.Error(codes.InvalidArgument, "Missing item")
+	}
+	err := s.q.EnqueueUnique(req.Item)
+	if err != nil && err != pqueue.ErrDuplicate {
+		return nil, statusError(err)
+	}
+	return &EnqueueResponse{Added: err == nil}, nil
+}
+
+// Dequeue takes an item from the queue, blocking while the queue is
+// empty until the call is cancelled.
+func (s *Server) Dequeue(ctx context.Context, req *DequeueRequest) (*Item, error) {
+	item, err := s.q.DequeueContext(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return item.(*Item), nil
+}
+
+// Len returns number of enqueued items.
+func (s *Server) Len(ctx context.Context, req *LenRequest) (*LenResponse, error) {
+	return &LenResponse{Len: int64(s.q.Len())}, nil
+}
+
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, pqueue.ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, pqueue.ErrQueueFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
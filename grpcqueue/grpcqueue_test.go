@@ -0,0 +1,70 @@
+package grpcqueue
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dial(t *testing.T, q *pqueue.Queue) QueueClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterQueueServer(srv, NewServer(q))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Expected client connection, given %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewQueueClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	q := pqueue.NewWithOptions(pqueue.WithStableOrder())
+	c := dial(t, q)
+	ctx := context.Background()
+	for _, it := range []*Item{{ItemId: "a", Priority: 2}, {ItemId: "b", Priority: 1, Payload: []byte("x")}} {
+		if _, err := c.Enqueue(ctx, &EnqueueRequest{Item: it}); err != nil {
+			t.Fatalf("Expected item to be enqueued, given %v", err)
+		}
+	}
+	if resp, err := c.EnqueueUnique(ctx, &EnqueueRequest{Item: &Item{ItemId: "a"}}); err != nil || resp.Added {
+		t.Errorf("Expected duplicate not to be added, given %v", err)
+	}
+	if resp, err := c.Len(ctx, &LenRequest{}); err != nil || resp.Len != 2 {
+		t.Errorf("Expected 2 items, given %v", resp)
+	}
+	for _, id := range []string{"b", "a"} {
+		it, err := c.Dequeue(ctx, &DequeueRequest{})
+		if err != nil || it.ItemId != id {
+			t.Fatalf("Expected to dequeue %s, given %v", id, err)
+		}
+		if id == "b" && string(it.Payload) != "x" {
+			t.Errorf("Expected payload to travel with the item")
+		}
+	}
+	dctx, cancel := context.WithTimeout(ctx, 5e7)
+	defer cancel()
+	if _, err := c.Dequeue(dctx, &DequeueRequest{}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected dequeue from empty queue to time out, given %v", err)
+	}
+	q.Close()
+	if _, err := c.Dequeue(ctx, &DequeueRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected closed queue to be unavailable, given %v", err)
+	}
+	if _, err := c.Enqueue(ctx, &EnqueueRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected missing item to be rejected, given %v", err)
+	}
+}
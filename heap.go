@@ -0,0 +1,158 @@
+package pqueue
+
+// The pending entries are kept in a binary heap maintained here
+// rather than with container/heap, so sifting calls less directly
+// instead of going through heap.Interface on every step, and moves
+// entries into place without swapping them one by one, see
+// BenchmarkEnqueueDequeue.
+//
+// While sifting, entries are moved out of place, so a panic of the
+// item's Less would leave the heap broken. Sifting recovers from it,
+// finishes putting every entry somewhere, and only then panics again
+// with the same value, so the heap stays whole, though possibly out
+// of order around the failing item.
+
+// init restores the heap order of all the entries.
+func (s *sorter) init() {
+	if s.ext != nil {
+		s.reset(s.list())
+		return
+	}
+	n := len(s.entries)
+	for i, e := range s.entries {
+		e.index = i
+	}
+	for i := n/2 - 1; i >= 0; i-- {
+		s.down(i, n)
+	}
+	s.repanic()
+}
+
+// push puts the entry to the heap.
+func (s *sorter) push(e *entry) {
+	if s.ext != nil {
+		s.ext.Push(e.element())
+		return
+	}
+	s.entries = append(s.entries, e)
+	s.up(len(s.entries) - 1)
+	s.repanic()
+}
+
+// pop takes the top entry from the heap. It returns nil when the
+// heap is empty.
+func (s *sorter) pop() *entry {
+	if s.ext != nil {
+		el := s.ext.Pop()
+		if el == nil {
+			return nil
+		}
+		el.e.index = -1
+		return el.e
+	}
+	if len(s.entries) == 0 {
+		return nil
+	}
+	return s.remove(0)
+}
+
+// remove takes the entry at given index out of the heap.
+func (s *sorter) remove(i int) *entry {
+	n := len(s.entries) - 1
+	e := s.entries[i]
+	last := s.entries[n]
+	s.entries[n] = nil
+	s.entries = s.entries[:n]
+	e.index = -1
+	if i < n {
+		s.move(last, i)
+		s.fix(i)
+	}
+	return e
+}
+
+// fix puts the entry at given index back in order, once it has
+// changed.
+func (s *sorter) fix(i int) {
+	if !s.down(i, len(s.entries)) {
+		s.up(i)
+	}
+	s.repanic()
+}
+
+// up moves the entry at index j towards the top while it's less
+// than its parent.
+func (s *sorter) up(j int) {
+	e := s.entries[j]
+	defer func() {
+		if r := recover(); r != nil {
+			s.keep(r)
+			s.move(e, j)
+		}
+	}()
+	for j > 0 {
+		i := (j - 1) / 2
+		parent := s.entries[i]
+		if !s.less(e, parent) {
+			break
+		}
+		s.move(parent, j)
+		j = i
+	}
+	s.move(e, j)
+}
+
+// down moves the entry at index i0 towards the bottom of the first n
+// entries while one of its children is less than it. It tells if the
+// entry has moved.
+func (s *sorter) down(i0, n int) (moved bool) {
+	e := s.entries[i0]
+	i := i0
+	defer func() {
+		if r := recover(); r != nil {
+			s.keep(r)
+			s.move(e, i)
+			moved = i > i0
+		}
+	}()
+	for {
+		j := 2*i + 1
+		if j >= n || j < 0 {
+			break
+		}
+		if j2 := j + 1; j2 < n && s.less(s.entries[j2], s.entries[j]) {
+			j = j2
+		}
+		if !s.less(s.entries[j], e) {
+			break
+		}
+		s.move(s.entries[j], i)
+		i = j
+	}
+	s.move(e, i)
+	return i > i0
+}
+
+func (s *sorter) move(e *entry, i int) {
+	s.entries[i] = e
+	e.index = i
+}
+
+// keep keeps the first value Less has panicked with for repanic.
+// Sifting recovers once per sift, putting the sifted entry to the
+// hole it has left, rather than around every comparison, which
+// would cost more than the comparison itself.
+func (s *sorter) keep(r interface{}) {
+	if s.panicked == nil {
+		s.panicked = r
+	}
+}
+
+// repanic panics with the value Less has panicked with while
+// sifting, if it has.
+func (s *sorter) repanic() {
+	if r := s.panicked; r != nil {
+		s.panicked = nil
+		panic(r)
+	}
+}
@@ -0,0 +1,119 @@
+package pqueue
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestHeap(t *testing.T) {
+	s := new(sorter)
+	var want []int
+	check := func() {
+		for i, e := range s.entries {
+			if e.index != i {
+				t.Fatalf("Expected entry at %d to know its index, given %d", i, e.index)
+			}
+			if i > 0 && s.less(e, s.entries[(i-1)/2]) {
+				t.Fatalf("Expected entry at %d not to be less than its parent", i)
+			}
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		switch op := rand.Intn(4); {
+		case op < 2 || len(s.entries) == 0:
+			x := rand.Intn(100)
+			s.push(&entry{item: NewDummyTask(x)})
+			want = append(want, x)
+		case op == 2:
+			e := s.remove(rand.Intn(len(s.entries)))
+			x := e.item.(*DummyTask).priority
+			for j, y := range want {
+				if y == x {
+					want = append(want[:j], want[j+1:]...)
+					break
+				}
+			}
+		default:
+			e := s.entries[rand.Intn(len(s.entries))]
+			task := e.item.(*DummyTask)
+			for j, y := range want {
+				if y == task.priority {
+					want = append(want[:j], want[j+1:]...)
+					break
+				}
+			}
+			task.priority = rand.Intn(100)
+			want = append(want, task.priority)
+			s.fix(e.index)
+		}
+		check()
+	}
+	sort.Ints(want)
+	for _, x := range want {
+		if e := s.pop(); e.item.(*DummyTask).priority != x || e.index != -1 {
+			t.Fatalf("Expected to pop %d, given %d", x, e.item.(*DummyTask).priority)
+		}
+	}
+	if s.pop() != nil {
+		t.Errorf("Expected nil from empty heap")
+	}
+}
+
+// entryHeap keeps the entries with container/heap, as the queue did
+// before sorter sifted them itself.
+type entryHeap struct {
+	sorter
+}
+
+func (h *entryHeap) Less(i, j int) bool { return h.less(h.entries[i], h.entries[j]) }
+func (h *entryHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *entryHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *entryHeap) Pop() interface{} {
+	n := len(h.entries) - 1
+	e := h.entries[n]
+	h.entries[n] = nil
+	h.entries = h.entries[:n]
+	e.index = -1
+	return e
+}
+
+func BenchmarkEnqueueDequeue(b *testing.B) {
+	const n = 10000
+	entries := make([]*entry, n)
+	for i := range entries {
+		entries[i] = &entry{item: NewDummyTask(rand.Intn(1000))}
+	}
+	b.Run("sorter", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s := new(sorter)
+			for _, e := range entries {
+				s.push(e)
+			}
+			for s.pop() != nil {
+			}
+		}
+	})
+	b.Run("container/heap", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			h := new(entryHeap)
+			for _, e := range entries {
+				heap.Push(h, e)
+			}
+			for h.Len() > 0 {
+				heap.Pop(h)
+			}
+		}
+	})
+}
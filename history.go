@@ -0,0 +1,347 @@
+package pqueue
+
+import (
+	"container/list"
+	"time"
+)
+
+// DedupStore keeps ids of items that have been enqueued, which
+// EnqueueUnique looks at to refuse duplicates. By default ids are
+// kept in a map, but any store can be plugged in with
+// WithDedupStore, eg. one shared by several processes.
+//
+// Store may also implement Touch(id) to learn about ids seen again,
+// Len() int to report its size, Reserve(n int) to make room for n
+// ids and Each(fn func(id interface{})) to list the ids, which is
+// used when exporting the queue state.
+type DedupStore interface {
+	// Seen tells if the id has been added. It's called under the
+	// read lock of the queue, so it may run concurrently with
+	// other Seen calls and must not change the store.
+	Seen(id interface{}) bool
+	// Add remembers the id as seen.
+	Add(id interface{})
+	// Remove forgets the id.
+	Remove(id interface{})
+	// Clear forgets all the ids.
+	Clear()
+}
+
+// WithDedupStore keeps the history in given store.
+func WithDedupStore(s DedupStore) Option {
+	return func(q *Queue) {
+		q.history = s
+	}
+}
+
+// WithHasher keeps hashes of items made by given function in the
+// history instead of their ids, so EnqueueUnique refuses items of
+// the same content whatever their id, and long ids don't have to be
+// kept around. IdExists and RemoveFromHistory take the hash then.
+// Items with the same hash are taken for duplicates, so the hash has
+// to be wide enough to make collisions unlikely.
+func WithHasher(hash func(QueueItem) uint64) Option {
+	return func(q *Queue) {
+		q.hasher = hash
+	}
+}
+
+// WithNormalize turns ids to their canonical form with given
+// function before they are put to or looked up in the history, eg.
+// lowercasing URLs, so EnqueueUnique deduplicates items whose ids
+// differ only in form. Pending items keep their own ids. It has no
+// effect with WithHasher.
+func WithNormalize(normalize func(id interface{}) interface{}) Option {
+	return func(q *Queue) {
+		q.normalize = normalize
+	}
+}
+
+// historyID returns what the history keeps for the item.
+func (q *Queue) historyID(item QueueItem) interface{} {
+	if q.hasher != nil {
+		return q.hasher(item)
+	}
+	return q.historyKey(item.Id())
+}
+
+// historyKey returns what the history keeps for given id.
+func (q *Queue) historyKey(id interface{}) interface{} {
+	if q.normalize != nil && q.hasher == nil {
+		return q.normalize(id)
+	}
+	return id
+}
+
+// historyTouch tells the store the id has been seen again.
+func (q *Queue) historyTouch(id interface{}) {
+	if s, ok := q.history.(interface{ Touch(id interface{}) }); ok {
+		s.Touch(id)
+	}
+}
+
+// historyLen returns number of ids in the store, -1 if unknown.
+func (q *Queue) historyLen() int {
+	if s, ok := q.history.(interface{ Len() int }); ok {
+		return s.Len()
+	}
+	return -1
+}
+
+// historyReserve asks the store to make room for n ids.
+func (q *Queue) historyReserve(n int) {
+	if s, ok := q.history.(interface{ Reserve(n int) }); ok {
+		s.Reserve(n)
+	}
+}
+
+// historyEach calls fn for every id in the store that can be listed.
+func (q *Queue) historyEach(fn func(id interface{})) {
+	if s, ok := q.history.(interface{ Each(fn func(id interface{})) }); ok {
+		s.Each(fn)
+	}
+}
+
+// HistoryLen returns number of ids in the history, or -1 when the
+// history store doesn't tell it, like Stats does.
+func (q *Queue) HistoryLen() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.historyLen()
+}
+
+// ExportHistory returns ids in the history, least recently seen
+// first, so the history can be stored apart from pending items and
+// brought back with ImportHistory. Bloom filter history and other
+// stores which can't be listed return nil.
+func (q *Queue) ExportHistory() (ids []interface{}) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if n := q.historyLen(); n > 0 {
+		ids = make([]interface{}, 0, n)
+	}
+	q.historyEach(func(id interface{}) {
+		ids = append(ids, id)
+	})
+	return
+}
+
+// ImportHistory adds given ids to the history, so items with them
+// are not enqueued by EnqueueUnique. Pending items stay as they are.
+func (q *Queue) ImportHistory(ids []interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.historyReserve(q.historyLen() + len(ids))
+	for _, id := range ids {
+		id = q.historyKey(id)
+		q.history.Add(id)
+		q.logHistory(walHistory, id)
+	}
+}
+
+// history keeps ids of items that have been enqueued, so they
+// can be enqueued only once. With ttl set, ids are forgotten
+// once they get older than ttl. With max set, least recently
+// seen ids are forgotten once there are more than max of them.
+type history struct {
+	ids   map[interface{}]*list.Element
+	order *list.List // most recently seen first
+	ttl   time.Duration
+	max   int
+	swept time.Time
+	now   func() time.Time
+	// peak is the most ids in the map since it's been rebuilt,
+	// reserved the room asked for with Reserve
+	peak     int
+	reserved int
+	// holding counts the ids with pending entries
+	holding int
+}
+
+// historyEntry is the value of history order elements. When the
+// queue keeps its index of pending entries in the history, see
+// Queue.index, ids of pending items stay in the map even once they
+// are forgotten, though out of the order, so every id is kept once.
+type historyEntry struct {
+	id        interface{}
+	added     time.Time
+	pending   []*entry
+	forgotten bool
+}
+
+func newHistory() *history {
+	return &history{ids: make(map[interface{}]*list.Element), order: list.New(), now: time.Now}
+}
+
+// Add remembers the id as seen right now.
+func (h *history) Add(id interface{}) {
+	now := h.now()
+	if el, ok := h.ids[id]; !ok {
+		h.ids[id] = h.order.PushFront(&historyEntry{id: id, added: now})
+		h.peak = max(h.peak, len(h.ids))
+	} else if he := el.Value.(*historyEntry); he.forgotten {
+		he.added, he.forgotten = now, false
+		h.ids[id] = h.order.PushFront(he)
+	} else {
+		he.added = now
+		h.order.MoveToFront(el)
+	}
+	for h.max > 0 && h.order.Len() > h.max {
+		h.removeElement(h.order.Back())
+	}
+	if h.ttl > 0 && now.Sub(h.swept) >= h.ttl {
+		h.sweep(now)
+	}
+}
+
+// Seen tells if the id has been added and hasn't expired yet.
+func (h *history) Seen(id interface{}) bool {
+	el, ok := h.ids[id]
+	return ok && !el.Value.(*historyEntry).forgotten && !h.expired(el, h.now())
+}
+
+// Touch marks the id as recently seen, so it's the last one to
+// be forgotten.
+func (h *history) Touch(id interface{}) {
+	if el, ok := h.ids[id]; ok {
+		h.order.MoveToFront(el)
+	}
+}
+
+func (h *history) Remove(id interface{}) {
+	if el, ok := h.ids[id]; ok && !el.Value.(*historyEntry).forgotten {
+		h.removeElement(el)
+	}
+}
+
+func (h *history) removeElement(el *list.Element) {
+	he := el.Value.(*historyEntry)
+	h.order.Remove(el)
+	if len(he.pending) > 0 {
+		he.forgotten = true
+		return
+	}
+	delete(h.ids, he.id)
+	h.shrink()
+}
+
+func (h *history) Clear() {
+	ids := make(map[interface{}]*list.Element, h.holding)
+	if h.holding > 0 {
+		for id, el := range h.ids {
+			if he := el.Value.(*historyEntry); len(he.pending) > 0 {
+				he.forgotten = true
+				ids[id] = el
+			}
+		}
+	}
+	h.ids = ids
+	h.order.Init()
+	h.peak = len(ids)
+}
+
+func (h *history) Len() int {
+	return h.order.Len()
+}
+
+// pending returns the queue's pending entries with given id.
+func (h *history) pending(id interface{}) []*entry {
+	if el, ok := h.ids[id]; ok {
+		return el.Value.(*historyEntry).pending
+	}
+	return nil
+}
+
+// setPending sets the queue's pending entries with given id. Ids
+// not in the history are kept only while they have pending entries.
+func (h *history) setPending(id interface{}, entries []*entry) {
+	el, ok := h.ids[id]
+	if !ok {
+		if len(entries) == 0 {
+			return
+		}
+		el = &list.Element{Value: &historyEntry{id: id, forgotten: true}}
+		h.ids[id] = el
+		h.peak = max(h.peak, len(h.ids))
+	}
+	he := el.Value.(*historyEntry)
+	if was, is := len(he.pending) > 0, len(entries) > 0; was != is {
+		if is {
+			h.holding += 1
+		} else {
+			h.holding -= 1
+		}
+	}
+	if len(entries) == 0 {
+		entries = nil
+	}
+	he.pending = entries
+	if entries == nil && he.forgotten {
+		delete(h.ids, id)
+		h.shrink()
+	}
+}
+
+// Reserve makes room for n ids.
+func (h *history) Reserve(n int) {
+	h.reserved = max(h.reserved, n)
+	if len(h.ids) >= n {
+		return
+	}
+	ids := make(map[interface{}]*list.Element, n)
+	for id, el := range h.ids {
+		ids[id] = el
+	}
+	h.ids = ids
+}
+
+// Each calls fn for every id which hasn't expired, least recently
+// seen first.
+func (h *history) Each(fn func(id interface{})) {
+	now := h.now()
+	for el := h.order.Back(); el != nil; el = el.Prev() {
+		if !h.expired(el, now) {
+			fn(el.Value.(*historyEntry).id)
+		}
+	}
+}
+
+func (h *history) expired(el *list.Element, now time.Time) bool {
+	return h.ttl > 0 && now.Sub(el.Value.(*historyEntry).added) >= h.ttl
+}
+
+// sweep forgets all the expired ids, so they don't pile up.
+func (h *history) sweep(now time.Time) {
+	for el := h.order.Front(); el != nil; {
+		next := el.Next()
+		if h.expired(el, now) {
+			h.removeElement(el)
+		}
+		el = next
+	}
+	h.swept = now
+}
+
+// WithDedupTTL makes EnqueueUnique deduplicate items only within
+// given time window. Ids are forgotten from the history once they
+// get older than ttl, so the same id can be enqueued again. It has
+// no effect on bloom filter history.
+func WithDedupTTL(ttl time.Duration) Option {
+	return func(q *Queue) {
+		if h, ok := q.history.(*history); ok {
+			h.ttl = ttl
+		}
+	}
+}
+
+// WithMaxHistory bounds the history to n ids. Once there are more,
+// the least recently seen ids are forgotten, so memory stays bounded
+// while recent duplicates are still refused by EnqueueUnique. It has
+// no effect on bloom filter history.
+func WithMaxHistory(n int) Option {
+	return func(q *Queue) {
+		if h, ok := q.history.(*history); ok {
+			h.max = n
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package pqueue
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupTTL(t *testing.T) {
+	q := NewWithOptions(WithDedupTTL(time.Hour))
+	now := time.Now()
+	q.history.(*history).now = func() time.Time { return now }
+	task := NewDummyTask(1)
+	if err := q.EnqueueUnique(task); err != nil {
+		t.Errorf("Expected to enqueue the task")
+	}
+	now = now.Add(59 * time.Minute)
+	if err := q.EnqueueUnique(task); err == nil {
+		t.Errorf("Expected task to be deduplicated within ttl")
+	}
+	now = now.Add(time.Minute)
+	if err := q.EnqueueUnique(task); err != nil {
+		t.Errorf("Expected task to be enqueued again after ttl")
+	}
+}
+
+func TestDedupTTLSweep(t *testing.T) {
+	q := NewWithOptions(WithDedupTTL(time.Hour))
+	now := time.Now()
+	q.history.(*history).now = func() time.Time { return now }
+	for i := 0; i < 10; i += 1 {
+		q.EnqueueUnique(NewDummyTask(i))
+	}
+	now = now.Add(2 * time.Hour)
+	q.EnqueueUnique(NewDummyTask(10))
+	if q.historyLen() != 1 {
+		t.Errorf("Expected expired ids to be swept, %d left", q.historyLen())
+	}
+}
+
+func TestMaxHistory(t *testing.T) {
+	q := NewWithOptions(WithMaxHistory(2))
+	tasks := []*DummyTask{NewDummyTask(1), NewDummyTask(2), NewDummyTask(3)}
+	q.EnqueueUnique(tasks[0])
+	q.EnqueueUnique(tasks[1])
+	// seeing the first task again keeps it in the history
+	q.EnqueueUnique(tasks[0])
+	q.EnqueueUnique(tasks[2])
+	if q.historyLen() != 2 {
+		t.Errorf("Expected history to be bounded, %d ids kept", q.historyLen())
+	}
+	if !q.IdExists(tasks[0].Id()) || !q.IdExists(tasks[2].Id()) {
+		t.Errorf("Expected recently seen ids to be kept")
+	}
+	if q.IdExists(tasks[1].Id()) {
+		t.Errorf("Expected least recently seen id to be forgotten")
+	}
+}
+
+// sharedStore is a store with no optional methods, like one
+// shared by several queues.
+type sharedStore map[interface{}]bool
+
+func (s sharedStore) Seen(id interface{}) bool { return s[id] }
+func (s sharedStore) Add(id interface{})       { s[id] = true }
+func (s sharedStore) Remove(id interface{})    { delete(s, id) }
+func (s sharedStore) Clear() {
+	for id := range s {
+		delete(s, id)
+	}
+}
+
+func TestDedupStore(t *testing.T) {
+	store := sharedStore{}
+	first := NewWithOptions(WithDedupStore(store))
+	second := NewWithOptions(WithDedupStore(store))
+	task := NewDummyTask(1)
+	if err := first.EnqueueUnique(task); err != nil {
+		t.Errorf("Expected to enqueue the task")
+	}
+	if err := second.EnqueueUnique(task); err == nil {
+		t.Errorf("Expected shared store to deduplicate across queues")
+	}
+	second.RemoveFromHistory(task.Id())
+	if err := second.EnqueueUnique(task); err != nil {
+		t.Errorf("Expected to enqueue the task once removed from store")
+	}
+	if second.historyLen() != -1 {
+		t.Errorf("Expected unknown history len")
+	}
+	if len(second.ExportState().History) != 0 {
+		t.Errorf("Expected no history to be exported from unlistable store")
+	}
+}
+
+func TestExportHistory(t *testing.T) {
+	q := New(0)
+	for i := 1; i <= 3; i++ {
+		q.EnqueueUnique(&stateTask{Name: fmt.Sprint(i), Priority: i})
+	}
+	ids := q.ExportHistory()
+	if len(ids) != 3 || ids[0] != q.Dequeue().Id() {
+		t.Errorf("Expected 3 ids, least recently seen first, given %v", ids)
+	}
+
+	fresh := New(0)
+	fresh.ImportHistory(ids)
+	if fresh.Len() != 0 {
+		t.Errorf("Expected no items to be imported with the history")
+	}
+	if err := fresh.EnqueueUnique(&stateTask{Name: "2"}); err == nil {
+		t.Errorf("Expected imported id to deduplicate")
+	}
+	if err := fresh.EnqueueUnique(&stateTask{Name: "4"}); err != nil {
+		t.Errorf("Expected new id to be enqueued")
+	}
+	if NewWithOptions(WithBloomHistory(10, 0.01)).ExportHistory() != nil {
+		t.Errorf("Expected no history to be exported from bloom filter")
+	}
+}
+
+func TestHasher(t *testing.T) {
+	q := NewWithOptions(WithHasher(func(item QueueItem) uint64 {
+		return uint64(item.(*stateTask).Priority)
+	}))
+	if err := q.EnqueueUnique(&stateTask{Name: "a", Priority: 1}); err != nil {
+		t.Errorf("Expected to enqueue the first item")
+	}
+	if err := q.EnqueueUnique(&stateTask{Name: "b", Priority: 1}); err == nil {
+		t.Errorf("Expected item of the same content to be refused")
+	}
+	if !q.ItemExists(&stateTask{Name: "c", Priority: 1}) || !q.IdExists(uint64(1)) || q.IdExists("a") {
+		t.Errorf("Expected history to keep the hash instead of the id")
+	}
+	q.RemoveFromHistory(uint64(1))
+	if err := q.EnqueueUnique(&stateTask{Name: "b", Priority: 1}); err != nil {
+		t.Errorf("Expected to enqueue the item once its hash is forgotten")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	q := NewWithOptions(WithNormalize(func(id interface{}) interface{} {
+		return strings.ToLower(id.(string))
+	}))
+	q.EnqueueUnique(&stateTask{Name: "HTTP://Example.com"})
+	if err := q.EnqueueUnique(&stateTask{Name: "http://example.com"}); err == nil {
+		t.Errorf("Expected id of another form to be refused")
+	}
+	if !q.IdExists("http://EXAMPLE.com") {
+		t.Errorf("Expected IdExists to normalize the id")
+	}
+	if task := q.Dequeue().(*stateTask); task.Name != "HTTP://Example.com" {
+		t.Errorf("Expected pending item to keep its id, given %s", task.Name)
+	}
+	q.RemoveFromHistory("Http://Example.Com")
+	if q.IdExists("http://example.com") {
+		t.Errorf("Expected RemoveFromHistory to normalize the id")
+	}
+}
+
+func TestHistoryIndex(t *testing.T) {
+	q := NewWithOptions(WithMaxHistory(2))
+	for _, name := range []string{"a", "b", "c"} {
+		q.EnqueueUnique(&stateTask{name, 1})
+	}
+	if len(q.active) != 0 {
+		t.Errorf("Expected pending ids to be kept in the history only")
+	}
+	q.RemoveFromHistory("b")
+	if !q.Contains("a") || !q.Contains("b") || q.IdExists("a") || q.IdExists("b") {
+		t.Errorf("Expected forgotten ids to stay pending")
+	}
+	if h := q.history.(*history); h.Len() != 1 {
+		t.Errorf("Expected 1 id in history, given %d", h.Len())
+	}
+	if err := q.EnqueueUnique(&stateTask{"a", 2}); err != nil {
+		t.Errorf("Expected forgotten id to be enqueued again")
+	}
+	q.ClearHistory()
+	if got := len(q.Items()); got != 4 || !q.Contains("c") {
+		t.Errorf("Expected items to stay pending once history is cleared, given %d", got)
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+	for q.Len() > 0 {
+		q.Dequeue()
+	}
+	if h := q.history.(*history); len(h.ids) != 0 {
+		t.Errorf("Expected forgotten ids gone once dequeued, given %d", len(h.ids))
+	}
+}
@@ -0,0 +1,105 @@
+package pqueue
+
+import "sync"
+
+// hooks holds the lifecycle callbacks and the calls waiting to be
+// run. Calls are run in order by a single goroutine, which runs
+// only while there are calls waiting.
+type hooks struct {
+	enqueue   []func(QueueItem)
+	dequeue   []func(QueueItem)
+	reject    []func(QueueItem)
+	duplicate []func(QueueItem)
+	late      []func(QueueItem)
+	cancel    []func(QueueItem)
+
+	mu      sync.Mutex
+	pending []hookCall
+	running bool
+	idle    sync.Cond
+}
+
+type hookCall struct {
+	fns  []func(QueueItem)
+	item QueueItem
+}
+
+// OnEnqueue registers fn to be called for every item put to the
+// queue. Callbacks are called outside the queue lock, in a separate
+// goroutine, in the order things happened, so they can call the
+// queue, but they may run after Enqueue has returned. Slow
+// callbacks don't slow the queue down, calls just pile up.
+func (q *Queue) OnEnqueue(fn func(QueueItem)) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.hooks.enqueue = append(q.hooks.enqueue, fn)
+}
+
+// OnDequeue registers fn to be called for every item taken from the
+// queue, see OnEnqueue.
+func (q *Queue) OnDequeue(fn func(QueueItem)) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.hooks.dequeue = append(q.hooks.dequeue, fn)
+}
+
+// OnReject registers fn to be called for every item rejected by the
+// full queue, see OnEnqueue.
+func (q *Queue) OnReject(fn func(QueueItem)) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.hooks.reject = append(q.hooks.reject, fn)
+}
+
+// OnDuplicate registers fn to be called for every item not enqueued
+// by EnqueueUnique or EnqueueIfNotQueued because its id has been
+// already seen, see OnEnqueue.
+func (q *Queue) OnDuplicate(fn func(QueueItem)) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.hooks.duplicate = append(q.hooks.duplicate, fn)
+}
+
+// WaitHooks blocks until all the callbacks called so far have
+// returned.
+func (q *Queue) WaitHooks() {
+	h := &q.hooks
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for h.running {
+		h.idle.Wait()
+	}
+}
+
+// fire schedules calls of given callbacks. Must be called with the
+// queue locked, so calls keep the order of the queue changes.
+func (h *hooks) fire(fns []func(QueueItem), item QueueItem) {
+	if len(fns) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pending = append(h.pending, hookCall{fns, item})
+	if !h.running {
+		h.running = true
+		go h.run()
+	}
+}
+
+func (h *hooks) run() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for len(h.pending) > 0 {
+		calls := h.pending
+		h.pending = nil
+		h.mu.Unlock()
+		for _, c := range calls {
+			for _, fn := range c.fns {
+				fn(c.item)
+			}
+		}
+		h.mu.Lock()
+	}
+	h.running = false
+	h.idle.Broadcast()
+}
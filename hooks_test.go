@@ -0,0 +1,34 @@
+package pqueue
+
+import "testing"
+
+func TestHooks(t *testing.T) {
+	q := New(1)
+	var calls []string
+	record := func(kind string) func(QueueItem) {
+		return func(item QueueItem) {
+			calls = append(calls, kind)
+			// callbacks run outside the lock
+			q.Len()
+		}
+	}
+	q.OnEnqueue(record("enqueue"))
+	q.OnDequeue(record("dequeue"))
+	q.OnReject(record("reject"))
+	q.OnDuplicate(record("duplicate"))
+	task := NewDummyTask(1)
+	q.EnqueueUnique(task)
+	q.EnqueueUnique(task)
+	q.Enqueue(NewDummyTask(2))
+	q.Dequeue()
+	q.WaitHooks()
+	expected := []string{"enqueue", "duplicate", "reject", "dequeue"}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected %v calls, given %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Expected %v calls, given %v", expected, calls)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package pqueue
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Items returns a copy of the pending items in priority order,
+// followed by delayed items in order of their ready time. The queue
+// stays untouched, so it's meant for inspection, eg. by dashboards
+// and debug endpoints. Snapshot is the one for persistence.
+func (q *Queue) Items() []QueueItem {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	entries := q.items.sorted()
+	delayed := append(timeHeap(nil), q.delayed...)
+	sort.Slice(delayed, delayed.Less)
+	items := make([]QueueItem, 0, len(entries)+len(delayed))
+	for _, e := range append(entries, delayed...) {
+		items = append(items, e.item)
+	}
+	return items
+}
+
+// ForEach calls fn for every pending item, delayed ones included,
+// in no particular order, until fn returns false. The queue stays
+// locked meanwhile, so fn must not call the queue.
+func (q *Queue) ForEach(fn func(QueueItem) bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	done := false
+	q.items.each(func(e *entry) bool {
+		done = !fn(e.item)
+		return !done
+	})
+	if done {
+		return
+	}
+	for _, e := range q.delayed {
+		if !fn(e.item) {
+			return
+		}
+	}
+}
+
+// RemoveWhere removes all the pending items, delayed ones included,
+// for which pred returns true, and returns how many were removed.
+// The order is restored once, after all of them are gone, so it's
+// much cheaper than calling Remove for each of them. The queue
+// stays locked meanwhile, so pred must not call the queue.
+func (q *Queue) RemoveWhere(pred func(QueueItem) bool) (n int) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	entries, removed := q.removeWhere(q.items.list(), pred)
+	if removed > 0 {
+		q.items.reset(entries)
+		n += removed
+	}
+	q.delayed, removed = q.removeWhere(q.delayed, pred)
+	if removed > 0 {
+		heap.Init(&q.delayed)
+		q.armDelayTimer()
+		n += removed
+	}
+	return
+}
+
+// removeWhere filters entries in place, forgetting the removed ones.
+func (q *Queue) removeWhere(entries []*entry, pred func(QueueItem) bool) ([]*entry, int) {
+	kept := entries[:0]
+	for _, e := range entries {
+		if !pred(e.item) {
+			e.index = len(kept)
+			kept = append(kept, e)
+			continue
+		}
+		e.index = -1
+		e.delayed = false
+		q.untrack(e)
+		q.emit(Removed, e.item)
+	}
+	clear(entries[len(kept):])
+	return kept, len(entries) - len(kept)
+}
+
+// Iterator walks the pending items of the queue as they were when
+// Iterator was called, in the order Items returns them. The queue is
+// locked only while the snapshot is taken, and the items are put in
+// order one by one as the iterator goes on, so slow consumers like
+// exports don't hold up Enqueue and Dequeue. Items themselves are
+// shared with the queue, not copied.
+type Iterator struct {
+	items   sorter
+	delayed timeHeap
+	item    QueueItem
+}
+
+// Iterator returns an iterator over a snapshot of the pending items,
+// delayed ones included:
+//
+//	for it := q.Iterator(); it.Next(); {
+//		export(it.Item())
+//	}
+func (q *Queue) Iterator() *Iterator {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	it := &Iterator{items: sorter{ranked: q.items.ranked, stable: q.items.stable, descending: q.items.descending, itemLess: q.items.itemLess}}
+	// entries are copied, so the queue can change them meanwhile,
+	// and they stay in heap order
+	entries := q.items.list()
+	it.items.entries = make([]*entry, len(entries))
+	for i, e := range entries {
+		x := *e
+		it.items.entries[i] = &x
+	}
+	if q.items.ext != nil {
+		it.items.init()
+	}
+	it.delayed = make(timeHeap, len(q.delayed))
+	for i, e := range q.delayed {
+		x := *e
+		it.delayed[i] = &x
+	}
+	return it
+}
+
+// Next moves the iterator to the next item, returning false when
+// there are no more items.
+func (it *Iterator) Next() bool {
+	if e := it.items.pop(); e != nil {
+		it.item = e.item
+	} else if len(it.delayed) > 0 {
+		it.item = heap.Pop(&it.delayed).(*entry).item
+	} else {
+		it.item = nil
+		return false
+	}
+	return true
+}
+
+// Item returns the current item of the iterator.
+func (it *Iterator) Item() QueueItem {
+	return it.item
+}
+
+// Len returns number of items the iterator has yet to walk.
+func (it *Iterator) Len() int {
+	return it.items.Len() + len(it.delayed)
+}
+
+// top returns up to n best pending entries, in order, without taking
+// them from the heap. Must be called with the queue locked.
+func (q *Queue) top(n int) (top []*entry) {
+	if n > 0 {
+		q.walk(func(e *entry) bool {
+			top = append(top, e)
+			return len(top) < n
+		})
+	}
+	return
+}
+
+// walk calls fn for the pending entries in order, until fn returns
+// false, without taking them from the heap. It walks the heap from
+// the root, always taking the best of the entries whose parents have
+// been taken, so it's cheap when it stops early. Must be called with
+// the queue locked.
+func (q *Queue) walk(fn func(e *entry) bool) {
+	s := q.items
+	if s.ext != nil {
+		for _, e := range s.sorted() {
+			if !fn(e) {
+				return
+			}
+		}
+		return
+	}
+	var next []int
+	if s.Len() > 0 {
+		next = append(next, 0)
+	}
+	for len(next) > 0 {
+		best := 0
+		for i := 1; i < len(next); i++ {
+			if s.less(s.entries[next[i]], s.entries[next[best]]) {
+				best = i
+			}
+		}
+		j := next[best]
+		next[best] = next[len(next)-1]
+		next = next[:len(next)-1]
+		if !fn(s.entries[j]) {
+			return
+		}
+		for _, c := range []int{2*j + 1, 2*j + 2} {
+			if c < s.Len() {
+				next = append(next, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestItems(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{4, 2, 5, 1, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.EnqueueAfter(NewDummyTask(0), time.Hour)
+	items := q.Items()
+	for i, x := range []int{1, 2, 3, 4, 5, 0} {
+		if task := items[i].(*DummyTask); task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+	items[0] = nil
+	if q.Len() != 6 || q.Dequeue().(*DummyTask).priority != 1 {
+		t.Errorf("Expected queue to stay untouched")
+	}
+}
+
+func TestForEach(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{4, 2, 5, 1, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.EnqueueAfter(NewDummyTask(6), time.Hour)
+	sum := 0
+	q.ForEach(func(item QueueItem) bool {
+		sum += item.(*DummyTask).priority
+		return true
+	})
+	if sum != 21 {
+		t.Errorf("Expected to visit all the items, given sum %d", sum)
+	}
+	visited := 0
+	q.ForEach(func(QueueItem) bool {
+		visited++
+		return visited < 2
+	})
+	if visited != 2 {
+		t.Errorf("Expected to stop after 2 items, %d visited", visited)
+	}
+}
+
+func TestRemoveWhere(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{4, 2, 5, 1, 3, 6} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.EnqueueAfter(NewDummyTask(8), 5e7)
+	q.EnqueueAfter(NewDummyTask(7), 5e7)
+	even := func(item QueueItem) bool {
+		return item.(*DummyTask).priority%2 == 0
+	}
+	if n := q.RemoveWhere(even); n != 4 {
+		t.Errorf("Expected to remove 4 items, %d removed", n)
+	}
+	for _, x := range []int{1, 3, 5, 7} {
+		if task := q.Dequeue().(*DummyTask); task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+	if q.RemoveWhere(even) != 0 {
+		t.Errorf("Expected nothing to remove from empty queue")
+	}
+}
+
+func TestIterator(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{4, 2, 5, 1, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.EnqueueAfter(NewDummyTask(0), time.Hour)
+	it := q.Iterator()
+	q.Dequeue()
+	q.Enqueue(NewDummyTask(-1))
+	if it.Len() != 6 {
+		t.Errorf("Expected 6 items to walk, given %d", it.Len())
+	}
+	var got []int
+	for it.Next() {
+		got = append(got, it.Item().(*DummyTask).priority)
+	}
+	for i, x := range []int{1, 2, 3, 4, 5, 0} {
+		if i >= len(got) || got[i] != x {
+			t.Errorf("Expected the snapshot in order, given %v", got)
+			break
+		}
+	}
+	if it.Item() != nil || q.Len() != 6 {
+		t.Errorf("Expected walking the snapshot not to touch the queue")
+	}
+}
@@ -0,0 +1,107 @@
+package pqueue
+
+import "context"
+
+// IntQueue is a priority queue of values of any type, each enqueued
+// with an int priority, so neither QueueItem nor less function has
+// to be written:
+//
+//	q := pqueue.NewIntQueue[string]()
+//	q.Enqueue("later", 2)
+//	q.Enqueue("now", 1)
+//	s, _ := q.Dequeue() // "now"
+//
+// Values with lower priority are dequeued first, values of equal
+// priority in no particular order, unless WithStableOrder is given.
+// Every enqueued value is unique. It's a thin wrapper around Queue,
+// which does all the work.
+type IntQueue[T any] struct {
+	q *Queue
+}
+
+// intItem adapts a value and its priority to QueueItem.
+type intItem[T any] struct {
+	v        T
+	priority int
+}
+
+func (it *intItem[T]) itemPriority() int {
+	return it.priority
+}
+
+func (it *intItem[T]) Less(other interface{}) bool {
+	return it.priority < other.(prioritized).itemPriority()
+}
+
+func (it *intItem[T]) Id() interface{} {
+	return it
+}
+
+// NewIntQueue creates an unlimited queue of values ordered by the
+// priority they are enqueued with.
+func NewIntQueue[T any](opts ...Option) *IntQueue[T] {
+	return &IntQueue[T]{q: NewWithOptions(opts...)}
+}
+
+// Untyped returns the underlying queue, for features not wrapped by
+// IntQueue. Its items are not the enqueued values themselves.
+func (iq *IntQueue[T]) Untyped() *Queue {
+	return iq.q
+}
+
+// Enqueue puts given value to the queue with given priority.
+func (iq *IntQueue[T]) Enqueue(v T, priority int) error {
+	return iq.q.Enqueue(&intItem[T]{v, priority})
+}
+
+// Dequeue takes a value from the queue, blocking while it's empty.
+// Once the queue is closed and drained it returns zero value and
+// false.
+func (iq *IntQueue[T]) Dequeue() (v T, ok bool) {
+	item, ok := iq.q.Dequeue().(*intItem[T])
+	if ok {
+		v = item.v
+	}
+	return
+}
+
+// DequeueContext takes a value from the queue, blocking while the
+// queue is empty until the context is done.
+func (iq *IntQueue[T]) DequeueContext(ctx context.Context) (v T, err error) {
+	item, err := iq.q.DequeueContext(ctx)
+	if err == nil {
+		v = item.(*intItem[T]).v
+	}
+	return
+}
+
+// TryDequeue takes a value from the queue without blocking. It
+// returns false when the queue is empty.
+func (iq *IntQueue[T]) TryDequeue() (v T, ok bool) {
+	item, ok := iq.q.TryDequeue()
+	if ok {
+		v = item.(*intItem[T]).v
+	}
+	return
+}
+
+// Peek returns the value that would be dequeued next and its
+// priority, without taking it from the queue.
+func (iq *IntQueue[T]) Peek() (v T, priority int, ok bool) {
+	item, ok := iq.q.Peek()
+	if ok {
+		it := item.(*intItem[T])
+		v, priority = it.v, it.priority
+	}
+	return
+}
+
+// Len returns number of enqueued values.
+func (iq *IntQueue[T]) Len() int {
+	return iq.q.Len()
+}
+
+// Close closes the queue.
+func (iq *IntQueue[T]) Close() {
+	iq.q.Close()
+}
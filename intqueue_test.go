@@ -0,0 +1,26 @@
+package pqueue
+
+import "testing"
+
+func TestIntQueue(t *testing.T) {
+	q := NewIntQueue[string](WithStableOrder())
+	q.Enqueue("c", 3)
+	q.Enqueue("a", 1)
+	q.Enqueue("b", 2)
+	q.Enqueue("a", 1)
+	if v, p, ok := q.Peek(); !ok || v != "a" || p != 1 {
+		t.Errorf("Expected to peek a with priority 1, given %q %d", v, p)
+	}
+	if q.Len() != 4 {
+		t.Errorf("Expected equal values enqueued twice, given %d", q.Len())
+	}
+	for _, x := range []string{"a", "a", "b", "c"} {
+		if v, ok := q.TryDequeue(); !ok || v != x {
+			t.Errorf("Expected %q, given %q", x, v)
+		}
+	}
+	q.Close()
+	if _, ok := q.Dequeue(); ok {
+		t.Errorf("Expected nothing from closed queue")
+	}
+}
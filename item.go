@@ -0,0 +1,44 @@
+package pqueue
+
+// Item is a ready-made QueueItem, to be used as it is, with any
+// payload in Value, or embedded in item types so they don't have to
+// write Less and Id. Items with lower Priority are dequeued first.
+// Items of different types embedding Item can share a queue.
+type Item struct {
+	Priority int
+	ID       interface{}
+	Value    interface{}
+}
+
+// prioritized is implemented by Item and the types embedding it.
+type prioritized interface {
+	itemPriority() int
+}
+
+func (it *Item) itemPriority() int {
+	return it.Priority
+}
+
+func (it *Item) Less(other interface{}) bool {
+	return it.Priority < other.(prioritized).itemPriority()
+}
+
+func (it *Item) Id() interface{} {
+	return it.ID
+}
+
+// Wrap makes a QueueItem of any value, eg. a struct, a map or a
+// scalar, so it can be queued without a type of its own. It's
+// an Item with given priority and id.
+func Wrap(value interface{}, priority int, id interface{}) QueueItem {
+	return &Item{Priority: priority, ID: id, Value: value}
+}
+
+// Unwrap returns the value of the item made by Wrap, or of an Item.
+// Other items are returned as they are.
+func Unwrap(item QueueItem) interface{} {
+	if it, ok := item.(*Item); ok {
+		return it.Value
+	}
+	return item
+}
@@ -0,0 +1,43 @@
+package pqueue
+
+import "testing"
+
+type embeddingTask struct {
+	Item
+	Name string
+}
+
+func TestItem(t *testing.T) {
+	q := New(0)
+	q.Enqueue(&Item{Priority: 3, ID: 1, Value: "three"})
+	q.Enqueue(&embeddingTask{Item{Priority: 1, ID: 2}, "one"})
+	q.Enqueue(&Item{Priority: 2, ID: 3, Value: "two"})
+	if err := q.EnqueueUnique(&Item{Priority: 0, ID: 1}); err == nil {
+		t.Errorf("Expected item with seen ID not to be enqueued")
+	}
+
+	if task, ok := q.Dequeue().(*embeddingTask); !ok || task.Name != "one" {
+		t.Errorf("Expected embedding task first, given %v", task)
+	}
+	for _, value := range []string{"two", "three"} {
+		if item := q.Dequeue().(*Item); item.Value != value {
+			t.Errorf("Expected %s, given %v", value, item.Value)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	q := New(0)
+	q.Enqueue(Wrap(map[string]int{"b": 2}, 2, "b"))
+	q.Enqueue(Wrap(42, 1, "a"))
+	if v := Unwrap(q.Dequeue()); v != 42 {
+		t.Errorf("Expected 42, given %v", v)
+	}
+	if v := Unwrap(q.Dequeue()).(map[string]int); v["b"] != 2 {
+		t.Errorf("Expected the map, given %v", v)
+	}
+	task := &stateTask{"c", 3}
+	if Unwrap(task) != task {
+		t.Errorf("Expected other items unwrapped as they are")
+	}
+}
@@ -0,0 +1,54 @@
+package pqueue
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over a snapshot of the pending items, in
+// the order of Iterator, leaving the queue untouched:
+//
+//	for item := range q.All() {
+//		fmt.Println(item)
+//	}
+func (q *Queue) All() iter.Seq[QueueItem] {
+	return func(yield func(QueueItem) bool) {
+		for it := q.Iterator(); it.Next(); {
+			if !yield(it.Item()) {
+				return
+			}
+		}
+	}
+}
+
+// Consume returns an iterator taking items from the queue with
+// DequeueContext, blocking while the queue is empty. It ends when the
+// context is done or the queue is closed and drained. Breaking out
+// of the loop leaves the rest of the items in the queue:
+//
+//	for item := range q.Consume(ctx) {
+//		process(item)
+//	}
+func (q *Queue) Consume(ctx context.Context) iter.Seq[QueueItem] {
+	return func(yield func(QueueItem) bool) {
+		for {
+			item, err := q.DequeueContext(ctx)
+			if err != nil || !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// HistoryIDs returns an iterator over a snapshot of the ids in the
+// history, in the order of ExportHistory, eg. to export them for
+// analysis. It yields nothing for stores which can't be listed.
+func (q *Queue) HistoryIDs() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for _, id := range q.ExportHistory() {
+			if !yield(id) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAll(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{3, 1, 2} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	var got []int
+	for item := range q.All() {
+		got = append(got, item.(*DummyTask).priority)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 || q.Len() != 3 {
+		t.Errorf("Expected to walk the items in order, given %v", got)
+	}
+}
+
+func TestConsume(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{3, 1, 2, 4} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	var got []int
+	for item := range q.Consume(context.Background()) {
+		if got = append(got, item.(*DummyTask).priority); len(got) == 3 {
+			break
+		}
+	}
+	if len(got) != 3 || got[2] != 3 || q.Len() != 1 {
+		t.Errorf("Expected to consume 3 items, given %v", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n := 0
+	for range q.Consume(ctx) {
+		n += 1
+	}
+	if n != 1 {
+		t.Errorf("Expected to consume the rest until the context is done, given %d", n)
+	}
+}
+
+func TestHistoryIDs(t *testing.T) {
+	q := New(0)
+	for _, name := range []string{"a", "b", "c"} {
+		q.EnqueueUnique(&stateTask{name, 1})
+	}
+	q.Dequeue()
+	if n := q.HistoryLen(); n != 3 {
+		t.Errorf("Expected 3 ids in history, given %d", n)
+	}
+	var ids []interface{}
+	for id := range q.HistoryIDs() {
+		ids = append(ids, id)
+	}
+	if len(ids) != 3 || ids[0] != "a" {
+		t.Errorf("Expected 3 ids, least recently seen first, given %v", ids)
+	}
+}
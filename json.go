@@ -0,0 +1,164 @@
+package pqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrUnregisteredType is returned when an item or id of a type not
+// registered with RegisterJSON is marshaled or unmarshaled.
+var ErrUnregisteredType = errors.New("Type is not registered")
+
+var jsonTypes = struct {
+	sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}{
+	byName: map[string]reflect.Type{
+		"string":  reflect.TypeOf(""),
+		"int":     reflect.TypeOf(0),
+		"int64":   reflect.TypeOf(int64(0)),
+		"uint64":  reflect.TypeOf(uint64(0)),
+		"float64": reflect.TypeOf(0.0),
+		"bool":    reflect.TypeOf(false),
+	},
+	byType: map[reflect.Type]string{},
+}
+
+func init() {
+	for name, t := range jsonTypes.byName {
+		jsonTypes.byType[t] = name
+	}
+}
+
+// RegisterJSON records the concrete type of given value under given
+// name, so queue items and history ids of that type can be encoded
+// with MarshalJSON and read back by UnmarshalJSON. Ids of basic
+// types are registered already.
+func RegisterJSON(name string, value interface{}) {
+	t := reflect.TypeOf(value)
+	jsonTypes.Lock()
+	defer jsonTypes.Unlock()
+	jsonTypes.byName[name] = t
+	jsonTypes.byType[t] = name
+}
+
+// jsonValue is a value tagged with the name of its type.
+type jsonValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+func encodeJSONValue(v interface{}) (jv jsonValue, err error) {
+	jsonTypes.RLock()
+	name, ok := jsonTypes.byType[reflect.TypeOf(v)]
+	jsonTypes.RUnlock()
+	if !ok {
+		return jv, fmt.Errorf("%w: %T", ErrUnregisteredType, v)
+	}
+	jv.Type = name
+	jv.Value, err = json.Marshal(v)
+	return
+}
+
+func decodeJSONValue(jv jsonValue) (interface{}, error) {
+	jsonTypes.RLock()
+	t, ok := jsonTypes.byName[jv.Type]
+	jsonTypes.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnregisteredType, jv.Type)
+	}
+	ptr := t.Kind() == reflect.Pointer
+	if ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t)
+	if err := json.Unmarshal(jv.Value, v.Interface()); err != nil {
+		return nil, err
+	}
+	if ptr {
+		return v.Interface(), nil
+	}
+	return v.Elem().Interface(), nil
+}
+
+// jsonQueue is what MarshalJSON writes.
+type jsonQueue struct {
+	Limit   int         `json:"limit"`
+	Seq     uint64      `json:"seq"`
+	History []jsonValue `json:"history"`
+	Items   []jsonItem  `json:"items"`
+}
+
+type jsonItem struct {
+	jsonValue
+	Producer   string     `json:"producer,omitempty"`
+	Seq        uint64     `json:"seq"`
+	ReadyAt    *time.Time `json:"readyAt,omitempty"`
+	Deliveries int        `json:"deliveries,omitempty"`
+}
+
+// MarshalJSON encodes pending items and the history, like Snapshot
+// does, but readable by humans. Concrete types of items and of ids
+// other than basic types have to be registered with RegisterJSON.
+func (q *Queue) MarshalJSON() ([]byte, error) {
+	state := q.ExportState()
+	jq := jsonQueue{Limit: state.Limit, Seq: state.Seq, History: []jsonValue{}, Items: []jsonItem{}}
+	for _, id := range state.History {
+		jv, err := encodeJSONValue(id)
+		if err != nil {
+			return nil, err
+		}
+		jq.History = append(jq.History, jv)
+	}
+	for _, it := range state.Items {
+		jv, err := encodeJSONValue(it.Item)
+		if err != nil {
+			return nil, err
+		}
+		ji := jsonItem{jsonValue: jv, Producer: it.Producer, Seq: it.Seq, Deliveries: it.Deliveries}
+		if !it.ReadyAt.IsZero() {
+			ji.ReadyAt = &it.ReadyAt
+		}
+		jq.Items = append(jq.Items, ji)
+	}
+	return json.Marshal(jq)
+}
+
+// UnmarshalJSON puts items and history encoded by MarshalJSON to
+// the queue, taking the encoded limit too, like Restore does. The
+// queue has to be created with New or NewWithOptions first.
+func (q *Queue) UnmarshalJSON(data []byte) error {
+	var jq jsonQueue
+	if err := json.Unmarshal(data, &jq); err != nil {
+		return err
+	}
+	history := make([]interface{}, len(jq.History))
+	for i, jv := range jq.History {
+		id, err := decodeJSONValue(jv)
+		if err != nil {
+			return err
+		}
+		history[i] = id
+	}
+	entries := make([]*entry, len(jq.Items))
+	for i, ji := range jq.Items {
+		v, err := decodeJSONValue(ji.jsonValue)
+		if err != nil {
+			return err
+		}
+		item, ok := v.(QueueItem)
+		if !ok {
+			return fmt.Errorf("%w: %s is not a QueueItem", ErrUnregisteredType, ji.Type)
+		}
+		entries[i] = &entry{item: item, id: item.Id(), producer: ji.Producer, seq: ji.Seq, deliveries: ji.Deliveries}
+		if ji.ReadyAt != nil {
+			entries[i].readyAt = *ji.ReadyAt
+		}
+	}
+	return q.restore(jq.Limit, jq.Seq, history, entries)
+}
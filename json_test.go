@@ -0,0 +1,50 @@
+package pqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func init() {
+	RegisterJSON("stateTask", &stateTask{})
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	q := NewWithOptions(WithLimit(5), WithStableOrder())
+	for i, x := range []int{2, 1, 2} {
+		q.EnqueueUnique(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	q.EnqueueAfter(&stateTask{Name: "d", Priority: 0}, 5e7)
+	q.Dequeue()
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("Expected queue to be marshaled, given %v", err)
+	}
+
+	r := NewWithOptions(WithStableOrder())
+	if err := json.Unmarshal(data, r); err != nil {
+		t.Fatalf("Expected queue to be unmarshaled, given %v", err)
+	}
+	if r.Limit != 5 || r.Len() != 3 || !r.IdExists("b") || r.Delayed() != 1 {
+		t.Errorf("Expected limit, items and history to be restored")
+	}
+	time.Sleep(5e7)
+	for _, name := range []string{"d", "a", "c"} {
+		if task := r.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+}
+
+func TestJSONUnregistered(t *testing.T) {
+	q := New(0)
+	q.Enqueue(NewDummyTask(1))
+	if _, err := json.Marshal(q); !errors.Is(err, ErrUnregisteredType) {
+		t.Errorf("Expected unregistered type error, given %v", err)
+	}
+	if err := q.UnmarshalJSON([]byte(`{"items":[{"type":"nope","value":{}}]}`)); !errors.Is(err, ErrUnregisteredType) {
+		t.Errorf("Expected unregistered type error, given %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/mileusna/gopqueue/kafkaqueue
+
+go 1.24
+
+require (
+	github.com/mileusna/gopqueue v0.0.0-20261014081040-eb84ca7ebd43
+	github.com/twmb/franz-go v1.18.0
+	github.com/twmb/franz-go/pkg/kadm v1.14.0
+	github.com/twmb/franz-go/pkg/kfake v0.0.0-20240821035758-b77dd13e2bfa
+)
+
+require (
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	github.com/twmb/franz-go/pkg/kmsg v1.9.0 // indirect
+	golang.org/x/crypto v0.28.0 // indirect
+)
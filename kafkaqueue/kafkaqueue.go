@@ -0,0 +1,236 @@
+// Package kafkaqueue consumes a Kafka topic into a pqueue.Queue,
+// through github.com/twmb/franz-go, so records of Kafka's strictly
+// ordered partitions are processed in priority order. Offsets are
+// committed only once the items of all the records before them are
+// acked, so records of items lost with the process are consumed
+// again after restart.
+package kafkaqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// ErrUnknown is returned by Ack and Nack for items which haven't
+// come from the bridge or have already been acked.
+var ErrUnknown = errors.New("Unknown item")
+
+// Options configure how records become items.
+type Options struct {
+	// Decode turns the record to an item. Records which can't be
+	// decoded are skipped.
+	Decode func(*kgo.Record) (pqueue.QueueItem, error)
+}
+
+// Bridge moves records consumed by a Kafka client to the queue.
+type Bridge struct {
+	q      *pqueue.Queue
+	client *kgo.Client
+	opts   Options
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	pending map[interface{}]*record
+	parts   map[partition][]*record
+	err     error
+
+	// commitMu serializes commits, so a commit never goes back
+	// to an offset below the one committed already
+	commitMu  sync.Mutex
+	committed map[partition]int64
+}
+
+type partition struct {
+	topic string
+	id    int32
+}
+
+// record is a consumed record whose offset isn't committed yet.
+type record struct {
+	rec   *kgo.Record
+	item  pqueue.QueueItem
+	acked bool
+}
+
+// Start starts polling records with given client, which has to be
+// a consumer of the topic with auto commit disabled, see
+// kgo.DisableAutoCommit. Records are put to the queue with
+// EnqueueUnique, so records of items already seen are skipped and
+// committed. While the queue is full polling waits. Call Stop once
+// done.
+func Start(q *pqueue.Queue, client *kgo.Client, opts Options) *Bridge {
+	b := &Bridge{
+		q:         q,
+		client:    client,
+		opts:      opts,
+		done:      make(chan struct{}),
+		pending:   make(map[interface{}]*record),
+		parts:     make(map[partition][]*record),
+		committed: make(map[partition]int64),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	go b.run()
+	return b
+}
+
+func (b *Bridge) run() {
+	defer close(b.done)
+	for {
+		fetches := b.client.PollFetches(b.ctx)
+		if fetches.IsClientClosed() || b.ctx.Err() != nil {
+			return
+		}
+		fetches.EachError(func(topic string, p int32, err error) {
+			b.fail(err)
+		})
+		for it := fetches.RecordIter(); !it.Done(); {
+			if !b.handle(it.Next()) {
+				return
+			}
+		}
+	}
+}
+
+// handle enqueues the record's item. It returns false once the
+// bridge is stopped.
+func (b *Bridge) handle(rec *kgo.Record) bool {
+	r := &record{rec: rec}
+	b.mu.Lock()
+	p := partition{rec.Topic, rec.Partition}
+	b.parts[p] = append(b.parts[p], r)
+	b.mu.Unlock()
+
+	item, err := b.opts.Decode(rec)
+	if err != nil {
+		b.fail(err)
+		b.ack(r)
+		return true
+	}
+	r.item = item
+	for {
+		b.mu.Lock()
+		err := b.q.EnqueueUnique(item)
+		if err == nil {
+			b.pending[item.Id()] = r
+		}
+		b.mu.Unlock()
+		switch {
+		case err == nil:
+			return true
+		case err == pqueue.ErrDuplicate:
+			b.ack(r)
+			return true
+		case err == pqueue.ErrQueueFull:
+			if b.q.WaitForSpace(b.ctx, 1) != nil {
+				return false
+			}
+		default:
+			b.fail(err)
+			return false
+		}
+	}
+}
+
+// ack marks the record acked and commits offsets of the partition
+// up to the first record not acked yet.
+func (b *Bridge) ack(r *record) {
+	b.mu.Lock()
+	r.acked = true
+	p := partition{r.rec.Topic, r.rec.Partition}
+	recs := b.parts[p]
+	i := 0
+	for i < len(recs) && recs[i].acked {
+		i += 1
+	}
+	if i == 0 {
+		b.mu.Unlock()
+		return
+	}
+	last := recs[i-1].rec
+	b.parts[p] = recs[i:]
+	b.mu.Unlock()
+	if err := b.commit(p, last); err != nil {
+		b.fail(err)
+	}
+}
+
+// commit commits the record's offset, unless a later offset of the
+// partition has been committed meanwhile by a concurrent ack.
+func (b *Bridge) commit(p partition, rec *kgo.Record) error {
+	b.commitMu.Lock()
+	defer b.commitMu.Unlock()
+	if at, ok := b.committed[p]; ok && rec.Offset <= at {
+		return nil
+	}
+	if err := b.client.CommitRecords(b.ctx, rec); err != nil {
+		return err
+	}
+	b.committed[p] = rec.Offset
+	return nil
+}
+
+// Ack tells the item has been processed, so its record's offset can
+// be committed.
+func (b *Bridge) Ack(item pqueue.QueueItem) error {
+	r, err := b.take(item)
+	if err != nil {
+		return err
+	}
+	b.ack(r)
+	return nil
+}
+
+// Nack tells the item hasn't been processed, so it's put back to the
+// queue, and its record's offset stays uncommitted.
+func (b *Bridge) Nack(item pqueue.QueueItem) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.pending[item.Id()]
+	if !ok {
+		return ErrUnknown
+	}
+	return b.q.Enqueue(r.item)
+}
+
+func (b *Bridge) take(item pqueue.QueueItem) (*record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.pending[item.Id()]
+	if !ok {
+		return nil, ErrUnknown
+	}
+	delete(b.pending, item.Id())
+	return r, nil
+}
+
+func (b *Bridge) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+// Pending returns number of items not acked yet.
+func (b *Bridge) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// Err returns the last error of polling, decoding or committing.
+func (b *Bridge) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// Stop stops polling. The client stays open.
+func (b *Bridge) Stop() {
+	b.cancel()
+	<-b.done
+}
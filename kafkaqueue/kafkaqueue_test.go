@@ -0,0 +1,163 @@
+package kafkaqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+var options = Options{
+	Decode: func(rec *kgo.Record) (pqueue.QueueItem, error) {
+		t := &task{}
+		return t, json.Unmarshal(rec.Value, t)
+	},
+}
+
+func TestBridge(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.SeedTopics(1, "tasks"))
+	if err != nil {
+		t.Fatalf("Expected cluster, given %v", err)
+	}
+	defer cluster.Close()
+	ctx := context.Background()
+	producer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...))
+	if err != nil {
+		t.Fatalf("Expected producer, given %v", err)
+	}
+	defer producer.Close()
+	for _, tk := range []task{{"a", 3}, {"b", 1}, {"b", 1}, {"c", 2}} {
+		data, _ := json.Marshal(tk)
+		if err := producer.ProduceSync(ctx, &kgo.Record{Topic: "tasks", Value: data}).FirstErr(); err != nil {
+			t.Fatalf("Expected record produced, given %v", err)
+		}
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumerGroup("workers"),
+		kgo.ConsumeTopics("tasks"),
+		kgo.DisableAutoCommit(),
+	)
+	if err != nil {
+		t.Fatalf("Expected consumer, given %v", err)
+	}
+	defer consumer.Close()
+	q := pqueue.New(0)
+	b := Start(q, consumer, options)
+	defer b.Stop()
+	for deadline := time.Now().Add(5 * time.Second); q.Len() < 3 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	committed := func() int64 {
+		offsets, err := kadm.NewClient(producer).FetchOffsets(ctx, "workers")
+		if err != nil {
+			t.Fatalf("Expected offsets, given %v", err)
+		}
+		o, _ := offsets.Lookup("tasks", 0)
+		return o.At
+	}
+	waitCommitted := func(at int64) {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if committed() == at {
+				return
+			}
+		}
+		t.Errorf("Expected offset %d committed, given %d", at, committed())
+	}
+
+	// b and its duplicate come before c, a blocks the partition
+	for _, name := range []string{"b", "c"} {
+		item := q.Dequeue()
+		if item.(*task).Name != name {
+			t.Errorf("Expected %s, given %s", name, item.(*task).Name)
+		}
+		b.Ack(item)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if at := committed(); at > 0 {
+		t.Errorf("Expected nothing committed before a is acked, given %d", at)
+	}
+	item := q.Dequeue()
+	if err := b.Nack(item); err != nil || q.Len() != 1 {
+		t.Errorf("Expected nacked item back in queue, given %v", err)
+	}
+	b.Ack(q.Dequeue())
+	waitCommitted(4)
+	if err := b.Ack(item); err != ErrUnknown {
+		t.Errorf("Expected ErrUnknown for acked item, given %v", err)
+	}
+}
+
+func TestBridgeConcurrentAcks(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.SeedTopics(1, "tasks"))
+	if err != nil {
+		t.Fatalf("Expected cluster, given %v", err)
+	}
+	defer cluster.Close()
+	ctx := context.Background()
+	producer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...))
+	if err != nil {
+		t.Fatalf("Expected producer, given %v", err)
+	}
+	defer producer.Close()
+	const n = 50
+	for i := 0; i < n; i++ {
+		data, _ := json.Marshal(task{fmt.Sprint(i), i})
+		if err := producer.ProduceSync(ctx, &kgo.Record{Topic: "tasks", Value: data}).FirstErr(); err != nil {
+			t.Fatalf("Expected record produced, given %v", err)
+		}
+	}
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumerGroup("workers"),
+		kgo.ConsumeTopics("tasks"),
+		kgo.DisableAutoCommit(),
+	)
+	if err != nil {
+		t.Fatalf("Expected consumer, given %v", err)
+	}
+	defer consumer.Close()
+	q := pqueue.New(0)
+	b := Start(q, consumer, options)
+	defer b.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		item := q.Dequeue()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Ack(item)
+		}()
+	}
+	wg.Wait()
+	offsets, err := kadm.NewClient(producer).FetchOffsets(ctx, "workers")
+	if err != nil {
+		t.Fatalf("Expected offsets, given %v", err)
+	}
+	if o, _ := offsets.Lookup("tasks", 0); o.At != n || b.Err() != nil {
+		t.Errorf("Expected offset %d committed, given %d %v", n, o.At, b.Err())
+	}
+}
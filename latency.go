@@ -0,0 +1,92 @@
+package pqueue
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultLatencyBuckets are the buckets of the latency histogram
+// unless WithLatencyBuckets sets others.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 25 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second,
+	5 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute,
+	30 * time.Minute, time.Hour,
+}
+
+// Histogram counts how long dequeued items waited in the queue,
+// from when they were enqueued, or became ready if delayed, until
+// they were dequeued or leased.
+type Histogram struct {
+	// Buckets are upper bounds of the buckets, ascending.
+	Buckets []time.Duration
+	// Counts holds number of items which waited up to the bound of
+	// the bucket at the same index, so the counts are cumulative,
+	// like Prometheus has them. Items which waited longer than
+	// the last bound are only counted by Count.
+	Counts []uint64
+	// Count and Sum are number of all the items and their total
+	// wait time.
+	Count uint64
+	Sum   time.Duration
+}
+
+// WithLatencyBuckets sets the upper bounds of the latency histogram
+// buckets, see Latency.
+func WithLatencyBuckets(buckets ...time.Duration) Option {
+	return func(q *Queue) {
+		b := slices.Clone(buckets)
+		slices.Sort(b)
+		q.latency = Histogram{Buckets: b, Counts: make([]uint64, len(b))}
+	}
+}
+
+// observe counts an item which waited for d.
+func (h *Histogram) observe(d time.Duration) {
+	h.Count += 1
+	h.Sum += d
+	for i := len(h.Buckets) - 1; i >= 0 && d <= h.Buckets[i]; i -= 1 {
+		h.Counts[i] += 1
+	}
+}
+
+// clone returns a copy of the histogram not sharing its counts.
+func (h Histogram) clone() Histogram {
+	h.Buckets = slices.Clone(h.Buckets)
+	h.Counts = slices.Clone(h.Counts)
+	return h
+}
+
+// Latency returns the histogram of how long dequeued items waited in
+// the queue. It's kept apart from Stats so Stats stays comparable.
+func (q *Queue) Latency() Histogram {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.latency.clone()
+}
+
+// Quantile returns the upper bound of the bucket holding given
+// quantile, between 0 and 1, of the wait times, eg. 0.99 for p99.
+// It returns 0 when nothing has been counted yet, and -1 when the
+// quantile is beyond the last bucket.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := q * float64(h.Count)
+	for i, n := range h.Counts {
+		if float64(n) >= rank {
+			return h.Buckets[i]
+		}
+	}
+	return -1
+}
+
+// dequeued counts the entry taken from the queue.
+func (q *Queue) dequeued(e *entry) {
+	q.latency.observe(q.now().Sub(e.waitingSince()))
+	if q.politeness > 0 {
+		q.taken(e.item)
+	}
+	q.emit(Dequeued, e.item)
+}
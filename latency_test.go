@@ -0,0 +1,33 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatency(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock), WithLatencyBuckets(time.Minute, time.Second))
+	for _, x := range []int{1, 2, 3, 4} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.Dequeue()
+	clock.Advance(2 * time.Second)
+	q.Dequeue()
+	clock.Advance(time.Hour)
+	q.Dequeue()
+	h := q.Latency()
+	if h.Count != 3 || h.Sum != time.Hour+4*time.Second {
+		t.Errorf("Expected 3 items waiting 1h4s, given %d and %v", h.Count, h.Sum)
+	}
+	if h.Buckets[0] != time.Second || h.Counts[0] != 1 || h.Counts[1] != 2 {
+		t.Errorf("Expected cumulative counts 1 and 2, given %v", h.Counts)
+	}
+	if h.Quantile(0.5) != time.Minute || h.Quantile(0.99) != -1 {
+		t.Errorf("Expected p50 within a minute and p99 beyond the buckets")
+	}
+	h.Counts[0] = 10
+	if q.Latency().Counts[0] != 1 {
+		t.Errorf("Expected stats not to share the counts")
+	}
+}
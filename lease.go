@@ -0,0 +1,180 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrLeaseExpired is returned by Ack and Nack when the delivery's
+// visibility timeout has passed and the item went back to the queue,
+// or the delivery has already been acked or nacked.
+var ErrLeaseExpired = errors.New("Lease expired")
+
+// Delivery is an item leased from the queue with Lease. The item
+// stays owned by the consumer until it's acked, nacked or until
+// the visibility timeout passes, when it goes back to the queue.
+type Delivery struct {
+	Item QueueItem
+
+	q     *Queue
+	e     *entry
+	group string
+	timer Timer
+	done  bool
+	// waited is how long the item waited to be leased
+	waited time.Duration
+}
+
+// WithVisibilityTimeout sets how long leased items stay invisible
+// before going back to the queue, unless they're acked or nacked.
+// With 0, the default, leased items never go back on their own.
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(q *Queue) {
+		q.visibility = d
+	}
+}
+
+// Lease takes an item from the queue like DequeueContext does, but
+// the item is only leased to the caller: it has to be acked once
+// processed, or it goes back to the queue after the visibility
+// timeout, so a crashed consumer doesn't lose it. Leased items are
+// not counted by Len. The write-ahead log keeps them until they are
+// acked, so they are back in the queue after a crash.
+func (q *Queue) Lease(ctx context.Context) (d *Delivery, err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	stop := context.AfterFunc(ctx, q.broadcast)
+	defer stop()
+	e, err := q.dequeueEntry(ctx.Err)
+	if err != nil {
+		return
+	}
+	e.deliveries += 1
+	d = &Delivery{Item: e.item, q: q, e: e, group: groupOf(e.item), waited: q.now().Sub(e.waitingSince())}
+	if d.group != "" {
+		if q.busy == nil {
+			q.busy = make(map[string]bool)
+		}
+		q.busy[d.group] = true
+	}
+	q.leased[d] = struct{}{}
+	q.logInflight(e)
+	if q.visibility > 0 {
+		d.timer = q.clock.AfterFunc(q.visibility, d.expire)
+	}
+	return
+}
+
+// Metadata returns the metadata of the delivered item.
+func (d *Delivery) Metadata() Metadata {
+	d.q.cond.L.Lock()
+	defer d.q.cond.L.Unlock()
+	return d.q.metadata(d.e)
+}
+
+// Waited returns how long the item waited in the queue before it
+// was leased, counted from when it was enqueued, or became ready if
+// it was delayed. Earlier deliveries of a redelivered item count
+// too, as the item has been waiting to be processed all that time.
+func (d *Delivery) Waited() time.Duration {
+	return d.waited
+}
+
+// Deliveries returns how many times the item has been delivered,
+// counting this delivery.
+func (d *Delivery) Deliveries() int {
+	d.q.cond.L.Lock()
+	defer d.q.cond.L.Unlock()
+	return d.e.deliveries
+}
+
+// Ack tells the item has been processed, so it's gone for good.
+func (d *Delivery) Ack() error {
+	d.q.cond.L.Lock()
+	defer d.q.cond.L.Unlock()
+	if !d.release() {
+		return ErrLeaseExpired
+	}
+	d.q.logRemove(d.e)
+	if d.q.ackProcessed {
+		d.q.markProcessed(d.e.id)
+	}
+	return nil
+}
+
+// Nack tells the item hasn't been processed, so it goes back to the
+// queue, unless it's been delivered too many times and goes to the
+// dead-letter queue instead. With retry policy, see SetRetryPolicy,
+// the item is retried like with Retry: it waits for the backoff of
+// its next attempt, and goes to the dead-letter queue once it runs
+// out of retries. Otherwise it goes back right away.
+func (d *Delivery) Nack() error {
+	d.q.cond.L.Lock()
+	if !d.release() {
+		d.q.cond.L.Unlock()
+		return ErrLeaseExpired
+	}
+	dead := !d.q.retryEntry(d.e)
+	d.q.cond.L.Unlock()
+	if dead {
+		d.q.bury(d.Item)
+	}
+	return nil
+}
+
+// expire puts the item back once the visibility timeout passes.
+func (d *Delivery) expire() {
+	d.q.cond.L.Lock()
+	dead := d.release() && !d.q.redeliver(d.e)
+	d.q.cond.L.Unlock()
+	if dead {
+		d.q.bury(d.Item)
+	}
+}
+
+// release ends the lease, returning false if it has already ended.
+// Must be called with the queue locked.
+func (d *Delivery) release() bool {
+	if d.done {
+		return false
+	}
+	d.done = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	delete(d.q.leased, d)
+	if d.group != "" {
+		delete(d.q.busy, d.group)
+		// any waiter may be after the held items of the group
+		d.q.wakeAll()
+	}
+	if d.q.spaceWaiters > 0 {
+		d.q.space.Broadcast()
+	}
+	return true
+}
+
+// redeliver puts the entry back to the queue, in its old place. The
+// log still holds the leased entry, see logInflight.
+// It returns false when the entry is dead and has to be buried
+// instead. Must be called with the queue locked.
+func (q *Queue) redeliver(e *entry) bool {
+	if q.dead(e) {
+		q.logRemove(e)
+		q.logEvent(LogDropped, e.item, nil)
+		q.emit(Dropped, e.item)
+		return false
+	}
+	q.logEvent(LogRedelivered, e.item, nil)
+	q.push(e)
+	q.signal()
+	return true
+}
+
+// Leased returns number of items leased and not acked yet.
+func (q *Queue) Leased() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.leased)
+}
@@ -0,0 +1,102 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLeaseAck(t *testing.T) {
+	q := NewWithOptions(WithVisibilityTimeout(50 * time.Millisecond))
+	q.Enqueue(NewDummyTask(1))
+	d, err := q.Lease(context.Background())
+	if err != nil || d.Item.(*DummyTask).priority != 1 {
+		t.Fatalf("Expected to lease the item, given %v", err)
+	}
+	if q.Len() != 0 || q.Leased() != 1 {
+		t.Errorf("Expected leased item not to be pending")
+	}
+	if err := d.Ack(); err != nil {
+		t.Errorf("Expected to ack the item, given %v", err)
+	}
+	<-time.After(100 * time.Millisecond)
+	if q.Len() != 0 || q.Leased() != 0 {
+		t.Errorf("Expected acked item to be gone")
+	}
+	if err := d.Ack(); err != ErrLeaseExpired {
+		t.Errorf("Expected second ack to fail, given %v", err)
+	}
+}
+
+func TestLeaseNack(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{1, 2} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	d, _ := q.Lease(context.Background())
+	d.Nack()
+	d, _ = q.Lease(context.Background())
+	if d.Item.(*DummyTask).priority != 1 || d.Deliveries() != 2 {
+		t.Errorf("Expected nacked item to be delivered again")
+	}
+}
+
+func TestLeaseNackRetry(t *testing.T) {
+	clock := newFakeClock()
+	var dead []QueueItem
+	q := NewWithOptions(WithClock(clock), WithDeadLetterFunc(0, func(item QueueItem) {
+		dead = append(dead, item)
+	}))
+	q.SetRetryPolicy(RetryPolicy{MaxRetries: 1, Base: time.Second})
+	task := NewDummyTask(1)
+	q.Enqueue(task)
+	ctx := context.Background()
+	d, _ := q.Lease(ctx)
+	d.Nack()
+	if _, ok := q.TryDequeue(); ok || q.Len() != 1 {
+		t.Errorf("Expected nacked item waiting for its backoff")
+	}
+	if q.Attempts(task.Id()) != 1 {
+		t.Errorf("Expected nack counted as an attempt, given %d", q.Attempts(task.Id()))
+	}
+	clock.Advance(time.Second)
+	d, _ = q.Lease(ctx)
+	if d.Item != task {
+		t.Errorf("Expected nacked item once the backoff passes, given %v", d.Item)
+	}
+	d.Nack()
+	if q.Len() != 0 || len(dead) != 1 || dead[0] != task {
+		t.Errorf("Expected item out of retries dead-lettered, given %d left", q.Len())
+	}
+}
+
+func TestLeaseVisibilityTimeout(t *testing.T) {
+	q := NewWithOptions(WithVisibilityTimeout(50 * time.Millisecond))
+	q.Enqueue(NewDummyTask(1))
+	d, _ := q.Lease(context.Background())
+	// the consumer crashes here, never acking the item
+	redelivered, err := q.DequeueTimeout(time.Second)
+	if err != nil || redelivered != d.Item {
+		t.Errorf("Expected item to be visible again after timeout, given %v", err)
+	}
+	if err := d.Ack(); err != ErrLeaseExpired {
+		t.Errorf("Expected ack after timeout to fail, given %v", err)
+	}
+}
+
+func TestLeaseWaited(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock))
+	q.Enqueue(NewDummyTask(1))
+	clock.Advance(3 * time.Second)
+	d, _ := q.Lease(context.Background())
+	if d.Waited() != 3*time.Second {
+		t.Errorf("Expected item to wait 3s, given %v", d.Waited())
+	}
+	d.Nack()
+	clock.Advance(2 * time.Second)
+	d, _ = q.Lease(context.Background())
+	if d.Waited() != 5*time.Second {
+		t.Errorf("Expected redelivered item to wait 5s, given %v", d.Waited())
+	}
+}
@@ -0,0 +1,99 @@
+package pqueue
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogEvent is a class of things the queue logs, see WithLogger.
+type LogEvent int
+
+const (
+	// LogRejected is an item refused by the full or closed queue,
+	// or over its producer's quota. Warn by default.
+	LogRejected LogEvent = iota
+	// LogDuplicate is an item refused because its id has been
+	// already seen. Debug by default.
+	LogDuplicate
+	// LogDropped is an item thrown out of the queue, to make room
+	// or because it has run out of retries or deliveries. Warn by
+	// default.
+	LogDropped
+	// LogExpired is an Expirable item dropped at dequeue. Info by
+	// default.
+	LogExpired
+	// LogRedelivered is a leased item back in the queue, nacked
+	// or after its visibility timeout. Info by default.
+	LogRedelivered
+	// LogPersistence is an error of writing the write-ahead log.
+	// Error by default.
+	LogPersistence
+)
+
+var logEventNames = []string{"rejected", "duplicate", "dropped", "expired", "redelivered", "persistence"}
+
+func (ev LogEvent) String() string {
+	if ev < 0 || int(ev) >= len(logEventNames) {
+		return "unknown"
+	}
+	return logEventNames[ev]
+}
+
+var defaultLogLevels = []slog.Level{slog.LevelWarn, slog.LevelDebug, slog.LevelWarn, slog.LevelInfo, slog.LevelInfo, slog.LevelError}
+
+// logger is the queue's slog logger with its levels.
+type logger struct {
+	l      *slog.Logger
+	levels []slog.Level
+}
+
+// WithLogger makes the queue log rejected, duplicate, dropped,
+// expired and redelivered items, and write-ahead log errors, to given
+// logger, with the item id and the event class as attributes. Like
+// hooks, records are logged outside the queue lock, in order. See
+// WithLogLevel for the levels.
+func WithLogger(l *slog.Logger) Option {
+	return func(q *Queue) {
+		q.log().l = l
+	}
+}
+
+// WithLogLevel sets the level the event class is logged at.
+func WithLogLevel(ev LogEvent, level slog.Level) Option {
+	return func(q *Queue) {
+		if ev >= 0 && int(ev) < len(defaultLogLevels) {
+			q.log().levels[ev] = level
+		}
+	}
+}
+
+func (q *Queue) log() *logger {
+	if q.logger == nil {
+		q.logger = &logger{levels: append([]slog.Level(nil), defaultLogLevels...)}
+	}
+	return q.logger
+}
+
+// logEvent logs the event, if the queue has a logger and the level
+// is enabled. Item and err may be nil. Must be called with the queue
+// locked.
+func (q *Queue) logEvent(ev LogEvent, item QueueItem, err error) {
+	lg := q.logger
+	if lg == nil || lg.l == nil {
+		return
+	}
+	level := lg.levels[ev]
+	if !lg.l.Enabled(context.Background(), level) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("event", ev.String())}
+	if item != nil {
+		attrs = append(attrs, slog.Any("id", item.Id()))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	q.hooks.fire([]func(QueueItem){func(QueueItem) {
+		lg.l.LogAttrs(context.Background(), level, "pqueue: "+ev.String(), attrs...)
+	}}, item)
+}
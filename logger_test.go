@@ -0,0 +1,40 @@
+package pqueue
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	q := NewWithOptions(WithLimit(2), WithLogger(l), WithLogLevel(LogDuplicate, slog.LevelInfo), WithDeadLetterFunc(2, func(QueueItem) {}))
+	q.EnqueueUnique(&stateTask{"a", 1})
+	q.EnqueueUnique(&stateTask{"a", 1})
+	q.Enqueue(&stateTask{"b", 2})
+	q.Enqueue(&stateTask{"c", 3})
+	for i := 0; i < 2; i++ {
+		d, _ := q.Lease(context.Background())
+		d.Nack()
+	}
+	q.WaitHooks()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		`level=INFO msg="pqueue: duplicate" event=duplicate id=a`,
+		`level=WARN msg="pqueue: rejected" event=rejected id=c error="Queue limit reached"`,
+		`level=INFO msg="pqueue: redelivered" event=redelivered id=a`,
+		`level=WARN msg="pqueue: dropped" event=dropped id=a`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d records, given %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, expected[i]) {
+			t.Errorf("Expected %s, given %s", expected[i], line)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package pqueue
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrNoRoute is returned by Manager's Enqueue when the manager has
+// no route, or the route gives no queue name for the item.
+var ErrNoRoute = errors.New("No queue for the item")
+
+// Manager owns named queues, eg. one per topic or tenant, created
+// with the same options on first use. Items can be enqueued to a
+// queue by name, or routed to one by a function of the item.
+type Manager struct {
+	mu     sync.Mutex
+	queues map[string]*Queue
+	route  func(QueueItem) string
+	opts   []Option
+	closed bool
+}
+
+// NewManager creates a manager of queues created by NewWithOptions
+// with given options. Route tells the name of the queue an item
+// enqueued with the manager's Enqueue goes to, it may be nil when
+// items are enqueued to the queues directly.
+func NewManager(route func(QueueItem) string, opts ...Option) *Manager {
+	return &Manager{queues: make(map[string]*Queue), route: route, opts: opts}
+}
+
+// Queue returns the queue with given name, creating it if there's
+// none yet. Queues created after the manager is closed are closed.
+func (m *Manager) Queue(name string) *Queue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.queues[name]
+	if !ok {
+		q = NewWithOptions(m.opts...)
+		if m.closed {
+			q.Close()
+		}
+		m.queues[name] = q
+	}
+	return q
+}
+
+// Lookup returns the queue with given name, without creating it.
+func (m *Manager) Lookup(name string) (*Queue, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.queues[name]
+	return q, ok
+}
+
+// Remove closes the queue with given name and forgets it, returning
+// the queue so its remaining items can still be drained.
+func (m *Manager) Remove(name string) *Queue {
+	m.mu.Lock()
+	q := m.queues[name]
+	delete(m.queues, name)
+	m.mu.Unlock()
+	if q != nil {
+		q.Close()
+	}
+	return q
+}
+
+// Names returns names of the queues, sorted.
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.queues))
+	for name := range m.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Enqueue puts the item to the queue its route tells.
+func (m *Manager) Enqueue(item QueueItem) error {
+	q, err := m.routed(item)
+	if err != nil {
+		return err
+	}
+	return q.Enqueue(item)
+}
+
+// EnqueueUnique puts the item to the queue its route tells, only if
+// it hasn't already been in that queue.
+func (m *Manager) EnqueueUnique(item QueueItem) error {
+	q, err := m.routed(item)
+	if err != nil {
+		return err
+	}
+	return q.EnqueueUnique(item)
+}
+
+func (m *Manager) routed(item QueueItem) (*Queue, error) {
+	if m.route == nil {
+		return nil, ErrNoRoute
+	}
+	name := m.route(item)
+	if name == "" {
+		return nil, ErrNoRoute
+	}
+	return m.Queue(name), nil
+}
+
+// Stats returns the counters, rates and sizes of all the queues
+// added up. History is -1 when any of the queues doesn't tell its
+// size.
+func (m *Manager) Stats() (total Stats) {
+	for _, q := range m.list() {
+		s := q.Stats()
+		total.Enqueued += s.Enqueued
+		total.Dequeued += s.Dequeued
+		total.Rejected += s.Rejected
+		total.Duplicates += s.Duplicates
+		total.Expired += s.Expired
+		total.Len += s.Len
+		total.Rates = total.Rates.plus(s.Rates)
+		if s.History < 0 || total.History < 0 {
+			total.History = -1
+		} else {
+			total.History += s.History
+		}
+	}
+	return
+}
+
+// StatsByName returns the stats of every queue by its name.
+func (m *Manager) StatsByName() map[string]Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]Stats, len(m.queues))
+	for name, q := range m.queues {
+		stats[name] = q.Stats()
+	}
+	return stats
+}
+
+// Close closes all the queues, and the ones created later.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	for _, q := range m.list() {
+		q.Close()
+	}
+}
+
+func (m *Manager) list() []*Queue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queues := make([]*Queue, 0, len(m.queues))
+	for _, q := range m.queues {
+		queues = append(queues, q)
+	}
+	return queues
+}
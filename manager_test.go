@@ -0,0 +1,54 @@
+package pqueue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager(func(item QueueItem) string {
+		if item.(*stateTask).Priority < 10 {
+			return "urgent"
+		}
+		return "bulk"
+	})
+	m.Enqueue(&stateTask{"a", 1})
+	m.Enqueue(&stateTask{"b", 20})
+	m.Enqueue(&stateTask{"c", 30})
+	if err := m.EnqueueUnique(&stateTask{"c", 30}); err != ErrDuplicate {
+		t.Errorf("Expected duplicate in the routed queue, given %v", err)
+	}
+	if names := m.Names(); !reflect.DeepEqual(names, []string{"bulk", "urgent"}) {
+		t.Errorf("Expected queues created by the route, given %v", names)
+	}
+	if n := m.Queue("bulk").Len(); n != 2 {
+		t.Errorf("Expected 2 items in bulk, given %d", n)
+	}
+	if task := m.Queue("urgent").Dequeue().(*stateTask); task.Name != "a" {
+		t.Errorf("Expected a, given %s", task.Name)
+	}
+	s := m.Stats()
+	if s.Enqueued != 3 || s.Dequeued != 1 || s.Duplicates != 1 || s.Len != 2 {
+		t.Errorf("Expected stats added up, given %+v", s)
+	}
+	if s.Rates.Enqueued.M1 != 3.0/60 || s.Rates.Dequeued.M1 != 1.0/60 {
+		t.Errorf("Expected rates added up, given %+v", s.Rates)
+	}
+	if s := m.StatsByName()["bulk"]; s.Len != 2 {
+		t.Errorf("Expected bulk stats, given %+v", s)
+	}
+
+	if _, ok := m.Lookup("mail"); ok {
+		t.Errorf("Expected no queue before first use")
+	}
+	if q := m.Remove("bulk"); q == nil || q.Enqueue(&stateTask{"d", 40}) != ErrClosed {
+		t.Errorf("Expected removed queue closed")
+	}
+	m.Close()
+	if m.Queue("late").Enqueue(&stateTask{"d", 40}) != ErrClosed {
+		t.Errorf("Expected queue created after close to be closed")
+	}
+	if err := NewManager(nil).Enqueue(&stateTask{"a", 1}); err != ErrNoRoute {
+		t.Errorf("Expected ErrNoRoute, given %v", err)
+	}
+}
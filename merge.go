@@ -0,0 +1,136 @@
+package pqueue
+
+import (
+	"sort"
+	"unsafe"
+)
+
+// Merge moves all the pending items of other queue, delayed ones
+// included, to this one, and with history set, the other queue's
+// history too. Both queues are locked meanwhile, so no consumer sees
+// an item in both or neither of them. Moved items keep their order
+// of arrival, but come after the items already in this queue. The
+// limit is not enforced, so the merged queue may end up over it.
+// It returns number of moved items.
+func (q *Queue) Merge(other *Queue, history bool) int {
+	if q == other {
+		return 0
+	}
+	defer lockBoth(q, other)()
+
+	entries := append(other.items.list(), other.delayed...)
+	other.items.reset(nil)
+	other.delayed = nil
+	other.armDelayTimer()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+	for _, e := range entries {
+		e.delayed = false
+		other.untrack(e)
+		other.emit(Removed, e.item)
+	}
+	if history {
+		other.historyEach(func(id interface{}) {
+			q.history.Add(id)
+			q.logHistory(walHistory, id)
+		})
+		other.history.Clear()
+		other.logHistory(walClearHistory, nil)
+	}
+
+	state := q.state()
+	ready := q.items.list()
+	for _, e := range entries {
+		if q.rank != nil {
+			e.score = q.rank(e.item, state)
+		}
+		if e.readyAt.After(q.now()) {
+			q.pushDelayed(e)
+		} else {
+			ready = append(ready, e)
+		}
+		q.adopt(e)
+	}
+	q.items.reset(ready)
+	q.wakeAll()
+	return len(entries)
+}
+
+// TransferTo moves up to n items of the highest priority from this
+// queue to dst, eg. to balance the work between worker pools. Both
+// queues are locked meanwhile, so no consumer sees an item in both
+// or neither of them. Items are admitted to dst the way Enqueue and
+// EnqueueAs admit them, so its limits, class limits, producer share
+// and overflow policy apply, and dst adds their ids to its history.
+// The first item dst refuses is put back to this queue and the
+// transfer stops with dst's error, eg. ErrQueueFull. It stops early
+// also once this queue runs out of ready items. It returns number of
+// moved items. Delayed items are not moved, and the history of this
+// queue stays.
+func (q *Queue) TransferTo(dst *Queue, n int) (moved int, err error) {
+	if q == dst {
+		return 0, nil
+	}
+	defer lockBoth(q, dst)()
+
+	for moved < n {
+		e := q.next()
+		if e == nil {
+			break
+		}
+		x := &entry{item: e.item, id: e.id, producer: e.producer, since: e.since, values: e.values}
+		if err = dst.admit(x); err != nil {
+			q.push(e)
+			if q.wal != nil {
+				q.wal.fail(q.logEnqueue(e))
+			}
+			break
+		}
+		q.emit(Removed, e.item)
+		moved += 1
+	}
+	return
+}
+
+// admit puts the entry moved from another queue to the queue, with
+// the producer share applied to the entry's producer. Must be called
+// with the queue locked.
+func (q *Queue) admit(e *entry) error {
+	if quota := q.shareQuota(); e.producer != "" && quota > 0 && q.producers[e.producer] >= quota {
+		q.logEvent(LogRejected, e.item, ErrQuotaExceeded)
+		q.emit(Dropped, e.item)
+		return ErrQuotaExceeded
+	}
+	return q.enqueueEntry(e)
+}
+
+// adopt takes the entry moved from another queue, already placed
+// in the heap, as if it's just been enqueued.
+func (q *Queue) adopt(e *entry) {
+	q.seq += 1
+	e.seq = q.seq
+	e.deliveries = 0
+	q.track(e)
+	if q.wal != nil {
+		q.wal.fail(q.logEnqueue(e))
+		q.compactWAL()
+	}
+	q.emit(Enqueued, e.item)
+}
+
+// lockBoth locks both queues, always in the same order whichever is
+// given first, so two goroutines moving items between the same
+// queues in opposite directions can't deadlock. It returns the
+// function unlocking them.
+func lockBoth(a, b *Queue) (unlock func()) {
+	if uintptr(unsafe.Pointer(a)) > uintptr(unsafe.Pointer(b)) {
+		a, b = b, a
+	}
+	a.cond.L.Lock()
+	b.cond.L.Lock()
+	return func() {
+		b.cond.L.Unlock()
+		a.cond.L.Unlock()
+	}
+}
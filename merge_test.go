@@ -0,0 +1,93 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	q, other := NewWithOptions(WithStableOrder()), NewWithOptions(WithStableOrder())
+	q.EnqueueUnique(&stateTask{Name: "a", Priority: 2})
+	for i, x := range []int{1, 2, 1} {
+		other.EnqueueUnique(&stateTask{Name: string(rune('b' + i)), Priority: x})
+	}
+	other.EnqueueAfter(&stateTask{Name: "e", Priority: 0}, time.Hour)
+	if n := q.Merge(other, true); n != 4 {
+		t.Errorf("Expected 4 items to be moved, %d moved", n)
+	}
+	if !other.IsEmpty() || other.IdExists("b") {
+		t.Errorf("Expected other queue to be emptied")
+	}
+	if q.Len() != 5 || q.Delayed() != 1 || !q.IdExists("c") {
+		t.Errorf("Expected items and history to be moved")
+	}
+	for _, name := range []string{"b", "d", "a", "c"} {
+		if task := q.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+	if q.Merge(q, true) != 0 {
+		t.Errorf("Expected merging the queue with itself to do nothing")
+	}
+}
+
+func TestTransferTo(t *testing.T) {
+	q, dst := New(0), New(3)
+	for _, x := range []int{5, 1, 4, 2, 3} {
+		q.Enqueue(&DummyTask{priority: x})
+	}
+	dst.Enqueue(&DummyTask{priority: 0})
+	if n, err := q.TransferTo(dst, 10); n != 2 || err != ErrQueueFull {
+		t.Errorf("Expected 2 items to be moved to the limit, %d moved, given %v", n, err)
+	}
+	if q.Len() != 3 {
+		t.Errorf("Expected the refused item to stay, given %d items", q.Len())
+	}
+	if n, err := dst.TransferTo(q, 1); n != 1 || err != nil {
+		t.Errorf("Expected 1 item to be moved back, %d moved, given %v", n, err)
+	}
+	for _, x := range []int{0, 3, 4, 5} {
+		if task := q.Dequeue().(*DummyTask); task.priority != x {
+			t.Errorf("Expected to dequeue %d, given %d", x, task.priority)
+		}
+	}
+	for _, x := range []int{1, 2} {
+		if task := dst.Dequeue().(*DummyTask); task.priority != x {
+			t.Errorf("Expected to dequeue %d from dst, given %d", x, task.priority)
+		}
+	}
+
+	// the producer share of dst applies to moved items
+	src, shared := New(0), NewWithOptions(WithProducerShare(0.5))
+	shared.Limit = 4
+	for x := 1; x <= 3; x++ {
+		src.EnqueueAs("p", &DummyTask{priority: x})
+	}
+	if n, err := src.TransferTo(shared, 3); n != 2 || err != ErrQuotaExceeded {
+		t.Errorf("Expected 2 items to be moved to the share, %d moved, given %v", n, err)
+	}
+	if src.Len() != 1 || src.ProducerLen("p") != 1 || shared.ProducerLen("p") != 2 {
+		t.Errorf("Expected the item over the share to stay")
+	}
+
+	// opposite transfers at once must not deadlock
+	a, b := New(0), New(0)
+	for i := 0; i < 100; i++ {
+		a.Enqueue(&DummyTask{priority: i})
+		b.Enqueue(&DummyTask{priority: i})
+	}
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			a.TransferTo(b, 1)
+		}
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		b.TransferTo(a, 1)
+	}
+	<-done
+	if a.Len()+b.Len() != 200 {
+		t.Errorf("Expected 200 items in both queues, given %d", a.Len()+b.Len())
+	}
+}
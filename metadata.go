@@ -0,0 +1,111 @@
+package pqueue
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// Metadata is what the queue knows about an item apart from the item
+// itself, so pipelines can pass context along, eg. trace context
+// propagated as key/values, without changing item types.
+type Metadata struct {
+	// EnqueuedAt is when the item has been put to the queue.
+	EnqueuedAt time.Time
+	// Deliveries counts leases of the item, see Lease.
+	Deliveries int
+	// Attempts counts retries of the item's id, see Retry.
+	Attempts int
+	// Producer is the label the item has been enqueued with, eg.
+	// the producer key of EnqueueQuota.
+	Producer string
+	// Values are the key/values given to EnqueueWithMetadata.
+	Values map[string]string
+}
+
+// EnqueueWithMetadata puts the item to the queue like Enqueue, along
+// with given producer label and key/values, which are handed back
+// with the item by DequeueWithMetadata and Delivery.Metadata. Values
+// are copied. Metadata isn't kept by the write-ahead log or snapshots.
+func (q *Queue) EnqueueWithMetadata(item QueueItem, producer string, values map[string]string) error {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.enqueueEntry(&entry{item: item, id: item.Id(), producer: producer, values: maps.Clone(values)})
+}
+
+type metadataKey struct{}
+
+// ContextWithMetadata returns a copy of ctx carrying md, for
+// middleware to hand to the next handler, or for callers of
+// EnqueueContext and DequeueContext, see Use. Enqueueing the item
+// then attaches md's Producer and Values to it, like
+// EnqueueWithMetadata, and dequeueing fills md in with the metadata
+// of the item taken.
+func ContextWithMetadata(ctx context.Context, md *Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFromContext returns the metadata ctx carries, see
+// ContextWithMetadata.
+func MetadataFromContext(ctx context.Context) (md *Metadata, ok bool) {
+	md, ok = ctx.Value(metadataKey{}).(*Metadata)
+	return
+}
+
+// newEntry returns the entry of the item, with the producer and
+// values of the metadata ctx carries.
+func newEntry(ctx context.Context, item QueueItem) *entry {
+	e := &entry{item: item, id: item.Id()}
+	if md, ok := MetadataFromContext(ctx); ok {
+		e.producer, e.values = md.Producer, maps.Clone(md.Values)
+	}
+	return e
+}
+
+// handOut returns the item of the dequeued entry, filling in the
+// metadata ctx carries. Must be called with the queue locked.
+func (q *Queue) handOut(ctx context.Context, e *entry, err error) (QueueItem, error) {
+	if err != nil {
+		return nil, err
+	}
+	if md, ok := MetadataFromContext(ctx); ok {
+		*md = q.metadata(e)
+	}
+	return e.item, nil
+}
+
+// DequeueWithMetadata is DequeueContext returning the item's
+// metadata too.
+func (q *Queue) DequeueWithMetadata(ctx context.Context) (item QueueItem, md Metadata, err error) {
+	e, err := q.dequeueContext(ctx)
+	if err != nil {
+		return
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return e.item, q.metadata(e), nil
+}
+
+// Metadata returns the metadata of the pending item with given id.
+// When more items share the id, the one enqueued first is looked at.
+func (q *Queue) Metadata(id interface{}) (md Metadata, ok bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	entries := q.pending(id)
+	if len(entries) == 0 {
+		return
+	}
+	return q.metadata(entries[0]), true
+}
+
+// metadata returns the metadata of the entry. Must be called with
+// the queue locked.
+func (q *Queue) metadata(e *entry) Metadata {
+	return Metadata{
+		EnqueuedAt: e.since,
+		Deliveries: e.deliveries,
+		Attempts:   q.attempts[e.id],
+		Producer:   e.producer,
+		Values:     maps.Clone(e.values),
+	}
+}
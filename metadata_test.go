@@ -0,0 +1,86 @@
+package pqueue
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock))
+	values := map[string]string{"traceparent": "00-abc-def-01"}
+	q.EnqueueWithMetadata(&stateTask{"a", 1}, "crawler", values)
+	values["traceparent"] = "changed"
+	q.Enqueue(&stateTask{"b", 2})
+
+	md, ok := q.Metadata("a")
+	if !ok || md.Producer != "crawler" || md.Values["traceparent"] != "00-abc-def-01" || !md.EnqueuedAt.Equal(clock.Now()) {
+		t.Errorf("Expected metadata of pending item, given %+v", md)
+	}
+	if q.ProducerLen("crawler") != 1 {
+		t.Errorf("Expected item counted for its producer")
+	}
+	clock.Advance(time.Minute)
+	ctx := context.Background()
+	d, _ := q.Lease(ctx)
+	d.Nack()
+	q.SetRetryPolicy(RetryPolicy{MaxRetries: 5, Base: time.Hour})
+	q.Retry(&stateTask{"a", 1})
+	d, _ = q.Lease(ctx)
+	if md := d.Metadata(); md.Deliveries != 2 || md.Attempts != 1 || md.Values["traceparent"] != "00-abc-def-01" || !md.EnqueuedAt.Equal(clock.Now().Add(-time.Minute)) {
+		t.Errorf("Expected metadata of leased item, given %+v", md)
+	}
+	d.Ack()
+
+	_, md, err := q.DequeueWithMetadata(ctx)
+	if err != nil || md.Values != nil || md.Producer != "" {
+		t.Errorf("Expected empty metadata of plain item, given %+v %v", md, err)
+	}
+	if _, ok := q.Metadata("zz"); ok {
+		t.Errorf("Expected no metadata of missing item")
+	}
+}
+
+func TestContextMetadata(t *testing.T) {
+	q := New(0)
+	// middleware tagging enqueued items and reading dequeued ones
+	var seen []string
+	q.Use(func(op Op, next Handler) Handler {
+		return func(ctx context.Context, item QueueItem) (QueueItem, error) {
+			md, ok := MetadataFromContext(ctx)
+			if !ok {
+				md = &Metadata{}
+				ctx = ContextWithMetadata(ctx, md)
+			}
+			if op == OpEnqueue {
+				md.Values = map[string]string{"via": "middleware"}
+				return next(ctx, item)
+			}
+			item, err := next(ctx, item)
+			if err == nil {
+				seen = append(seen, md.Producer+":"+md.Values["via"])
+			}
+			return item, err
+		}
+	})
+	ctx := ContextWithMetadata(context.Background(), &Metadata{Producer: "crawler"})
+	q.EnqueueContext(ctx, &stateTask{"a", 1})
+	q.Enqueue(&stateTask{"b", 2})
+	q.EnqueueUnique(&stateTask{"c", 3})
+
+	md := &Metadata{}
+	if item, err := q.DequeueContext(ContextWithMetadata(context.Background(), md)); err != nil || item.(*stateTask).Name != "a" {
+		t.Fatalf("Expected a, given %v", err)
+	}
+	if md.Producer != "crawler" || md.Values["via"] != "middleware" || md.EnqueuedAt.IsZero() {
+		t.Errorf("Expected metadata of the dequeued item, given %+v", md)
+	}
+	q.TryDequeue()
+	q.DequeueTimeout(time.Second)
+	expected := []string{"crawler:middleware", ":middleware", ":middleware"}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected %v, given %v", expected, seen)
+	}
+}
@@ -0,0 +1,57 @@
+package pqueue
+
+import "context"
+
+// Op is the queue operation a middleware wraps.
+type Op int
+
+const (
+	// OpEnqueue is putting the handler's item to the queue. The
+	// handler returns the item which has been enqueued.
+	OpEnqueue Op = iota
+	// OpDequeue is taking an item from the queue. The handler gets
+	// nil item and returns the one dequeued.
+	OpDequeue
+)
+
+func (op Op) String() string {
+	if op == OpEnqueue {
+		return "enqueue"
+	}
+	return "dequeue"
+}
+
+// Handler does a queue operation, or the rest of the middleware
+// chain wrapping it.
+type Handler func(ctx context.Context, item QueueItem) (QueueItem, error)
+
+// Middleware wraps the handler of given operation, eg. to change or
+// reject items, time, log or recover from panics, and returns the
+// handler to call instead. It may call next any number of times, or
+// not at all.
+type Middleware func(op Op, next Handler) Handler
+
+// Use adds the middleware to the chain wrapping Enqueue,
+// EnqueueContext, EnqueueUnique, Dequeue, DequeueContext, TryDequeue,
+// DequeueFunc and DequeueTimeout. Middleware added first is
+// outermost. Handlers are called outside the queue lock, so they can
+// call the queue. The other ways in and out of the queue bypass the
+// chain.
+func (q *Queue) Use(mw Middleware) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	// a new slice, so intercept can use the old one unlocked
+	q.middleware = append(q.middleware[:len(q.middleware):len(q.middleware)], mw)
+}
+
+// intercept calls the handler of given operation through the
+// middleware chain.
+func (q *Queue) intercept(op Op, ctx context.Context, item QueueItem, h Handler) (QueueItem, error) {
+	q.mu.RLock()
+	chain := q.middleware
+	q.mu.RUnlock()
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i](op, h)
+	}
+	return h(ctx, item)
+}
@@ -0,0 +1,59 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	q := New(0)
+	var log []string
+	q.Use(func(op Op, next Handler) Handler {
+		return func(ctx context.Context, item QueueItem) (QueueItem, error) {
+			item, err := next(ctx, item)
+			if err == nil {
+				log = append(log, op.String()+" "+item.(*stateTask).Name)
+			}
+			return item, err
+		}
+	})
+	negative := errors.New("negative priority")
+	q.Use(func(op Op, next Handler) Handler {
+		if op != OpEnqueue {
+			return next
+		}
+		return func(ctx context.Context, item QueueItem) (QueueItem, error) {
+			task := item.(*stateTask)
+			if task.Priority < 0 {
+				return nil, negative
+			}
+			// queued under changed name
+			return next(ctx, &stateTask{task.Name + "!", task.Priority})
+		}
+	})
+
+	if err := q.Enqueue(&stateTask{"a", 1}); err != nil {
+		t.Errorf("Expected no error, given %v", err)
+	}
+	if err := q.Enqueue(&stateTask{"b", -1}); err != negative {
+		t.Errorf("Expected rejected item, given %v", err)
+	}
+	if err := q.EnqueueUnique(&stateTask{"c", 2}); err != nil {
+		t.Errorf("Expected changed item added, given %v", err)
+	}
+	if item, ok := q.DequeueFunc(func(QueueItem) bool { return true }); !ok || item.(*stateTask).Name != "a!" {
+		t.Errorf("Expected a!, given %v", item)
+	}
+	if item, ok := q.TryDequeue(); !ok || item.(*stateTask).Name != "c!" {
+		t.Errorf("Expected c!, given %v", item)
+	}
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected empty queue")
+	}
+	expected := []string{"enqueue a!", "enqueue c!", "dequeue a!", "dequeue c!"}
+	if !reflect.DeepEqual(log, expected) {
+		t.Errorf("Expected %v, given %v", expected, log)
+	}
+}
@@ -0,0 +1,193 @@
+package pqueue
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MirrorRecord is a change of the mirrored queue, numbered by the
+// mirror in order the changes happened, from 1.
+type MirrorRecord struct {
+	Seq uint64
+	Event
+}
+
+// MirrorSink forwards mirror records to the remote queue, eg. over
+// gRPC or HTTP, where they are applied with ApplyMirror.
+type MirrorSink interface {
+	// Send applies the records on the remote, in order.
+	Send(ctx context.Context, records []MirrorRecord) error
+	// Cursor returns Seq of the last record the remote has
+	// applied, so records it already has aren't sent again once
+	// the mirror resumes after a failed Send.
+	Cursor(ctx context.Context) (uint64, error)
+}
+
+// Mirror forwards every change of the queue to a remote one, see
+// Queue.Mirror.
+type Mirror struct {
+	q      *Queue
+	sink   MirrorSink
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	cond    sync.Cond
+	seq     uint64
+	pending []MirrorRecord
+	sent    uint64
+	err     error
+}
+
+// mirrorBatch is the most records sent at once.
+const mirrorBatch = 256
+
+// Mirror starts forwarding every change of the queue, enqueues,
+// dequeues and removals, to the sink in the background, so a standby
+// queue applying them with ApplyMirror can take over consumption
+// after failover. Records wait in memory until the sink takes them,
+// they are never dropped. When Send fails it's retried, with backoff
+// of up to a second, from the remote's Cursor. Call Close to stop.
+func (q *Queue) Mirror(sink MirrorSink) *Mirror {
+	m := &Mirror{q: q, sink: sink, done: make(chan struct{})}
+	m.cond.L = &m.mu
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	q.cond.L.Lock()
+	q.mirrors = append(q.mirrors, m)
+	q.cond.L.Unlock()
+	go m.run()
+	return m
+}
+
+// add keeps the event to be forwarded. Called with the queue locked.
+func (m *Mirror) add(ev Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq += 1
+	m.pending = append(m.pending, MirrorRecord{Seq: m.seq, Event: ev})
+	m.cond.Signal()
+}
+
+func (m *Mirror) run() {
+	defer close(m.done)
+	backoff := time.Duration(0)
+	resume := false
+	for {
+		m.mu.Lock()
+		for len(m.pending) == 0 && m.ctx.Err() == nil {
+			m.cond.Wait()
+		}
+		if m.ctx.Err() != nil {
+			m.mu.Unlock()
+			return
+		}
+		batch := m.pending[:min(len(m.pending), mirrorBatch):min(len(m.pending), mirrorBatch)]
+		m.mu.Unlock()
+
+		var err error
+		if resume {
+			var cursor uint64
+			if cursor, err = m.sink.Cursor(m.ctx); err == nil {
+				m.ack(cursor)
+				resume = false
+				continue
+			}
+		} else {
+			err = m.sink.Send(m.ctx, batch)
+			if err == nil {
+				m.ack(batch[len(batch)-1].Seq)
+			}
+		}
+		m.mu.Lock()
+		m.err = err
+		m.mu.Unlock()
+		if err == nil {
+			backoff = 0
+			continue
+		}
+		resume = true
+		backoff = min(max(2*backoff, 10*time.Millisecond), time.Second)
+		timer := m.q.clock.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-m.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// ack forgets the records up to given Seq, the remote has them.
+func (m *Mirror) ack(seq uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := 0
+	for i < len(m.pending) && m.pending[i].Seq <= seq {
+		i += 1
+	}
+	m.pending = slices.Delete(m.pending, 0, i)
+	if seq > m.sent {
+		m.sent = seq
+	}
+}
+
+// Cursor returns Seq of the last record the sink has taken.
+func (m *Mirror) Cursor() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sent
+}
+
+// Lag returns number of records waiting to be forwarded.
+func (m *Mirror) Lag() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.pending)
+}
+
+// Err returns the error of the last failed attempt to forward
+// records, or nil when the last one succeeded.
+func (m *Mirror) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Close stops mirroring and waits for the background forwarding to
+// end. Records not forwarded yet are dropped.
+func (m *Mirror) Close() {
+	m.q.cond.L.Lock()
+	m.q.mirrors = slices.DeleteFunc(m.q.mirrors, func(x *Mirror) bool {
+		return x == m
+	})
+	m.q.cond.L.Unlock()
+	m.cancel()
+	m.mu.Lock()
+	m.cond.Broadcast()
+	m.mu.Unlock()
+	<-m.done
+}
+
+// ApplyMirror applies the mirrored change to the queue, so it
+// follows the mirrored one. Enqueued items already waiting in the
+// queue are skipped, updated ones replace the waiting item with
+// their id, and for the other changes the waiting item with the id
+// is removed, if there is one, so records applied again don't
+// duplicate items.
+func (q *Queue) ApplyMirror(rec MirrorRecord) error {
+	switch rec.Kind {
+	case Enqueued:
+		if err := q.EnqueueIfNotQueued(rec.Item); err != ErrDuplicate {
+			return err
+		}
+	case Updated:
+		_, err := q.EnqueueReplace(rec.Item)
+		return err
+	default:
+		q.Remove(rec.Item.Id())
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakySink applies records to the standby queue, but every other
+// Send loses its reply, as if the connection broke.
+type flakySink struct {
+	mu      sync.Mutex
+	standby *Queue
+	cursor  uint64
+	sends   int
+}
+
+func (s *flakySink) Send(ctx context.Context, records []MirrorRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range records {
+		if rec.Seq <= s.cursor {
+			return errors.New("records out of order")
+		}
+		s.standby.ApplyMirror(rec)
+		s.cursor = rec.Seq
+	}
+	s.sends += 1
+	if s.sends%2 == 1 {
+		return errors.New("connection lost")
+	}
+	return nil
+}
+
+func (s *flakySink) Cursor(ctx context.Context) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursor, nil
+}
+
+func TestMirror(t *testing.T) {
+	q := New(0)
+	sink := &flakySink{standby: New(0)}
+	m := q.Mirror(sink)
+	for i, x := range []int{3, 1, 2, 4} {
+		q.Enqueue(&stateTask{Name: string(rune('a' + i)), Priority: x})
+		time.Sleep(5 * time.Millisecond)
+	}
+	q.Dequeue()
+	q.Remove("d")
+
+	for deadline := time.Now().Add(5 * time.Second); m.Lag() > 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if m.Lag() != 0 || m.Cursor() != 6 {
+		t.Errorf("Expected all 6 records forwarded, given lag %d cursor %d", m.Lag(), m.Cursor())
+	}
+	m.Close()
+	q.Enqueue(&stateTask{Name: "e", Priority: 0})
+
+	standby := sink.standby
+	if standby.Len() != 2 {
+		t.Errorf("Expected 2 items on standby, given %d", standby.Len())
+	}
+	for _, name := range []string{"c", "a"} {
+		if task := standby.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected %s on standby, given %s", name, task.Name)
+		}
+	}
+	if err := standby.ApplyMirror(MirrorRecord{Seq: 1, Event: Event{Kind: Enqueued, Item: &stateTask{Name: "a"}}}); err != nil {
+		t.Errorf("Expected enqueue applied again to be fine, given %v", err)
+	}
+	standby.ApplyMirror(MirrorRecord{Seq: 1, Event: Event{Kind: Enqueued, Item: &stateTask{Name: "a"}}})
+	if standby.Len() != 1 {
+		t.Errorf("Expected no duplicate on standby, given %d", standby.Len())
+	}
+}
@@ -0,0 +1,88 @@
+package pqueue
+
+import (
+	"context"
+	"slices"
+)
+
+// Mux takes items from several queues, always the best one of the
+// items ready in them, so one consumer can serve eg. queues of several
+// tenants without a goroutine per queue. Queues are used as they
+// are, items are still enqueued to them directly. Items of different
+// queues are compared the way the first queue orders its items.
+type Mux struct {
+	queues []*Queue
+	notify *notifier
+}
+
+// NewMux creates a multiplexer of given queues. Call Close once it's
+// not needed, so the queues forget it.
+func NewMux(queues ...*Queue) *Mux {
+	m := &Mux{queues: queues, notify: newNotifier()}
+	for _, q := range queues {
+		q.cond.L.Lock()
+		q.watchers = append(q.watchers, m.notify)
+		q.cond.L.Unlock()
+	}
+	return m
+}
+
+// Dequeue takes the best item of all the queues, waiting for one
+// while they are all empty. Once all the queues are closed and
+// drained, or the mux is closed, it returns nil.
+func (m *Mux) Dequeue() QueueItem {
+	item, _ := m.DequeueContext(context.Background())
+	return item
+}
+
+// DequeueContext is Dequeue giving up when the context is done,
+// with the context's error. Once all the queues are closed and
+// drained, or the mux is closed, it returns ErrClosed.
+func (m *Mux) DequeueContext(ctx context.Context) (QueueItem, error) {
+	return m.notify.wait(ctx, m.TryDequeue, m.drained)
+}
+
+// TryDequeue takes the best item of all the queues without
+// blocking. It returns false when all the queues are empty.
+func (m *Mux) TryDequeue() (QueueItem, bool) {
+	if m.notify.isClosed() {
+		return nil, false
+	}
+	return tryBest(m.queues)
+}
+
+// Len returns number of items in all the queues.
+func (m *Mux) Len() (n int) {
+	for _, q := range m.queues {
+		n += q.Len()
+	}
+	return
+}
+
+// Close releases consumers waiting on the mux and detaches it from
+// the queues, which stay open.
+func (m *Mux) Close() {
+	for _, q := range m.queues {
+		q.cond.L.Lock()
+		q.watchers = slices.DeleteFunc(q.watchers, func(n *notifier) bool {
+			return n == m.notify
+		})
+		q.cond.L.Unlock()
+	}
+	m.notify.close()
+}
+
+func (m *Mux) drained() bool {
+	if m.notify.isClosed() {
+		return true
+	}
+	for _, q := range m.queues {
+		q.mu.RLock()
+		drained := q.closed && q.size() == 0
+		q.mu.RUnlock()
+		if !drained {
+			return false
+		}
+	}
+	return true
+}
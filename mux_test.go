@@ -0,0 +1,88 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMux(t *testing.T) {
+	a, b := New(0), New(0)
+	m := NewMux(a, b)
+	defer m.Close()
+	a.Enqueue(&stateTask{Name: "a3", Priority: 3})
+	b.Enqueue(&stateTask{Name: "b1", Priority: 1})
+	a.Enqueue(&stateTask{Name: "a2", Priority: 2})
+	for _, name := range []string{"b1", "a2", "a3"} {
+		if task := m.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+	go func() {
+		time.Sleep(2e7)
+		b.Enqueue(&stateTask{Name: "b4", Priority: 4})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if item, err := m.DequeueContext(ctx); err != nil || item.(*stateTask).Name != "b4" {
+		t.Errorf("Expected mux to wait for an item, given %v", err)
+	}
+	a.Close()
+	b.Close()
+	if _, err := m.DequeueContext(ctx); err != ErrClosed {
+		t.Errorf("Expected ErrClosed once all the queues are closed, given %v", err)
+	}
+}
+
+func TestMuxClose(t *testing.T) {
+	q := New(0)
+	m := NewMux(q)
+	done := make(chan error)
+	go func() {
+		_, err := m.DequeueContext(context.Background())
+		done <- err
+	}()
+	time.Sleep(2e7)
+	m.Close()
+	if err := <-done; err != ErrClosed {
+		t.Errorf("Expected ErrClosed from closed mux, given %v", err)
+	}
+	if len(q.watchers) != 0 {
+		t.Errorf("Expected mux to be detached from the queue")
+	}
+}
+
+func TestMuxNotReady(t *testing.T) {
+	a, b := New(0), NewWithOptions(WithDequeueRate(1, 1))
+	m := NewMux(a, b)
+	defer m.Close()
+	a.Enqueue(&stateTask{Name: "a1", Priority: 1})
+	b.Enqueue(&stateTask{Name: "b2", Priority: 2})
+	b.Enqueue(&stateTask{Name: "b3", Priority: 3})
+	a.Pause()
+	if item, ok := m.TryDequeue(); !ok || item.(*stateTask).Name != "b2" {
+		t.Errorf("Expected the item of the queue not paused, given %v", item)
+	}
+	done := make(chan bool)
+	go func() {
+		_, ok := m.TryDequeue()
+		done <- ok
+	}()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Errorf("Expected nothing from paused and rate limited queues")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected TryDequeue not to spin over items not ready")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.DequeueContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected to wait for a ready item, given %v", err)
+	}
+	a.Resume()
+	if item, ok := m.TryDequeue(); !ok || item.(*stateTask).Name != "a1" {
+		t.Errorf("Expected the item of resumed queue, given %v", item)
+	}
+}
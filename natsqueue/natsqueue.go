@@ -0,0 +1,159 @@
+// Package natsqueue bridges a NATS JetStream consumer to a
+// pqueue.Queue, so messages keep JetStream's durability while they
+// are processed in priority order, with the queue's uniqueness. A
+// message is acked to JetStream only once its item is acked to the
+// bridge, so items lost with the process are redelivered.
+package natsqueue
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ErrUnknown is returned by Ack and Nack for items which haven't
+// come from the bridge or have already been acked.
+var ErrUnknown = errors.New("Unknown item")
+
+// Options configure how messages become items.
+type Options struct {
+	// Decode turns message data to an item.
+	Decode func([]byte) (pqueue.QueueItem, error)
+	// KeepAlive is how often JetStream is told that messages of
+	// items waiting in the queue are still being worked on, so
+	// they aren't redelivered. It should be well below the ack
+	// wait of the consumer. With 0 it's never told.
+	KeepAlive time.Duration
+}
+
+// Bridge moves messages of a JetStream consumer to the queue.
+type Bridge struct {
+	q    *pqueue.Queue
+	opts Options
+	cc   jetstream.ConsumeContext
+	stop chan struct{}
+	done chan struct{}
+
+	mu   sync.Mutex
+	msgs map[interface{}]jetstream.Msg
+}
+
+// Start starts consuming the messages of given consumer, putting
+// them to the queue with EnqueueUnique. Redeliveries of messages
+// whose items are still waiting, or have been processed already,
+// don't put them to the queue again. Messages which can't be decoded
+// or enqueued are terminated or nacked. Call Stop once done.
+func Start(q *pqueue.Queue, cons jetstream.Consumer, opts Options) (*Bridge, error) {
+	b := &Bridge{
+		q:    q,
+		opts: opts,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		msgs: make(map[interface{}]jetstream.Msg),
+	}
+	cc, err := cons.Consume(b.handle)
+	if err != nil {
+		return nil, err
+	}
+	b.cc = cc
+	go b.keepAlive()
+	return b, nil
+}
+
+func (b *Bridge) handle(msg jetstream.Msg) {
+	item, err := b.opts.Decode(msg.Data())
+	if err != nil {
+		msg.Term()
+		return
+	}
+	id := item.Id()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, pending := b.msgs[id]; pending {
+		// redelivered while waiting, acking either one will do
+		b.msgs[id] = msg
+		return
+	}
+	switch err := b.q.EnqueueUnique(item); {
+	case err == nil:
+		b.msgs[id] = msg
+	case err == pqueue.ErrDuplicate:
+		// processed already
+		msg.Ack()
+	default:
+		msg.Nak()
+	}
+}
+
+func (b *Bridge) keepAlive() {
+	defer close(b.done)
+	if b.opts.KeepAlive <= 0 {
+		<-b.stop
+		return
+	}
+	ticker := time.NewTicker(b.opts.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.mu.Lock()
+			for _, msg := range b.msgs {
+				msg.InProgress()
+			}
+			b.mu.Unlock()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Ack tells the item has been processed, so its message is acked to
+// JetStream and gone for good.
+func (b *Bridge) Ack(item pqueue.QueueItem) error {
+	msg, err := b.take(item)
+	if err != nil {
+		return err
+	}
+	return msg.Ack()
+}
+
+// Nack tells the item hasn't been processed, so JetStream redelivers
+// its message. The item's id is forgotten by the queue history, so
+// the redelivered message is enqueued again.
+func (b *Bridge) Nack(item pqueue.QueueItem) error {
+	msg, err := b.take(item)
+	if err != nil {
+		return err
+	}
+	b.q.RemoveFromHistory(item.Id())
+	return msg.Nak()
+}
+
+func (b *Bridge) take(item pqueue.QueueItem) (jetstream.Msg, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	msg, ok := b.msgs[item.Id()]
+	if !ok {
+		return nil, ErrUnknown
+	}
+	delete(b.msgs, item.Id())
+	return msg, nil
+}
+
+// Pending returns number of messages not acked yet.
+func (b *Bridge) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.msgs)
+}
+
+// Stop stops consuming. Messages not acked yet are redelivered by
+// JetStream once their ack wait passes.
+func (b *Bridge) Stop() {
+	b.cc.Stop()
+	close(b.stop)
+	<-b.done
+}
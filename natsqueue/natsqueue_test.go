@@ -0,0 +1,99 @@
+package natsqueue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+var options = Options{
+	Decode: func(data []byte) (pqueue.QueueItem, error) {
+		t := &task{}
+		return t, json.Unmarshal(data, t)
+	},
+	KeepAlive: 50 * time.Millisecond,
+}
+
+func TestBridge(t *testing.T) {
+	srv, err := server.NewServer(&server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Expected server, given %v", err)
+	}
+	srv.Start()
+	defer srv.Shutdown()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatalf("Expected server to be ready")
+	}
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("Expected connection, given %v", err)
+	}
+	defer nc.Close()
+	js, _ := jetstream.New(nc)
+	ctx := context.Background()
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "TASKS", Subjects: []string{"tasks"}})
+	if err != nil {
+		t.Fatalf("Expected stream, given %v", err)
+	}
+	cons, err := stream.CreateConsumer(ctx, jetstream.ConsumerConfig{Durable: "worker", AckPolicy: jetstream.AckExplicitPolicy, AckWait: time.Second})
+	if err != nil {
+		t.Fatalf("Expected consumer, given %v", err)
+	}
+	for _, tk := range []task{{"a", 3}, {"b", 1}, {"c", 2}, {"b", 1}} {
+		data, _ := json.Marshal(tk)
+		if _, err := js.Publish(ctx, "tasks", data); err != nil {
+			t.Fatalf("Expected message published, given %v", err)
+		}
+	}
+
+	q := pqueue.New(0)
+	b, err := Start(q, cons, options)
+	if err != nil {
+		t.Fatalf("Expected bridge to start, given %v", err)
+	}
+	defer b.Stop()
+	for deadline := time.Now().Add(5 * time.Second); q.Len() < 3 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, name := range []string{"b", "c", "a"} {
+		item := q.Dequeue()
+		if item.(*task).Name != name {
+			t.Errorf("Expected %s, given %s", name, item.(*task).Name)
+		}
+		if err := b.Ack(item); err != nil {
+			t.Errorf("Expected ack, given %v", err)
+		}
+	}
+	if err := b.Ack(&task{Name: "a"}); err != ErrUnknown {
+		t.Errorf("Expected ErrUnknown for acked item, given %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if q.Len() != 0 {
+		t.Errorf("Expected duplicate message not enqueued, given %d", q.Len())
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if info, _ := cons.Info(ctx); info.NumAckPending == 0 {
+			return
+		}
+	}
+	t.Errorf("Expected all messages acked to JetStream")
+}
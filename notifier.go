@@ -0,0 +1,114 @@
+package pqueue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// notifier puts consumers of a queue made of several queues to
+// sleep until an item shows up in any of them. It may be woken up
+// with a queue locked, so it never calls the queues with its own
+// lock held.
+type notifier struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	gen     uint64
+	waiters int32
+	closed  bool
+}
+
+func newNotifier() *notifier {
+	n := new(notifier)
+	n.cond = sync.NewCond(&n.mu)
+	return n
+}
+
+// wait calls try until it gets an item, sleeping between the calls
+// until woken up. It gives up when the context is done, or with
+// ErrClosed once drained tells nothing is going to show up.
+func (n *notifier) wait(ctx context.Context, try func() (QueueItem, bool), drained func() bool) (QueueItem, error) {
+	stop := context.AfterFunc(ctx, n.wake)
+	defer stop()
+	atomic.AddInt32(&n.waiters, 1)
+	defer atomic.AddInt32(&n.waiters, -1)
+	for {
+		n.mu.Lock()
+		gen := n.gen
+		n.mu.Unlock()
+		if item, ok := try(); ok {
+			return item, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if drained() {
+			return nil, ErrClosed
+		}
+		// anything showing up since gen has been read wakes us
+		n.mu.Lock()
+		for n.gen == gen && ctx.Err() == nil {
+			n.cond.Wait()
+		}
+		n.mu.Unlock()
+	}
+}
+
+// wake wakes the waiting consumers, if there are any.
+func (n *notifier) wake() {
+	if atomic.LoadInt32(&n.waiters) == 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.gen++
+	n.cond.Broadcast()
+}
+
+// close wakes the waiting consumers for good.
+func (n *notifier) close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	n.gen++
+	n.cond.Broadcast()
+}
+
+// isClosed tells if the notifier has been closed.
+func (n *notifier) isClosed() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.closed
+}
+
+// tryBest takes the best of the items ready in given queues, without
+// blocking. A queue which doesn't hand its item over, because it has
+// been taken meanwhile, is passed over for the next best one, so it
+// returns false once none of the queues has an item ready. The items
+// are ordered the way the first queue orders its own, see
+// Queue.lessEntries.
+func tryBest(queues []*Queue) (QueueItem, bool) {
+	type head struct {
+		q *Queue
+		e *entry
+	}
+	heads := make([]head, 0, len(queues))
+	for _, q := range queues {
+		if e := q.ready(); e != nil {
+			heads = append(heads, head{q, e})
+		}
+	}
+	if len(heads) > 1 {
+		less := queues[0].lessEntries
+		sort.SliceStable(heads, func(i, j int) bool {
+			return less(heads[i].e, heads[j].e)
+		})
+	}
+	for _, h := range heads {
+		if item, ok := h.q.TryDequeue(); ok {
+			return item, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,93 @@
+package pqueue
+
+import "time"
+
+// Option configures a queue created with NewWithOptions.
+type Option func(q *Queue)
+
+// NewWithOptions creates and initializes a new priority queue,
+// configured with given options. Without options the queue is
+// the same as the one created with New(0).
+func NewWithOptions(opts ...Option) (q *Queue) {
+	q = New(0)
+	for _, opt := range opts {
+		opt(q)
+	}
+	for _, start := range q.starts {
+		start()
+	}
+	q.starts = nil
+	return
+}
+
+// WithLimit sets the queue limit. If 0 given, then queue will be
+// unlimited.
+func WithLimit(max int) Option {
+	return func(q *Queue) {
+		q.Limit = max
+	}
+}
+
+// WithStableOrder turns stable ordering on, see SetStableOrder.
+func WithStableOrder() Option {
+	return func(q *Queue) {
+		q.items.stable = true
+	}
+}
+
+// WithOverflowPolicy sets what happens when an item is enqueued to
+// a full queue, see SetOverflowPolicy.
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(q *Queue) {
+		q.overflow = p
+	}
+}
+
+// WithRank orders the queue by scores computed with given RankFunc,
+// see NewRanked.
+func WithRank(rank RankFunc) Option {
+	return func(q *Queue) {
+		q.rank = rank
+		q.items.ranked = rank != nil
+	}
+}
+
+// WithRetryPolicy sets the policy used by Retry.
+func WithRetryPolicy(maxRetries int, base, max time.Duration) Option {
+	return func(q *Queue) {
+		q.retry = &RetryPolicy{MaxRetries: maxRetries, Base: base, Max: max}
+	}
+}
+
+// Order tells which items are dequeued first.
+type Order int
+
+const (
+	// Ascending order dequeues the least item first.
+	Ascending Order = iota
+	// Descending order dequeues the greatest item first, as if
+	// Less and the ranks were inverted.
+	Descending
+)
+
+// WithOrder sets the order of the queue. Stable order still keeps
+// items of equal priority in the order they have been enqueued.
+func WithOrder(order Order) Option {
+	return func(q *Queue) {
+		q.items.descending = order == Descending
+	}
+}
+
+// WithLess orders the items with given function instead of their
+// Less, see SetLess.
+func WithLess(less func(a, b QueueItem) bool) Option {
+	return func(q *Queue) {
+		q.items.itemLess = less
+	}
+}
+
+// NewMax creates a queue which dequeues the greatest item first,
+// see WithOrder.
+func NewMax(max int) *Queue {
+	return NewWithOptions(WithLimit(max), WithOrder(Descending))
+}
@@ -0,0 +1,56 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	q := NewWithOptions(
+		WithLimit(2),
+		WithStableOrder(),
+		WithOverflowPolicy(DropOldest),
+		WithRetryPolicy(3, time.Second, time.Minute),
+	)
+	if q.Limit != 2 || !q.items.stable || q.overflow != DropOldest {
+		t.Errorf("Expected options to configure the queue")
+	}
+	if q.retry == nil || q.retry.MaxRetries != 3 || q.retry.Base != time.Second || q.retry.Max != time.Minute {
+		t.Errorf("Expected retry policy to be set")
+	}
+	for _, x := range []int{1, 2, 3} {
+		if err := q.Enqueue(NewDummyTask(x)); err != nil {
+			t.Errorf("Expected overflow policy to make room, given %v", err)
+		}
+	}
+}
+
+func TestNewWithoutOptions(t *testing.T) {
+	q := NewWithOptions()
+	if q.Limit != 0 || q.items.stable || q.items.ranked || q.overflow != Reject {
+		t.Errorf("Expected default queue")
+	}
+}
+
+func TestDescendingOrder(t *testing.T) {
+	q := NewMax(0)
+	for _, x := range []int{2, 5, 1, 4, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	for _, x := range []int{5, 4, 3, 2, 1} {
+		if task := q.Dequeue().(*DummyTask); task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+	r := NewWithOptions(WithOrder(Descending), WithStableOrder(), WithRank(func(item QueueItem, _ QueueState) int64 {
+		return int64(item.(*stateTask).Priority)
+	}))
+	for i, x := range []int{1, 2, 2} {
+		r.Enqueue(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	for _, name := range []string{"b", "c", "a"} {
+		if task := r.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+}
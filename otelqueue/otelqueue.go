@@ -0,0 +1,183 @@
+// Package otelqueue instruments a pqueue.Queue with OpenTelemetry,
+// see WithTelemetry. Items enqueued carry the trace context of the
+// producer in their metadata, so consumers continue the same trace,
+// and spans are recorded for enqueue and dequeue. Queue depth and
+// time items spend waiting in the queue are recorded as metrics.
+package otelqueue
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/mileusna/gopqueue/otelqueue"
+
+// Option configures the instrumentation.
+type Option func(c *config)
+
+type config struct {
+	mp         metric.MeterProvider
+	propagator propagation.TextMapPropagator
+	name       string
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		mp:         otel.GetMeterProvider(),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithMeterProvider sets the meter provider used for metrics.
+// Without it the global one is used.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.mp = mp
+	}
+}
+
+// WithPropagator sets the propagator carrying trace context with
+// items. Without it the global one is used.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// WithName sets the queue name recorded with spans and metrics.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithTelemetry instruments the queue with spans recorded by given
+// tracer provider, or the global one when it's nil, as a middleware,
+// see pqueue.Queue.Use. Trace context is injected into the metadata
+// values of items enqueued through the middleware chain, ie. with
+// Enqueue, EnqueueContext and EnqueueUnique, see Extract. Failures to
+// create the metric instruments go to otel.Handle.
+func WithTelemetry(tp trace.TracerProvider, opts ...Option) pqueue.Option {
+	return func(q *pqueue.Queue) {
+		if tp == nil {
+			tp = otel.GetTracerProvider()
+		}
+		c := newConfig(opts)
+		t := &telemetry{tracer: tp.Tracer(scope), propagator: c.propagator}
+		if c.name != "" {
+			t.attrs = []attribute.KeyValue{attribute.String("messaging.destination.name", c.name)}
+		}
+		meter := c.mp.Meter(scope)
+		var err error
+		t.wait, err = meter.Float64Histogram("pqueue.wait_time",
+			metric.WithDescription("Time items spend waiting in the queue."),
+			metric.WithUnit("s"))
+		if err != nil {
+			otel.Handle(err)
+		}
+		_, err = meter.Int64ObservableGauge("pqueue.depth",
+			metric.WithDescription("Number of items in the queue."),
+			metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+				o.Observe(int64(q.Len()), metric.WithAttributes(t.attrs...))
+				return nil
+			}))
+		if err != nil {
+			otel.Handle(err)
+		}
+		t.now = q.Now
+		q.Use(t.middleware)
+	}
+}
+
+type telemetry struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	attrs      []attribute.KeyValue
+	wait       metric.Float64Histogram
+	// now tells the time of the queue clock
+	now func() time.Time
+}
+
+func (t *telemetry) middleware(op pqueue.Op, next pqueue.Handler) pqueue.Handler {
+	if op == pqueue.OpEnqueue {
+		return t.enqueue(next)
+	}
+	return t.dequeue(next)
+}
+
+// enqueue records enqueue span started from the producer's context
+// and injects the span's context into the item's metadata values.
+// Metadata the caller has put to the context is kept.
+func (t *telemetry) enqueue(next pqueue.Handler) pqueue.Handler {
+	return func(ctx context.Context, item pqueue.QueueItem) (pqueue.QueueItem, error) {
+		ctx, span := t.tracer.Start(ctx, "pqueue.enqueue",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(t.attrs...))
+		defer span.End()
+		var md pqueue.Metadata
+		if given, ok := pqueue.MetadataFromContext(ctx); ok {
+			md = *given
+		}
+		carrier := propagation.MapCarrier(maps.Clone(md.Values))
+		if carrier == nil {
+			carrier = propagation.MapCarrier{}
+		}
+		t.propagator.Inject(ctx, carrier)
+		md.Values = carrier
+		item, err := next(pqueue.ContextWithMetadata(ctx, &md), item)
+		if err != nil {
+			span.RecordError(err)
+		}
+		return item, err
+	}
+}
+
+// dequeue records dequeue span, covering the wait for an item, linked
+// to the producer's span, and the time the item has waited in the
+// queue by the queue clock.
+func (t *telemetry) dequeue(next pqueue.Handler) pqueue.Handler {
+	return func(ctx context.Context, item pqueue.QueueItem) (pqueue.QueueItem, error) {
+		md, ok := pqueue.MetadataFromContext(ctx)
+		if !ok {
+			md = &pqueue.Metadata{}
+			ctx = pqueue.ContextWithMetadata(ctx, md)
+		}
+		ctx, span := t.tracer.Start(ctx, "pqueue.dequeue",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(t.attrs...))
+		defer span.End()
+		item, err := next(ctx, item)
+		if err != nil {
+			span.RecordError(err)
+			return item, err
+		}
+		if t.wait != nil {
+			t.wait.Record(ctx, t.now().Sub(md.EnqueuedAt).Seconds(), metric.WithAttributes(t.attrs...))
+		}
+		producer := t.propagator.Extract(context.Background(), propagation.MapCarrier(md.Values))
+		span.AddLink(trace.LinkFromContext(producer))
+		return item, nil
+	}
+}
+
+// Extract returns a copy of ctx carrying the trace context of the
+// item's producer, taken from the item's metadata, so spans for
+// processing the item continue the producer's trace. The metadata of
+// a dequeued item is handed out with pqueue.ContextWithMetadata or
+// DequeueWithMetadata. Only WithPropagator of given options is used.
+func Extract(ctx context.Context, md pqueue.Metadata, opts ...Option) context.Context {
+	c := newConfig(opts)
+	return c.propagator.Extract(ctx, propagation.MapCarrier(md.Values))
+}
@@ -0,0 +1,182 @@
+package otelqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+// groupedTask is dequeued one of its group at a time, so it tells if
+// the queue still sees the item's interfaces.
+type groupedTask struct {
+	task
+}
+
+func (t *groupedTask) Less(other interface{}) bool {
+	return t.Priority < other.(*groupedTask).Priority
+}
+
+func (t *groupedTask) Group() string {
+	return "g"
+}
+
+func TestTracePropagation(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	propagator := WithPropagator(propagation.TraceContext{})
+	q := pqueue.NewWithOptions(WithTelemetry(tp, WithMeterProvider(mp), propagator, WithName("jobs")))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "produce")
+	q.EnqueueContext(ctx, &task{"b", 2})
+	q.EnqueueContext(ctx, &task{"a", 1})
+	parent.End()
+
+	md := &pqueue.Metadata{}
+	item, err := q.DequeueContext(pqueue.ContextWithMetadata(context.Background(), md))
+	if err != nil || item.(*task).Name != "a" {
+		t.Fatalf("Expected to dequeue the item itself, given %v", err)
+	}
+	ctx = Extract(context.Background(), *md, propagator)
+	if sc := trace.SpanContextFromContext(ctx); sc.TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("Expected consumer context to continue producer's trace")
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 4 {
+		t.Fatalf("Expected 4 spans, given %d", len(ended))
+	}
+	dequeue := ended[3]
+	if dequeue.Name() != "pqueue.dequeue" || len(dequeue.Links()) != 1 || dequeue.Links()[0].SpanContext.TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("Expected dequeue span linked to the producer")
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Expected metrics to be collected, given %v", err)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = true
+			if g, ok := m.Data.(metricdata.Gauge[int64]); ok && g.DataPoints[0].Value != 1 {
+				t.Errorf("Expected depth 1, given %d", g.DataPoints[0].Value)
+			}
+		}
+	}
+	if !found["pqueue.depth"] || !found["pqueue.wait_time"] {
+		t.Errorf("Expected depth and wait time metrics, given %v", found)
+	}
+}
+
+func TestItemInterfaces(t *testing.T) {
+	q := pqueue.NewWithOptions(WithTelemetry(sdktrace.NewTracerProvider()))
+	q.Enqueue(&groupedTask{task{"a", 1}})
+	q.Enqueue(&groupedTask{task{"b", 2}})
+	d, err := q.Lease(context.Background())
+	if err != nil {
+		t.Fatalf("Expected lease, given %v", err)
+	}
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected the group to be busy while its item is leased")
+	}
+	d.Ack()
+	if item, ok := q.TryDequeue(); !ok || item.(*groupedTask).Name != "b" {
+		t.Errorf("Expected b once the group is free, given %v", item)
+	}
+}
+
+// clock is a queue clock standing still until it's moved.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) NewTimer(d time.Duration) pqueue.Timer {
+	return timer{time.NewTimer(d)}
+}
+
+func (c *clock) AfterFunc(d time.Duration, f func()) pqueue.Timer {
+	return timer{time.AfterFunc(d, f)}
+}
+
+type timer struct {
+	*time.Timer
+}
+
+func (t timer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+func TestWaitTime(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	c := &clock{now: time.Unix(1000, 0)}
+	q := pqueue.NewWithOptions(WithTelemetry(tp, WithMeterProvider(mp)), pqueue.WithClock(c))
+
+	done := make(chan error)
+	go func() {
+		_, err := q.DequeueContext(context.Background())
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	q.Enqueue(&task{"a", 1})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue(&task{"b", 2})
+	c.now = c.now.Add(5 * time.Second)
+	if item, ok := q.TryDequeue(); !ok || item.(*task).Name != "b" {
+		t.Fatalf("Expected to dequeue b, given %v", item)
+	}
+
+	waited := false
+	for _, s := range spans.Ended() {
+		if s.Name() == "pqueue.dequeue" && s.EndTime().Sub(s.StartTime()) >= 20*time.Millisecond {
+			waited = true
+		}
+	}
+	if !waited {
+		t.Errorf("Expected dequeue span to cover the wait for an item")
+	}
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Expected metrics to be collected, given %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok {
+				if p := h.DataPoints[0]; p.Count != 2 || p.Sum != 5 {
+					t.Errorf("Expected wait time by the queue clock, given %d items waiting %vs", p.Count, p.Sum)
+				}
+			}
+		}
+	}
+}
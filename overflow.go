@@ -0,0 +1,100 @@
+package pqueue
+
+// OverflowPolicy tells what to do with a new item when the queue
+// has reached its limit.
+type OverflowPolicy int
+
+const (
+	// Reject refuses the new item, which is the default.
+	Reject OverflowPolicy = iota
+	// DropLowest evicts the lowest priority pending item to make
+	// room for the new one. When the new item has the lowest
+	// priority of all, it's refused instead.
+	DropLowest
+	// DropOldest evicts the item that has been pending the longest
+	// to make room for the new one.
+	DropOldest
+)
+
+// SetOverflowPolicy safely changes what happens when an item is
+// enqueued to a full queue. Evicted items are reported with
+// Dropped events.
+func (q *Queue) SetOverflowPolicy(p OverflowPolicy) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.overflow = p
+}
+
+// makeRoom evicts pending entries according to the overflow
+// policy until e can be pushed. It returns false when e has to be
+// refused. Must be called with the queue locked.
+func (q *Queue) makeRoom(e *entry) bool {
+	for !q.fits(sizeOf(e.item)) {
+		victim := q.victim()
+		if victim == nil || (q.overflow == DropLowest && !q.items.less(e, victim)) {
+			return false
+		}
+		q.remove(victim)
+		q.logEvent(LogDropped, victim.item, nil)
+		q.emit(Dropped, victim.item)
+	}
+	return true
+}
+
+// victim picks the pending entry to be evicted, nil if none.
+func (q *Queue) victim() (victim *entry) {
+	switch q.overflow {
+	case DropLowest:
+		victim = q.worst()
+	case DropOldest:
+		for _, x := range q.items.list() {
+			if victim == nil || x.seq < victim.seq {
+				victim = x
+			}
+		}
+	}
+	return
+}
+
+// worst returns the lowest priority pending entry, nil if none.
+// Delayed entries are not looked at.
+func (q *Queue) worst() (worst *entry) {
+	entries := q.items.list()
+	if q.items.ext == nil {
+		// the lowest priority entry is one of the heap leaves
+		entries = entries[len(entries)/2:]
+	}
+	for _, x := range entries {
+		if worst == nil || q.items.less(worst, x) {
+			worst = x
+		}
+	}
+	return
+}
+
+// PeekWorst returns the lowest priority pending item, the one that
+// would be dequeued last, without taking it from the queue. It
+// returns false when the queue is empty.
+func (q *Queue) PeekWorst() (item QueueItem, ok bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if e := q.worst(); e != nil {
+		return e.item, true
+	}
+	return nil, false
+}
+
+// PopWorst takes the lowest priority pending item from the queue,
+// so callers can shed load their own way. It's reported by Removed
+// event. It returns false when the queue is empty.
+func (q *Queue) PopWorst() (item QueueItem, ok bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	e := q.worst()
+	if e == nil {
+		return nil, false
+	}
+	q.remove(e)
+	q.emit(Removed, e.item)
+	return e.item, true
+}
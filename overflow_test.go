@@ -0,0 +1,81 @@
+package pqueue
+
+import "testing"
+
+func TestOverflowReject(t *testing.T) {
+	q := New(2)
+	for _, x := range []int{2, 3, 1} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	for _, x := range []int{2, 3} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestOverflowDropLowest(t *testing.T) {
+	q := New(3)
+	q.SetOverflowPolicy(DropLowest)
+	events := q.Events(10)
+	for _, x := range []int{2, 5, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	if err := q.Enqueue(NewDummyTask(1)); err != nil {
+		t.Errorf("Expected to make room for better item, given %v", err)
+	}
+	if err := q.Enqueue(NewDummyTask(4)); err == nil {
+		t.Errorf("Expected to refuse the lowest priority item")
+	}
+	for i := 0; i < 3; i += 1 {
+		<-events
+	}
+	if ev := <-events; ev.Kind != Dropped || ev.Item.(*DummyTask).priority != 5 {
+		t.Errorf("Expected lowest priority item to be dropped")
+	}
+	for _, x := range []int{1, 2, 3} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	q := New(3)
+	q.SetOverflowPolicy(DropOldest)
+	for _, x := range []int{2, 5, 3, 4, 1} {
+		if err := q.Enqueue(NewDummyTask(x)); err != nil {
+			t.Errorf("Expected to make room for new item, given %v", err)
+		}
+	}
+	for _, x := range []int{1, 3, 4} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestPopWorst(t *testing.T) {
+	q := New(0)
+	if _, ok := q.PeekWorst(); ok {
+		t.Errorf("Expected nothing to peek in empty queue")
+	}
+	for _, x := range []int{4, 9, 1, 7, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	if item, ok := q.PeekWorst(); !ok || item.(*DummyTask).priority != 9 {
+		t.Errorf("Expected to peek the lowest priority item")
+	}
+	for _, x := range []int{9, 7, 4} {
+		item, ok := q.PopWorst()
+		if !ok || item.(*DummyTask).priority != x {
+			t.Errorf("Expected to pop priority %d", x)
+		}
+	}
+	if q.Len() != 2 || q.Dequeue().(*DummyTask).priority != 1 {
+		t.Errorf("Expected the best items left in order")
+	}
+}
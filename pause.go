@@ -0,0 +1,26 @@
+package pqueue
+
+// Pause stops handing out items. Consumers block like on empty
+// queue until Resume is called, while items can still be enqueued.
+// Closing the paused queue releases blocked consumers with
+// ErrClosed, without items. Peek still shows the next item.
+func (q *Queue) Pause() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.paused = true
+}
+
+// Resume continues handing out items after Pause.
+func (q *Queue) Resume() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.paused = false
+	q.wakeAll()
+}
+
+// Paused returns true if the queue is paused.
+func (q *Queue) Paused() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.paused
+}
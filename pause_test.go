@@ -0,0 +1,46 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPauseResume(t *testing.T) {
+	q := New(0)
+	q.Pause()
+	task := NewDummyTask(1)
+	if err := q.Enqueue(task); err != nil {
+		t.Errorf("Expected paused queue to accept items, given %v", err)
+	}
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected paused queue not to hand out items")
+	}
+	dequeued := make(chan QueueItem)
+	go func() {
+		dequeued <- q.Dequeue()
+	}()
+	select {
+	case <-dequeued:
+		t.Errorf("Expected Dequeue to block while paused")
+	case <-time.After(5e7):
+	}
+	q.Resume()
+	select {
+	case item := <-dequeued:
+		if item != task {
+			t.Errorf("Expected to dequeue the task after Resume")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected Resume to release the consumer")
+	}
+}
+
+func TestPausedClose(t *testing.T) {
+	q := New(0)
+	q.Enqueue(NewDummyTask(1))
+	q.Pause()
+	q.Close()
+	if _, err := q.DequeueContext(t.Context()); err != ErrClosed {
+		t.Errorf("Expected ErrClosed from paused and closed queue, given %v", err)
+	}
+}
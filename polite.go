@@ -0,0 +1,73 @@
+package pqueue
+
+import "time"
+
+// Keyed items share a key, eg. the host name of a crawled URL, which
+// the queue keeps a polite delay between, see WithPoliteness. Empty
+// key means the item isn't keyed.
+type Keyed interface {
+	Key() string
+}
+
+// WithPoliteness makes the queue keep at least given delay between
+// dequeues of Keyed items of the same key, so the queue can serve as
+// a polite crawl frontier: the best item whose key hasn't been taken
+// within the delay is dequeued, the others wait in the queue. Peek and
+// Each still see waiting items.
+func WithPoliteness(delay time.Duration) Option {
+	return func(q *Queue) {
+		q.politeness = delay
+		q.politeLast = make(map[string]time.Time)
+	}
+}
+
+// impolite tells if an item of the item's key has been dequeued within
+// the polite delay, arming the timer which wakes the waiters once the
+// delay passes. Must be called with the queue locked.
+func (q *Queue) impolite(item QueueItem) bool {
+	k, ok := item.(Keyed)
+	if !ok {
+		return false
+	}
+	last, ok := q.politeLast[k.Key()]
+	if !ok {
+		return false
+	}
+	at := last.Add(q.politeness)
+	if !q.now().Before(at) {
+		return false
+	}
+	if q.politeTimer == nil || at.Before(q.politeAt) {
+		if q.politeTimer != nil {
+			q.politeTimer.Stop()
+		}
+		q.politeAt = at
+		q.politeTimer = q.clock.AfterFunc(at.Sub(q.now()), func() {
+			q.cond.L.Lock()
+			defer q.cond.L.Unlock()
+			q.politeTimer = nil
+			q.wakeAll()
+		})
+	}
+	return true
+}
+
+// taken records when an item of the item's key has been dequeued.
+// Must be called with the queue locked.
+func (q *Queue) taken(item QueueItem) {
+	k, ok := item.(Keyed)
+	if !ok || k.Key() == "" {
+		return
+	}
+	now := q.now()
+	if len(q.politeLast) >= q.politePrune {
+		// forget the keys whose delay has passed
+		for key, last := range q.politeLast {
+			if !now.Before(last.Add(q.politeness)) {
+				delete(q.politeLast, key)
+			}
+		}
+		q.politePrune = 2*len(q.politeLast) + 64
+	}
+	q.politeLast[k.Key()] = now
+}
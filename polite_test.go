@@ -0,0 +1,54 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+type hostTask struct {
+	stateTask
+	host string
+}
+
+func (ht *hostTask) Key() string {
+	return ht.host
+}
+
+func (ht *hostTask) Less(other interface{}) bool {
+	return ht.Priority < other.(*hostTask).Priority
+}
+
+func TestPoliteness(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock), WithPoliteness(time.Second))
+	q.Enqueue(&hostTask{stateTask{"a", 1}, "x"})
+	q.Enqueue(&hostTask{stateTask{"b", 2}, "x"})
+	q.Enqueue(&hostTask{stateTask{"c", 3}, "y"})
+
+	for _, name := range []string{"a", "c"} {
+		if item, ok := q.TryDequeue(); !ok || item.(*hostTask).Name != name {
+			t.Errorf("Expected %s, given %v", name, item)
+		}
+	}
+	if item, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected b waiting for the polite delay, given %v", item)
+	}
+
+	got := make(chan string)
+	go func() {
+		item := q.Dequeue()
+		got <- item.(*hostTask).Name
+	}()
+	select {
+	case name := <-got:
+		t.Errorf("Expected b not dequeued before the delay, given %s", name)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	if name := <-got; name != "b" {
+		t.Errorf("Expected b once the delay passes, given %s", name)
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+}
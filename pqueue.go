@@ -11,8 +11,11 @@ package pqueue
 
 import (
 	"container/heap"
+	"context"
 	"errors"
+	"math"
 	"sync"
+	"time"
 )
 
 // Only items implementing this interface can be enqueued
@@ -22,6 +25,25 @@ type QueueItem interface {
 	Id() interface{}
 }
 
+// ErrClosed is returned when enqueueing to a closed queue, or
+// dequeueing from a closed queue that has been drained.
+var ErrClosed = errors.New("Queue is closed")
+
+// ErrQueueFull is returned when enqueueing to a queue which has
+// reached its limit.
+var ErrQueueFull = errors.New("Queue limit reached")
+
+// ErrDuplicate is returned by EnqueueUnique and its kin when the item
+// isn't enqueued because its id has been seen already.
+var ErrDuplicate = errors.New("Duplicate item")
+
+// ErrTimeout is returned by DequeueTimeout when no item has been
+// enqueued in time.
+var ErrTimeout = errors.New("Dequeue timed out")
+
+// errEmpty stops dequeue from waiting for an item.
+var errEmpty = errors.New("Queue is empty")
+
 // Queue is a threadsafe priority queue exchange. Here's
 // a trivial example of usage:
 //
@@ -39,73 +61,577 @@ type QueueItem interface {
 //
 type Queue struct {
 	Limit   int
-	history map[interface{}]struct{}
+	history DedupStore
 	items   *sorter
+	active  map[interface{}][]*entry // unless history indexes them, see index
 	cond    *sync.Cond
+	closed  bool
+
+	// mu is the lock of both conds, taken for reading by the
+	// methods which only look at the queue
+	mu *sync.RWMutex
+
+	// space is signalled when items leave the queue, for
+	// producers waiting on a full queue
+	space        *sync.Cond
+	spaceWaiters int
+
+	producers map[string]int
+	// classes counts pending items by class, see WithClassLimits
+	classes     map[string]int
+	classOf     func(QueueItem) string
+	classLimits map[string]int
+
+	rank RankFunc
+
+	// seq numbers enqueued entries in order of arrival
+	seq      uint64
+	overflow OverflowPolicy
+
+	retry    *RetryPolicy
+	attempts map[interface{}]int
+
+	events        []chan Event
+	eventsDropped uint64
+
+	approx approxLen
+
+	wal *WAL
+
+	visibility time.Duration
+	leased     map[*Delivery]struct{}
+	// busy are groups with a leased item not acked yet
+	busy map[string]bool
+	// maxBytes is the byte budget of pending items, and bytes
+	// their total size, see Sizer
+	maxBytes int
+	bytes    int
+	// bumps counts Bump calls, ordering the bumped entries
+	bumps uint64
+	// producerShare is the share of the limit a producer may take
+	producerShare float64
+	// reservations are entries reserved with Reserve
+	reservations map[*entry]struct{}
+	// results are producers of EnqueueWait waiting, by item id
+	results map[interface{}][]chan Result
+
+	middleware []Middleware
+
+	compressor Compressor
+	keys       KeyProvider
+
+	mirrors []*Mirror
+	logger  *logger
+
+	maxDeliveries int
+	deadLetter    func(QueueItem)
+
+	delayed    timeHeap
+	delayTimer Timer
+
+	stats  Stats
+	paused bool
+	hooks  hooks
+
+	// waiters are consumers waiting for an item, in order of
+	// their arrival
+	waiters []*sync.Cond
+	// watchers are woken up whenever waiters are, see Mux
+	watchers []*notifier
+
+	limiter   *limiter
+	hasher    func(QueueItem) uint64
+	normalize func(id interface{}) interface{}
+
+	// reserved is the room reserved with Preheat, which is kept
+	// when the queue shrinks
+	reserved   int
+	activePeak int
+
+	lenWatches []*lenWatch
+
+	// latency counts wait times of dequeued items
+	latency Histogram
+	// rates count the events of the last minutes, see Stats
+	rates *rates
+	// processed are ids marked with MarkProcessed
+	processed DedupStore
+	// dependents counts pending Dependent items
+	dependents int
+	// ackProcessed marks acked items processed
+	ackProcessed bool
+	// occurrences count enqueues of history ids, see WithMaxDuplicates
+	maxDuplicates    int
+	occurrences      map[interface{}]int
+	occurrencesPrune int
+	// spill keeps items spilled to files, see WithSpill
+	spill *spill
+	// randomTop is how many best items a random one is taken of
+	randomTop int
+	// politeness is the delay between items of a key, see Keyed
+	politeness  time.Duration
+	politeLast  map[string]time.Time
+	politePrune int
+	politeTimer Timer
+	politeAt    time.Time
+
+	clock Clock
+	// starts are run once all the options are applied, to start
+	// background work which depends on them
+	starts []func()
+	// closers are run once when the queue is closed, unlocked
+	closers []func()
 }
 
 // New creates and initializes a new priority queue, taking
 // a limit as a parameter. If 0 given, then queue will be
 // unlimited.
 func New(max int) (q *Queue) {
-	var locker sync.Mutex
 	q = &Queue{Limit: max}
-	q.history = make(map[interface{}]struct{}, 0)
+	q.history = newHistory()
 	q.items = new(sorter)
-	q.cond = sync.NewCond(&locker)
-	heap.Init(q.items)
+	q.active = make(map[interface{}][]*entry)
+	q.producers = make(map[string]int)
+	q.attempts = make(map[interface{}]int)
+	q.leased = make(map[*Delivery]struct{})
+	q.mu = new(sync.RWMutex)
+	q.cond = sync.NewCond(q.mu)
+	q.space = sync.NewCond(q.mu)
+	WithLatencyBuckets(DefaultLatencyBuckets...)(q)
+	q.hooks.idle.L = &q.hooks.mu
+	q.clock = systemClock{}
+	q.items.init()
 	return
 }
 
 // Enqueue puts given item to the queue.
 // Lock the queue and calls enqueue()
 func (q *Queue) Enqueue(item QueueItem) (err error) {
+	_, err = q.intercept(OpEnqueue, context.Background(), item, func(ctx context.Context, item QueueItem) (QueueItem, error) {
+		q.cond.L.Lock()
+		defer q.cond.L.Unlock()
+		return item, q.enqueueEntry(newEntry(ctx, item))
+	})
+	return
+}
+
+// EnqueueAll puts given items to the queue at once, holding the
+// lock only once. It stops at the first item that can't be
+// enqueued, eg. because of the limit, and returns number of items
+// enqueued before it along with the error.
+func (q *Queue) EnqueueAll(items []QueueItem) (n int, err error) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	return q.enqueue(item)
+	for _, item := range items {
+		if err = q.enqueue(item); err != nil {
+			return
+		}
+		n += 1
+	}
+	return
+}
+
+// EnqueueContext puts given item to the queue. While the queue is
+// full it blocks waiting for a free place until the context is
+// done, and returns the context's error then.
+func (q *Queue) EnqueueContext(ctx context.Context, item QueueItem) (err error) {
+	_, err = q.intercept(OpEnqueue, ctx, item, func(ctx context.Context, item QueueItem) (QueueItem, error) {
+		return item, q.enqueueContext(ctx, item)
+	})
+	return
+}
+
+func (q *Queue) enqueueContext(ctx context.Context, item QueueItem) (err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	stop := context.AfterFunc(ctx, q.broadcast)
+	defer stop()
+	for (!q.fits(sizeOf(item)) || q.classFull(item)) && !q.closed {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		q.spaceWaiters += 1
+		q.space.Wait()
+		q.spaceWaiters -= 1
+	}
+	return q.enqueueEntry(newEntry(ctx, item))
+}
+
+// full tells if the queue has reached its limit, or its byte budget.
+func (q *Queue) full() bool {
+	return q.Limit > 0 && q.size() >= q.Limit || q.maxBytes > 0 && q.bytes >= q.maxBytes
+}
+
+// Cap returns the queue limit, 0 for unlimited queue.
+func (q *Queue) Cap() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.Limit
+}
+
+// Free returns number of items that can be enqueued before the queue
+// reaches its limit, math.MaxInt for unlimited queue.
+func (q *Queue) Free() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.free()
+}
+
+func (q *Queue) free() int {
+	if q.Limit <= 0 {
+		return math.MaxInt
+	}
+	return max(q.Limit-q.size(), 0)
+}
+
+// WaitForSpace blocks until there is room for n items in the queue,
+// so producers can wait before making expensive items. It's woken up
+// whenever items leave the queue or the limit changes. It returns
+// the context's error once the context is done, or ErrClosed once
+// the queue is closed. Other producers may take the room before the
+// caller enqueues, so Enqueue may still fail.
+func (q *Queue) WaitForSpace(ctx context.Context, n int) (err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	stop := context.AfterFunc(ctx, q.broadcast)
+	defer stop()
+	for q.free() < n {
+		if q.closed {
+			return ErrClosed
+		}
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		q.spaceWaiters += 1
+		q.space.Wait()
+		q.spaceWaiters -= 1
+	}
+	return
+}
+
+// WaitEmpty blocks until there are no items in the queue, delayed
+// ones included, eg. to flush a pipeline before shutting it down.
+// With leased set it also waits for all the leased items to be acked
+// or nacked, as nacked ones come back. It returns the context's
+// error once the context is done.
+func (q *Queue) WaitEmpty(ctx context.Context, leased bool) (err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	stop := context.AfterFunc(ctx, q.broadcast)
+	defer stop()
+	for q.size() > 0 || leased && len(q.leased) > 0 {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		q.spaceWaiters += 1
+		q.space.Wait()
+		q.spaceWaiters -= 1
+	}
+	return
 }
 
 // Enqueue puts given item to the queue.
 func (q *Queue) enqueue(item QueueItem) (err error) {
-	if q.Limit > 0 && q.Len() >= q.Limit {
-		return errors.New("Queue limit reached")
+	return q.enqueueEntry(&entry{item: item, id: item.Id()})
+}
+
+// enqueueEntry puts prepared entry to the queue.
+func (q *Queue) enqueueEntry(e *entry) (err error) {
+	if q.closed {
+		q.logEvent(LogRejected, e.item, ErrClosed)
+		q.emit(Dropped, e.item)
+		return ErrClosed
+	}
+	if q.rank != nil {
+		e.score = q.rank(e.item, q.state())
+	}
+	if q.classFull(e.item) || !q.fits(sizeOf(e.item)) && !q.makeRoom(e) {
+		q.stats.Rejected += 1
+		q.countRate(rateRejected)
+		q.hooks.fire(q.hooks.reject, e.item)
+		q.logEvent(LogRejected, e.item, ErrQueueFull)
+		q.emit(Dropped, e.item)
+		return ErrQueueFull
+	}
+	q.seq += 1
+	e.seq = q.seq
+	if err = q.logEnqueue(e); err != nil {
+		q.logEvent(LogPersistence, e.item, err)
+		q.emit(Dropped, e.item)
+		return
+	}
+	id := q.historyID(e.item)
+	if q.maxDuplicates > 1 {
+		q.occurred(id)
+	}
+	q.history.Add(id)
+	q.push(e)
+	q.compactWAL()
+	q.emit(Enqueued, e.item)
+	if !e.delayed {
+		if q.spill != nil {
+			q.spillOver()
+		}
+		q.signal()
 	}
-	q.history[item.Id()] = struct{}{}
-	heap.Push(q.items, item)
-	q.cond.Signal()
 	return
 }
 
 // check if item already exists in queue (or it has been into queue)
 func (q *Queue) ItemExists(item QueueItem) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.idExists(q.historyID(item))
+}
+
+func (q *Queue) IdExists(id interface{}) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.idExists(q.historyKey(id))
+}
+
+func (q *Queue) idExists(id interface{}) bool {
+	return q.history.Seen(id)
+}
+
+// Enqueue puts item in queue only if it hasn't already been in queue,
+// otherwise it returns ErrDuplicate.
+func (q *Queue) EnqueueUnique(item QueueItem) (err error) {
+	_, err = q.intercept(OpEnqueue, context.Background(), item, func(ctx context.Context, item QueueItem) (QueueItem, error) {
+		return item, q.enqueueUnique(ctx, item)
+	})
+	return
+}
+
+func (q *Queue) enqueueUnique(ctx context.Context, item QueueItem) error {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	return q.idExists(item.Id())
+	id := q.historyID(item)
+	if q.idExists(id) && !q.duplicateAllowed(id) {
+		q.duplicate(item)
+		q.historyTouch(id)
+		return ErrDuplicate
+	}
+	return q.enqueueEntry(newEntry(ctx, item))
 }
 
-func (q *Queue) IdExists(id interface{}) bool {
+// duplicate counts the item refused as a duplicate.
+func (q *Queue) duplicate(item QueueItem) {
+	q.stats.Duplicates += 1
+	q.hooks.fire(q.hooks.duplicate, item)
+	q.logEvent(LogDuplicate, item, nil)
+}
+
+// EnqueueIfNotQueued puts item in queue only if no item with the
+// same id is waiting in it right now. Unlike EnqueueUnique it
+// doesn't look at the history, so an item can be enqueued again
+// as soon as the previous one with its id has been dequeued. It
+// returns ErrDuplicate for refused items.
+func (q *Queue) EnqueueIfNotQueued(item QueueItem) error {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	return q.idExists(id)
+	if len(q.pending(item.Id())) > 0 {
+		q.duplicate(item)
+		return ErrDuplicate
+	}
+	return q.enqueue(item)
 }
 
-func (q *Queue) idExists(id interface{}) bool {
-	if _, ok := q.history[id]; ok {
-		return true
-	} else {
+// EnqueueReplace puts item in queue, or when an item with the same
+// id is waiting in it already, puts item in its place, keeping its
+// place in the arrival order, so the latest version wins. When more
+// items share the id, the one enqueued first is replaced. It returns
+// true when an item has been replaced.
+func (q *Queue) EnqueueReplace(item QueueItem) (replaced bool, err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	entries := q.pending(item.Id())
+	if len(entries) == 0 {
+		return false, q.enqueue(item)
+	}
+	return true, q.replace(entries[0], item)
+}
+
+// EnqueueIfHigher puts item in queue, or when an item with the same
+// id is waiting in it already, puts item in its place only if item
+// would be dequeued before it, like relaxing a node in Dijkstra's
+// algorithm. Otherwise item is dropped and ErrDuplicate returned.
+// When more items share the id, the one enqueued first is compared.
+func (q *Queue) EnqueueIfHigher(item QueueItem) error {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	entries := q.pending(item.Id())
+	if len(entries) == 0 {
+		return q.enqueue(item)
+	}
+	e := entries[0]
+	x := &entry{item: item, seq: e.seq}
+	if q.rank != nil {
+		x.score = q.rank(item, q.state())
+	}
+	if !q.items.less(x, e) {
+		q.duplicate(item)
+		return ErrDuplicate
+	}
+	return q.replace(e, item)
+}
+
+// replace puts item in place of the pending entry's item and puts
+// the entry back in order.
+func (q *Queue) replace(e *entry, item QueueItem) error {
+	if q.wal != nil {
+		x := *e
+		x.item = item
+		rec, err := walEnqueueRecord(&x)
+		if err != nil {
+			return err
+		}
+		// log the entry again under the same sequence number
+		q.logRemove(e)
+		q.wal.fail(q.wal.write(rec, nil))
+	}
+	e.item = item
+	if q.rank != nil {
+		e.score = q.rank(e.item, q.state())
+	}
+	if !e.delayed {
+		q.items.fixEntry(e)
+	}
+	q.emit(Updated, e.item)
+	return nil
+}
+
+// Remove takes the pending item with given id out of the queue,
+// without dequeueing it. When more items share the id, the one
+// enqueued first is removed. It returns false when no such item
+// is waiting in the queue.
+func (q *Queue) Remove(id interface{}) (item QueueItem, ok bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	entries := q.pending(id)
+	if len(entries) == 0 {
+		return nil, false
+	}
+	e := entries[0]
+	q.remove(e)
+	q.emit(Removed, e.item)
+	return e.item, true
+}
+
+// UpdatePriority calls update on the pending item with given id
+// and puts the item back in order, so update can change anything
+// Less depends on. Ranked queues score the item again. When more
+// items share the id, the one enqueued first is updated. It returns
+// false when no such item is waiting in the queue.
+func (q *Queue) UpdatePriority(id interface{}, update func(QueueItem)) bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	entries := q.pending(id)
+	if len(entries) == 0 {
 		return false
 	}
+	e := entries[0]
+	update(e.item)
+	if q.rank != nil {
+		e.score = q.rank(e.item, q.state())
+	}
+	if !e.delayed {
+		q.items.fixEntry(e)
+	}
+	q.logUpdate(e)
+	q.emit(Updated, e.item)
+	return true
 }
 
-// Enqueue puts item in queue only if it hasn't already been in queue
-func (q *Queue) EnqueueUnique(item QueueItem) (added bool, err error) {
+// Reprioritize calls update on every pending item, delayed ones
+// included, and puts the items back in order all at once, eg. to
+// lower the priority of all the items of some tenant. Ranked queues
+// score the items again. The queue stays locked meanwhile, so update
+// must not call the queue.
+func (q *Queue) Reprioritize(update func(QueueItem)) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	if !q.idExists(item.Id()) {
-		err = q.enqueue(item)
-		added = true
+	entries := append(q.items.list(), q.delayed...)
+	var state QueueState
+	if q.rank != nil {
+		state = q.state()
+	}
+	for _, e := range entries {
+		update(e.item)
+		if q.rank != nil {
+			e.score = q.rank(e.item, state)
+		}
+		q.logUpdate(e)
+		q.emit(Updated, e.item)
+	}
+	q.items.init()
+}
+
+// Bump moves the pending item with given id to the front of the
+// queue, ahead of all the items whatever their priority, eg. when
+// a user asks to run a queued job now. Delayed item is made ready.
+// Bumped items are dequeued in order they were bumped in. The bump
+// is not written to the write-ahead log or snapshots. When more
+// items share the id, the one enqueued first is bumped. It returns
+// false when no such item is waiting in the queue.
+func (q *Queue) Bump(id interface{}) bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	entries := q.pending(id)
+	if len(entries) == 0 {
+		return false
+	}
+	e := entries[0]
+	q.bumps += 1
+	e.bumped = q.bumps
+	if e.delayed {
+		heap.Remove(&q.delayed, e.index)
+		e.delayed = false
+		e.readyAt = time.Time{}
+		q.armDelayTimer()
+		q.items.push(e)
+		q.signal()
+	} else {
+		q.items.fixEntry(e)
+	}
+	q.emit(Updated, e.item)
+	return true
+}
+
+// Clear removes all the pending items, delayed ones included, from
+// the queue. Unless keepHistory is set, the history is cleared too.
+func (q *Queue) Clear(keepHistory bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.clear(Removed)
+	if !keepHistory {
+		q.history.Clear()
+		q.logHistory(walClearHistory, nil)
 	}
-	return
+}
+
+// clear removes all the pending items, emitting given event for
+// every one of them, and returns how many there were.
+func (q *Queue) clear(kind EventKind) int {
+	entries := append(q.items.list(), q.delayed...)
+	q.items.reset(nil)
+	q.delayed = nil
+	q.armDelayTimer()
+	for _, e := range entries {
+		e.index = -1
+		e.delayed = false
+		q.untrack(e)
+		q.emit(kind, e.item)
+	}
+	n := len(entries)
+	if q.spillLen() > 0 {
+		q.dropSpill(func(e *entry) {
+			q.logRemove(e)
+			q.emit(kind, e.item)
+			n += 1
+		})
+	}
+	return n
 }
 
 /*
@@ -114,45 +640,457 @@ func (q *Queue) EnqueueUnique(item QueueItem) (added bool, err error) {
 func (q *Queue) ClearHistory() {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	q.history = nil
-	q.history = make(map[interface{}]struct{}, 0)
+	q.history.Clear()
+	q.logHistory(walClearHistory, nil)
 }
 
 func (q *Queue) RemoveFromHistory(element interface{}) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	delete(q.history, element)
+	element = q.historyKey(element)
+	q.history.Remove(element)
+	q.logHistory(walForget, element)
 }
 
 // Dequeue takes an item from the queue. If queue is empty
-// then should block waiting for at least one item.
+// then should block waiting for at least one item. Once the
+// queue is closed and drained it returns nil.
 func (q *Queue) Dequeue() (item QueueItem) {
+	item, _ = q.intercept(OpDequeue, context.Background(), nil, func(ctx context.Context, _ QueueItem) (QueueItem, error) {
+		q.cond.L.Lock()
+		defer q.cond.L.Unlock()
+		e, err := q.dequeueEntry(nil)
+		return q.handOut(ctx, e, err)
+	})
+	return
+}
+
+// DequeueN takes up to max items from the queue at once, in
+// priority order. If queue is empty it blocks waiting for at least
+// one item. Once the queue is closed and drained it returns nil.
+func (q *Queue) DequeueN(max int) (items []QueueItem) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	var x interface{}
-	for {
-		x = heap.Pop(q.items)
-		if x == nil {
-			q.cond.Wait()
-		} else {
+	if max <= 0 {
+		return
+	}
+	item, err := q.dequeue(nil)
+	if err != nil {
+		return
+	}
+	items = append(items, item)
+	for len(items) < max && q.allowed() {
+		e := q.next()
+		if e == nil {
+			break
+		}
+		q.took()
+		items = append(items, e.item)
+		q.dequeued(e)
+	}
+	return
+}
+
+// DequeueInto is DequeueN taking up to max items into buf, no more
+// than fit in it, so hot paths can reuse the buffer instead of
+// allocating a slice every call. It returns number of items put to
+// the front of buf, 0 once the queue is closed and drained.
+func (q *Queue) DequeueInto(buf []QueueItem, max int) (n int) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	max = min(max, len(buf))
+	if max <= 0 {
+		return
+	}
+	item, err := q.dequeue(nil)
+	if err != nil {
+		return
+	}
+	buf[0], n = item, 1
+	for n < max && q.allowed() {
+		e := q.next()
+		if e == nil {
 			break
 		}
+		q.took()
+		buf[n] = e.item
+		n += 1
+		q.dequeued(e)
+	}
+	return
+}
+
+// DequeueContext takes an item from the queue, blocking while the
+// queue is empty until the context is done. Then it returns the
+// context's error. Once the queue is closed and drained it
+// returns ErrClosed.
+func (q *Queue) DequeueContext(ctx context.Context) (item QueueItem, err error) {
+	return q.intercept(OpDequeue, ctx, nil, func(ctx context.Context, _ QueueItem) (QueueItem, error) {
+		e, err := q.dequeueContext(ctx)
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+		return q.handOut(ctx, e, err)
+	})
+}
+
+// TryDequeue takes an item from the queue without blocking. It
+// returns false when the queue is empty.
+func (q *Queue) TryDequeue() (item QueueItem, ok bool) {
+	item, err := q.intercept(OpDequeue, context.Background(), nil, func(ctx context.Context, _ QueueItem) (QueueItem, error) {
+		q.cond.L.Lock()
+		defer q.cond.L.Unlock()
+		e, err := q.dequeueEntry(func() error { return errEmpty })
+		return q.handOut(ctx, e, err)
+	})
+	return item, err == nil
+}
+
+// DequeueFunc takes the best item for which pred returns true from
+// the queue without blocking, skipping the better ones which stay in
+// the queue, eg. for workers which can only handle some kinds of items
+// right now. Walking the queue stops at the first item pred takes,
+// so pred is called for the skipped items only. Expired items and
+// held items, see Grouped and Dependent, are skipped too. The queue stays
+// locked meanwhile, so pred must not call the queue. It returns false
+// when no item is taken.
+func (q *Queue) DequeueFunc(pred func(QueueItem) bool) (item QueueItem, ok bool) {
+	item, err := q.intercept(OpDequeue, context.Background(), nil, func(ctx context.Context, _ QueueItem) (QueueItem, error) {
+		q.cond.L.Lock()
+		defer q.cond.L.Unlock()
+		if q.paused || !q.allowed() {
+			return nil, errEmpty
+		}
+		var found *entry
+		now := q.now()
+		q.walk(func(e *entry) bool {
+			if isExpired(e.item, now) || q.heldBack(e.item) || !pred(e.item) {
+				return true
+			}
+			found = e
+			return false
+		})
+		if found == nil {
+			return nil, errEmpty
+		}
+		q.remove(found)
+		q.took()
+		q.dequeued(found)
+		return q.handOut(ctx, found, nil)
+	})
+	return item, err == nil
+}
+
+// Drain takes all the items from the queue at once and returns
+// them in priority order, followed by delayed items in order of
+// their ready time. It doesn't block, so it returns nil for empty
+// queue.
+func (q *Queue) Drain() (items []QueueItem) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for e := q.next(); e != nil; e = q.next() {
+		items = append(items, e.item)
+		q.dequeued(e)
+	}
+	for len(q.delayed) > 0 {
+		e := heap.Pop(&q.delayed).(*entry)
+		e.delayed = false
+		q.untrack(e)
+		items = append(items, e.item)
+		q.dequeued(e)
 	}
-	item = x.(QueueItem)
+	q.armDelayTimer()
 	return
 }
 
+// DequeueTimeout takes an item from the queue, blocking while the
+// queue is empty for at most given duration. When no item shows up
+// in time ErrTimeout is returned. Once the queue is closed and
+// drained it returns ErrClosed.
+func (q *Queue) DequeueTimeout(d time.Duration) (item QueueItem, err error) {
+	return q.intercept(OpDequeue, context.Background(), nil, func(ctx context.Context, _ QueueItem) (QueueItem, error) {
+		return q.dequeueTimeout(ctx, d)
+	})
+}
+
+func (q *Queue) dequeueTimeout(ctx context.Context, d time.Duration) (item QueueItem, err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	expired := d <= 0
+	timer := q.clock.AfterFunc(d, func() {
+		q.cond.L.Lock()
+		defer q.cond.L.Unlock()
+		expired = true
+		q.wakeAll()
+	})
+	defer timer.Stop()
+	e, err := q.dequeueEntry(func() error {
+		if expired {
+			return ErrTimeout
+		}
+		return nil
+	})
+	return q.handOut(ctx, e, err)
+}
+
+// Peek returns the top item of the queue, without taking it from
+// the queue. It returns false when the queue is empty. The top item
+// may not be the one dequeued next when it has expired, or it's held
+// back by its group, dependencies or politeness, see PeekReady.
+func (q *Queue) Peek() (item QueueItem, ok bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	e := q.items.first()
+	if e == nil {
+		return nil, false
+	}
+	return e.item, true
+}
+
+// PeekReady returns the best item which can be dequeued right now,
+// without taking it from the queue, skipping the expired and held
+// back ones. It returns false also while the queue is paused or its
+// dequeue rate is used up.
+func (q *Queue) PeekReady() (item QueueItem, ok bool) {
+	e := q.ready()
+	if e == nil {
+		return nil, false
+	}
+	return e.item, true
+}
+
+// lessEntries tells if entry a goes before entry b in the order of
+// the queue, which takes WithOrder, WithLess and WithRank into
+// account, so entries of several queues sharing the options get
+// compared the same way they are in each of them.
+func (q *Queue) lessEntries(a, b *entry) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.items.less(a, b)
+}
+
+// ready returns a copy of the best entry which can be dequeued right
+// now, or nil when there is none. Delayed items which are due and
+// spilled ones are not looked at, dequeue takes them in first.
+func (q *Queue) ready() *entry {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.paused || !q.allowed() {
+		return nil
+	}
+	var found *entry
+	now := q.now()
+	q.walk(func(e *entry) bool {
+		if isExpired(e.item, now) || q.heldBack(e.item) {
+			return true
+		}
+		x := *e
+		found = &x
+		return false
+	})
+	return found
+}
+
+// PeekN returns up to n items that would be dequeued next, in order,
+// without taking them from the queue. Delayed items are not included.
+// It's cheap for small n, it doesn't copy or sort the whole heap.
+func (q *Queue) PeekN(n int) []QueueItem {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	top := q.top(n)
+	items := make([]QueueItem, len(top))
+	for i, e := range top {
+		items[i] = e.item
+	}
+	return items
+}
+
+// Contains tells if an item with given id is waiting in the queue
+// right now, delayed ones included. Unlike IdExists it doesn't look
+// at the history, so it's false once the item has been dequeued.
+func (q *Queue) Contains(id interface{}) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.pending(id)) > 0
+}
+
+// Get returns the pending item with given id, delayed ones included,
+// without taking it from the queue. When more items share the id,
+// the one enqueued first is returned. It returns false when no such
+// item is waiting in the queue.
+func (q *Queue) Get(id interface{}) (item QueueItem, ok bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	entries := q.pending(id)
+	if len(entries) == 0 {
+		return nil, false
+	}
+	return entries[0].item, true
+}
+
+// dequeue takes an item from the queue, waiting for one while the
+// queue is empty. Before every wait it calls done, if given, and
+// gives up with the error it returns. Must be called with the
+// queue locked.
+func (q *Queue) dequeue(done func() error) (item QueueItem, err error) {
+	e, err := q.dequeueEntry(done)
+	if err != nil {
+		return
+	}
+	return e.item, nil
+}
+
+// dequeueEntry is dequeue returning the whole entry. Consumers
+// which have to wait are served in order of their arrival.
+func (q *Queue) dequeueEntry(done func() error) (e *entry, err error) {
+	var w *sync.Cond
+	defer func() {
+		if w != nil {
+			q.leave(w)
+		}
+	}()
+	for {
+		if q.turn(w) && !q.paused && q.allowed() {
+			if e = q.next(); e != nil {
+				q.took()
+				break
+			}
+		}
+		if q.closed && (q.paused || q.items.Len() == 0) {
+			return nil, ErrClosed
+		}
+		if done != nil {
+			if err = done(); err != nil {
+				return
+			}
+		}
+		if w == nil {
+			w = q.join()
+		}
+		w.Wait()
+	}
+	q.dequeued(e)
+	return
+}
+
+// broadcast wakes up all the goroutines waiting on the queue so
+// they can check why they wait.
+func (q *Queue) broadcast() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.wakeAll()
+	q.space.Broadcast()
+}
+
+// Preheat reserves room for expectedItems in both the internal
+// slice and the history map, so a known bulk load doesn't have
+// to grow them step by step. It's an optimization hint only and
+// doesn't limit the queue in any way.
+func (q *Queue) Preheat(expectedItems int) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if expectedItems <= 0 {
+		return
+	}
+	q.reserved = max(q.reserved, expectedItems)
+	if q.items.ext == nil && cap(q.items.entries) < expectedItems {
+		entries := make([]*entry, len(q.items.entries), expectedItems)
+		copy(entries, q.items.entries)
+		q.items.entries = entries
+	}
+	q.historyReserve(expectedItems)
+}
+
+// Close marks the end of the stream. After closing, Enqueue returns
+// ErrClosed, while items already in the queue can still be dequeued.
+// Goroutines blocked in Dequeue are woken up and get ErrClosed (or
+// nil from Dequeue) once the queue is drained.
+func (q *Queue) Close() {
+	q.cond.L.Lock()
+	q.closed = true
+	q.wakeAll()
+	q.space.Broadcast()
+	closers := q.closers
+	q.closers = nil
+	q.cond.L.Unlock()
+	for _, c := range closers {
+		c()
+	}
+}
+
+// Shutdown closes the queue and lets consumers drain it until the
+// context is done. Items still in the queue then, delayed ones
+// included, are dropped and reported by Dropped events, so blocked
+// consumers get ErrClosed, and Shutdown returns their number along
+// with the context's error. Leased items are not waited for.
+func (q *Queue) Shutdown(ctx context.Context) (left int, err error) {
+	q.Close()
+	if err = q.WaitEmpty(ctx, false); err == nil {
+		return
+	}
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	left = q.clear(Dropped)
+	q.wakeAll()
+	return
+}
+
+// SetStableOrder turns stable ordering on or off. In stable order
+// items of equal priority are dequeued in the order they have been
+// enqueued, otherwise their order is arbitrary.
+func (q *Queue) SetStableOrder(stable bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.items.stable != stable {
+		q.items.stable = stable
+		q.items.init()
+	}
+}
+
+// SetLess orders the items with given function instead of their
+// Less from now on, and puts the pending items back in order, so the
+// queue can switch eg. from priority to deadline order while running.
+// Nil less brings the items' Less back. Ranked queues order by rank
+// whatever less is, and stable and descending order still apply.
+func (q *Queue) SetLess(less func(a, b QueueItem) bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.items.itemLess = less
+	q.items.init()
+}
+
 // Safely changes enqueued items limit. When limit is set
 // to 0, then queue is unlimited.
 func (q *Queue) ChangeLimit(newLimit int) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
 	q.Limit = newLimit
+	q.logLimit()
+	q.space.Broadcast()
 }
 
 // Len returns number of enqueued elemnents.
 func (q *Queue) Len() int {
-	return q.items.Len()
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.size()
+}
+
+// size is Len for callers holding the lock.
+func (q *Queue) size() int {
+	return q.items.Len() + len(q.delayed) + q.spillLen()
+}
+
+// Fullness returns number of enqueued elements, the limit and
+// their ratio, all read at once. Ratio is always 0 for unlimited
+// queues.
+func (q *Queue) Fullness() (n, limit int, ratio float64) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	n, limit = q.size(), q.Limit
+	if limit > 0 {
+		ratio = float64(n) / float64(limit)
+	}
+	return
 }
 
 // IsEmpty returns true if queue is empty.
@@ -160,36 +1098,239 @@ func (q *Queue) IsEmpty() bool {
 	return q.Len() == 0
 }
 
-type sorter []QueueItem
+// push puts entry to the heap, or aside until it's ready, and
+// keeps track of it.
+func (q *Queue) push(e *entry) {
+	// the entry is in the heap even if Less panics while sifting
+	defer q.track(e)
+	if e.readyAt.After(q.now()) {
+		q.pushDelayed(e)
+	} else {
+		q.items.push(e)
+	}
+}
+
+// pop takes the top entry from the heap. It returns nil when
+// the heap is empty.
+func (q *Queue) pop() *entry {
+	e := q.items.first()
+	if e == nil {
+		return nil
+	}
+	// the entry is out of the heap even if Less panics while
+	// the heap is put back in order
+	defer q.untrack(e)
+	q.items.pop()
+	q.shrinkEntries()
+	return e
+}
+
+// remove takes given entry out of the queue.
+func (q *Queue) remove(e *entry) {
+	defer q.untrack(e)
+	if e.delayed {
+		first := e.index == 0
+		heap.Remove(&q.delayed, e.index)
+		e.delayed = false
+		if first {
+			q.armDelayTimer()
+		}
+	} else {
+		q.items.removeEntry(e)
+	}
+}
+
+// index returns the history when it keeps the index of pending
+// entries by their ids too, so ids of pending items aren't kept
+// twice, in the history and in the index. That's the default history,
+// unless history ids differ from item ids. Otherwise it returns nil
+// and pending entries are indexed apart.
+func (q *Queue) index() *history {
+	if h, ok := q.history.(*history); ok && q.hasher == nil && q.normalize == nil {
+		return h
+	}
+	return nil
+}
+
+// pending returns the pending entries with given id, delayed ones
+// included, in order they were enqueued.
+func (q *Queue) pending(id interface{}) []*entry {
+	if h := q.index(); h != nil {
+		return h.pending(id)
+	}
+	return q.active[id]
+}
+
+// setPending sets the pending entries with given id.
+func (q *Queue) setPending(id interface{}, entries []*entry) {
+	if h := q.index(); h != nil {
+		h.setPending(id, entries)
+		return
+	}
+	if len(entries) == 0 {
+		delete(q.active, id)
+		q.shrinkActive()
+		return
+	}
+	q.active[id] = entries
+	q.activePeak = max(q.activePeak, len(q.active))
+}
 
-func (s *sorter) Push(i interface{}) {
-	item, ok := i.(QueueItem)
-	if !ok {
+// eachPending calls fn for every id with pending entries.
+func (q *Queue) eachPending(fn func(id interface{}, entries []*entry)) {
+	if h := q.index(); h != nil {
+		for id, el := range h.ids {
+			if he := el.Value.(*historyEntry); len(he.pending) > 0 {
+				fn(id, he.pending)
+			}
+		}
 		return
 	}
-	*s = append((*s)[:], item)
+	for id, entries := range q.active {
+		fn(id, entries)
+	}
 }
 
-func (s *sorter) Pop() (x interface{}) {
-	if s.Len() > 0 {
-		l := s.Len() - 1
-		x = (*s)[l]
-		(*s)[l] = nil
-		*s = (*s)[:l]
+// track indexes pending entry by its id and producer.
+func (q *Queue) track(e *entry) {
+	if e.since.IsZero() {
+		e.since = q.now()
+	}
+	e.size = sizeOf(e.item)
+	q.bytes += e.size
+	q.setPending(e.id, append(q.pending(e.id), e))
+	q.watchLen()
+	if e.producer != "" {
+		q.producers[e.producer] += 1
+	}
+	if q.classOf != nil {
+		e.class = q.classOf(e.item)
+		q.classes[e.class] += 1
+	}
+	if _, ok := e.item.(Dependent); ok {
+		q.dependents += 1
 	}
-	return
+}
+
+// untrack forgets the entry which is not pending any more.
+func (q *Queue) untrack(e *entry) {
+	q.logRemove(e)
+	q.forget(e)
+}
+
+// forget is untrack keeping the entry in the write-ahead log, for
+// entries which leave the memory but not the queue.
+func (q *Queue) forget(e *entry) {
+	if q.spaceWaiters > 0 {
+		q.space.Broadcast()
+	}
+	q.bytes -= e.size
+	entries := q.pending(e.id)
+	for i, x := range entries {
+		if x == e {
+			entries = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	q.setPending(e.id, entries)
+	if e.producer != "" {
+		if q.producers[e.producer] -= 1; q.producers[e.producer] <= 0 {
+			delete(q.producers, e.producer)
+		}
+	}
+	if q.classOf != nil {
+		if q.classes[e.class] -= 1; q.classes[e.class] <= 0 {
+			delete(q.classes, e.class)
+		}
+	}
+	if _, ok := e.item.(Dependent); ok {
+		q.dependents -= 1
+	}
+	q.watchLen()
+}
+
+// entry wraps the enqueued item together with the data needed
+// to keep it in order.
+type entry struct {
+	item     QueueItem
+	id       interface{}
+	producer string
+	class    string
+	score    int64
+	seq      uint64
+	// deliveries counts leases of the entry
+	deliveries int
+	// readyAt is when delayed entry can be dequeued
+	readyAt time.Time
+	delayed bool
+	// since is when the entry started waiting in the queue
+	since time.Time
+	// values are the metadata given at enqueue
+	values map[string]string
+	// bumped orders the entry bumped to the front, 0 if it's not
+	bumped uint64
+	// size is the item's size when it was enqueued, see Sizer
+	size  int
+	index int
+	// elem is the handle of the entry in Storage
+	elem Element
+}
+
+// waitingSince returns when the entry started waiting to be
+// dequeued.
+func (e *entry) waitingSince() time.Time {
+	if e.readyAt.After(e.since) {
+		return e.readyAt
+	}
+	return e.since
+}
+
+type sorter struct {
+	entries    []*entry
+	ranked     bool
+	stable     bool
+	descending bool
+	// panicked is what Less has panicked with while sifting
+	panicked interface{}
+	// ext keeps the entries instead of the heap, see WithStorage
+	ext Storage
+	// itemLess orders the items instead of their Less, see SetLess
+	itemLess func(a, b QueueItem) bool
 }
 
 func (s *sorter) Len() int {
-	return len((*s)[:])
+	if s.ext != nil {
+		return s.ext.Len()
+	}
+	return len(s.entries)
 }
 
-func (s *sorter) Less(i, j int) bool {
-	return (*s)[i].Less((*s)[j])
+func (s *sorter) less(a, b *entry) bool {
+	if a.bumped != b.bumped {
+		// bumped entries go first, in order of their bumps
+		return a.bumped != 0 && (b.bumped == 0 || a.bumped < b.bumped)
+	}
+	if s.ranked {
+		if s.stable && a.score == b.score {
+			return a.seq < b.seq
+		}
+		if s.descending {
+			return a.score > b.score
+		}
+		return a.score < b.score
+	}
+	if s.stable && !s.itemsLess(a.item, b.item) && !s.itemsLess(b.item, a.item) {
+		return a.seq < b.seq
+	}
+	if s.descending {
+		return s.itemsLess(b.item, a.item)
+	}
+	return s.itemsLess(a.item, b.item)
 }
 
-func (s *sorter) Swap(i, j int) {
-	if s.Len() > 0 {
-		(*s)[i], (*s)[j] = (*s)[j], (*s)[i]
+func (s *sorter) itemsLess(a, b QueueItem) bool {
+	if s.itemLess != nil {
+		return s.itemLess(a, b)
 	}
+	return a.Less(b)
 }
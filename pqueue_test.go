@@ -5,6 +5,9 @@
 package pqueue
 
 import (
+	"context"
+	"errors"
+	"math"
 	"math/rand"
 	"testing"
 	"time"
@@ -22,6 +25,10 @@ func (dt *DummyTask) Less(other interface{}) bool {
 	return dt.priority < other.(*DummyTask).priority
 }
 
+func (dt *DummyTask) Id() interface{} {
+	return dt
+}
+
 func TestNewQueue(t *testing.T) {
 	q := New(100)
 	if q.Limit != 100 {
@@ -48,22 +55,278 @@ func TestEnqueueAndDequeue(t *testing.T) {
 	}
 }
 
+func TestStableOrder(t *testing.T) {
+	q := New(0)
+	q.SetStableOrder(true)
+	tasks := []*DummyTask{}
+	for _, x := range []int{2, 1, 2, 1, 2, 1, 2, 1} {
+		task := NewDummyTask(x)
+		tasks = append(tasks, task)
+		q.Enqueue(task)
+	}
+	for _, i := range []int{1, 3, 5, 7, 0, 2, 4, 6} {
+		if q.Dequeue() != tasks[i] {
+			t.Errorf("Expected equal priority items to keep insertion order")
+		}
+	}
+}
+
 func TestWaitForDequeue(t *testing.T) {
 	q := New(0)
-	dequeued := false
+	dequeued := make(chan bool, 1)
 	go func() {
-		if q.Dequeue() != nil {
-			dequeued = true
-		}
+		dequeued <- q.Dequeue() != nil
 	}()
 	<-time.After(1e9)
 	q.Enqueue(NewDummyTask(1))
-	<-time.After(1e2)
-	if !dequeued {
+	select {
+	case ok := <-dequeued:
+		if !ok {
+			t.Errorf("Expected to dequeue an item")
+		}
+	case <-time.After(1e9):
 		t.Errorf("Expected to wait for dequeue")
 	}
 }
 
+func TestDequeueContext(t *testing.T) {
+	q := New(0)
+	q.Enqueue(NewDummyTask(1))
+	if item, err := q.DequeueContext(context.Background()); err != nil || item == nil {
+		t.Errorf("Expected to dequeue an item, given %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-time.After(1e8)
+		cancel()
+	}()
+	if item, err := q.DequeueContext(ctx); err != context.Canceled || item != nil {
+		t.Errorf("Expected dequeue to be cancelled, given %v", err)
+	}
+}
+
+func TestTryDequeue(t *testing.T) {
+	q := New(0)
+	if item, ok := q.TryDequeue(); ok || item != nil {
+		t.Errorf("Expected not to dequeue from empty queue")
+	}
+	q.Enqueue(NewDummyTask(1))
+	if item, ok := q.TryDequeue(); !ok || item.(*DummyTask).priority != 1 {
+		t.Errorf("Expected to dequeue an item")
+	}
+}
+
+func TestDequeueTimeout(t *testing.T) {
+	q := New(0)
+	start := time.Now()
+	if item, err := q.DequeueTimeout(1e8); err != ErrTimeout || item != nil {
+		t.Errorf("Expected dequeue to time out, given %v", err)
+	}
+	if time.Since(start) < 1e8 {
+		t.Errorf("Expected to wait for the timeout")
+	}
+	go func() {
+		<-time.After(1e7)
+		q.Enqueue(NewDummyTask(1))
+	}()
+	if item, err := q.DequeueTimeout(1e9); err != nil || item == nil {
+		t.Errorf("Expected to dequeue an item, given %v", err)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	q := New(0)
+	if _, ok := q.Peek(); ok {
+		t.Errorf("Expected nothing to peek in empty queue")
+	}
+	for _, x := range []int{3, 1, 2} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	if item, ok := q.Peek(); !ok || item.(*DummyTask).priority != 1 {
+		t.Errorf("Expected to peek the top item")
+	}
+	if q.Len() != 3 {
+		t.Errorf("Expected peek not to remove the item")
+	}
+}
+
+func TestPeekReady(t *testing.T) {
+	q := New(0)
+	q.Enqueue(&groupTask{stateTask{"a1", 1}, "a"})
+	q.Enqueue(&groupTask{stateTask{"a2", 2}, "a"})
+	q.Enqueue(&groupTask{stateTask{"b3", 3}, "b"})
+	if _, err := q.Lease(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if item, ok := q.Peek(); !ok || item.(*groupTask).Name != "a2" {
+		t.Errorf("Expected to peek the top item, given %v", item)
+	}
+	if item, ok := q.PeekReady(); !ok || item.(*groupTask).Name != "b3" {
+		t.Errorf("Expected to peek the item not held back, given %v", item)
+	}
+	q.Pause()
+	if _, ok := q.PeekReady(); ok {
+		t.Errorf("Expected nothing ready in paused queue")
+	}
+}
+
+func TestPeekN(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{5, 3, 8, 1, 9, 2, 7} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	items := q.PeekN(4)
+	if len(items) != 4 {
+		t.Fatalf("Expected 4 items, given %d", len(items))
+	}
+	for i, x := range []int{1, 2, 3, 5} {
+		if p := items[i].(*DummyTask).priority; p != x {
+			t.Errorf("Expected priority %d at %d, given %d", x, i, p)
+		}
+	}
+	if len(q.PeekN(10)) != 7 || q.Len() != 7 {
+		t.Errorf("Expected all the items peeked and none removed")
+	}
+}
+
+func TestClose(t *testing.T) {
+	q := New(0)
+	q.Enqueue(NewDummyTask(1))
+	done := make(chan error)
+	go func() {
+		q.Dequeue()
+		_, err := q.DequeueContext(context.Background())
+		done <- err
+	}()
+	<-time.After(1e8)
+	q.Close()
+	if err := <-done; err != ErrClosed {
+		t.Errorf("Expected blocked dequeue to be closed, given %v", err)
+	}
+	if err := q.Enqueue(NewDummyTask(2)); err != ErrClosed {
+		t.Errorf("Expected enqueue to closed queue to fail, given %v", err)
+	}
+	if q.Dequeue() != nil {
+		t.Errorf("Expected nothing to dequeue from closed queue")
+	}
+	if _, err := q.DequeueTimeout(1e9); err != ErrClosed {
+		t.Errorf("Expected dequeue with timeout to be closed, given %v", err)
+	}
+}
+
+func TestCloseDrains(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{2, 1} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.Close()
+	for _, x := range []int{1, 2} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestDequeueN(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{4, 2, 5, 1, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	for _, batch := range [][]int{{1, 2}, {3, 4}, {5}} {
+		items := q.DequeueN(2)
+		if len(items) != len(batch) {
+			t.Fatalf("Expected to dequeue %d items, %d dequeued", len(batch), len(items))
+		}
+		for i, x := range batch {
+			if task := items[i].(*DummyTask); task.priority != x {
+				t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+			}
+		}
+	}
+	q.Close()
+	if items := q.DequeueN(2); items != nil {
+		t.Errorf("Expected nothing to dequeue from closed queue")
+	}
+}
+
+func TestDequeueInto(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{4, 2, 5, 1, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	buf := make([]QueueItem, 2)
+	for _, batch := range [][]int{{1, 2}, {3}, {4, 5}} {
+		n := q.DequeueInto(buf, len(batch))
+		if n != len(batch) {
+			t.Fatalf("Expected to dequeue %d items, %d dequeued", len(batch), n)
+		}
+		for i, x := range batch {
+			if task := buf[i].(*DummyTask); task.priority != x {
+				t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+			}
+		}
+	}
+	q.Enqueue(NewDummyTask(6))
+	if n := q.DequeueInto(buf[:0], 2); n != 0 || q.Len() != 1 {
+		t.Errorf("Expected nothing to dequeue into empty buffer, given %d", n)
+	}
+	q.Close()
+	q.Dequeue()
+	if n := q.DequeueInto(buf, 2); n != 0 {
+		t.Errorf("Expected nothing to dequeue from closed queue")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	q := New(0)
+	if items := q.Drain(); items != nil {
+		t.Errorf("Expected nothing to drain from empty queue")
+	}
+	for _, x := range []int{4, 2, 5, 1, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.EnqueueAfter(NewDummyTask(0), time.Hour)
+	items := q.Drain()
+	if len(items) != 6 || !q.IsEmpty() {
+		t.Fatalf("Expected to drain 6 items, %d drained", len(items))
+	}
+	for i, x := range []int{1, 2, 3, 4, 5, 0} {
+		if task := items[i].(*DummyTask); task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	q := New(0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			q.EnqueueUnique(NewDummyTask(i))
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					q.Len()
+					q.IsEmpty()
+					q.IdExists(i)
+				}
+			}
+		}()
+	}
+	<-done
+	if q.Len() != 1000 {
+		t.Errorf("Expected 1000 items, given %d", q.Len())
+	}
+}
+
 func TestIsEmpty(t *testing.T) {
 	q := New(0)
 	if !q.IsEmpty() {
@@ -91,6 +354,225 @@ func TestLimit(t *testing.T) {
 	}
 }
 
+func TestEnqueueAll(t *testing.T) {
+	q := New(4)
+	items := []QueueItem{}
+	for _, x := range []int{3, 1, 4, 2, 5, 6} {
+		items = append(items, NewDummyTask(x))
+	}
+	n, err := q.EnqueueAll(items)
+	if n != 4 || err == nil || err.Error() != "Queue limit reached" {
+		t.Errorf("Expected to enqueue 4 items until limit, %d enqueued", n)
+	}
+	for _, x := range []int{1, 2, 3, 4} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestEnqueueAllWakesWaiters(t *testing.T) {
+	q := New(0)
+	done := make(chan bool)
+	for i := 0; i < 3; i += 1 {
+		go func() {
+			q.Dequeue()
+			done <- true
+		}()
+	}
+	<-time.After(1e8)
+	q.EnqueueAll([]QueueItem{NewDummyTask(1), NewDummyTask(2), NewDummyTask(3)})
+	for i := 0; i < 3; i += 1 {
+		select {
+		case <-done:
+		case <-time.After(1e9):
+			t.Fatalf("Expected all waiters to be woken up")
+		}
+	}
+}
+
+func TestEnqueueContext(t *testing.T) {
+	q := New(1)
+	q.Enqueue(NewDummyTask(1))
+	go func() {
+		<-time.After(1e8)
+		q.Dequeue()
+	}()
+	if err := q.EnqueueContext(context.Background(), NewDummyTask(2)); err != nil {
+		t.Errorf("Expected to enqueue once there is room, given %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1e8)
+	defer cancel()
+	if err := q.EnqueueContext(ctx, NewDummyTask(3)); err != context.DeadlineExceeded {
+		t.Errorf("Expected enqueue to be cancelled, given %v", err)
+	}
+	go func() {
+		<-time.After(1e8)
+		q.ChangeLimit(2)
+	}()
+	if err := q.EnqueueContext(context.Background(), NewDummyTask(4)); err != nil {
+		t.Errorf("Expected to enqueue once limit is raised, given %v", err)
+	}
+	if q.Len() != 2 {
+		t.Errorf("Expected 2 items to be enqueued, %d enqueued", q.Len())
+	}
+}
+
+func TestEnqueueIfNotQueued(t *testing.T) {
+	q := New(0)
+	task := NewDummyTask(1)
+	if err := q.EnqueueIfNotQueued(task); err != nil {
+		t.Errorf("Expected to enqueue the task")
+	}
+	if err := q.EnqueueIfNotQueued(task); err == nil {
+		t.Errorf("Expected not to enqueue the task while it's queued")
+	}
+	q.Dequeue()
+	if err := q.EnqueueIfNotQueued(task); err != nil {
+		t.Errorf("Expected to enqueue the task again once dequeued")
+	}
+	if err := q.EnqueueUnique(task); err == nil {
+		t.Errorf("Expected EnqueueUnique to look at the history")
+	}
+	if q.Len() != 1 {
+		t.Errorf("Expected only one task to be queued, %d queued", q.Len())
+	}
+}
+
+func TestClear(t *testing.T) {
+	q := New(0)
+	tasks := []*DummyTask{NewDummyTask(1), NewDummyTask(2)}
+	q.EnqueueUnique(tasks[0])
+	q.EnqueueAfter(tasks[1], time.Hour)
+	q.Clear(true)
+	if !q.IsEmpty() || !q.IdExists(tasks[0].Id()) {
+		t.Errorf("Expected queue to be empty with history kept")
+	}
+	q.Enqueue(tasks[1])
+	q.Clear(false)
+	if !q.IsEmpty() || q.IdExists(tasks[0].Id()) {
+		t.Errorf("Expected queue and history to be empty")
+	}
+	q.Enqueue(tasks[0])
+	if q.Dequeue() != tasks[0] {
+		t.Errorf("Expected cleared queue to be usable")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	q := New(0)
+	tasks := []*DummyTask{}
+	for _, x := range []int{5, 1, 4, 2, 3} {
+		task := NewDummyTask(x)
+		tasks = append(tasks, task)
+		q.Enqueue(task)
+	}
+	if item, ok := q.Remove(tasks[2].Id()); !ok || item != tasks[2] {
+		t.Errorf("Expected to remove the task")
+	}
+	if _, ok := q.Remove(tasks[2].Id()); ok {
+		t.Errorf("Expected removed task not to be pending")
+	}
+	for _, x := range []int{1, 2, 3, 5} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestUpdatePriority(t *testing.T) {
+	q := New(0)
+	task := NewDummyTask(5)
+	q.Enqueue(task)
+	for _, x := range []int{1, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	if !q.UpdatePriority(task.Id(), func(item QueueItem) { item.(*DummyTask).priority = 2 }) {
+		t.Errorf("Expected to update the task")
+	}
+	if q.UpdatePriority("missing", func(QueueItem) {}) {
+		t.Errorf("Expected not to update missing task")
+	}
+	for _, x := range []int{1, 2, 3} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestReprioritize(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{1, 2, 3, 4} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.Reprioritize(func(item QueueItem) {
+		// even ones go first
+		task := item.(*DummyTask)
+		if task.priority%2 == 0 {
+			task.priority -= 10
+		}
+	})
+	for _, x := range []int{-8, -6, 1, 3} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestSetLess(t *testing.T) {
+	q := NewWithOptions(WithStableOrder())
+	for i, p := range []int{3, 1, 2} {
+		q.Enqueue(&stateTask{string(rune('a' + i)), p})
+	}
+	byName := func(a, b QueueItem) bool {
+		return a.(*stateTask).Name > b.(*stateTask).Name
+	}
+	q.SetLess(byName)
+	if item, _ := q.Peek(); item.(*stateTask).Name != "c" {
+		t.Errorf("Expected c first by name, given %v", item)
+	}
+	q.SetLess(nil)
+	for _, x := range []int{1, 2, 3} {
+		if task := q.Dequeue().(*stateTask); task.Priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.Priority)
+		}
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+}
+
+func TestFullness(t *testing.T) {
+	q := New(4)
+	q.Enqueue(NewDummyTask(1))
+	if n, limit, ratio := q.Fullness(); n != 1 || limit != 4 || ratio != 0.25 {
+		t.Errorf("Expected fullness to be 1, 4, 0.25, given %d, %d, %v", n, limit, ratio)
+	}
+	q.ChangeLimit(0)
+	if n, limit, ratio := q.Fullness(); n != 1 || limit != 0 || ratio != 0 {
+		t.Errorf("Expected zero ratio for unlimited queue, given %v", ratio)
+	}
+}
+
+func TestPreheat(t *testing.T) {
+	q := New(0)
+	q.EnqueueUnique(NewDummyTask(1))
+	q.Preheat(1000)
+	if cap(q.items.entries) < 1000 {
+		t.Errorf("Expected to reserve room for 1000 items, %d reserved", cap(q.items.entries))
+	}
+	if q.Len() != 1 || q.historyLen() != 1 {
+		t.Errorf("Expected to keep enqueued items and history")
+	}
+	if q.Limit != 0 {
+		t.Errorf("Expected preheat not to change the limit")
+	}
+}
+
 func BenchmarkEnqueue(b *testing.B) {
 	b.StopTimer()
 	q := New(0)
@@ -100,6 +582,36 @@ func BenchmarkEnqueue(b *testing.B) {
 	}
 }
 
+func BenchmarkEnqueueUnique(b *testing.B) {
+	for n := 0; n < b.N; n += 1 {
+		q := New(0)
+		for i := 0; i < 100000; i += 1 {
+			q.EnqueueUnique(NewDummyTask(rand.Intn(10)))
+		}
+	}
+}
+
+func BenchmarkEnqueueUniquePreheat(b *testing.B) {
+	for n := 0; n < b.N; n += 1 {
+		q := New(0)
+		q.Preheat(100000)
+		for i := 0; i < 100000; i += 1 {
+			q.EnqueueUnique(NewDummyTask(rand.Intn(10)))
+		}
+	}
+}
+
+func BenchmarkEnqueueAll(b *testing.B) {
+	b.StopTimer()
+	q := New(0)
+	items := make([]QueueItem, 200000)
+	for i := range items {
+		items[i] = NewDummyTask(rand.Intn(10))
+	}
+	b.StartTimer()
+	q.EnqueueAll(items)
+}
+
 func BenchmarkMultiEnqueue(b *testing.B) {
 	b.StopTimer()
 	q := New(0)
@@ -160,3 +672,248 @@ func BenchmarkMultiDequeue(b *testing.B) {
 	}
 	<-done
 }
+
+func TestEnqueueReplace(t *testing.T) {
+	q := NewWithOptions(WithStableOrder())
+	q.Enqueue(&stateTask{Name: "a", Priority: 1})
+	q.Enqueue(&stateTask{Name: "b", Priority: 2})
+	if replaced, err := q.EnqueueReplace(&stateTask{Name: "c", Priority: 2}); replaced || err != nil {
+		t.Errorf("Expected new item to be enqueued, given %v, %v", replaced, err)
+	}
+	if replaced, _ := q.EnqueueReplace(&stateTask{Name: "a", Priority: 3}); !replaced {
+		t.Errorf("Expected pending item to be replaced")
+	}
+	if q.Len() != 3 {
+		t.Errorf("Expected 3 items, given %d", q.Len())
+	}
+	for _, x := range []int{2, 2, 3} {
+		if task := q.Dequeue().(*stateTask); task.Priority != x {
+			t.Errorf("Expected to dequeue item of priority %d, given %d", x, task.Priority)
+		}
+	}
+}
+
+func TestEnqueueIfHigher(t *testing.T) {
+	q := New(0)
+	q.Enqueue(&stateTask{Name: "a", Priority: 5})
+	q.Enqueue(&stateTask{Name: "b", Priority: 3})
+	if err := q.EnqueueIfHigher(&stateTask{Name: "a", Priority: 6}); err == nil {
+		t.Errorf("Expected lower priority item to be dropped")
+	}
+	if err := q.EnqueueIfHigher(&stateTask{Name: "a", Priority: 5}); err == nil {
+		t.Errorf("Expected item of the same priority to be dropped")
+	}
+	if err := q.EnqueueIfHigher(&stateTask{Name: "a", Priority: 1}); err != nil {
+		t.Errorf("Expected higher priority item to replace pending one")
+	}
+	if err := q.EnqueueIfHigher(&stateTask{Name: "c", Priority: 4}); err != nil {
+		t.Errorf("Expected new item to be enqueued")
+	}
+	if q.Len() != 3 || q.Stats().Duplicates != 2 {
+		t.Errorf("Expected 3 items and 2 duplicates, given %d and %d", q.Len(), q.Stats().Duplicates)
+	}
+	for _, x := range []int{1, 3, 4} {
+		if task := q.Dequeue().(*stateTask); task.Priority != x {
+			t.Errorf("Expected to dequeue item of priority %d, given %d", x, task.Priority)
+		}
+	}
+}
+
+func TestWaitForSpace(t *testing.T) {
+	q := New(3)
+	if q.Cap() != 3 || q.Free() != 3 || New(0).Free() != math.MaxInt {
+		t.Errorf("Expected capacity of 3 and unlimited free room of unlimited queue")
+	}
+	for i := 0; i < 3; i++ {
+		q.Enqueue(NewDummyTask(i))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.WaitForSpace(ctx, 1); err != context.DeadlineExceeded {
+		t.Errorf("Expected wait on full queue to time out, given %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- q.WaitForSpace(context.Background(), 2)
+	}()
+	q.Dequeue()
+	select {
+	case <-done:
+		t.Errorf("Expected to wait for room for 2 items")
+	case <-time.After(10 * time.Millisecond):
+	}
+	q.ChangeLimit(4)
+	if err := <-done; err != nil || q.Free() != 2 {
+		t.Errorf("Expected room for 2 items once the limit is raised, given %v", err)
+	}
+	q.ChangeLimit(1)
+	go func() {
+		done <- q.WaitForSpace(context.Background(), 1)
+	}()
+	q.Close()
+	if err := <-done; err != ErrClosed {
+		t.Errorf("Expected ErrClosed, given %v", err)
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	q := New(1)
+	task := &stateTask{Name: "a"}
+	if err := q.EnqueueUnique(task); err != nil {
+		t.Errorf("Expected to enqueue the item, given %v", err)
+	}
+	if err := q.EnqueueUnique(task); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate, given %v", err)
+	}
+	if err := q.EnqueueIfNotQueued(task); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate from EnqueueIfNotQueued, given %v", err)
+	}
+	if err := q.EnqueueUnique(&stateTask{Name: "b"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, given %v", err)
+	}
+	q.Close()
+	if err := q.Enqueue(task); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, given %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	q := New(0)
+	q.Enqueue(&stateTask{Name: "a", Priority: 1})
+	q.Enqueue(&stateTask{Name: "a", Priority: 2})
+	q.EnqueueAfter(&stateTask{Name: "b", Priority: 3}, time.Hour)
+	if item, ok := q.Get("a"); !ok || item.(*stateTask).Priority != 1 {
+		t.Errorf("Expected to get the first item with the id")
+	}
+	if _, ok := q.Get("b"); !ok {
+		t.Errorf("Expected to get the delayed item")
+	}
+	if _, ok := q.Get("c"); ok {
+		t.Errorf("Expected no item with unknown id")
+	}
+	q.Dequeue()
+	q.Dequeue()
+	if _, ok := q.Get("a"); ok || q.Len() != 1 {
+		t.Errorf("Expected no item once dequeued")
+	}
+}
+
+func TestContains(t *testing.T) {
+	q := New(0)
+	q.EnqueueUnique(&stateTask{Name: "a"})
+	if !q.Contains("a") || !q.IdExists("a") {
+		t.Errorf("Expected pending item to be both contained and seen")
+	}
+	q.Dequeue()
+	if q.Contains("a") || !q.IdExists("a") {
+		t.Errorf("Expected dequeued item to be seen, but not contained")
+	}
+}
+
+func TestWaitEmpty(t *testing.T) {
+	q := New(0)
+	if err := q.WaitEmpty(context.Background(), true); err != nil {
+		t.Errorf("Expected empty queue not to block, given %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		q.Enqueue(NewDummyTask(i))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.WaitEmpty(ctx, false); err != context.DeadlineExceeded {
+		t.Errorf("Expected wait to time out, given %v", err)
+	}
+	go func() {
+		q.Dequeue()
+		q.Dequeue()
+	}()
+	d, _ := q.Lease(context.Background())
+	if err := q.WaitEmpty(context.Background(), false); err != nil || q.Leased() != 1 {
+		t.Errorf("Expected to wait for the queue to drain, given %v", err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		d.Ack()
+	}()
+	if err := q.WaitEmpty(context.Background(), true); err != nil || q.Leased() != 0 {
+		t.Errorf("Expected to wait for the leased item to be acked, given %v", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	q := New(0)
+	for i := 0; i < 3; i++ {
+		q.Enqueue(NewDummyTask(i))
+	}
+	go q.Dequeue()
+	go q.Dequeue()
+	q.Dequeue()
+	if left, err := q.Shutdown(context.Background()); left != 0 || err != nil {
+		t.Errorf("Expected drained queue to shut down, given %d, %v", left, err)
+	}
+	if err := q.Enqueue(NewDummyTask(1)); err != ErrClosed {
+		t.Errorf("Expected enqueue to be refused after shutdown")
+	}
+
+	q = New(0)
+	for i := 0; i < 3; i++ {
+		q.Enqueue(NewDummyTask(i))
+	}
+	q.EnqueueAfter(NewDummyTask(3), time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	q.Dequeue()
+	if left, err := q.Shutdown(ctx); left != 3 || err != context.DeadlineExceeded {
+		t.Errorf("Expected 3 items left undelivered, given %d, %v", left, err)
+	}
+	if _, err := q.DequeueContext(context.Background()); err != ErrClosed || q.Len() != 0 {
+		t.Errorf("Expected ErrClosed once shut down, given %v", err)
+	}
+}
+
+func TestBump(t *testing.T) {
+	q := New(0)
+	tasks := make(map[int]*DummyTask)
+	for _, x := range []int{1, 2, 3, 4, 5} {
+		tasks[x] = NewDummyTask(x)
+		if x == 5 {
+			q.EnqueueAfter(tasks[x], time.Hour)
+		} else {
+			q.Enqueue(tasks[x])
+		}
+	}
+	if q.Bump(NewDummyTask(1)) {
+		t.Errorf("Expected nothing to bump")
+	}
+	for _, x := range []int{3, 5} {
+		if !q.Bump(tasks[x]) {
+			t.Errorf("Expected to bump %d", x)
+		}
+	}
+	for _, x := range []int{3, 5, 1, 2, 4} {
+		if task, _ := q.TryDequeue(); task == nil || task.(*DummyTask).priority != x {
+			t.Errorf("Expected priority %d, given %v", x, task)
+		}
+	}
+}
+
+func TestDequeueFunc(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{5, 3, 8, 1, 9, 2, 6} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	even := func(item QueueItem) bool {
+		return item.(*DummyTask).priority%2 == 0
+	}
+	for _, x := range []int{2, 6, 8} {
+		if item, ok := q.DequeueFunc(even); !ok || item.(*DummyTask).priority != x {
+			t.Errorf("Expected priority %d, given %v", x, item)
+		}
+	}
+	if _, ok := q.DequeueFunc(even); ok {
+		t.Errorf("Expected no more even items")
+	}
+	if q.Len() != 4 || q.Dequeue().(*DummyTask).priority != 1 {
+		t.Errorf("Expected skipped items to stay in order")
+	}
+}
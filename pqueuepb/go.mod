@@ -0,0 +1,8 @@
+module github.com/mileusna/gopqueue/pqueuepb
+
+go 1.24
+
+require (
+	github.com/mileusna/gopqueue v0.0.0-20261014081040-eb84ca7ebd43
+	google.golang.org/protobuf v1.35.2
+)
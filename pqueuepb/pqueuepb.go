@@ -0,0 +1,92 @@
+// Package pqueuepb defines the protobuf wire format of queue items,
+// see item.proto, so queues can be fed by producers written in
+// other languages and persisted compactly. An Item can be enqueued
+// as it is, or used as an envelope for items of other types through
+// Codec.
+package pqueuepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative item.proto
+
+import (
+	"fmt"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Less orders items by their priority, lower first.
+func (x *Item) Less(other interface{}) bool {
+	return x.Priority < other.(*Item).Priority
+}
+
+// Id returns the item id.
+func (x *Item) Id() interface{} {
+	return x.ItemId
+}
+
+// Codec turns items of other types to Item and back. Its Encode and
+// Decode fit the encode and decode functions taken by Restore,
+// OpenWAL and the storage sub-packages.
+type Codec struct {
+	// Priority of the item on the wire.
+	Priority func(pqueue.QueueItem) int64
+	// Marshal encodes the item to the payload, Unmarshal decodes
+	// it back.
+	Marshal   func(pqueue.QueueItem) ([]byte, error)
+	Unmarshal func([]byte) (pqueue.QueueItem, error)
+}
+
+// Envelope returns the Item carrying given item. Its id is the
+// item id formatted with fmt.Sprint.
+func (c Codec) Envelope(item pqueue.QueueItem, enqueuedAt time.Time, attempts int) (*Item, error) {
+	payload, err := c.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	x := &Item{
+		ItemId:   fmt.Sprint(item.Id()),
+		Payload:  payload,
+		Attempts: int32(attempts),
+	}
+	if c.Priority != nil {
+		x.Priority = c.Priority(item)
+	}
+	if !enqueuedAt.IsZero() {
+		x.EnqueuedAt = timestamppb.New(enqueuedAt)
+	}
+	return x, nil
+}
+
+// Encode returns the wire form of given item, enqueued now.
+func (c Codec) Encode(item pqueue.QueueItem) ([]byte, error) {
+	x, err := c.Envelope(item, time.Now(), 0)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(x)
+}
+
+// Decode reads the item back from its wire form.
+func (c Codec) Decode(data []byte) (pqueue.QueueItem, error) {
+	var x Item
+	if err := proto.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	return c.Unmarshal(x.Payload)
+}
+
+// Marshal returns the wire form of the Item.
+func Marshal(x *Item) ([]byte, error) {
+	return proto.Marshal(x)
+}
+
+// Unmarshal reads the Item from its wire form.
+func Unmarshal(data []byte) (*Item, error) {
+	x := new(Item)
+	if err := proto.Unmarshal(data, x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
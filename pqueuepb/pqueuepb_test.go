@@ -0,0 +1,69 @@
+package pqueuepb
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+var codec = Codec{
+	Priority: func(item pqueue.QueueItem) int64 {
+		return int64(item.(*task).Priority)
+	},
+	Marshal: func(item pqueue.QueueItem) ([]byte, error) {
+		return json.Marshal(item)
+	},
+	Unmarshal: func(data []byte) (pqueue.QueueItem, error) {
+		t := new(task)
+		return t, json.Unmarshal(data, t)
+	},
+}
+
+func TestCodec(t *testing.T) {
+	now := time.Now()
+	x, err := codec.Envelope(&task{"a", 3}, now, 2)
+	if err != nil {
+		t.Fatalf("Expected envelope, given %v", err)
+	}
+	if x.ItemId != "a" || x.Priority != 3 || x.Attempts != 2 || !x.EnqueuedAt.AsTime().Equal(now) {
+		t.Errorf("Expected envelope fields to be set, given %v", x)
+	}
+	data, err := codec.Encode(&task{"b", 1})
+	if err != nil {
+		t.Fatalf("Expected item to be encoded, given %v", err)
+	}
+	item, err := codec.Decode(data)
+	if err != nil || item.(*task).Name != "b" {
+		t.Errorf("Expected item to be decoded, given %v", err)
+	}
+}
+
+func TestItemQueue(t *testing.T) {
+	q := pqueue.New(0)
+	for _, x := range []*Item{{ItemId: "b", Priority: 2}, {ItemId: "a", Priority: 1}} {
+		data, _ := Marshal(x)
+		x, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Expected item to be unmarshaled, given %v", err)
+		}
+		q.Enqueue(x)
+	}
+	if x := q.Dequeue().(*Item); x.ItemId != "a" {
+		t.Errorf("Expected to dequeue item of lower priority first")
+	}
+}
@@ -0,0 +1,57 @@
+package pqueue
+
+// WithProcessedStore keeps ids marked with MarkProcessed in given
+// store, eg. one shared by more processes, instead of in memory.
+func WithProcessedStore(s DedupStore) Option {
+	return func(q *Queue) {
+		q.processed = s
+	}
+}
+
+// MarkProcessed remembers that the item with given id has been
+// processed successfully. Processed ids are kept apart from the
+// history, which tells only that an item has been enqueued, so
+// callers can tell completed items from pending or failed ones,
+// eg. to decide whether to retry, and items depending on the id,
+// see Dependent, can be dequeued. Ids are normalized like history
+// ids are. They aren't kept by the write-ahead log or snapshots.
+func (q *Queue) MarkProcessed(id interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.markProcessed(id)
+}
+
+// ProcessedExists tells if the item with given id has been marked
+// as processed.
+func (q *Queue) ProcessedExists(id interface{}) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.processed != nil && q.processed.Seen(q.processedKey(id))
+}
+
+// ForgetProcessed forgets that the item with given id has been
+// processed.
+func (q *Queue) ForgetProcessed(id interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.processed != nil {
+		q.processed.Remove(q.processedKey(id))
+	}
+}
+
+// ClearProcessed forgets all the processed ids.
+func (q *Queue) ClearProcessed() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.processed != nil {
+		q.processed.Clear()
+	}
+}
+
+// processedKey normalizes the id, if ids are normalized.
+func (q *Queue) processedKey(id interface{}) interface{} {
+	if q.normalize != nil {
+		return q.normalize(id)
+	}
+	return id
+}
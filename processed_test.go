@@ -0,0 +1,32 @@
+package pqueue
+
+import "testing"
+
+func TestMarkProcessed(t *testing.T) {
+	q := New(0)
+	q.EnqueueUnique(&stateTask{"a", 1})
+	q.EnqueueUnique(&stateTask{"b", 2})
+	if q.ProcessedExists("a") {
+		t.Errorf("Expected enqueued item not to be processed")
+	}
+	q.MarkProcessed(q.Dequeue().Id())
+	if !q.ProcessedExists("a") || q.ProcessedExists("b") {
+		t.Errorf("Expected only a to be processed")
+	}
+	if !q.IdExists("b") {
+		t.Errorf("Expected history to be kept apart")
+	}
+	q.ClearHistory()
+	if !q.ProcessedExists("a") {
+		t.Errorf("Expected processed ids to survive history clear")
+	}
+	q.ForgetProcessed("a")
+	if q.ProcessedExists("a") {
+		t.Errorf("Expected a to be forgotten")
+	}
+	q.MarkProcessed("b")
+	q.ClearProcessed()
+	if q.ProcessedExists("b") {
+		t.Errorf("Expected processed ids to be cleared")
+	}
+}
@@ -0,0 +1,79 @@
+// Package promqueue exports metrics of a pqueue.Queue to Prometheus.
+// The collector reads the queue counters on every scrape, so the
+// queue doesn't have to be wrapped at call sites.
+package promqueue
+
+import (
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector for a single queue.
+type Collector struct {
+	q          *pqueue.Queue
+	length     *prometheus.Desc
+	history    *prometheus.Desc
+	enqueued   *prometheus.Desc
+	dequeued   *prometheus.Desc
+	rejected   *prometheus.Desc
+	duplicates *prometheus.Desc
+	expired    *prometheus.Desc
+	oldestAge  *prometheus.Desc
+	latency    *prometheus.Desc
+}
+
+// NewCollector creates a collector for given queue. Metrics are
+// labeled with queue label set to name, so more queues can be
+// registered at once.
+func NewCollector(q *pqueue.Queue, name string) *Collector {
+	labels := prometheus.Labels{"queue": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("pqueue_"+metric, help, nil, labels)
+	}
+	return &Collector{
+		q:          q,
+		length:     desc("length", "Number of items in the queue."),
+		history:    desc("history_size", "Number of ids in the queue history."),
+		enqueued:   desc("enqueued_total", "Items put to the queue."),
+		dequeued:   desc("dequeued_total", "Items taken from the queue."),
+		rejected:   desc("rejected_total", "Items rejected by the full queue."),
+		duplicates: desc("duplicates_total", "Items not enqueued because their id has been seen."),
+		expired:    desc("expired_total", "Items dropped because they expired."),
+		oldestAge:  desc("oldest_item_age_seconds", "How long the oldest item has been waiting."),
+		latency:    desc("latency_seconds", "How long dequeued items waited in the queue."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.length
+	ch <- c.history
+	ch <- c.enqueued
+	ch <- c.dequeued
+	ch <- c.rejected
+	ch <- c.duplicates
+	ch <- c.expired
+	ch <- c.oldestAge
+	ch <- c.latency
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.q.Stats()
+	ch <- prometheus.MustNewConstMetric(c.length, prometheus.GaugeValue, float64(s.Len))
+	if s.History >= 0 {
+		ch <- prometheus.MustNewConstMetric(c.history, prometheus.GaugeValue, float64(s.History))
+	}
+	ch <- prometheus.MustNewConstMetric(c.enqueued, prometheus.CounterValue, float64(s.Enqueued))
+	ch <- prometheus.MustNewConstMetric(c.dequeued, prometheus.CounterValue, float64(s.Dequeued))
+	ch <- prometheus.MustNewConstMetric(c.rejected, prometheus.CounterValue, float64(s.Rejected))
+	ch <- prometheus.MustNewConstMetric(c.duplicates, prometheus.CounterValue, float64(s.Duplicates))
+	ch <- prometheus.MustNewConstMetric(c.expired, prometheus.CounterValue, float64(s.Expired))
+	ch <- prometheus.MustNewConstMetric(c.oldestAge, prometheus.GaugeValue, c.q.OldestAge().Seconds())
+	h := c.q.Latency()
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	for i, b := range h.Buckets {
+		buckets[b.Seconds()] = h.Counts[i]
+	}
+	ch <- prometheus.MustNewConstHistogram(c.latency, h.Count, h.Sum.Seconds(), buckets)
+}
@@ -0,0 +1,87 @@
+package promqueue
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+func TestCollector(t *testing.T) {
+	q := pqueue.New(2)
+	q.EnqueueUnique(&task{"a", 1})
+	q.EnqueueUnique(&task{"a", 1})
+	q.Enqueue(&task{"b", 2})
+	q.Enqueue(&task{"c", 3})
+	q.Dequeue()
+
+	c := NewCollector(q, "jobs")
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("Expected collector to be registered, given %v", err)
+	}
+	expected := `
+# HELP pqueue_dequeued_total Items taken from the queue.
+# TYPE pqueue_dequeued_total counter
+pqueue_dequeued_total{queue="jobs"} 1
+# HELP pqueue_duplicates_total Items not enqueued because their id has been seen.
+# TYPE pqueue_duplicates_total counter
+pqueue_duplicates_total{queue="jobs"} 1
+# HELP pqueue_enqueued_total Items put to the queue.
+# TYPE pqueue_enqueued_total counter
+pqueue_enqueued_total{queue="jobs"} 2
+# HELP pqueue_length Number of items in the queue.
+# TYPE pqueue_length gauge
+pqueue_length{queue="jobs"} 1
+# HELP pqueue_rejected_total Items rejected by the full queue.
+# TYPE pqueue_rejected_total counter
+pqueue_rejected_total{queue="jobs"} 1
+`
+	names := []string{"pqueue_dequeued_total", "pqueue_duplicates_total", "pqueue_enqueued_total", "pqueue_length", "pqueue_rejected_total"}
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), names...); err != nil {
+		t.Errorf("Expected metrics to match, given %v", err)
+	}
+	if n := testutil.CollectAndCount(c); n != 9 {
+		t.Errorf("Expected 9 metrics, given %d", n)
+	}
+}
+
+func TestCollectorLatency(t *testing.T) {
+	q := pqueue.NewWithOptions(pqueue.WithLatencyBuckets(time.Hour, time.Minute))
+	q.Enqueue(&task{"a", 1})
+	q.Dequeue()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewCollector(q, "jobs"))
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Expected metrics to be gathered, given %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != "pqueue_latency_seconds" {
+			continue
+		}
+		h := f.GetMetric()[0].GetHistogram()
+		if h.GetSampleCount() != 1 || len(h.GetBucket()) != 2 || h.GetBucket()[0].GetUpperBound() != 60 {
+			t.Errorf("Expected one item in the minute bucket, given %v", h)
+		}
+		return
+	}
+	t.Errorf("Expected latency histogram to be exported")
+}
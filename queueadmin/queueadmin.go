@@ -0,0 +1,199 @@
+// Package queueadmin provides an http.Handler to look at and manage
+// a pqueue.Queue of a running service. It serves JSON:
+//
+//	GET    /              length, pause state and stats
+//	GET    /items         pending items, paged with offset and limit
+//	POST   /pause         pause the queue
+//	POST   /resume        resume the queue
+//	POST   /clear         remove pending items, and the history
+//	                      unless keepHistory=true is given
+//	DELETE /items/{id}    remove the pending item with given id
+//	POST   /items/{id}/priority
+//	                      change the priority of the pending item
+//	                      with given id to priority=N, see
+//	                      Options.SetPriority
+//
+// The handler doesn't authenticate anybody, so it's meant to be
+// mounted behind whatever guards the service's internal endpoints.
+package queueadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+// DefaultLimit is the page size of items listing when the request
+// doesn't give one.
+const DefaultLimit = 100
+
+// Options configure how items and ids are shown.
+type Options struct {
+	// Encode turns an item to the value shown in items listing,
+	// encoded with encoding/json. Without it, the item itself is
+	// encoded.
+	Encode func(pqueue.QueueItem) (interface{}, error)
+	// ParseID turns the id given in the path to the item id.
+	// Without it, the id is the path string itself.
+	ParseID func(string) (interface{}, error)
+	// SetPriority sets the priority of the item, called through
+	// pqueue.Queue.UpdatePriority so the item is put back in order.
+	// Without it, changing priorities is not implemented.
+	SetPriority func(item pqueue.QueueItem, priority int)
+}
+
+// Status is what GET / returns.
+type Status struct {
+	Len    int          `json:"len"`
+	Paused bool         `json:"paused"`
+	Stats  pqueue.Stats `json:"stats"`
+}
+
+// Page is what GET /items returns.
+type Page struct {
+	Total  int           `json:"total"`
+	Offset int           `json:"offset"`
+	Items  []interface{} `json:"items"`
+}
+
+// NewHandler returns the handler managing given queue.
+func NewHandler(q *pqueue.Queue, opts Options) http.Handler {
+	h := &handler{q: q, opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", h.status)
+	mux.HandleFunc("GET /items", h.items)
+	mux.HandleFunc("POST /pause", h.pause)
+	mux.HandleFunc("POST /resume", h.resume)
+	mux.HandleFunc("POST /clear", h.clear)
+	mux.HandleFunc("DELETE /items/{id}", h.remove)
+	mux.HandleFunc("POST /items/{id}/priority", h.reprioritize)
+	return mux
+}
+
+type handler struct {
+	q    *pqueue.Queue
+	opts Options
+}
+
+func (h *handler) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, Status{Len: h.q.Len(), Paused: h.q.Paused(), Stats: h.q.Stats()})
+}
+
+func (h *handler) items(w http.ResponseWriter, r *http.Request) {
+	offset, err := intParam(r, "offset", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := intParam(r, "limit", DefaultLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	total := h.q.Len()
+	// limit is clamped before adding, so huge ones don't overflow
+	end := offset + min(limit, max(total-offset, 0))
+	// the best items are enough, unless the page reaches the delayed
+	// ones, which only Items lists
+	items := h.q.PeekN(end)
+	if len(items) < end {
+		items = h.q.Items()
+		total = len(items)
+	}
+	page := Page{Total: total, Offset: offset, Items: []interface{}{}}
+	if offset < len(items) {
+		items = items[offset:min(end, len(items))]
+	} else {
+		items = nil
+	}
+	for _, item := range items {
+		var v interface{} = item
+		if h.opts.Encode != nil {
+			if v, err = h.opts.Encode(item); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		page.Items = append(page.Items, v)
+	}
+	writeJSON(w, page)
+}
+
+func (h *handler) pause(w http.ResponseWriter, r *http.Request) {
+	h.q.Pause()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) resume(w http.ResponseWriter, r *http.Request) {
+	h.q.Resume()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) clear(w http.ResponseWriter, r *http.Request) {
+	h.q.Clear(r.URL.Query().Get("keepHistory") == "true")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) remove(w http.ResponseWriter, r *http.Request) {
+	id, err := h.id(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.q.Remove(id); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) reprioritize(w http.ResponseWriter, r *http.Request) {
+	if h.opts.SetPriority == nil {
+		http.Error(w, "changing priorities is not implemented", http.StatusNotImplemented)
+		return
+	}
+	id, err := h.id(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	priority, err := strconv.Atoi(r.URL.Query().Get("priority"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.q.UpdatePriority(id, func(item pqueue.QueueItem) {
+		h.opts.SetPriority(item, priority)
+	}) {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// id returns the item id given in the path.
+func (h *handler) id(r *http.Request) (interface{}, error) {
+	if h.opts.ParseID == nil {
+		return r.PathValue("id"), nil
+	}
+	return h.opts.ParseID(r.PathValue("id"))
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err == nil && n < 0 {
+		err = strconv.ErrRange
+	}
+	return n, err
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,129 @@
+package queueadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+func do(t *testing.T, h http.Handler, method, target string, status int, v interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	if rec.Code != status {
+		t.Fatalf("Expected %s %s to return %d, given %d", method, target, status, rec.Code)
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("Expected JSON from %s, given %v", target, err)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	q := pqueue.New(0)
+	for i, name := range []string{"c", "a", "b"} {
+		q.Enqueue(&task{name, 3 - i})
+	}
+	h := NewHandler(q, Options{
+		Encode: func(item pqueue.QueueItem) (interface{}, error) {
+			return item.(*task).Name, nil
+		},
+	})
+
+	var page Page
+	do(t, h, "GET", "/items?offset=1&limit=1", http.StatusOK, &page)
+	if page.Total != 3 || len(page.Items) != 1 || page.Items[0] != "a" {
+		t.Errorf("Expected second item in priority order, given %+v", page)
+	}
+	do(t, h, "GET", "/items?offset=5", http.StatusOK, &page)
+	if len(page.Items) != 0 {
+		t.Errorf("Expected empty page past the end, given %+v", page)
+	}
+	do(t, h, "GET", "/items?offset=1&limit=9223372036854775807", http.StatusOK, &page)
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0] != "a" {
+		t.Errorf("Expected the rest of the items for huge limit, given %+v", page)
+	}
+	do(t, h, "GET", "/items?offset=9223372036854775807&limit=9223372036854775807", http.StatusOK, &page)
+	if len(page.Items) != 0 {
+		t.Errorf("Expected empty page for huge offset, given %+v", page)
+	}
+	do(t, h, "GET", "/items?limit=x", http.StatusBadRequest, nil)
+	do(t, h, "GET", "/items?offset=-1", http.StatusBadRequest, nil)
+
+	do(t, h, "POST", "/pause", http.StatusNoContent, nil)
+	var status Status
+	do(t, h, "GET", "/", http.StatusOK, &status)
+	if !status.Paused || status.Len != 3 || status.Stats.Enqueued != 3 {
+		t.Errorf("Expected paused queue of 3 items, given %+v", status)
+	}
+	do(t, h, "POST", "/resume", http.StatusNoContent, nil)
+	if q.Paused() {
+		t.Errorf("Expected queue to be resumed")
+	}
+
+	do(t, h, "DELETE", "/items/a", http.StatusNoContent, nil)
+	do(t, h, "DELETE", "/items/a", http.StatusNotFound, nil)
+	if q.Len() != 2 {
+		t.Errorf("Expected item to be removed")
+	}
+	do(t, h, "POST", "/clear?keepHistory=true", http.StatusNoContent, nil)
+	if !q.IsEmpty() || !q.IdExists("b") {
+		t.Errorf("Expected queue to be cleared with history kept")
+	}
+}
+
+func TestHandlerDelayed(t *testing.T) {
+	q := pqueue.New(0)
+	q.Enqueue(&task{"a", 1})
+	q.EnqueueAfter(&task{"b", 0}, time.Hour)
+	h := NewHandler(q, Options{
+		Encode: func(item pqueue.QueueItem) (interface{}, error) {
+			return item.(*task).Name, nil
+		},
+	})
+	var page Page
+	do(t, h, "GET", "/items?limit=1", http.StatusOK, &page)
+	if page.Total != 2 || len(page.Items) != 1 || page.Items[0] != "a" {
+		t.Errorf("Expected the ready item first, given %+v", page)
+	}
+	do(t, h, "GET", "/items?offset=1", http.StatusOK, &page)
+	if len(page.Items) != 1 || page.Items[0] != "b" {
+		t.Errorf("Expected the delayed item after the ready ones, given %+v", page)
+	}
+}
+
+func TestHandlerPriority(t *testing.T) {
+	q := pqueue.New(0)
+	q.Enqueue(&task{"a", 1})
+	q.Enqueue(&task{"b", 2})
+	do(t, NewHandler(q, Options{}), "POST", "/items/b/priority?priority=0", http.StatusNotImplemented, nil)
+	h := NewHandler(q, Options{
+		SetPriority: func(item pqueue.QueueItem, priority int) {
+			item.(*task).Priority = priority
+		},
+	})
+	do(t, h, "POST", "/items/b/priority?priority=0", http.StatusNoContent, nil)
+	if item, _ := q.Peek(); item.(*task).Name != "b" {
+		t.Errorf("Expected the item put back in order, given %v", item)
+	}
+	do(t, h, "POST", "/items/x/priority?priority=0", http.StatusNotFound, nil)
+	do(t, h, "POST", "/items/a/priority?priority=x", http.StatusBadRequest, nil)
+}
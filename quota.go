@@ -0,0 +1,63 @@
+package pqueue
+
+import "errors"
+
+// ErrQuotaExceeded is returned by EnqueueQuota when the producer
+// already has as many items queued as its quota allows.
+var ErrQuotaExceeded = errors.New("Producer quota exceeded")
+
+// EnqueueQuota puts item to the queue on behalf of given producer,
+// unless the producer already has quota items waiting in it. The
+// queue limit still applies, so both the producer quota and the
+// queue limit need room for the item. Quota of 0 or less means
+// no quota.
+func (q *Queue) EnqueueQuota(producerKey string, item QueueItem, quota int) (err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.enqueueQuota(producerKey, item, quota)
+}
+
+// enqueueQuota is EnqueueQuota with the queue locked.
+func (q *Queue) enqueueQuota(producerKey string, item QueueItem, quota int) error {
+	if quota > 0 && q.producers[producerKey] >= quota {
+		q.logEvent(LogRejected, item, ErrQuotaExceeded)
+		q.emit(Dropped, item)
+		return ErrQuotaExceeded
+	}
+	return q.enqueueEntry(&entry{item: item, id: item.Id(), producer: producerKey})
+}
+
+// WithProducerShare sets the most of the queue limit, between 0 and
+// 1, a single producer may take with EnqueueAs, so one noisy producer
+// can't fill the whole queue. With share of 0.25 and limit of 100
+// every producer may have up to 25 items waiting, but at least one.
+// It has no effect on unlimited queues.
+func WithProducerShare(share float64) Option {
+	return func(q *Queue) {
+		q.producerShare = share
+	}
+}
+
+// EnqueueAs puts item to the queue on behalf of given producer, like
+// EnqueueQuota does, with the quota given by WithProducerShare.
+func (q *Queue) EnqueueAs(producerKey string, item QueueItem) error {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.enqueueQuota(producerKey, item, q.shareQuota())
+}
+
+// shareQuota returns the producer quota given by the share of the
+// limit, 0 for no quota.
+func (q *Queue) shareQuota() int {
+	if q.producerShare <= 0 || q.Limit <= 0 {
+		return 0
+	}
+	return max(int(q.producerShare*float64(q.Limit)), 1)
+}
+
+// ProducerLen returns number of items queued by given producer.
+func (q *Queue) ProducerLen(producerKey string) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.producers[producerKey]
+}
@@ -0,0 +1,56 @@
+package pqueue
+
+import "testing"
+
+func TestEnqueueQuota(t *testing.T) {
+	q := New(0)
+	for i := 0; i < 5; i += 1 {
+		q.EnqueueQuota("greedy", NewDummyTask(1), 3)
+		q.EnqueueQuota("modest", NewDummyTask(2), 1)
+	}
+	if q.ProducerLen("greedy") != 3 || q.ProducerLen("modest") != 1 {
+		t.Errorf("Expected producers to be limited by their quotas")
+	}
+	if err := q.EnqueueQuota("greedy", NewDummyTask(1), 3); err != ErrQuotaExceeded {
+		t.Errorf("Expected quota to be exceeded, given %v", err)
+	}
+	q.Dequeue()
+	if err := q.EnqueueQuota("greedy", NewDummyTask(1), 3); err != nil {
+		t.Errorf("Expected dequeue to free producer quota, given %v", err)
+	}
+}
+
+func TestEnqueueQuotaLimit(t *testing.T) {
+	q := New(2)
+	q.EnqueueQuota("first", NewDummyTask(1), 5)
+	q.EnqueueQuota("first", NewDummyTask(1), 5)
+	err := q.EnqueueQuota("second", NewDummyTask(2), 5)
+	if err == nil || err.Error() != "Queue limit reached" {
+		t.Errorf("Expected to reach queue limit within the quota")
+	}
+	if q.ProducerLen("second") != 0 {
+		t.Errorf("Expected rejected item not to be counted")
+	}
+}
+
+func TestEnqueueAs(t *testing.T) {
+	q := NewWithOptions(WithLimit(8), WithProducerShare(0.25))
+	for i := 0; i < 4; i += 1 {
+		q.EnqueueAs("noisy", NewDummyTask(1))
+	}
+	if err := q.EnqueueAs("noisy", NewDummyTask(1)); err != ErrQuotaExceeded {
+		t.Errorf("Expected share to be exceeded, given %v", err)
+	}
+	if q.ProducerLen("noisy") != 2 {
+		t.Errorf("Expected 2 items of the noisy producer, given %d", q.ProducerLen("noisy"))
+	}
+	if err := q.EnqueueAs("quiet", NewDummyTask(2)); err != nil {
+		t.Errorf("Expected other producer to have room, given %v", err)
+	}
+	u := NewWithOptions(WithProducerShare(0.25))
+	for i := 0; i < 10; i += 1 {
+		if err := u.EnqueueAs("noisy", NewDummyTask(1)); err != nil {
+			t.Errorf("Expected no quota in unlimited queue, given %v", err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+module github.com/mileusna/gopqueue/raftqueue
+
+go 1.24
+
+require (
+	github.com/hashicorp/raft v1.7.1
+	github.com/mileusna/gopqueue v0.0.0-20261014081040-eb84ca7ebd43
+)
+
+require (
+	github.com/armon/go-metrics v0.4.1 // indirect
+	github.com/fatih/color v1.13.0 // indirect
+	github.com/hashicorp/go-hclog v1.6.2 // indirect
+	github.com/hashicorp/go-immutable-radix v1.0.0 // indirect
+	github.com/hashicorp/go-msgpack/v2 v2.1.2 // indirect
+	github.com/hashicorp/golang-lru v0.5.0 // indirect
+	github.com/mattn/go-colorable v0.1.12 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
@@ -0,0 +1,244 @@
+// Package raftqueue provides a priority queue replicated with Raft,
+// through github.com/hashicorp/raft, so it survives failure of a
+// minority of nodes. Enqueues and dequeues are both entries of the
+// Raft log, applied on every node in the same order, so once an
+// operation returns it has been committed on a quorum: an enqueued
+// item isn't lost when the leader fails, and a dequeued item isn't
+// handed out again by the next leader.
+package raftqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/hashicorp/raft"
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+// ErrCorrupt is returned when a log entry or a snapshot can't be
+// read back.
+var ErrCorrupt = errors.New("Corrupt log entry")
+
+const (
+	opEnqueue byte = iota + 1
+	opEnqueueUnique
+	opDequeue
+)
+
+// Options configure how items are encoded for the log.
+type Options struct {
+	// Encode and Decode turn items to bytes and back.
+	Encode func(pqueue.QueueItem) ([]byte, error)
+	Decode func([]byte) (pqueue.QueueItem, error)
+	// Timeout of applying an operation to the log, 10 seconds
+	// when 0.
+	Timeout time.Duration
+}
+
+// FSM is the raft.FSM keeping the replicated queue, one per node.
+// Pass it to raft.NewRaft, then use the node through Queue.
+type FSM struct {
+	q    *pqueue.Queue
+	opts Options
+}
+
+// NewFSM creates the state machine of an empty queue. Items of equal
+// priority are kept in stable order, so every node dequeues the same
+// item, snapshots and restores included.
+func NewFSM(opts Options) *FSM {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &FSM{q: pqueue.NewWithOptions(pqueue.WithStableOrder()), opts: opts}
+}
+
+// Len returns number of items in the node's copy of the queue, which
+// may be behind the leader's.
+func (f *FSM) Len() int {
+	return f.q.Len()
+}
+
+// result is what applying an entry returns.
+type result struct {
+	item pqueue.QueueItem
+	err  error
+}
+
+// Apply applies the log entry to the queue.
+func (f *FSM) Apply(l *raft.Log) interface{} {
+	if len(l.Data) == 0 {
+		return result{err: ErrCorrupt}
+	}
+	switch l.Data[0] {
+	case opEnqueue, opEnqueueUnique:
+		item, err := f.opts.Decode(l.Data[1:])
+		if err != nil {
+			return result{err: err}
+		}
+		if l.Data[0] == opEnqueueUnique {
+			return result{err: f.q.EnqueueUnique(item)}
+		}
+		return result{err: f.q.Enqueue(item)}
+	case opDequeue:
+		item, _ := f.q.TryDequeue()
+		return result{item: item}
+	}
+	return result{err: ErrCorrupt}
+}
+
+// snapshot is what the FSM snapshot persists, encoded with gob.
+type snapshot struct {
+	Items   [][]byte
+	History []interface{}
+}
+
+// Snapshot captures the queue. History ids are encoded with
+// encoding/gob, so ids of other than basic types have to be
+// registered with gob.Register.
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	return &fsmSnapshot{items: f.q.Items(), history: f.q.ExportHistory(), encode: f.opts.Encode}, nil
+}
+
+// Restore replaces the queue with the snapshot.
+func (f *FSM) Restore(r io.ReadCloser) error {
+	defer r.Close()
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return ErrCorrupt
+	}
+	f.q.Clear(false)
+	for _, data := range snap.Items {
+		item, err := f.opts.Decode(data)
+		if err != nil {
+			return err
+		}
+		f.q.Enqueue(item)
+	}
+	f.q.ImportHistory(snap.History)
+	return nil
+}
+
+type fsmSnapshot struct {
+	items   []pqueue.QueueItem
+	history []interface{}
+	encode  func(pqueue.QueueItem) ([]byte, error)
+}
+
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	snap := snapshot{Items: make([][]byte, len(s.items)), History: s.history}
+	for i, item := range s.items {
+		data, err := s.encode(item)
+		if err != nil {
+			sink.Cancel()
+			return err
+		}
+		snap.Items[i] = data
+	}
+	if err := gob.NewEncoder(sink).Encode(&snap); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {}
+
+// Queue is the replicated queue as seen through a Raft node. Its
+// operations have to be called on the leader, on other nodes they
+// return raft.ErrNotLeader.
+type Queue struct {
+	raft *raft.Raft
+	fsm  *FSM
+}
+
+// New returns the queue of given Raft node, created with given FSM.
+func New(r *raft.Raft, fsm *FSM) *Queue {
+	return &Queue{raft: r, fsm: fsm}
+}
+
+// Enqueue puts given item to the queue, once it's committed.
+func (q *Queue) Enqueue(ctx context.Context, item pqueue.QueueItem) error {
+	return q.enqueue(ctx, opEnqueue, item)
+}
+
+// EnqueueUnique puts item in queue only if it hasn't already been
+// in queue, otherwise it returns pqueue.ErrDuplicate. Enqueueing the
+// same item again after an error, eg. a timeout, is safe with it.
+func (q *Queue) EnqueueUnique(ctx context.Context, item pqueue.QueueItem) error {
+	return q.enqueue(ctx, opEnqueueUnique, item)
+}
+
+func (q *Queue) enqueue(ctx context.Context, op byte, item pqueue.QueueItem) error {
+	data, err := q.fsm.opts.Encode(item)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(op)
+	buf.Write(data)
+	r, err := q.apply(ctx, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return r.err
+}
+
+// TryDequeue takes an item from the queue without blocking, once
+// it's committed. It returns nil item when the queue is empty.
+func (q *Queue) TryDequeue(ctx context.Context) (pqueue.QueueItem, error) {
+	r, err := q.apply(ctx, []byte{opDequeue})
+	if err != nil {
+		return nil, err
+	}
+	return r.item, r.err
+}
+
+// Dequeue takes an item from the queue. If queue is empty then it
+// blocks waiting for at least one item, until the context is done.
+func (q *Queue) Dequeue(ctx context.Context) (pqueue.QueueItem, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lens := q.fsm.q.Watch(ctx, 1)
+	for {
+		item, err := q.TryDequeue(ctx)
+		if item != nil || err != nil {
+			return item, err
+		}
+		// wait for the node's queue to get an item
+		for n := 0; n == 0; {
+			select {
+			case n = <-lens:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// Len returns number of enqueued items, as known to the node.
+func (q *Queue) Len() int {
+	return q.fsm.Len()
+}
+
+func (q *Queue) apply(ctx context.Context, cmd []byte) (r result, err error) {
+	timeout := q.fsm.opts.Timeout
+	if at, ok := ctx.Deadline(); ok && time.Until(at) < timeout {
+		timeout = time.Until(at)
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	f := q.raft.Apply(cmd, timeout)
+	if err = f.Error(); err != nil {
+		return
+	}
+	r, ok := f.Response().(result)
+	if !ok {
+		err = ErrCorrupt
+	}
+	return
+}
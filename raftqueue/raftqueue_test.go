@@ -0,0 +1,174 @@
+package raftqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+var options = Options{
+	Encode: func(item pqueue.QueueItem) ([]byte, error) {
+		return json.Marshal(item)
+	},
+	Decode: func(data []byte) (pqueue.QueueItem, error) {
+		t := &task{}
+		return t, json.Unmarshal(data, t)
+	},
+}
+
+type node struct {
+	raft *raft.Raft
+	fsm  *FSM
+}
+
+// cluster starts Raft nodes talking over in-memory transports.
+func cluster(t *testing.T, n int) []node {
+	nodes := make([]node, n)
+	transports := make([]*raft.InmemTransport, n)
+	var servers []raft.Server
+	for i := range transports {
+		_, transports[i] = raft.NewInmemTransport(raft.ServerAddress(fmt.Sprint("node", i)))
+		servers = append(servers, raft.Server{ID: raft.ServerID(fmt.Sprint(i)), Address: transports[i].LocalAddr()})
+	}
+	for i, tr := range transports {
+		for _, other := range transports {
+			if other != tr {
+				tr.Connect(other.LocalAddr(), other)
+			}
+		}
+		conf := raft.DefaultConfig()
+		conf.LocalID = servers[i].ID
+		conf.HeartbeatTimeout = 50 * time.Millisecond
+		conf.ElectionTimeout = 50 * time.Millisecond
+		conf.LeaderLeaseTimeout = 50 * time.Millisecond
+		conf.CommitTimeout = 5 * time.Millisecond
+		conf.LogOutput = nopWriter{}
+		store := raft.NewInmemStore()
+		snaps := raft.NewInmemSnapshotStore()
+		if err := raft.BootstrapCluster(conf, store, store, snaps, tr, raft.Configuration{Servers: servers}); err != nil {
+			t.Fatalf("Expected cluster to bootstrap, given %v", err)
+		}
+		fsm := NewFSM(options)
+		r, err := raft.NewRaft(conf, fsm, store, store, snaps, tr)
+		if err != nil {
+			t.Fatalf("Expected node to start, given %v", err)
+		}
+		nodes[i] = node{r, fsm}
+		t.Cleanup(func() { r.Shutdown().Error() })
+	}
+	return nodes
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func leader(t *testing.T, nodes []node) node {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, n := range nodes {
+			if n.raft.State() == raft.Leader {
+				return n
+			}
+		}
+	}
+	t.Fatalf("Expected a leader to be elected")
+	return node{}
+}
+
+func TestReplicatedQueue(t *testing.T) {
+	nodes := cluster(t, 3)
+	l := leader(t, nodes)
+	q := New(l.raft, l.fsm)
+	ctx := context.Background()
+	for i, x := range []int{3, 1, 2} {
+		if err := q.Enqueue(ctx, &task{Name: string(rune('a' + i)), Priority: x}); err != nil {
+			t.Fatalf("Expected item to be enqueued, given %v", err)
+		}
+	}
+	if err := q.EnqueueUnique(ctx, &task{Name: "a", Priority: 0}); err != pqueue.ErrDuplicate {
+		t.Errorf("Expected duplicate, given %v", err)
+	}
+	if item, err := q.Dequeue(ctx); err != nil || item.(*task).Name != "b" {
+		t.Errorf("Expected b, given %v %v", item, err)
+	}
+	if err := l.raft.Barrier(time.Second).Error(); err != nil {
+		t.Fatalf("Expected barrier, given %v", err)
+	}
+	for _, n := range nodes {
+		if n.raft != l.raft {
+			if err := New(n.raft, n.fsm).Enqueue(ctx, &task{Name: "x"}); err != raft.ErrNotLeader {
+				t.Errorf("Expected ErrNotLeader on follower, given %v", err)
+			}
+		}
+	}
+
+	// the leader fails, the rest of the cluster carries on
+	l.raft.Shutdown().Error()
+	var rest []node
+	for _, n := range nodes {
+		if n.raft != l.raft {
+			rest = append(rest, n)
+		}
+	}
+	l = leader(t, rest)
+	q = New(l.raft, l.fsm)
+	for _, name := range []string{"c", "a"} {
+		if item, err := q.Dequeue(ctx); err != nil || item.(*task).Name != name {
+			t.Errorf("Expected %s from the new leader, given %v %v", name, item, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := q.Dequeue(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected nothing more to dequeue, given %v", err)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	f := NewFSM(options)
+	f.Apply(&raft.Log{Data: append([]byte{opEnqueue}, `{"Name":"a","Priority":2}`...)})
+	f.Apply(&raft.Log{Data: append([]byte{opEnqueue}, `{"Name":"b","Priority":1}`...)})
+	f.Apply(&raft.Log{Data: []byte{opDequeue}})
+	snap, _ := f.Snapshot()
+	sink := &memSink{}
+	if err := snap.Persist(sink); err != nil {
+		t.Fatalf("Expected snapshot to be persisted, given %v", err)
+	}
+
+	r := NewFSM(options)
+	if err := r.Restore(sink); err != nil {
+		t.Fatalf("Expected snapshot to be restored, given %v", err)
+	}
+	if r.Len() != 1 || !r.q.IdExists("b") {
+		t.Errorf("Expected items and history restored")
+	}
+}
+
+type memSink struct {
+	bytes.Buffer
+}
+
+func (s *memSink) ID() string    { return "mem" }
+func (s *memSink) Cancel() error { return nil }
+func (s *memSink) Close() error  { return nil }
@@ -0,0 +1,41 @@
+package pqueue
+
+import "math/rand"
+
+// WithRandomTop makes dequeueing take one of the k best items at
+// random, each as likely, instead of the best one, so the load of
+// items of about the same priority spreads, eg. over downstream
+// shards. Held and expired items don't count. Peek still returns
+// the best item.
+func WithRandomTop(k int) Option {
+	return func(q *Queue) {
+		q.randomTop = k
+	}
+}
+
+// pick takes one of the randomTop best entries at random, dropping
+// the expired ones on the way. It returns nil when there is none.
+// Must be called with the queue locked.
+func (q *Queue) pick() *entry {
+	var top, expired []*entry
+	now := q.now()
+	q.walk(func(e *entry) bool {
+		if isExpired(e.item, now) {
+			expired = append(expired, e)
+		} else if !q.heldBack(e.item) {
+			top = append(top, e)
+		}
+		return len(top) < q.randomTop
+	})
+	for _, e := range expired {
+		q.remove(e)
+		q.logEvent(LogExpired, e.item, nil)
+		q.emit(Expired, e.item)
+	}
+	if len(top) == 0 {
+		return nil
+	}
+	e := top[rand.Intn(len(top))]
+	q.remove(e)
+	return e
+}
@@ -0,0 +1,43 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRandomTop(t *testing.T) {
+	seen := make(map[int]int)
+	for i := 0; i < 200; i++ {
+		q := NewWithOptions(WithRandomTop(2))
+		for p := 1; p <= 5; p++ {
+			q.Enqueue(NewDummyTask(p))
+		}
+		item, _ := q.TryDequeue()
+		seen[item.(*DummyTask).priority] += 1
+		if q.Len() != 4 {
+			t.Errorf("Expected 4 items left, given %d", q.Len())
+		}
+		if err := q.Validate(); err != nil {
+			t.Errorf("Expected valid queue, given %v", err)
+		}
+	}
+	if len(seen) != 2 || seen[1] == 0 || seen[2] == 0 {
+		t.Errorf("Expected both of the 2 best items taken, given %v", seen)
+	}
+}
+
+func TestRandomTopExpired(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithRandomTop(3), WithClock(clock))
+	stale := &expiringTask{DummyTask{1}, clock.Now().Add(time.Second)}
+	forever := &expiringTask{DummyTask: DummyTask{2}}
+	q.Enqueue(stale)
+	q.Enqueue(forever)
+	clock.Advance(time.Second)
+	if item, ok := q.TryDequeue(); !ok || item != forever {
+		t.Errorf("Expected item which doesn't expire, given %v", item)
+	}
+	if q.Expired() != 1 || q.Len() != 0 {
+		t.Errorf("Expected expired item dropped, given %d expired and %d left", q.Expired(), q.Len())
+	}
+}
@@ -0,0 +1,66 @@
+package pqueue
+
+// RankFunc scores an item at the moment it's enqueued, given a
+// read-only view of the queue. Items with lower scores are
+// dequeued first, the same way Less puts the lesser item first.
+type RankFunc func(item QueueItem, state QueueState) int64
+
+// QueueState is a read-only view of the queue passed to RankFunc.
+type QueueState struct {
+	len   int
+	head  QueueItem
+	score int64
+}
+
+// Len returns number of enqueued elements.
+func (s QueueState) Len() int {
+	return s.len
+}
+
+// Head returns the item that would be dequeued next, or nil when
+// the queue is empty.
+func (s QueueState) Head() QueueItem {
+	return s.head
+}
+
+// HeadScore returns score of the head item. Second value is false
+// when the queue is empty.
+func (s QueueState) HeadScore() (int64, bool) {
+	return s.score, s.head != nil
+}
+
+// NewRanked creates a priority queue ordered by scores computed
+// with given RankFunc instead of items' Less method. Scores are
+// snapshots taken at enqueue time, so they aren't refreshed when
+// the queue changes later on; call Rerank to refresh them.
+func NewRanked(max int, rank RankFunc) *Queue {
+	return NewWithOptions(WithLimit(max), WithRank(rank))
+}
+
+// Rerank computes scores of all the enqueued items again and
+// restores the ordering. Every item is scored against the state
+// of the queue as it was before reranking. It does nothing for
+// queues which are not ranked.
+func (q *Queue) Rerank() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.rank == nil {
+		return
+	}
+	state := q.state()
+	for _, e := range q.items.list() {
+		e.score = q.rank(e.item, state)
+	}
+	q.items.init()
+}
+
+// state returns the current view of the queue. Must be called
+// with the queue locked.
+func (q *Queue) state() (s QueueState) {
+	s.len = q.items.Len()
+	if e := q.items.first(); e != nil {
+		s.head = e.item
+		s.score = e.score
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package pqueue
+
+import "testing"
+
+// relativeRank scores an item by its distance from the head
+// priority, so items close to the current best go first.
+func relativeRank(item QueueItem, state QueueState) int64 {
+	head := state.Head()
+	if head == nil {
+		return 0
+	}
+	d := item.(*DummyTask).priority - head.(*DummyTask).priority
+	if d < 0 {
+		d = -d
+	}
+	return int64(d)
+}
+
+func TestRankedQueue(t *testing.T) {
+	q := NewRanked(0, func(item QueueItem, state QueueState) int64 {
+		return int64(-item.(*DummyTask).priority)
+	})
+	for _, x := range []int{1, 3, 4, 2, 7, 3} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	for _, x := range []int{7, 4, 3, 3, 2, 1} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestRankedQueueRelativeScore(t *testing.T) {
+	q := NewRanked(0, relativeRank)
+	for _, x := range []int{5, 1, 6, 10} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	for _, x := range []int{5, 6, 1, 10} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestRerank(t *testing.T) {
+	q := NewRanked(0, relativeRank)
+	for _, x := range []int{5, 9, 2} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.Dequeue()
+	// scores of 9 and 2 are still relative to 5, rerank them
+	// relative to the new head
+	q.Rerank()
+	q.Enqueue(NewDummyTask(8))
+	for _, x := range []int{2, 8, 9} {
+		task := q.Dequeue().(*DummyTask)
+		if task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+}
+
+func TestQueueState(t *testing.T) {
+	q := New(0)
+	if _, ok := q.state().HeadScore(); ok {
+		t.Errorf("Expected no head score for empty queue")
+	}
+	q.Enqueue(NewDummyTask(2))
+	q.Enqueue(NewDummyTask(1))
+	s := q.state()
+	if s.Len() != 2 || s.Head().(*DummyTask).priority != 1 {
+		t.Errorf("Expected state to expose len and head")
+	}
+}
+
+func TestRankedStableOrder(t *testing.T) {
+	q := NewRanked(0, func(QueueItem, QueueState) int64 { return 0 })
+	q.SetStableOrder(true)
+	tasks := []*DummyTask{}
+	for _, x := range []int{3, 1, 2} {
+		task := NewDummyTask(x)
+		tasks = append(tasks, task)
+		q.Enqueue(task)
+	}
+	for _, task := range tasks {
+		if q.Dequeue() != task {
+			t.Errorf("Expected equal scores to keep insertion order")
+		}
+	}
+}
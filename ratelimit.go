@@ -0,0 +1,82 @@
+package pqueue
+
+import (
+	"math"
+	"time"
+)
+
+// limiter is a token bucket limiting the rate of dequeueing.
+type limiter struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	timer  Timer
+}
+
+// WithDequeueRate lets consumers take at most r items per second,
+// with bursts of up to burst items, so consumers don't need an
+// external limiter each. Dequeue and the other blocking methods wait
+// for their turn, keeping their place in line, while TryDequeue
+// returns nothing until the rate allows it. Drain is not limited.
+// Rate of 0 or less turns limiting off.
+func WithDequeueRate(r float64, burst int) Option {
+	return func(q *Queue) {
+		q.SetDequeueRate(r, burst)
+	}
+}
+
+// SetDequeueRate safely changes the dequeue rate, see
+// WithDequeueRate.
+func (q *Queue) SetDequeueRate(r float64, burst int) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if r <= 0 {
+		q.limiter = nil
+		q.wakeAll()
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	q.limiter = &limiter{rate: r, burst: float64(burst), tokens: float64(burst), last: q.now()}
+	q.wakeAll()
+}
+
+// refill adds the tokens earned since the last refill.
+func (l *limiter) refill(now time.Time) {
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// allowed tells if an item may be dequeued right now, and if not,
+// makes sure the waiting consumers are woken up once it may. Must be
+// called with the queue locked.
+func (q *Queue) allowed() bool {
+	l := q.limiter
+	if l == nil {
+		return true
+	}
+	l.refill(q.now())
+	if l.tokens >= 1 {
+		return true
+	}
+	if l.timer == nil && q.items.Len()+len(q.delayed) > 0 {
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.timer = q.clock.AfterFunc(wait, func() {
+			q.cond.L.Lock()
+			defer q.cond.L.Unlock()
+			l.timer = nil
+			q.wakeAll()
+		})
+	}
+	return false
+}
+
+// took uses up a token for the dequeued item. Must be called with
+// the queue locked.
+func (q *Queue) took() {
+	if q.limiter != nil {
+		q.limiter.tokens -= 1
+	}
+}
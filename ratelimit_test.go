@@ -0,0 +1,37 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDequeueRate(t *testing.T) {
+	q := NewWithOptions(WithDequeueRate(20, 2))
+	for i := 0; i < 5; i++ {
+		q.Enqueue(&DummyTask{priority: i})
+	}
+	if items := q.DequeueN(5); len(items) != 2 {
+		t.Errorf("Expected burst of 2 items, given %d", len(items))
+	}
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected TryDequeue to be limited")
+	}
+	start := time.Now()
+	for i := 2; i < 5; i++ {
+		if task := q.Dequeue().(*DummyTask); task.priority != i {
+			t.Errorf("Expected to dequeue %d, given %d", i, task.priority)
+		}
+	}
+	if d := time.Since(start); d < 120*time.Millisecond {
+		t.Errorf("Expected 3 items to take at least 150ms, taken %v", d)
+	}
+
+	q.Enqueue(&DummyTask{priority: 5})
+	if _, err := q.DequeueTimeout(10 * time.Millisecond); err != ErrTimeout {
+		t.Errorf("Expected limited dequeue to time out, given %v", err)
+	}
+	q.SetDequeueRate(0, 0)
+	if _, ok := q.TryDequeue(); !ok {
+		t.Errorf("Expected no limit once rate is turned off")
+	}
+}
@@ -0,0 +1,117 @@
+package pqueue
+
+import "time"
+
+const (
+	// rateStep is how long every counted step of the rate windows is
+	rateStep = 5 * time.Second
+	// rateSteps cover the longest window, 15 minutes
+	rateSteps = int64(15 * time.Minute / rateStep)
+)
+
+const (
+	rateEnqueued = iota
+	rateDequeued
+	rateRejected
+)
+
+// Rate is the number of events per second over the last 1, 5 and
+// 15 minutes. Events are counted in 5 second steps, so the windows
+// slide in steps too.
+type Rate struct {
+	M1, M5, M15 float64
+}
+
+// Rates are the rates of enqueued, dequeued and rejected items, as
+// reported by Stats.
+type Rates struct {
+	Enqueued Rate
+	Dequeued Rate
+	Rejected Rate
+}
+
+// rates counts events in the steps of the last 15 minutes.
+type rates struct {
+	counts [rateSteps][3]uint64
+	// at is the latest step counted, since the zero time
+	at int64
+}
+
+// add counts the event of given kind.
+func (r *rates) add(now time.Time, kind int) {
+	step := now.UnixNano() / int64(rateStep)
+	if step > r.at {
+		for i := r.at + 1; i <= step && i <= r.at+rateSteps; i += 1 {
+			r.counts[i%rateSteps] = [3]uint64{}
+		}
+		r.at = step
+	}
+	// events of a clock going back count to the latest step
+	r.counts[r.at%rateSteps][kind] += 1
+}
+
+// rate returns the rate of events of given kind over given window.
+func (r *rates) rate(now time.Time, kind int, window time.Duration) float64 {
+	step := now.UnixNano() / int64(rateStep)
+	var n uint64
+	for i := max(step-int64(window/rateStep)+1, r.at-rateSteps+1); i <= min(step, r.at); i += 1 {
+		n += r.counts[i%rateSteps][kind]
+	}
+	return float64(n) / window.Seconds()
+}
+
+func (r *rates) get(now time.Time, kind int) Rate {
+	return Rate{
+		M1:  r.rate(now, kind, time.Minute),
+		M5:  r.rate(now, kind, 5*time.Minute),
+		M15: r.rate(now, kind, 15*time.Minute),
+	}
+}
+
+// rates returns the rates of enqueued, dequeued and rejected items.
+func (r *rates) rates(now time.Time) Rates {
+	if r == nil {
+		return Rates{}
+	}
+	return Rates{
+		Enqueued: r.get(now, rateEnqueued),
+		Dequeued: r.get(now, rateDequeued),
+		Rejected: r.get(now, rateRejected),
+	}
+}
+
+// plus adds the rates up, eg. of several queues.
+func (r Rates) plus(o Rates) Rates {
+	return Rates{
+		Enqueued: r.Enqueued.plus(o.Enqueued),
+		Dequeued: r.Dequeued.plus(o.Dequeued),
+		Rejected: r.Rejected.plus(o.Rejected),
+	}
+}
+
+func (r Rate) plus(o Rate) Rate {
+	return Rate{M1: r.M1 + o.M1, M5: r.M5 + o.M5, M15: r.M15 + o.M15}
+}
+
+// countRate counts the event of given kind in the rate windows. Must
+// be called with the queue locked.
+func (q *Queue) countRate(kind int) {
+	if q.rates == nil {
+		q.rates = &rates{}
+	}
+	q.rates.add(q.now(), kind)
+}
+
+// ResetStats sets the counters returned by Stats, the rates and the
+// latency histogram back to zero.
+func (q *Queue) ResetStats() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.stats = Stats{}
+	q.rates = nil
+	for i := range q.latency.Counts {
+		q.latency.Counts[i] = 0
+	}
+	q.latency.Count = 0
+	q.latency.Sum = 0
+}
@@ -0,0 +1,171 @@
+// Package redisqueue provides a priority queue kept in Redis, so
+// several processes can share one queue. Items are kept in a
+// sorted set scored by their priority, and ids of items that have
+// been enqueued are kept in a set, giving the same Enqueue,
+// EnqueueUnique and Dequeue semantics as the in-memory queue.
+// Keys of a queue share the {name} hash tag, so they're kept in the
+// same slot and the queue works with Redis Cluster too. The queue
+// has no limit, options of the in-memory queue like WithLimit have
+// no counterpart here.
+package redisqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCorrupt is returned when stored item can't be read back.
+var ErrCorrupt = errors.New("Corrupt stored item")
+
+// Options configure how items are stored and ordered.
+type Options struct {
+	// Encode and Decode turn items to bytes and back.
+	Encode func(pqueue.QueueItem) ([]byte, error)
+	Decode func([]byte) (pqueue.QueueItem, error)
+	// Priority of the item, items with lower priority are
+	// dequeued first. Items of equal priority are dequeued in
+	// the order they have been enqueued.
+	Priority func(pqueue.QueueItem) float64
+}
+
+// Queue is a priority queue kept in Redis under a given name.
+type Queue struct {
+	rdb     redis.UniversalClient
+	items   string
+	history string
+	seq     string
+	opts    Options
+}
+
+// enqueueScript adds the item, and its id to the history, in one
+// go. With unique flag set, items already in history are skipped.
+var enqueueScript = redis.NewScript(`
+if ARGV[4] == "1" and redis.call("SISMEMBER", KEYS[2], ARGV[3]) == 1 then
+	return 0
+end
+redis.call("SADD", KEYS[2], ARGV[3])
+local seq = redis.call("INCR", KEYS[3])
+redis.call("ZADD", KEYS[1], ARGV[1], string.format("%020d:", seq) .. ARGV[2])
+return 1
+`)
+
+// New returns the queue with given name, kept in Redis through
+// given client. Queues with the same name share their items.
+func New(rdb redis.UniversalClient, name string, opts Options) *Queue {
+	tag := "{" + name + "}"
+	return &Queue{
+		rdb:     rdb,
+		items:   tag + ":items",
+		history: tag + ":history",
+		seq:     tag + ":seq",
+		opts:    opts,
+	}
+}
+
+// Enqueue puts given item to the queue.
+func (q *Queue) Enqueue(ctx context.Context, item pqueue.QueueItem) error {
+	return q.enqueue(ctx, item, false)
+}
+
+// EnqueueUnique puts item in queue only if it hasn't already
+// been in queue, otherwise it returns pqueue.ErrDuplicate.
+func (q *Queue) EnqueueUnique(ctx context.Context, item pqueue.QueueItem) error {
+	return q.enqueue(ctx, item, true)
+}
+
+func (q *Queue) enqueue(ctx context.Context, item pqueue.QueueItem, unique bool) error {
+	data, err := q.opts.Encode(item)
+	if err != nil {
+		return err
+	}
+	flag := "0"
+	if unique {
+		flag = "1"
+	}
+	keys := []string{q.items, q.history, q.seq}
+	n, err := enqueueScript.Run(ctx, q.rdb, keys, q.opts.Priority(item), data, historyID(item.Id()), flag).Int()
+	if err == nil && n == 0 {
+		err = pqueue.ErrDuplicate
+	}
+	return err
+}
+
+// Dequeue takes an item from the queue. If queue is empty then it
+// blocks waiting for at least one item, until the context is done.
+func (q *Queue) Dequeue(ctx context.Context) (pqueue.QueueItem, error) {
+	z, err := q.rdb.BZPopMin(ctx, 0, q.items).Result()
+	if err != nil {
+		return nil, err
+	}
+	return q.decode(z.Member)
+}
+
+// DequeueTimeout takes an item from the queue, blocking while the
+// queue is empty for at most given duration. When no item shows up
+// in time pqueue.ErrTimeout is returned.
+func (q *Queue) DequeueTimeout(ctx context.Context, d time.Duration) (pqueue.QueueItem, error) {
+	z, err := q.rdb.BZPopMin(ctx, d, q.items).Result()
+	if err == redis.Nil {
+		return nil, pqueue.ErrTimeout
+	} else if err != nil {
+		return nil, err
+	}
+	return q.decode(z.Member)
+}
+
+// TryDequeue takes an item from the queue without blocking. It
+// returns nil item when the queue is empty.
+func (q *Queue) TryDequeue(ctx context.Context) (pqueue.QueueItem, error) {
+	zs, err := q.rdb.ZPopMin(ctx, q.items).Result()
+	if err != nil || len(zs) == 0 {
+		return nil, err
+	}
+	return q.decode(zs[0].Member)
+}
+
+func (q *Queue) decode(member interface{}) (pqueue.QueueItem, error) {
+	s, ok := member.(string)
+	if !ok {
+		return nil, ErrCorrupt
+	}
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return nil, ErrCorrupt
+	}
+	return q.opts.Decode([]byte(s[i+1:]))
+}
+
+// Len returns number of enqueued items.
+func (q *Queue) Len(ctx context.Context) (int64, error) {
+	return q.rdb.ZCard(ctx, q.items).Result()
+}
+
+// IdExists tells if the item with given id has ever been enqueued.
+func (q *Queue) IdExists(ctx context.Context, id interface{}) (bool, error) {
+	return q.rdb.SIsMember(ctx, q.history, historyID(id)).Result()
+}
+
+// RemoveFromHistory forgets the id, so the item can be enqueued
+// with EnqueueUnique again.
+func (q *Queue) RemoveFromHistory(ctx context.Context, id interface{}) error {
+	return q.rdb.SRem(ctx, q.history, historyID(id)).Err()
+}
+
+// ClearHistory clears queue history so the items can be enqueued
+// with EnqueueUnique again.
+func (q *Queue) ClearHistory(ctx context.Context) error {
+	return q.rdb.Del(ctx, q.history).Err()
+}
+
+// historyID turns the id to a set member. Ids are compared by
+// their default formatting, so ids of different types that print
+// the same are the same id.
+func historyID(id interface{}) string {
+	return fmt.Sprint(id)
+}
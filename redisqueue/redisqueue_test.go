@@ -0,0 +1,94 @@
+package redisqueue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	pqueue "github.com/mileusna/gopqueue"
+	"github.com/redis/go-redis/v9"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+var options = Options{
+	Encode: func(item pqueue.QueueItem) ([]byte, error) {
+		return json.Marshal(item)
+	},
+	Decode: func(data []byte) (pqueue.QueueItem, error) {
+		t := &task{}
+		return t, json.Unmarshal(data, t)
+	},
+	Priority: func(item pqueue.QueueItem) float64 {
+		return float64(item.(*task).Priority)
+	},
+}
+
+func TestQueue(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+	producer, consumer := New(rdb, "jobs", options), New(rdb, "jobs", options)
+
+	for i, x := range []int{3, 1, 2, 1} {
+		producer.Enqueue(ctx, &task{Name: string(rune('a' + i)), Priority: x})
+	}
+	if err := consumer.EnqueueUnique(ctx, &task{Name: "a", Priority: 0}); err == nil {
+		t.Errorf("Expected shared history to deduplicate")
+	}
+	if n, _ := consumer.Len(ctx); n != 4 {
+		t.Errorf("Expected 4 items to be shared, %d given", n)
+	}
+	for _, name := range []string{"b", "d", "c"} {
+		if item, err := consumer.Dequeue(ctx); err != nil || item.(*task).Name != name {
+			t.Errorf("Expected to dequeue %s, given %v", name, err)
+		}
+	}
+	if item, err := consumer.TryDequeue(ctx); err != nil || item.(*task).Name != "a" {
+		t.Errorf("Expected to dequeue the last item, given %v", err)
+	}
+	if item, err := consumer.TryDequeue(ctx); err != nil || item != nil {
+		t.Errorf("Expected queue to be empty")
+	}
+	if _, err := consumer.DequeueTimeout(ctx, time.Second); err != pqueue.ErrTimeout {
+		t.Errorf("Expected dequeue to time out, given %v", err)
+	}
+	producer.RemoveFromHistory(ctx, "a")
+	if ok, _ := consumer.IdExists(ctx, "a"); ok {
+		t.Errorf("Expected id to be removed from history")
+	}
+	producer.ClearHistory(ctx)
+	if ok, _ := consumer.IdExists(ctx, "b"); ok {
+		t.Errorf("Expected history to be cleared")
+	}
+}
+
+func TestKeysHashTagged(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+	q := New(rdb, "jobs", options)
+	if err := q.Enqueue(ctx, &task{"one", 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"{jobs}:items", "{jobs}:history", "{jobs}:seq"} {
+		if !mr.Exists(key) {
+			t.Errorf("Expected key %s to be used", key)
+		}
+	}
+}
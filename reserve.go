@@ -0,0 +1,83 @@
+package pqueue
+
+import "errors"
+
+// ErrReservationDone is returned by Commit and Rollback when the
+// reservation has already been committed or rolled back.
+var ErrReservationDone = errors.New("Reservation already done")
+
+// Reservation is the top item of the queue reserved with Reserve.
+// The item is out of the queue, but not gone, until the reservation
+// is committed, eg. once it's been stored in a database, or rolled
+// back when that fails.
+type Reservation struct {
+	Item QueueItem
+
+	q    *Queue
+	e    *entry
+	done bool
+}
+
+// Reserve takes the top item from the queue without blocking, but
+// only reserves it: Commit removes it for good and Rollback puts it
+// back in its old place, so it's not lost when handing it over
+// fails. Unlike leased items, reserved items never go back on their
+// own. Reserved items are not counted by Len. The write-ahead log
+// keeps them until they are committed. It returns false when the
+// queue is empty.
+func (q *Queue) Reserve() (r *Reservation, ok bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	e, err := q.dequeueEntry(func() error { return errEmpty })
+	if err != nil {
+		return nil, false
+	}
+	if q.reservations == nil {
+		q.reservations = make(map[*entry]struct{})
+	}
+	q.reservations[e] = struct{}{}
+	q.logInflight(e)
+	return &Reservation{Item: e.item, q: q, e: e}, true
+}
+
+// Commit removes the reserved item from the queue for good.
+func (r *Reservation) Commit() error {
+	r.q.cond.L.Lock()
+	defer r.q.cond.L.Unlock()
+	if err := r.end(); err != nil {
+		return err
+	}
+	r.q.logRemove(r.e)
+	return nil
+}
+
+// Rollback puts the reserved item back to the queue, in its old
+// place, to be dequeued again.
+func (r *Reservation) Rollback() error {
+	r.q.cond.L.Lock()
+	defer r.q.cond.L.Unlock()
+	if err := r.end(); err != nil {
+		return err
+	}
+	r.q.push(r.e)
+	r.q.signal()
+	return nil
+}
+
+// end ends the reservation. Must be called with the queue locked.
+func (r *Reservation) end() error {
+	if r.done {
+		return ErrReservationDone
+	}
+	r.done = true
+	delete(r.q.reservations, r.e)
+	return nil
+}
+
+// Reserved returns number of items reserved and not committed or
+// rolled back yet.
+func (q *Queue) Reserved() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.reservations)
+}
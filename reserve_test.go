@@ -0,0 +1,70 @@
+package pqueue
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestReserve(t *testing.T) {
+	q := NewWithOptions(WithStableOrder())
+	for _, x := range []int{1, 2, 1} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	r, ok := q.Reserve()
+	if !ok || r.Item.(*DummyTask).priority != 1 {
+		t.Fatalf("Expected to reserve the top item")
+	}
+	if q.Len() != 2 || q.Reserved() != 1 {
+		t.Errorf("Expected reserved item not to be pending")
+	}
+	if err := r.Rollback(); err != nil {
+		t.Errorf("Expected to roll back, given %v", err)
+	}
+	if err := r.Commit(); err != ErrReservationDone {
+		t.Errorf("Expected commit after rollback to fail, given %v", err)
+	}
+	if item, _ := q.Peek(); item != r.Item {
+		t.Errorf("Expected rolled back item in its old place")
+	}
+	r, _ = q.Reserve()
+	r.Commit()
+	if q.Len() != 2 || q.Reserved() != 0 {
+		t.Errorf("Expected committed item to be gone")
+	}
+	q.Clear(false)
+	if _, ok := q.Reserve(); ok {
+		t.Errorf("Expected nothing to reserve in empty queue")
+	}
+}
+
+func TestReserveWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := New(0)
+	w, err := q.OpenWAL(path, decodeStateTask, 2)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	for _, x := range []int{1, 2, 3} {
+		q.Enqueue(&stateTask{Name: fmt.Sprint(x), Priority: x})
+	}
+	committed, _ := q.Reserve()
+	committed.Commit()
+	rolledBack, _ := q.Reserve()
+	rolledBack.Rollback()
+	q.Reserve()
+	w.Close()
+
+	r := New(0)
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	for _, name := range []string{"2", "3"} {
+		if item, ok := r.TryDequeue(); !ok || item.(*stateTask).Name != name {
+			t.Errorf("Expected %s recovered, given %v", name, item)
+		}
+	}
+	if r.Len() != 0 {
+		t.Errorf("Expected committed item gone, given %d items", r.Len())
+	}
+}
@@ -0,0 +1,82 @@
+package pqueue
+
+import "context"
+
+// Result is what a consumer resolves an item enqueued with
+// EnqueueWait with.
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// EnqueueWait puts the item to the queue and waits until a consumer
+// resolves it with Resolve, returning the consumer's result, so the
+// queue can dispatch prioritized requests and get responses back.
+// Once the context is done it stops waiting and returns the
+// context's error; the item stays in the queue. Items dropped from
+// the queue are never resolved, so ctx should have a deadline.
+func (q *Queue) EnqueueWait(ctx context.Context, item QueueItem) (Result, error) {
+	id := item.Id()
+	ch := make(chan Result, 1)
+	q.cond.L.Lock()
+	if q.results == nil {
+		q.results = make(map[interface{}][]chan Result)
+	}
+	q.results[id] = append(q.results[id], ch)
+	err := q.enqueue(item)
+	if err != nil {
+		q.forgetResult(id, ch)
+	}
+	q.cond.L.Unlock()
+	if err != nil {
+		return Result{}, err
+	}
+	select {
+	case r := <-ch:
+		return r, nil
+	case <-ctx.Done():
+		q.cond.L.Lock()
+		q.forgetResult(id, ch)
+		q.cond.L.Unlock()
+		select {
+		case r := <-ch:
+			// resolved meanwhile
+			return r, nil
+		default:
+			return Result{}, ctx.Err()
+		}
+	}
+}
+
+// Resolve hands the result of processing the item with given id to
+// the producer waiting in EnqueueWait. With several producers
+// waiting for the same id, the one which enqueued first gets it. It
+// returns false when there's nobody waiting.
+func (q *Queue) Resolve(id interface{}, value interface{}, err error) bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	waiting := q.results[id]
+	if len(waiting) == 0 {
+		return false
+	}
+	waiting[0] <- Result{Value: value, Err: err}
+	q.forgetResult(id, waiting[0])
+	return true
+}
+
+// forgetResult stops waiting for the result on given channel. Must
+// be called with the queue locked.
+func (q *Queue) forgetResult(id interface{}, ch chan Result) {
+	waiting := q.results[id]
+	for i, c := range waiting {
+		if c == ch {
+			waiting = append(waiting[:i:i], waiting[i+1:]...)
+			break
+		}
+	}
+	if len(waiting) == 0 {
+		delete(q.results, id)
+	} else {
+		q.results[id] = waiting
+	}
+}
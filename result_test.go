@@ -0,0 +1,51 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEnqueueWait(t *testing.T) {
+	q := New(0)
+	go func() {
+		for {
+			task, ok := q.Dequeue().(*stateTask)
+			if !ok {
+				return
+			}
+			if task.Priority < 0 {
+				q.Resolve(task.Id(), nil, errors.New("negative"))
+			} else {
+				q.Resolve(task.Id(), task.Priority*2, nil)
+			}
+		}
+	}()
+	defer q.Close()
+
+	ctx := context.Background()
+	r, err := q.EnqueueWait(ctx, &stateTask{"a", 21})
+	if err != nil || r.Value != 42 || r.Err != nil {
+		t.Errorf("Expected 42, given %v %v", r, err)
+	}
+	r, err = q.EnqueueWait(ctx, &stateTask{"b", -1})
+	if err != nil || r.Err == nil {
+		t.Errorf("Expected consumer's error, given %v %v", r, err)
+	}
+
+	idle := New(0)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := idle.EnqueueWait(ctx, &stateTask{"c", 1}); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, given %v", err)
+	}
+	if idle.Resolve("c", 1, nil) {
+		t.Errorf("Expected nobody waiting once given up")
+	}
+	full := New(1)
+	full.Enqueue(&stateTask{"d", 1})
+	if _, err := full.EnqueueWait(ctx, &stateTask{"e", 1}); err != ErrQueueFull {
+		t.Errorf("Expected ErrQueueFull, given %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package pqueue
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ErrRetriesExhausted is returned by Retry and Requeue when the
+// item has been retried more times than the retry policy allows.
+var ErrRetriesExhausted = errors.New("Retries exhausted")
+
+// RetryPolicy tells how many times an item may be retried and
+// how long to wait before every retry. Delay starts at Base and
+// doubles with every attempt, up to Max. With Jitter between 0
+// and 1, every delay is cut by a random part of up to Jitter of
+// it, so retries of many items don't come all at once.
+type RetryPolicy struct {
+	MaxRetries int
+	Base       time.Duration
+	Max        time.Duration
+	Jitter     float64
+}
+
+// Backoff returns delay before given attempt, counted from 1,
+// without jitter.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 || p.Base <= 0 {
+		return 0
+	}
+	d := p.Base
+	for i := 1; i < attempt && d < math.MaxInt64/2; i += 1 {
+		d *= 2
+		if p.Max > 0 && d >= p.Max {
+			return p.Max
+		}
+	}
+	if p.Max > 0 && d > p.Max {
+		d = p.Max
+	}
+	return d
+}
+
+// delay returns backoff before given attempt with jitter applied.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff(attempt)
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * math.Min(p.Jitter, 1) * float64(d))
+	}
+	return d
+}
+
+// SetRetryPolicy sets the policy used by Retry and Requeue.
+func (q *Queue) SetRetryPolicy(p RetryPolicy) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.retry = &p
+}
+
+// Retry puts failed item back to the queue once the backoff delay
+// for its next attempt passes, and returns that delay. Attempts are
+// counted per item id. When the item runs out of retries it's not
+// enqueued again, but moved to the dead-letter queue if there is
+// one, Dropped event is emitted and ErrRetriesExhausted returned.
+// Without retry policy item is enqueued right away. Item waiting for
+// its retry is delayed like with EnqueueAfter, so it's counted by Len
+// and kept by the write-ahead log and snapshots meanwhile, and the
+// error of enqueueing it, eg. to a full queue, is returned.
+func (q *Queue) Retry(item QueueItem) (delay time.Duration, err error) {
+	q.cond.L.Lock()
+	id := item.Id()
+	attempt := q.attempts[id] + 1
+	if q.exhausted(attempt) {
+		delete(q.attempts, id)
+	} else {
+		q.attempts[id] = attempt
+	}
+	return q.requeue(item, attempt)
+}
+
+// Requeue is like Retry, but the caller keeps track of attempts and
+// tells which attempt, counted from 1, is the item going for.
+func (q *Queue) Requeue(item QueueItem, attempt int) (delay time.Duration, err error) {
+	q.cond.L.Lock()
+	return q.requeue(item, attempt)
+}
+
+// requeue schedules the item for given attempt. Must be called with
+// the queue locked, and unlocks it.
+func (q *Queue) requeue(item QueueItem, attempt int) (delay time.Duration, err error) {
+	if q.exhausted(attempt) {
+		q.logEvent(LogDropped, item, ErrRetriesExhausted)
+		q.emit(Dropped, item)
+		q.cond.L.Unlock()
+		q.bury(item)
+		return 0, ErrRetriesExhausted
+	}
+	defer q.cond.L.Unlock()
+	if q.retry != nil {
+		delay = q.retry.delay(attempt)
+	}
+	if delay <= 0 {
+		return 0, q.enqueue(item)
+	}
+	return delay, q.enqueueEntry(&entry{item: item, id: item.Id(), readyAt: q.now().Add(delay)})
+}
+
+// retryEntry puts the nacked entry back to the queue for its next
+// attempt, in its old place once the backoff passes. It returns false
+// when the entry runs out of retries or is dead and has to be buried
+// instead. Must be called with the queue locked.
+func (q *Queue) retryEntry(e *entry) bool {
+	if q.retry == nil {
+		return q.redeliver(e)
+	}
+	attempt := q.attempts[e.id] + 1
+	if q.exhausted(attempt) {
+		delete(q.attempts, e.id)
+		q.logRemove(e)
+		q.logEvent(LogDropped, e.item, ErrRetriesExhausted)
+		q.emit(Dropped, e.item)
+		return false
+	}
+	q.attempts[e.id] = attempt
+	delay := q.retry.delay(attempt)
+	if delay > 0 {
+		e.readyAt = q.now().Add(delay)
+	}
+	ok := q.redeliver(e)
+	if ok && delay > 0 {
+		// the log keeps the entry, but not when it's ready
+		q.logInflight(e)
+	}
+	return ok
+}
+
+// exhausted tells if the retry policy doesn't allow given attempt.
+func (q *Queue) exhausted(attempt int) bool {
+	return q.retry != nil && attempt > q.retry.MaxRetries
+}
+
+// Attempts returns how many times the item with given id has been
+// retried, so handlers can tell the first delivery from retries.
+func (q *Queue) Attempts(id interface{}) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.attempts[id]
+}
+
+// ResetAttempts forgets retry attempts of the item with given id,
+// eg. once it's been processed successfully.
+func (q *Queue) ResetAttempts(id interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	delete(q.attempts, id)
+}
@@ -0,0 +1,105 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 10, Base: time.Second, Max: 10 * time.Second}
+	for attempt, d := range []time.Duration{0, 1, 2, 4, 8, 10, 10} {
+		if p.Backoff(attempt) != d*time.Second {
+			t.Errorf("Expected backoff %d to be %v, given %v", attempt, d*time.Second, p.Backoff(attempt))
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	q := New(0)
+	q.SetRetryPolicy(RetryPolicy{MaxRetries: 5, Base: 10 * time.Millisecond, Max: 20 * time.Millisecond})
+	task := NewDummyTask(1)
+	for _, d := range []time.Duration{10, 20, 20} {
+		delay, err := q.Retry(task)
+		if err != nil || delay != d*time.Millisecond {
+			t.Errorf("Expected retry delay to be %v, given %v", d*time.Millisecond, delay)
+		}
+		if q.Dequeue() != task {
+			t.Errorf("Expected retried task to be enqueued again")
+		}
+	}
+	if q.Attempts(task.Id()) != 3 {
+		t.Errorf("Expected 3 attempts, given %d", q.Attempts(task.Id()))
+	}
+	q.ResetAttempts(task.Id())
+	if q.Attempts(task.Id()) != 0 {
+		t.Errorf("Expected attempts to be reset")
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	q := New(0)
+	q.SetRetryPolicy(RetryPolicy{MaxRetries: 1})
+	events := q.Events(10)
+	task := NewDummyTask(1)
+	if _, err := q.Retry(task); err != nil {
+		t.Errorf("Expected first retry to succeed, given %v", err)
+	}
+	expectEvent(t, events, Enqueued, task)
+	if _, err := q.Retry(task); err != ErrRetriesExhausted {
+		t.Errorf("Expected retries to be exhausted, given %v", err)
+	}
+	expectEvent(t, events, Dropped, task)
+	if q.Len() != 1 {
+		t.Errorf("Expected exhausted task not to be enqueued")
+	}
+}
+
+func TestRetryDelayed(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithClock(clock), WithLimit(1))
+	q.SetRetryPolicy(RetryPolicy{MaxRetries: 5, Base: time.Second})
+	task := NewDummyTask(1)
+	if _, err := q.Retry(task); err != nil {
+		t.Errorf("Expected retry to be scheduled, given %v", err)
+	}
+	if q.Len() != 1 {
+		t.Errorf("Expected item waiting for retry counted, given %d", q.Len())
+	}
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected item not dequeued before its retry delay")
+	}
+	if _, err := q.Retry(NewDummyTask(2)); err != ErrQueueFull {
+		t.Errorf("Expected retry to the full queue to fail, given %v", err)
+	}
+	clock.Advance(time.Second)
+	if item, ok := q.TryDequeue(); !ok || item != task {
+		t.Errorf("Expected retried item once the delay passes, given %v", item)
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 10, Base: time.Second, Max: time.Minute, Jitter: 0.5}
+	for i := 0; i < 100; i += 1 {
+		if d := p.delay(3); d < 2*time.Second || d > 4*time.Second {
+			t.Fatalf("Expected jittered delay between 2s and 4s, given %v", d)
+		}
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	q := NewWithOptions(WithRetryPolicy(2, 10*time.Millisecond, time.Second))
+	task := NewDummyTask(1)
+	start := time.Now()
+	if delay, err := q.Requeue(task, 2); err != nil || delay != 20*time.Millisecond {
+		t.Errorf("Expected requeue delay to be 20ms, given %v", delay)
+	}
+	if q.Dequeue() != task || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("Expected task to be requeued after the delay")
+	}
+	if _, err := q.Requeue(task, 3); err != ErrRetriesExhausted {
+		t.Errorf("Expected retries to be exhausted, given %v", err)
+	}
+	if q.Attempts(task.Id()) != 0 {
+		t.Errorf("Expected requeue not to count attempts")
+	}
+}
@@ -0,0 +1,165 @@
+package pqueue
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCronSpec is returned by Cron for malformed cron expressions.
+var ErrCronSpec = errors.New("Invalid cron expression")
+
+// Every enqueues the item made by factory each given interval, with
+// EnqueueUnique, until stop is called or the queue is closed. Items
+// whose id has been seen are not enqueued again, so factory decides
+// if every run is a new job, eg. by putting the time in the id, or
+// the same job enqueued once, or once per WithDedupTTL window. When
+// factory returns nil, nothing is enqueued for that run.
+func (q *Queue) Every(interval time.Duration, factory func() QueueItem) (stop func()) {
+	return q.schedule(func(now time.Time) time.Time {
+		return now.Add(interval)
+	}, factory)
+}
+
+// Cron enqueues the item made by factory at times given by the cron
+// expression, the same way Every does. The expression has five
+// fields, minute, hour, day of month, month and day of week, where
+// the week starts with 0 for Sunday. Every field is either * or a
+// comma separated list of numbers and ranges like 1-5, each of them
+// optionally followed by a step like */15 or 0-30/10. When both day
+// fields are restricted, either of them matching is enough, as with
+// the cron daemon. Times are in the local time zone.
+func (q *Queue) Cron(spec string, factory func() QueueItem) (stop func(), err error) {
+	c, err := parseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	return q.schedule(c.next, factory), nil
+}
+
+// schedule runs factory at times given by next until stopped.
+func (q *Queue) schedule(next func(now time.Time) time.Time, factory func() QueueItem) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			at := next(q.now())
+			if at.IsZero() {
+				return
+			}
+			timer := q.clock.NewTimer(at.Sub(q.now()))
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+			q.mu.RLock()
+			closed := q.closed
+			q.mu.RUnlock()
+			if closed {
+				return
+			}
+			if item := factory(); item != nil {
+				if err := q.EnqueueUnique(item); err == ErrClosed {
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// cron is a parsed cron expression, with a bit set for every
+// matching value of every field.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	anyDom, anyDow                bool
+}
+
+func parseCron(spec string) (c cron, err error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return c, ErrCronSpec
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := [5]*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, f := range fields {
+		if *sets[i], err = parseCronField(f, bounds[i][0], bounds[i][1]); err != nil {
+			return
+		}
+	}
+	// 7 is Sunday too
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.anyDom, c.anyDow = fields[2] == "*", fields[4] == "*"
+	return
+}
+
+func parseCronField(f string, min, max int) (set uint64, err error) {
+	for _, part := range strings.Split(f, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, ErrCronSpec
+			}
+			part = part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, ErrCronSpec
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, ErrCronSpec
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, ErrCronSpec
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+// next returns the first matching minute after now, or zero time
+// when there is none in the next five years, eg. for February 30.
+func (c cron) next(now time.Time) time.Time {
+	t := now.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if !c.anyDom && !c.anyDow {
+		return dom || dow
+	}
+	return dom && dow
+}
@@ -0,0 +1,56 @@
+package pqueue
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEvery(t *testing.T) {
+	q := New(0)
+	n := 0
+	stop := q.Every(10*time.Millisecond, func() QueueItem {
+		n += 1
+		return &stateTask{Name: fmt.Sprint("job", n%2)}
+	})
+	time.Sleep(55 * time.Millisecond)
+	stop()
+	stop()
+	if q.Len() != 2 {
+		t.Errorf("Expected 2 unique jobs to be enqueued, given %d", q.Len())
+	}
+}
+
+func TestCron(t *testing.T) {
+	tests := []struct {
+		spec, now, next string
+	}{
+		{"*/15 * * * *", "2024-03-10 10:07", "2024-03-10 10:15"},
+		{"0 9-17/4 * * 1-5", "2024-03-08 17:30", "2024-03-11 09:00"},
+		{"30 2 1 * *", "2024-01-31 12:00", "2024-02-01 02:30"},
+		{"0 0 13 * 5", "2024-03-10 00:00", "2024-03-13 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"0 0 * * 7", "2024-03-10 00:00", "2024-03-17 00:00"},
+		{"0 0 30 2 *", "2024-03-01 00:00", ""},
+	}
+	for _, test := range tests {
+		c, err := parseCron(test.spec)
+		if err != nil {
+			t.Errorf("Expected %q to parse, given %v", test.spec, err)
+			continue
+		}
+		now, _ := time.ParseInLocation("2006-01-02 15:04", test.now, time.Local)
+		next := ""
+		if at := c.next(now); !at.IsZero() {
+			next = at.Format("2006-01-02 15:04")
+		}
+		if next != test.next {
+			t.Errorf("Expected %q after %s to be %q, given %q", test.spec, test.now, test.next, next)
+		}
+	}
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := New(0).Cron(spec, nil); err != ErrCronSpec {
+			t.Errorf("Expected %q to be refused, given %v", spec, err)
+		}
+	}
+}
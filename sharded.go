@@ -0,0 +1,154 @@
+package pqueue
+
+import (
+	"context"
+	"hash/maphash"
+	"sort"
+	"sync/atomic"
+)
+
+// ShardedQueue spreads items across several queues, picked by the
+// hash of the item id, so producers and consumers of different
+// shards don't wait for each other's lock. Items with the same id
+// always land in the same shard, so EnqueueUnique works as usual.
+// Dequeue takes the best of the shards' top items, which is the
+// globally best item as long as nothing else changes the queue at
+// the same time.
+type ShardedQueue struct {
+	shards []*Queue
+	seed   maphash.Seed
+	notify *notifier
+	stolen atomic.Uint64
+}
+
+// NewSharded creates a queue of given number of shards, each one
+// created by NewWithOptions with given options. The limit applies
+// to every shard separately. Consumers are woken up by anything
+// which makes an item ready in a shard, so shards may be used
+// directly too, see Shard.
+func NewSharded(shards int, opts ...Option) *ShardedQueue {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &ShardedQueue{shards: make([]*Queue, shards), seed: maphash.MakeSeed(), notify: newNotifier()}
+	for i := range s.shards {
+		s.shards[i] = NewWithOptions(opts...)
+		s.shards[i].watchers = append(s.shards[i].watchers, s.notify)
+	}
+	return s
+}
+
+// Shard returns the shard given item id belongs to.
+func (s *ShardedQueue) Shard(id interface{}) *Queue {
+	return s.shards[maphash.Comparable(s.seed, id)%uint64(len(s.shards))]
+}
+
+// Enqueue puts given item to its shard.
+func (s *ShardedQueue) Enqueue(item QueueItem) error {
+	return s.Shard(item.Id()).Enqueue(item)
+}
+
+// EnqueueUnique puts item to its shard only if it hasn't already
+// been there.
+func (s *ShardedQueue) EnqueueUnique(item QueueItem) error {
+	return s.Shard(item.Id()).EnqueueUnique(item)
+}
+
+// Dequeue takes the best item of all the shards, waiting for one
+// while they are all empty. Once the queue is closed and drained it
+// returns nil.
+func (s *ShardedQueue) Dequeue() QueueItem {
+	item, _ := s.DequeueContext(context.Background())
+	return item
+}
+
+// DequeueContext is Dequeue giving up when the context is done,
+// with the context's error. Once the queue is closed and drained it
+// returns ErrClosed.
+func (s *ShardedQueue) DequeueContext(ctx context.Context) (QueueItem, error) {
+	return s.notify.wait(ctx, s.TryDequeue, func() bool {
+		return s.notify.isClosed() && s.IsEmpty()
+	})
+}
+
+// TryDequeue takes the best item of all the shards without
+// blocking. It returns false when all the shards are empty.
+func (s *ShardedQueue) TryDequeue() (QueueItem, bool) {
+	return tryBest(s.shards)
+}
+
+// Shards returns number of shards.
+func (s *ShardedQueue) Shards() int {
+	return len(s.shards)
+}
+
+// DequeueShard takes the best item of the shard at given index, for
+// consumers which each serve their own shard. When the shard is empty
+// the consumer steals the best item of the most loaded shard instead
+// of waiting, so skewed ids don't leave some consumers idle while
+// others fall behind. It waits only while no shard has an item ready,
+// until the context is done. Once the queue is closed and drained it
+// returns ErrClosed.
+func (s *ShardedQueue) DequeueShard(ctx context.Context, shard int) (QueueItem, error) {
+	return s.notify.wait(ctx, func() (QueueItem, bool) {
+		return s.TryDequeueShard(shard)
+	}, func() bool {
+		return s.notify.isClosed() && s.IsEmpty()
+	})
+}
+
+// TryDequeueShard is DequeueShard without blocking. It returns false
+// when none of the shards has an item ready, eg. because they are
+// empty, paused or out of their dequeue rate.
+func (s *ShardedQueue) TryDequeueShard(shard int) (QueueItem, bool) {
+	if item, ok := s.shards[shard].TryDequeue(); ok {
+		return item, true
+	}
+	// the most loaded shard may have no ready items, or may be
+	// drained meanwhile, so the others are tried next
+	victims := make([]*Queue, 0, len(s.shards))
+	lens := make(map[*Queue]int, len(s.shards))
+	for i, q := range s.shards {
+		if n := q.Len(); i != shard && n > 0 {
+			victims = append(victims, q)
+			lens[q] = n
+		}
+	}
+	sort.SliceStable(victims, func(i, j int) bool {
+		return lens[victims[i]] > lens[victims[j]]
+	})
+	for _, q := range victims {
+		if item, ok := q.TryDequeue(); ok {
+			s.stolen.Add(1)
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+// Stolen returns number of items DequeueShard has taken from other
+// shards than the consumer's own.
+func (s *ShardedQueue) Stolen() uint64 {
+	return s.stolen.Load()
+}
+
+// Len returns number of items in all the shards.
+func (s *ShardedQueue) Len() (n int) {
+	for _, q := range s.shards {
+		n += q.Len()
+	}
+	return
+}
+
+// IsEmpty returns true if all the shards are empty.
+func (s *ShardedQueue) IsEmpty() bool {
+	return s.Len() == 0
+}
+
+// Close closes all the shards.
+func (s *ShardedQueue) Close() {
+	for _, q := range s.shards {
+		q.Close()
+	}
+	s.notify.close()
+}
@@ -0,0 +1,195 @@
+package pqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedOrder(t *testing.T) {
+	s := NewSharded(4)
+	for _, x := range []int{5, 3, 8, 1, 7, 2, 6, 4} {
+		s.Enqueue(&stateTask{Name: string(rune('a' + x)), Priority: x})
+	}
+	if s.Len() != 8 {
+		t.Errorf("Expected 8 items, given %d", s.Len())
+	}
+	for x := 1; x <= 8; x++ {
+		if task := s.Dequeue().(*stateTask); task.Priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.Priority)
+		}
+	}
+	if _, ok := s.TryDequeue(); ok {
+		t.Errorf("Expected not to dequeue from empty queue")
+	}
+}
+
+func TestShardedOptionsOrder(t *testing.T) {
+	byName := func(a, b QueueItem) bool {
+		return a.(*stateTask).Name < b.(*stateTask).Name
+	}
+	for name, tc := range map[string]struct {
+		opts []Option
+		want []int
+	}{
+		"descending": {[]Option{WithOrder(Descending)}, []int{8, 7, 6, 5, 4, 3, 2, 1}},
+		"less":       {[]Option{WithLess(byName)}, []int{8, 7, 6, 5, 4, 3, 2, 1}},
+	} {
+		s := NewSharded(4, tc.opts...)
+		for _, x := range []int{5, 3, 8, 1, 7, 2, 6, 4} {
+			s.Enqueue(&stateTask{Name: string(rune('a' + 8 - x)), Priority: x})
+		}
+		for _, x := range tc.want {
+			if task := s.Dequeue().(*stateTask); task.Priority != x {
+				t.Errorf("Expected %s order to give priority %d, given %d", name, x, task.Priority)
+			}
+		}
+	}
+}
+
+func TestShardedUnique(t *testing.T) {
+	s := NewSharded(4)
+	if err := s.EnqueueUnique(&stateTask{Name: "a"}); err != nil {
+		t.Errorf("Expected item to be added")
+	}
+	if err := s.EnqueueUnique(&stateTask{Name: "a"}); err == nil {
+		t.Errorf("Expected duplicate not to be added")
+	}
+}
+
+func TestShardedConcurrent(t *testing.T) {
+	s := NewSharded(8)
+	var wg sync.WaitGroup
+	seen := make(chan string, 1000)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, err := s.DequeueContext(context.Background())
+				if err != nil {
+					return
+				}
+				seen <- item.(*stateTask).Name
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		s.Enqueue(&stateTask{Name: string(rune(i + 'a')), Priority: i % 10})
+	}
+	names := map[string]bool{}
+	for len(names) < 1000 {
+		name := <-seen
+		if names[name] {
+			t.Fatalf("Expected %s to be dequeued once", name)
+		}
+		names[name] = true
+	}
+	s.Close()
+	wg.Wait()
+	if len(seen) != 0 {
+		t.Errorf("Expected all the items to be dequeued once")
+	}
+}
+
+func TestShardedCancel(t *testing.T) {
+	s := NewSharded(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.DequeueContext(ctx); err != context.Canceled {
+		t.Errorf("Expected context error, given %v", err)
+	}
+}
+
+func TestShardedStealing(t *testing.T) {
+	s := NewSharded(4)
+	for x := 1; x <= 6; x++ {
+		s.Enqueue(&stateTask{Name: "same", Priority: x})
+	}
+	home := -1
+	for i := 0; i < s.Shards(); i++ {
+		if s.shards[i].Len() > 0 {
+			home = i
+		}
+	}
+	idle := (home + 1) % s.Shards()
+	for x := 1; x <= 3; x++ {
+		task, err := s.DequeueShard(context.Background(), idle)
+		if err != nil || task.(*stateTask).Priority != x {
+			t.Errorf("Expected idle consumer to steal priority %d, given %v", x, task)
+		}
+	}
+	if task, _ := s.TryDequeueShard(home); task.(*stateTask).Priority != 4 {
+		t.Errorf("Expected home consumer to take its own item")
+	}
+	if s.Stolen() != 3 {
+		t.Errorf("Expected 3 stolen items, given %d", s.Stolen())
+	}
+	s.Close()
+	s.TryDequeue()
+	s.TryDequeue()
+	if _, err := s.DequeueShard(context.Background(), idle); err != ErrClosed {
+		t.Errorf("Expected closed queue, given %v", err)
+	}
+}
+
+func TestShardedStealingNotReady(t *testing.T) {
+	s := NewSharded(2, WithDequeueRate(1, 1))
+	defer s.Close()
+	for x := 1; x <= 3; x++ {
+		s.Enqueue(&stateTask{Name: "same", Priority: x})
+	}
+	home := 0
+	if s.shards[1].Len() > 0 {
+		home = 1
+	}
+	idle := 1 - home
+	if task, ok := s.TryDequeueShard(idle); !ok || task.(*stateTask).Priority != 1 {
+		t.Errorf("Expected to steal the first item, given %v", task)
+	}
+	done := make(chan bool)
+	go func() {
+		_, ok := s.TryDequeueShard(idle)
+		s.shards[home].Pause()
+		_, paused := s.TryDequeueShard(idle)
+		done <- ok || paused
+	}()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Errorf("Expected nothing to steal from rate limited or paused shard")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected TryDequeueShard to return at once")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.DequeueShard(ctx, idle); err != context.DeadlineExceeded {
+		t.Errorf("Expected to wait for a ready item, given %v", err)
+	}
+	if s.Stolen() != 1 {
+		t.Errorf("Expected 1 stolen item, given %d", s.Stolen())
+	}
+}
+
+func TestShardedWakeUp(t *testing.T) {
+	s := NewSharded(2)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item := NewDummyTask(1)
+	if err := s.Shard(item.Id()).EnqueueAfter(item, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.DequeueContext(ctx); err != nil || got != item {
+		t.Errorf("Expected the delayed item once it's ready, given %v %v", got, err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.Shard(item.Id()).Enqueue(item)
+	}()
+	if got, err := s.DequeueShard(ctx, 0); err != nil || got != item {
+		t.Errorf("Expected the item enqueued to the shard, given %v %v", got, err)
+	}
+}
@@ -0,0 +1,77 @@
+package pqueue
+
+// shrinkMin is the capacity below which slices and maps are not
+// shrunk, there's little memory to give back.
+const shrinkMin = 256
+
+// Shrink gives back the memory kept by the queue after a burst of
+// items, reallocating the internal slices and maps, and the history
+// map, to fit what's in them now. It also drops the room reserved
+// with Preheat. The queue shrinks on its own as it drains, once
+// internal slices and maps are at most a quarter full, so Shrink is
+// needed only to get the memory back right away.
+func (q *Queue) Shrink() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.reserved = 0
+	q.items.entries = resized(q.items.entries, len(q.items.entries))
+	q.delayed = resized(q.delayed, len(q.delayed))
+	q.active = copyMap(q.active)
+	q.activePeak = len(q.active)
+	q.producers = copyMap(q.producers)
+	q.attempts = copyMap(q.attempts)
+	if s, ok := q.history.(interface{ Shrink() }); ok {
+		s.Shrink()
+	}
+}
+
+// shrinkEntries shrinks the heap slice once it's at most a quarter
+// full, to twice its length, but not below the room reserved with
+// Preheat. Must be called with the queue locked.
+func (q *Queue) shrinkEntries() {
+	entries := q.items.entries
+	if c := cap(entries); c >= shrinkMin && c > q.reserved && len(entries)*4 <= c {
+		q.items.entries = resized(entries, max(2*len(entries), q.reserved))
+	}
+}
+
+// shrinkActive rebuilds the map of pending ids once it's at most a
+// quarter of its peak size, as maps never give memory back on their
+// own. Must be called with the queue locked.
+func (q *Queue) shrinkActive() {
+	if q.activePeak >= shrinkMin && q.activePeak > q.reserved && len(q.active)*4 <= q.activePeak {
+		q.active = copyMap(q.active)
+		q.activePeak = len(q.active)
+	}
+}
+
+// resized returns copy of the entries with given capacity.
+func resized[S ~[]*entry](entries S, capacity int) S {
+	s := make(S, len(entries), capacity)
+	copy(s, entries)
+	return s
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// Shrink rebuilds the id map to fit the ids in it now.
+func (h *history) Shrink() {
+	h.ids = copyMap(h.ids)
+	h.peak = len(h.ids)
+	h.reserved = 0
+}
+
+// shrink rebuilds the id map once it's at most a quarter of its
+// peak size.
+func (h *history) shrink() {
+	if h.peak >= shrinkMin && h.peak > h.reserved && len(h.ids)*4 <= h.peak {
+		h.ids = copyMap(h.ids)
+		h.peak = len(h.ids)
+	}
+}
@@ -0,0 +1,42 @@
+package pqueue
+
+import "testing"
+
+func TestShrink(t *testing.T) {
+	q := New(0)
+	for i := 0; i < 4096; i++ {
+		q.EnqueueUnique(&stateTask{Name: string(rune(i)), Priority: i})
+	}
+	for i := 0; i < 4000; i++ {
+		q.Dequeue()
+	}
+	if c := cap(q.items.entries); c > 4*96 {
+		t.Errorf("Expected heap slice to shrink as the queue drains, capacity %d", c)
+	}
+	for i := 0; i < 4000; i++ {
+		q.RemoveFromHistory(string(rune(i)))
+	}
+	if h := q.history.(*history); h.peak > 4*96 || len(h.ids) != 96 {
+		t.Errorf("Expected history map to be rebuilt, peak %d", h.peak)
+	}
+	for i := 0; i < 96; i++ {
+		if task := q.Dequeue().(*stateTask); task.Priority != 4000+i {
+			t.Errorf("Expected to dequeue %d, given %d", 4000+i, task.Priority)
+		}
+	}
+
+	q.Preheat(1000)
+	for i := 0; i < 500; i++ {
+		q.Enqueue(NewDummyTask(i))
+	}
+	for i := 0; i < 500; i++ {
+		q.Dequeue()
+	}
+	if cap(q.items.entries) < 1000 {
+		t.Errorf("Expected room reserved with Preheat to stay")
+	}
+	q.Shrink()
+	if cap(q.items.entries) != 0 || q.reserved != 0 {
+		t.Errorf("Expected Shrink to give all the room back")
+	}
+}
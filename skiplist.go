@@ -0,0 +1,252 @@
+package pqueue
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+)
+
+// skipMaxLevel bounds the height of skip list towers, enough for
+// billions of items.
+const skipMaxLevel = 24
+
+// SkipQueue is a priority queue kept in a skip list ordered by
+// integer priorities, for schedulers which need more than the top
+// item: PopRange takes all the items of priorities in a range at
+// once, eg. all the items due before some time, and Range walks
+// pending items in priority order. Both Enqueue and Dequeue take
+// logarithmic time. Items of lower priority are dequeued first, and
+// items of the same priority in the order they have been enqueued.
+// Item's Less is not used at all.
+type SkipQueue struct {
+	Limit int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	priority func(QueueItem) int64
+	head     skipNode
+	level    int
+	n        int
+	seq      uint64
+	history  DedupStore
+	closed   bool
+}
+
+type skipNode struct {
+	item     QueueItem
+	priority int64
+	seq      uint64
+	next     []*skipNode
+}
+
+// before tells if the node goes before given priority and sequence.
+func (n *skipNode) before(priority int64, seq uint64) bool {
+	return n.priority < priority || n.priority == priority && n.seq < seq
+}
+
+// NewSkip creates a skip list queue, taking a limit as a parameter.
+// If 0 given, then queue will be unlimited. Priority of every item
+// is given by priority.
+func NewSkip(max int, priority func(QueueItem) int64) *SkipQueue {
+	s := &SkipQueue{
+		Limit:    max,
+		priority: priority,
+		head:     skipNode{next: make([]*skipNode, skipMaxLevel)},
+		level:    1,
+		history:  newHistory(),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Enqueue puts given item to the queue.
+func (s *SkipQueue) Enqueue(item QueueItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enqueue(item)
+}
+
+// EnqueueUnique puts item in queue only if it hasn't already been
+// in queue, see Queue.EnqueueUnique.
+func (s *SkipQueue) EnqueueUnique(item QueueItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.history.Seen(item.Id()) {
+		return ErrDuplicate
+	}
+	return s.enqueue(item)
+}
+
+func (s *SkipQueue) enqueue(item QueueItem) error {
+	if s.closed {
+		return ErrClosed
+	}
+	if s.Limit > 0 && s.n >= s.Limit {
+		return ErrQueueFull
+	}
+	s.history.Add(item.Id())
+	s.seq++
+	node := &skipNode{item: item, priority: s.priority(item), seq: s.seq}
+	level := 1
+	for level < skipMaxLevel && rand.Intn(4) == 0 {
+		level++
+	}
+	node.next = make([]*skipNode, level)
+	if level > s.level {
+		s.level = level
+	}
+	x := &s.head
+	for l := s.level - 1; l >= 0; l-- {
+		for x.next[l] != nil && x.next[l].before(node.priority, node.seq) {
+			x = x.next[l]
+		}
+		if l < level {
+			node.next[l] = x.next[l]
+			x.next[l] = node
+		}
+	}
+	s.n++
+	s.cond.Signal()
+	return nil
+}
+
+// Dequeue takes an item from the queue, blocking while it's empty.
+// Once the queue is closed and drained it returns nil.
+func (s *SkipQueue) Dequeue() QueueItem {
+	item, _ := s.DequeueContext(context.Background())
+	return item
+}
+
+// DequeueContext takes an item from the queue, blocking while the
+// queue is empty until the context is done. Once the queue is
+// closed and drained it returns ErrClosed.
+func (s *SkipQueue) DequeueContext(ctx context.Context) (QueueItem, error) {
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cond.Broadcast()
+	})
+	defer stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.n == 0 {
+		if s.closed {
+			return nil, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.cond.Wait()
+	}
+	return s.pop(), nil
+}
+
+// TryDequeue takes an item from the queue without blocking. It
+// returns false when the queue is empty.
+func (s *SkipQueue) TryDequeue() (QueueItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.n == 0 {
+		return nil, false
+	}
+	return s.pop(), true
+}
+
+// Peek returns the item that would be dequeued next, without
+// taking it from the queue.
+func (s *SkipQueue) Peek() (QueueItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.n == 0 {
+		return nil, false
+	}
+	return s.head.next[0].item, true
+}
+
+func (s *SkipQueue) pop() QueueItem {
+	node := s.head.next[0]
+	for l := range node.next {
+		s.head.next[l] = node.next[l]
+	}
+	s.n--
+	s.shrink()
+	return node.item
+}
+
+// shrink lowers the list level once its top levels are empty.
+func (s *SkipQueue) shrink() {
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+}
+
+// PopRange takes all the items of priority from min to max, both
+// included, from the queue at once, and returns them in priority
+// order. It doesn't block, so it returns nil when there are none.
+func (s *SkipQueue) PopRange(min, max int64) (items []QueueItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if min > max {
+		return nil
+	}
+	// find the last node before the range on every level
+	var update [skipMaxLevel]*skipNode
+	x := &s.head
+	for l := s.level - 1; l >= 0; l-- {
+		for x.next[l] != nil && x.next[l].priority < min {
+			x = x.next[l]
+		}
+		update[l] = x
+	}
+	for node := x.next[0]; node != nil && node.priority <= max; node = node.next[0] {
+		items = append(items, node.item)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	// link every level past the range
+	for l := 0; l < s.level; l++ {
+		next := update[l].next[l]
+		for next != nil && next.priority <= max {
+			next = next.next[l]
+		}
+		update[l].next[l] = next
+	}
+	s.n -= len(items)
+	s.shrink()
+	return
+}
+
+// Range calls fn for the pending items of priority from min to max,
+// both included, in priority order, until fn returns false. The
+// queue is locked meanwhile, so fn must not call it.
+func (s *SkipQueue) Range(min, max int64, fn func(item QueueItem) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	x := &s.head
+	for l := s.level - 1; l >= 0; l-- {
+		for x.next[l] != nil && x.next[l].priority < min {
+			x = x.next[l]
+		}
+	}
+	for node := x.next[0]; node != nil && node.priority <= max; node = node.next[0] {
+		if !fn(node.item) {
+			return
+		}
+	}
+}
+
+// Len returns number of enqueued items.
+func (s *SkipQueue) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
+
+// Close closes the queue, see Queue.Close.
+func (s *SkipQueue) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+}
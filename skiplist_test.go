@@ -0,0 +1,70 @@
+package pqueue
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func taskPriority(item QueueItem) int64 {
+	return int64(item.(*stateTask).Priority)
+}
+
+func TestSkipQueue(t *testing.T) {
+	s := NewSkip(0, taskPriority)
+	for i, x := range []int{70, 3, 70, 0, 99, 3} {
+		s.Enqueue(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	if err := s.EnqueueUnique(&stateTask{Name: "a", Priority: 1}); err == nil {
+		t.Errorf("Expected duplicate not to be added")
+	}
+	if item, _ := s.Peek(); item.(*stateTask).Name != "d" {
+		t.Errorf("Expected to peek the lowest priority")
+	}
+	var names []string
+	s.Range(3, 70, func(item QueueItem) bool {
+		names = append(names, item.(*stateTask).Name)
+		return len(names) < 3
+	})
+	if len(names) != 3 || names[0] != "b" || names[1] != "f" || names[2] != "a" {
+		t.Errorf("Expected to walk items in priority order, given %v", names)
+	}
+	for _, name := range []string{"d", "b", "f", "a", "c", "e"} {
+		if task := s.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+	if _, ok := s.TryDequeue(); ok || s.Len() != 0 {
+		t.Errorf("Expected queue to be empty")
+	}
+}
+
+func TestSkipPopRange(t *testing.T) {
+	s := NewSkip(0, taskPriority)
+	var want []int
+	for i := 0; i < 1000; i++ {
+		x := rand.Intn(100)
+		s.Enqueue(&stateTask{Name: string(rune(i)), Priority: x})
+		want = append(want, x)
+	}
+	sort.Ints(want)
+	items := s.PopRange(20, 29)
+	lo, hi := sort.SearchInts(want, 20), sort.SearchInts(want, 30)
+	if len(items) != hi-lo || s.Len() != 1000-len(items) {
+		t.Errorf("Expected %d items in range, given %d", hi-lo, len(items))
+	}
+	for i, item := range items {
+		if p := item.(*stateTask).Priority; p != want[lo+i] {
+			t.Errorf("Expected item of priority %d, given %d", want[lo+i], p)
+		}
+	}
+	if s.PopRange(20, 29) != nil {
+		t.Errorf("Expected no items left in range")
+	}
+	want = append(want[:lo], want[hi:]...)
+	for _, x := range want {
+		if task := s.Dequeue().(*stateTask); task.Priority != x {
+			t.Errorf("Expected to dequeue %d, given %d", x, task.Priority)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package pqueue
+
+import (
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// ErrNotMarshaler is returned by Snapshot when an enqueued item
+// doesn't implement encoding.BinaryMarshaler.
+var ErrNotMarshaler = errors.New("Item is not a BinaryMarshaler")
+
+// snapshot is what Snapshot writes, encoded with encoding/gob.
+type snapshot struct {
+	Limit   int
+	Seq     uint64
+	History []interface{}
+	Items   []snapshotItem
+}
+
+type snapshotItem struct {
+	Data       []byte
+	Producer   string
+	Seq        uint64
+	ReadyAt    time.Time
+	Deliveries int
+}
+
+// Snapshot writes pending items and the history to w, so they can
+// be brought back with Restore, eg. after process restart. Items
+// must implement encoding.BinaryMarshaler. History ids are encoded
+// with encoding/gob, so ids of other than basic types have to be
+// registered with gob.Register. With WithCompression the snapshot is
+// compressed, and with WithEncryption encrypted.
+func (q *Queue) Snapshot(w io.Writer) (err error) {
+	state := q.ExportState()
+	snap := snapshot{Limit: state.Limit, Seq: state.Seq, History: state.History}
+	snap.Items = make([]snapshotItem, len(state.Items))
+	for i, it := range state.Items {
+		m, ok := it.Item.(encoding.BinaryMarshaler)
+		if !ok {
+			return ErrNotMarshaler
+		}
+		data, err := m.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		snap.Items[i] = snapshotItem{Data: data, Producer: it.Producer, Seq: it.Seq, ReadyAt: it.ReadyAt, Deliveries: it.Deliveries}
+	}
+	aead, err := q.aead()
+	if err != nil {
+		return
+	}
+	if aead != nil {
+		var ew *encWriter
+		if ew, err = newEncWriter(w, aead); err != nil {
+			return
+		}
+		defer func() {
+			if cerr := ew.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = ew
+	}
+	if q.compressor != nil {
+		var cw io.WriteCloser
+		if cw, err = q.compressor.NewWriter(w); err != nil {
+			return
+		}
+		defer func() {
+			if cerr := cw.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = cw
+	}
+	return gob.NewEncoder(w).Encode(&snap)
+}
+
+// Restore reads the snapshot written by Snapshot from r and puts
+// its items and history to the queue, taking the snapshot's limit
+// too. Items are decoded with given decode function. Restored items
+// keep their relative arrival order, so stable order survives.
+func (q *Queue) Restore(r io.Reader, decode func([]byte) (QueueItem, error)) error {
+	aead, err := q.aead()
+	if err != nil {
+		return err
+	}
+	if aead != nil {
+		if r, err = newEncReader(r, aead); err != nil {
+			return err
+		}
+	}
+	if q.compressor != nil {
+		cr, err := q.compressor.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer cr.Close()
+		r = cr
+	}
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+	entries := make([]*entry, len(snap.Items))
+	for i, it := range snap.Items {
+		item, err := decode(it.Data)
+		if err != nil {
+			return err
+		}
+		entries[i] = &entry{item: item, id: item.Id(), producer: it.Producer, seq: it.Seq, readyAt: it.ReadyAt, deliveries: it.Deliveries}
+	}
+	return q.restore(snap.Limit, snap.Seq, snap.History, entries)
+}
+
+// restore puts decoded entries and history to the queue, in order of
+// their arrival.
+func (q *Queue) restore(limit int, seq uint64, history []interface{}, entries []*entry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.Limit = limit
+	if seq > q.seq {
+		q.seq = seq
+	}
+	for _, id := range history {
+		q.history.Add(id)
+	}
+	for _, e := range entries {
+		if q.rank != nil {
+			e.score = q.rank(e.item, q.state())
+		}
+		q.push(e)
+		q.signal()
+	}
+	if q.wal != nil {
+		return q.wal.compact()
+	}
+	return nil
+}
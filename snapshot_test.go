@@ -0,0 +1,83 @@
+package pqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func (st *stateTask) MarshalBinary() ([]byte, error) {
+	return json.Marshal(st)
+}
+
+func (st *stateTask) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, st)
+}
+
+func decodeStateTask(data []byte) (QueueItem, error) {
+	st := &stateTask{}
+	return st, st.UnmarshalBinary(data)
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	q := NewWithOptions(WithLimit(10), WithStableOrder())
+	for i, x := range []int{2, 1, 2, 1} {
+		q.EnqueueUnique(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	q.Dequeue()
+	var buf bytes.Buffer
+	if err := q.Snapshot(&buf); err != nil {
+		t.Fatalf("Expected snapshot to be written, given %v", err)
+	}
+
+	r := NewWithOptions(WithStableOrder())
+	if err := r.Restore(&buf, decodeStateTask); err != nil {
+		t.Fatalf("Expected snapshot to be restored, given %v", err)
+	}
+	if r.Limit != 10 || !r.IdExists("b") {
+		t.Errorf("Expected limit and history to be restored")
+	}
+	for _, name := range []string{"d", "a", "c"} {
+		if task := r.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+	if err := r.EnqueueUnique(&stateTask{Name: "a"}); err == nil {
+		t.Errorf("Expected restored history to deduplicate")
+	}
+}
+
+func TestSnapshotNotMarshaler(t *testing.T) {
+	q := New(0)
+	q.Enqueue(NewDummyTask(1))
+	if err := q.Snapshot(&bytes.Buffer{}); err != ErrNotMarshaler {
+		t.Errorf("Expected items to be required to marshal, given %v", err)
+	}
+}
+
+func TestSnapshotCompression(t *testing.T) {
+	q := NewWithOptions(WithCompression(Gzip))
+	for i := 0; i < 100; i++ {
+		q.Enqueue(&stateTask{Name: "https://example.com/" + string(rune('a'+i%26)), Priority: i})
+	}
+	var raw, compressed bytes.Buffer
+	plain := q.Clone()
+	plain.compressor = nil
+	plain.Snapshot(&raw)
+	if err := q.Snapshot(&compressed); err != nil {
+		t.Fatalf("Expected snapshot to be written, given %v", err)
+	}
+	if compressed.Len() >= raw.Len() {
+		t.Errorf("Expected compressed snapshot smaller, given %d of %d bytes", compressed.Len(), raw.Len())
+	}
+	r := NewWithOptions(WithCompression(Gzip))
+	if err := r.Restore(&compressed, decodeStateTask); err != nil {
+		t.Fatalf("Expected snapshot to be restored, given %v", err)
+	}
+	if r.Len() != 100 || r.Dequeue().(*stateTask).Priority != 0 {
+		t.Errorf("Expected all items restored in order")
+	}
+	if err := New(0).Restore(&raw, decodeStateTask); err != nil {
+		t.Errorf("Expected plain snapshot restored without compression, given %v", err)
+	}
+}
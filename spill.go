@@ -0,0 +1,237 @@
+package pqueue
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/gob"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// WithSpill keeps the queue's memory in check under large backlogs:
+// once more than high items are ready in memory, the worst ones are
+// written to a file in given directory until low items are left, and
+// they are paged back as the queue drains, always before an item in
+// memory they should go ahead of, so the order holds. Items must
+// implement encoding.BinaryMarshaler and are decoded with given
+// decode function. Spilled items count to Len and the queue limit,
+// but until they are paged back Peek, Each, Remove and the like don't
+// see them, and they don't count as queued for EnqueueIfNotQueued,
+// quotas or class limits. Clone, snapshots, ExportState and the
+// write-ahead log keep them, reading them again from the spill files.
+// Spill files are removed once paged back. Items which can't be
+// spilled stay in memory and the failure is reported by
+// LogPersistence log events.
+func WithSpill(dir string, high, low int, decode func([]byte) (QueueItem, error)) Option {
+	return func(q *Queue) {
+		q.spill = &spill{dir: dir, high: high, low: min(low, high), decode: decode}
+	}
+}
+
+// spill keeps the items spilled to files, every file holding entries
+// in order, so the best spilled entry is the best of the heads.
+type spill struct {
+	dir       string
+	high, low int
+	decode    func([]byte) (QueueItem, error)
+	segments  []*segment
+	// n is number of spilled entries
+	n int
+}
+
+type segment struct {
+	f    *os.File
+	dec  *gob.Decoder
+	head *entry
+	// total is number of entries written to the segment
+	total int
+	// left is number of entries not paged back yet, head included
+	left int
+}
+
+type spillRecord struct {
+	Data     []byte
+	Producer string
+	Seq      uint64
+	Score    int64
+	Since    time.Time
+}
+
+// spillLen returns number of spilled entries.
+func (q *Queue) spillLen() int {
+	if q.spill == nil {
+		return 0
+	}
+	return q.spill.n
+}
+
+// spillOver writes the worst ready entries to a new spill file when
+// there are too many of them. Must be called with the queue locked.
+func (q *Queue) spillOver() {
+	s := q.spill
+	if q.items.Len() <= s.high {
+		return
+	}
+	tail := q.items.sorted()[s.low:]
+	f, err := os.CreateTemp(s.dir, "pqueue-spill-*")
+	if err == nil {
+		err = writeSpill(f, tail)
+	}
+	if err != nil {
+		if f != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		q.logEvent(LogPersistence, nil, err)
+		return
+	}
+	seg := &segment{f: f, dec: gob.NewDecoder(bufio.NewReader(f)), total: len(tail), left: len(tail)}
+	if err = q.read(seg); err != nil || seg.head == nil {
+		seg.close()
+		q.logEvent(LogPersistence, nil, err)
+		return
+	}
+	for _, e := range tail {
+		q.unlink(e)
+	}
+	s.segments = append(s.segments, seg)
+	s.n += len(tail)
+}
+
+// unlink takes the ready entry out of the heap without logging its
+// removal, so the write-ahead log keeps the spilled entry and pageIn
+// just puts it back.
+func (q *Queue) unlink(e *entry) {
+	defer q.forget(e)
+	q.items.removeEntry(e)
+}
+
+func writeSpill(f *os.File, entries []*entry) error {
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	for _, e := range entries {
+		m, ok := e.item.(encoding.BinaryMarshaler)
+		if !ok {
+			return ErrNotMarshaler
+		}
+		data, err := m.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		rec := spillRecord{Data: data, Producer: e.producer, Seq: e.seq, Score: e.score, Since: e.since}
+		if err = enc.Encode(&rec); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+// read decodes the next entry of the segment as its head, nil at the
+// end of the segment.
+func (q *Queue) read(seg *segment) error {
+	seg.head = nil
+	var rec spillRecord
+	if err := seg.dec.Decode(&rec); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	e, err := q.spillEntry(&rec)
+	seg.head = e
+	return err
+}
+
+func (q *Queue) spillEntry(rec *spillRecord) (*entry, error) {
+	item, err := q.spill.decode(rec.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{item: item, id: item.Id(), producer: rec.Producer, seq: rec.Seq, score: rec.Score, since: rec.Since}, nil
+}
+
+// spilled returns copies of the spilled entries, read again from the
+// spill files without moving on in them. Must be called with the
+// queue locked, for reading at least.
+func (q *Queue) spilled() (entries []*entry, err error) {
+	if q.spillLen() == 0 {
+		return nil, nil
+	}
+	for _, seg := range q.spill.segments {
+		dec := gob.NewDecoder(bufio.NewReader(io.NewSectionReader(seg.f, 0, math.MaxInt64)))
+		for i := 0; i < seg.total; i++ {
+			var rec spillRecord
+			if err = dec.Decode(&rec); err != nil {
+				return nil, err
+			}
+			if i < seg.total-seg.left {
+				// paged back already
+				continue
+			}
+			e, err := q.spillEntry(&rec)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (seg *segment) close() {
+	seg.f.Close()
+	os.Remove(seg.f.Name())
+}
+
+// pageIn brings spilled entries back while there are less than low
+// entries ready in memory, or the best spilled one goes ahead of the
+// best one in memory. Must be called with the queue locked.
+func (q *Queue) pageIn() {
+	s := q.spill
+	for len(s.segments) > 0 {
+		best := 0
+		for i, seg := range s.segments {
+			if q.items.less(seg.head, s.segments[best].head) {
+				best = i
+			}
+		}
+		seg := s.segments[best]
+		if top := q.items.first(); q.items.Len() >= s.low && top != nil && !q.items.less(seg.head, top) {
+			return
+		}
+		q.push(seg.head)
+		seg.left -= 1
+		s.n -= 1
+		if err := q.read(seg); err != nil || seg.head == nil {
+			if err != nil {
+				// the rest of the segment is lost
+				s.n -= seg.left
+				q.logEvent(LogPersistence, nil, err)
+			}
+			seg.close()
+			s.segments = append(s.segments[:best], s.segments[best+1:]...)
+		}
+	}
+}
+
+// dropSpill drops all the spilled entries, calling fn for every one
+// which can still be read. Must be called with the queue locked.
+func (q *Queue) dropSpill(fn func(e *entry)) {
+	s := q.spill
+	for _, seg := range s.segments {
+		for seg.head != nil {
+			fn(seg.head)
+			if q.read(seg) != nil {
+				break
+			}
+		}
+		seg.close()
+	}
+	s.segments = nil
+	s.n = 0
+}
@@ -0,0 +1,108 @@
+package pqueue
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpill(t *testing.T) {
+	dir := t.TempDir()
+	q := NewWithOptions(WithSpill(dir, 4, 2, decodeStateTask))
+	for i, p := range []int{5, 3, 8, 1, 9, 7, 2, 6, 4} {
+		q.Enqueue(&stateTask{string(rune('a' + i)), p})
+	}
+	if q.Len() != 9 {
+		t.Errorf("Expected spilled items counted, given %d", q.Len())
+	}
+	if q.items.Len() > 4 {
+		t.Errorf("Expected at most 4 items in memory, given %d", q.items.Len())
+	}
+	if files, _ := os.ReadDir(dir); len(files) == 0 {
+		t.Errorf("Expected spill files written")
+	}
+	for want := 1; want <= 9; want++ {
+		item, ok := q.TryDequeue()
+		if !ok || item.(*stateTask).Priority != want {
+			t.Errorf("Expected priority %d, given %v", want, item)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("Expected empty queue, given %d", q.Len())
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected spill files removed, given %d", len(files))
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+}
+
+func TestSpillClear(t *testing.T) {
+	dir := t.TempDir()
+	q := NewWithOptions(WithSpill(dir, 2, 1, decodeStateTask))
+	for i := 0; i < 6; i++ {
+		q.Enqueue(&stateTask{string(rune('a' + i)), i})
+	}
+	q.Clear(false)
+	if q.Len() != 0 {
+		t.Errorf("Expected cleared queue, given %d", q.Len())
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected spill files removed, given %d", len(files))
+	}
+}
+
+func TestSpillPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := NewWithOptions(WithSpill(t.TempDir(), 4, 2, decodeStateTask))
+	w, err := q.OpenWAL(path, decodeStateTask, 0)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		q.Enqueue(&stateTask{string(rune('a' + i)), 10 - i})
+	}
+	if q.spillLen() == 0 {
+		t.Fatalf("Expected items spilled")
+	}
+	var snap bytes.Buffer
+	if err := q.Snapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(q.ExportState().Items); n != 10 {
+		t.Errorf("Expected spilled items exported, given %d", n)
+	}
+	if n := q.Clone().Len(); n != 10 {
+		t.Errorf("Expected spilled items cloned, given %d", n)
+	}
+	// pages in some of the spilled items
+	q.TryDequeue()
+	q.TryDequeue()
+	if err := w.compact(); err != nil {
+		t.Fatal(err)
+	}
+	q.TryDequeue()
+
+	r := New(0)
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	w.Close()
+	if r.Len() != 7 {
+		t.Errorf("Expected spilled items recovered from the log, given %d", r.Len())
+	}
+	for want := 4; want <= 10; want++ {
+		if item, ok := r.TryDequeue(); !ok || item.(*stateTask).Priority != want {
+			t.Errorf("Expected priority %d, given %v", want, item)
+		}
+	}
+	s := New(0)
+	if err := s.Restore(&snap, decodeStateTask); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 10 {
+		t.Errorf("Expected spilled items restored from the snapshot, given %d", s.Len())
+	}
+}
@@ -0,0 +1,208 @@
+// Package sqlitequeue provides a durable priority queue kept in a
+// SQLite table. Every item is a row with its priority and status,
+// so the queue survives restarts and can be inspected with plain
+// SQL. Rows of dequeued items are kept, without their bodies, as
+// the history EnqueueUnique looks at.
+//
+// The package works with any database/sql SQLite driver, which
+// the caller imports and opens the database with.
+package sqlitequeue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+// ErrTableName is returned by Open for table names which aren't
+// plain SQL identifiers.
+var ErrTableName = errors.New("Invalid table name")
+
+// tableName matches the names Open accepts. Table names can't be
+// query parameters, so they're put in queries as they are.
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Item statuses, as stored in the status column.
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+)
+
+// Options configure how items are stored and ordered.
+type Options struct {
+	// Encode and Decode turn items to bytes and back.
+	Encode func(pqueue.QueueItem) ([]byte, error)
+	Decode func([]byte) (pqueue.QueueItem, error)
+	// Priority of the item, items with lower priority are
+	// dequeued first. Items of equal priority are dequeued in
+	// the order they have been enqueued.
+	Priority func(pqueue.QueueItem) int64
+	// PollInterval tells how often blocked Dequeue looks for
+	// items enqueued by other processes. Items enqueued through
+	// the same Queue wake it up right away. Defaults to 1s.
+	PollInterval time.Duration
+}
+
+// Queue is a priority queue kept in a SQLite table.
+type Queue struct {
+	db    *sql.DB
+	table string
+	opts  Options
+
+	mu     sync.Mutex
+	notify chan struct{}
+}
+
+// Open returns the queue kept in given table, creating the table
+// if it doesn't exist. The table name may contain only letters,
+// digits and underscores, otherwise ErrTableName is returned.
+func Open(db *sql.DB, table string, opts Options) (*Queue, error) {
+	if !tableName.MatchString(table) {
+		return nil, ErrTableName
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	q := &Queue{db: db, table: table, opts: opts, notify: make(chan struct{})}
+	_, err := db.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			item_id TEXT NOT NULL,
+			priority INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			body BLOB,
+			enqueued_at INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS %[1]s_pending ON %[1]s (status, priority, seq);
+		CREATE INDEX IF NOT EXISTS %[1]s_item_id ON %[1]s (item_id);`, table))
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Enqueue puts given item to the queue.
+func (q *Queue) Enqueue(ctx context.Context, item pqueue.QueueItem) error {
+	return q.enqueue(ctx, item, false)
+}
+
+// EnqueueUnique puts item in queue only if it hasn't already
+// been in queue, otherwise it returns pqueue.ErrDuplicate.
+func (q *Queue) EnqueueUnique(ctx context.Context, item pqueue.QueueItem) error {
+	return q.enqueue(ctx, item, true)
+}
+
+func (q *Queue) enqueue(ctx context.Context, item pqueue.QueueItem, unique bool) error {
+	body, err := q.opts.Encode(item)
+	if err != nil {
+		return err
+	}
+	id := fmt.Sprint(item.Id())
+	args := []interface{}{id, q.opts.Priority(item), StatusPending, body, time.Now().UnixNano()}
+	query := fmt.Sprintf(`INSERT INTO %s (item_id, priority, status, body, enqueued_at) VALUES (?, ?, ?, ?, ?)`, q.table)
+	if unique {
+		query = fmt.Sprintf(`INSERT INTO %[1]s (item_id, priority, status, body, enqueued_at)
+			SELECT ?, ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM %[1]s WHERE item_id = ?)`, q.table)
+		args = append(args, id)
+	}
+	res, err := q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return pqueue.ErrDuplicate
+	}
+	q.wake()
+	return nil
+}
+
+// wake wakes up Dequeue calls waiting for items.
+func (q *Queue) wake() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+func (q *Queue) waiting() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.notify
+}
+
+// Dequeue takes an item from the queue. If queue is empty then it
+// blocks waiting for at least one item, until the context is done.
+func (q *Queue) Dequeue(ctx context.Context) (pqueue.QueueItem, error) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		// take the channel before looking, so no enqueue is missed
+		notify := q.waiting()
+		item, err := q.TryDequeue(ctx)
+		if item != nil || err != nil {
+			return item, err
+		}
+		select {
+		case <-notify:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// TryDequeue takes an item from the queue without blocking. It
+// returns nil item when the queue is empty.
+func (q *Queue) TryDequeue(ctx context.Context) (pqueue.QueueItem, error) {
+	for {
+		var seq int64
+		var body []byte
+		err := q.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT seq, body FROM %s
+			WHERE status = ? ORDER BY priority, seq LIMIT 1`, q.table), StatusPending).Scan(&seq, &body)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		// another consumer may have taken the row in the meantime
+		res, err := q.db.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET status = ?, body = NULL
+			WHERE seq = ? AND status = ?`, q.table), StatusDone, seq, StatusPending)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			return q.opts.Decode(body)
+		}
+	}
+}
+
+// Len returns number of enqueued items.
+func (q *Queue) Len(ctx context.Context) (n int, err error) {
+	err = q.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE status = ?`, q.table), StatusPending).Scan(&n)
+	return
+}
+
+// IdExists tells if the item with given id has ever been enqueued.
+// Ids are compared by their default formatting.
+func (q *Queue) IdExists(ctx context.Context, id interface{}) (ok bool, err error) {
+	err = q.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE item_id = ?)`, q.table), fmt.Sprint(id)).Scan(&ok)
+	return
+}
+
+// ClearHistory forgets the dequeued items, so they can be enqueued
+// with EnqueueUnique again.
+func (q *Queue) ClearHistory(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE status = ?`, q.table), StatusDone)
+	return err
+}
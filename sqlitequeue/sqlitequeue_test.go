@@ -0,0 +1,113 @@
+package sqlitequeue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+	_ "modernc.org/sqlite"
+)
+
+type task struct {
+	Name     string
+	Priority int
+}
+
+func (t *task) Less(other interface{}) bool {
+	return t.Priority < other.(*task).Priority
+}
+
+func (t *task) Id() interface{} {
+	return t.Name
+}
+
+var options = Options{
+	Encode: func(item pqueue.QueueItem) ([]byte, error) {
+		return json.Marshal(item)
+	},
+	Decode: func(data []byte) (pqueue.QueueItem, error) {
+		t := &task{}
+		return t, json.Unmarshal(data, t)
+	},
+	Priority: func(item pqueue.QueueItem) int64 {
+		return int64(item.(*task).Priority)
+	},
+}
+
+func openQueue(t *testing.T) *Queue {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("Expected database to be opened, given %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	q, err := Open(db, "jobs", options)
+	if err != nil {
+		t.Fatalf("Expected queue to be opened, given %v", err)
+	}
+	return q
+}
+
+func TestQueue(t *testing.T) {
+	q := openQueue(t)
+	ctx := context.Background()
+	for i, x := range []int{3, 1, 2, 1} {
+		q.Enqueue(ctx, &task{Name: string(rune('a' + i)), Priority: x})
+	}
+	if err := q.EnqueueUnique(ctx, &task{Name: "a", Priority: 0}); err != pqueue.ErrDuplicate {
+		t.Errorf("Expected history to deduplicate")
+	}
+	if n, _ := q.Len(ctx); n != 4 {
+		t.Errorf("Expected 4 items to be enqueued, %d given", n)
+	}
+	for _, name := range []string{"b", "d", "c", "a"} {
+		if item, err := q.Dequeue(ctx); err != nil || item.(*task).Name != name {
+			t.Errorf("Expected to dequeue %s, given %v", name, err)
+		}
+	}
+	if item, err := q.TryDequeue(ctx); err != nil || item != nil {
+		t.Errorf("Expected queue to be empty")
+	}
+	if ok, _ := q.IdExists(ctx, "b"); !ok {
+		t.Errorf("Expected dequeued items to stay in history")
+	}
+	q.ClearHistory(ctx)
+	if err := q.EnqueueUnique(ctx, &task{Name: "b", Priority: 0}); err != nil {
+		t.Errorf("Expected item to be enqueued once history is cleared")
+	}
+}
+
+func TestDequeueWaits(t *testing.T) {
+	q := openQueue(t)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		q.Enqueue(context.Background(), &task{Name: "a", Priority: 1})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if item, err := q.Dequeue(ctx); err != nil || item.(*task).Name != "a" {
+		t.Errorf("Expected to wait for the item, given %v", err)
+	}
+	if _, err := q.Dequeue(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected dequeue to be cancelled, given %v", err)
+	}
+}
+
+func TestTableName(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("Expected database to be opened, given %v", err)
+	}
+	defer db.Close()
+	for _, name := range []string{"", "jobs; DROP TABLE users", `jobs"`, "1jobs", "jobs-2"} {
+		if _, err := Open(db, name, options); err != ErrTableName {
+			t.Errorf("Expected %q to be refused, given %v", name, err)
+		}
+	}
+	if _, err := Open(db, "_jobs_2", options); err != nil {
+		t.Errorf("Expected plain identifier to be accepted, given %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package sqsqueue serves queues of a pqueue.Manager over a minimal
+// Amazon SQS compatible HTTP API, the JSON protocol of current AWS
+// SDKs, so code written for SQS can use an in-process queue during
+// development and tests. Supported actions are CreateQueue,
+// GetQueueUrl, SendMessage, ReceiveMessage and DeleteMessage.
+//
+// Messages are dequeued in order of their "Priority" message
+// attribute, of Number type, lower first, then in order they have
+// been sent. Messages without it have priority 0. Received messages
+// are leased from the queue until they are deleted, or their
+// visibility timeout passes and they are received again.
+//
+// The handler doesn't check request signatures, so it's meant for
+// local use only.
+package sqsqueue
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+// Message is the item a sent message is queued as.
+type Message struct {
+	ID       string
+	Body     string
+	Priority int64
+	seq      uint64
+}
+
+func (m *Message) Less(other interface{}) bool {
+	o := other.(*Message)
+	if m.Priority != o.Priority {
+		return m.Priority < o.Priority
+	}
+	return m.seq < o.seq
+}
+
+func (m *Message) Id() interface{} {
+	return m.ID
+}
+
+// Options configure the handler.
+type Options struct {
+	// URL the handler is served at, queue URLs are made of it.
+	URL string
+	// VisibilityTimeout of received messages when the request
+	// doesn't give one, 30 seconds when 0.
+	VisibilityTimeout time.Duration
+}
+
+type handler struct {
+	m    *pqueue.Manager
+	opts Options
+	seq  atomic.Uint64
+
+	mu       sync.Mutex
+	receipts map[string]*receipt
+}
+
+// receipt is a received message not deleted yet.
+type receipt struct {
+	d     *pqueue.Delivery
+	timer *time.Timer
+}
+
+// NewHandler returns the handler serving queues of given manager,
+// which holds only Message items, by their names.
+func NewHandler(m *pqueue.Manager, opts Options) http.Handler {
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = 30 * time.Second
+	}
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	return &handler{m: m, opts: opts, receipts: make(map[string]*receipt)}
+}
+
+// request holds the fields of all the supported actions.
+type request struct {
+	QueueName           string
+	QueueUrl            string
+	MessageBody         string
+	DelaySeconds        int
+	MessageAttributes   map[string]attribute
+	MaxNumberOfMessages int
+	VisibilityTimeout   *int
+	WaitTimeSeconds     int
+	ReceiptHandle       string
+}
+
+type attribute struct {
+	DataType    string
+	StringValue string
+}
+
+type message struct {
+	MessageId     string
+	ReceiptHandle string
+	MD5OfBody     string
+	Body          string
+}
+
+// apiError is the error response of the SQS JSON protocol.
+type apiError struct {
+	status int
+	Type   string `json:"__type"`
+	Msg    string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Msg
+}
+
+func invalid(format string, args ...interface{}) *apiError {
+	return &apiError{http.StatusBadRequest, "com.amazonaws.sqs#InvalidParameterValue", fmt.Sprintf(format, args...)}
+}
+
+var (
+	errNoQueue   = &apiError{http.StatusBadRequest, "com.amazonaws.sqs#QueueDoesNotExist", "The specified queue does not exist."}
+	errNoReceipt = &apiError{http.StatusBadRequest, "com.amazonaws.sqs#ReceiptHandleIsInvalid", "The receipt handle isn't valid."}
+	errAction    = &apiError{http.StatusBadRequest, "com.amazonaws.sqs#InvalidAction", "The action isn't supported."}
+	errInternal  = &apiError{http.StatusInternalServerError, "com.amazonaws.sqs#InternalError", "Internal error."}
+)
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action, ok := strings.CutPrefix(r.Header.Get("X-Amz-Target"), "AmazonSQS.")
+	var req request
+	if !ok || r.Method != http.MethodPost {
+		h.reply(w, nil, errAction)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.reply(w, nil, invalid("Malformed request: %v", err))
+		return
+	}
+	var resp interface{}
+	var err error
+	switch action {
+	case "CreateQueue", "GetQueueUrl":
+		resp, err = h.queueURL(req, action == "CreateQueue")
+	case "SendMessage":
+		resp, err = h.send(req)
+	case "ReceiveMessage":
+		resp, err = h.receive(r.Context(), req)
+	case "DeleteMessage":
+		resp, err = h.delete(req)
+	default:
+		err = errAction
+	}
+	h.reply(w, resp, err)
+}
+
+func (h *handler) reply(w http.ResponseWriter, resp interface{}, err error) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if err != nil {
+		e, ok := err.(*apiError)
+		if !ok {
+			e = errInternal
+		}
+		w.WriteHeader(e.status)
+		resp = e
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *handler) queueURL(req request, create bool) (interface{}, error) {
+	if req.QueueName == "" {
+		return nil, invalid("QueueName is required.")
+	}
+	if _, ok := h.m.Lookup(req.QueueName); !ok && !create {
+		return nil, errNoQueue
+	}
+	h.m.Queue(req.QueueName)
+	return map[string]string{"QueueUrl": h.opts.URL + "/" + req.QueueName}, nil
+}
+
+// queue returns the queue of the request's URL.
+func (h *handler) queue(req request) (*pqueue.Queue, error) {
+	name := req.QueueUrl[strings.LastIndexByte(req.QueueUrl, '/')+1:]
+	q, ok := h.m.Lookup(name)
+	if !ok {
+		return nil, errNoQueue
+	}
+	return q, nil
+}
+
+func (h *handler) send(req request) (interface{}, error) {
+	q, err := h.queue(req)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{ID: newID(), Body: req.MessageBody, seq: h.seq.Add(1)}
+	if a, ok := req.MessageAttributes["Priority"]; ok {
+		if msg.Priority, err = strconv.ParseInt(a.StringValue, 10, 64); err != nil || a.DataType != "Number" {
+			return nil, invalid("Priority attribute must be an integer Number.")
+		}
+	}
+	if req.DelaySeconds > 0 {
+		err = q.EnqueueAfter(msg, time.Duration(req.DelaySeconds)*time.Second)
+	} else {
+		err = q.Enqueue(msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"MessageId": msg.ID, "MD5OfMessageBody": md5Hex(msg.Body)}, nil
+}
+
+func (h *handler) receive(ctx context.Context, req request) (interface{}, error) {
+	q, err := h.queue(req)
+	if err != nil {
+		return nil, err
+	}
+	max := req.MaxNumberOfMessages
+	if max == 0 {
+		max = 1
+	}
+	if max < 1 || max > 10 {
+		return nil, invalid("MaxNumberOfMessages must be between 1 and 10.")
+	}
+	visibility := h.opts.VisibilityTimeout
+	if req.VisibilityTimeout != nil {
+		visibility = time.Duration(*req.VisibilityTimeout) * time.Second
+	}
+	wait, cancel := context.WithTimeout(ctx, time.Duration(req.WaitTimeSeconds)*time.Second)
+	defer cancel()
+	msgs := []message{}
+	for len(msgs) < max {
+		d, err := q.Lease(wait)
+		if err != nil {
+			break
+		}
+		msg := d.Item.(*Message)
+		handle := newID()
+		rc := &receipt{d: d}
+		h.mu.Lock()
+		h.receipts[handle] = rc
+		rc.timer = time.AfterFunc(visibility, func() {
+			h.mu.Lock()
+			delete(h.receipts, handle)
+			h.mu.Unlock()
+			d.Nack()
+		})
+		h.mu.Unlock()
+		msgs = append(msgs, message{MessageId: msg.ID, ReceiptHandle: handle, MD5OfBody: md5Hex(msg.Body), Body: msg.Body})
+		// once there's a message, only take the ones waiting
+		cancel()
+	}
+	return map[string][]message{"Messages": msgs}, nil
+}
+
+func (h *handler) delete(req request) (interface{}, error) {
+	if _, err := h.queue(req); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	rc, ok := h.receipts[req.ReceiptHandle]
+	delete(h.receipts, req.ReceiptHandle)
+	h.mu.Unlock()
+	if !ok {
+		return nil, errNoReceipt
+	}
+	rc.timer.Stop()
+	rc.d.Ack()
+	return struct{}{}, nil
+}
+
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package sqsqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pqueue "github.com/mileusna/gopqueue"
+)
+
+func call(t *testing.T, h http.Handler, action string, req interface{}, status int, resp interface{}) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	r.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != status {
+		t.Fatalf("Expected %s to return %d, given %d %s", action, status, rec.Code, rec.Body)
+	}
+	if resp != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+			t.Fatalf("Expected JSON from %s, given %v", action, err)
+		}
+	}
+}
+
+type received struct {
+	Messages []struct {
+		MessageId     string
+		ReceiptHandle string
+		MD5OfBody     string
+		Body          string
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := pqueue.NewManager(nil)
+	h := NewHandler(m, Options{URL: "http://localhost:9324/", VisibilityTimeout: time.Hour})
+
+	call(t, h, "GetQueueUrl", map[string]string{"QueueName": "jobs"}, http.StatusBadRequest, nil)
+	var created struct{ QueueUrl string }
+	call(t, h, "CreateQueue", map[string]string{"QueueName": "jobs"}, http.StatusOK, &created)
+	if created.QueueUrl != "http://localhost:9324/jobs" {
+		t.Errorf("Expected queue URL, given %s", created.QueueUrl)
+	}
+	url := created.QueueUrl
+	for _, msg := range []struct {
+		body     string
+		priority string
+	}{{"low", "5"}, {"high", "1"}, {"none", ""}} {
+		req := map[string]interface{}{"QueueUrl": url, "MessageBody": msg.body}
+		if msg.priority != "" {
+			req["MessageAttributes"] = map[string]interface{}{
+				"Priority": map[string]string{"DataType": "Number", "StringValue": msg.priority},
+			}
+		}
+		call(t, h, "SendMessage", req, http.StatusOK, nil)
+	}
+
+	var r received
+	call(t, h, "ReceiveMessage", map[string]interface{}{"QueueUrl": url, "MaxNumberOfMessages": 2}, http.StatusOK, &r)
+	if len(r.Messages) != 2 || r.Messages[0].Body != "none" || r.Messages[1].Body != "high" {
+		t.Fatalf("Expected none and high, given %+v", r.Messages)
+	}
+	if r.Messages[0].MD5OfBody != "334c4a4c42fdb79d7ebc3e73b517e6f8" {
+		t.Errorf("Expected MD5 of body, given %s", r.Messages[0].MD5OfBody)
+	}
+	if q, _ := m.Lookup("jobs"); q.Leased() != 2 || q.Len() != 1 {
+		t.Errorf("Expected received messages leased")
+	}
+	call(t, h, "DeleteMessage", map[string]string{"QueueUrl": url, "ReceiptHandle": r.Messages[0].ReceiptHandle}, http.StatusOK, nil)
+	call(t, h, "DeleteMessage", map[string]string{"QueueUrl": url, "ReceiptHandle": r.Messages[0].ReceiptHandle}, http.StatusBadRequest, nil)
+
+	// high stays invisible, low is back as soon as it is received
+	call(t, h, "ReceiveMessage", map[string]interface{}{"QueueUrl": url, "VisibilityTimeout": 0}, http.StatusOK, &r)
+	if len(r.Messages) != 1 || r.Messages[0].Body != "low" {
+		t.Fatalf("Expected low, given %+v", r.Messages)
+	}
+	time.Sleep(20 * time.Millisecond)
+	call(t, h, "ReceiveMessage", map[string]interface{}{"QueueUrl": url, "MaxNumberOfMessages": 10}, http.StatusOK, &r)
+	if len(r.Messages) != 1 || r.Messages[0].Body != "low" {
+		t.Fatalf("Expected low again once invisible no more, given %+v", r.Messages)
+	}
+	start := time.Now()
+	call(t, h, "ReceiveMessage", map[string]interface{}{"QueueUrl": url, "WaitTimeSeconds": 1}, http.StatusOK, &r)
+	if len(r.Messages) != 0 || time.Since(start) < time.Second {
+		t.Errorf("Expected long poll to wait for a second, given %+v", r.Messages)
+	}
+	call(t, h, "SendMessage", map[string]string{"QueueUrl": "http://localhost:9324/missing", "MessageBody": "x"}, http.StatusBadRequest, nil)
+}
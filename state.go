@@ -0,0 +1,128 @@
+package pqueue
+
+import (
+	"encoding/gob"
+	"io"
+	"time"
+)
+
+// State is a copy of the queue internals, made to be stored and
+// used to bring the queue back later, eg. after process restart.
+// It holds the limit, the history, retry attempts, the arrival
+// sequence counter and pending items in their exact heap order, so
+// the restored queue dequeues items in the same order. Delayed items
+// follow, with their ready time, and then items leased or reserved
+// and not done with yet, with their delivery count, which are pending
+// again in the restored queue. Event listeners, retry and overflow
+// policy and RankFunc are not part of the state.
+//
+// State can be encoded with encoding/gob, as long as concrete item
+// and id types are registered with gob.Register.
+type State struct {
+	Limit    int
+	History  []interface{}
+	Attempts map[interface{}]int
+	Seq      uint64
+	Items    []StateItem
+}
+
+// StateItem is a pending item with its ordering data.
+type StateItem struct {
+	Item       QueueItem
+	Producer   string
+	Score      int64
+	Seq        uint64
+	ReadyAt    time.Time
+	Deliveries int
+}
+
+// ExportState returns copy of the queue state.
+func (q *Queue) ExportState() (s State) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	s.Limit = q.Limit
+	s.Seq = q.seq
+	if n := q.historyLen(); n > 0 {
+		s.History = make([]interface{}, 0, n)
+	}
+	q.historyEach(func(id interface{}) {
+		s.History = append(s.History, id)
+	})
+	s.Attempts = make(map[interface{}]int, len(q.attempts))
+	for id, n := range q.attempts {
+		s.Attempts[id] = n
+	}
+	s.Items = make([]StateItem, 0, q.size())
+	for _, e := range q.items.list() {
+		s.Items = append(s.Items, StateItem{Item: e.item, Producer: e.producer, Score: e.score, Seq: e.seq})
+	}
+	for _, e := range q.delayed {
+		s.Items = append(s.Items, StateItem{Item: e.item, Producer: e.producer, Score: e.score, Seq: e.seq, ReadyAt: e.readyAt})
+	}
+	for _, e := range q.inflight() {
+		s.Items = append(s.Items, StateItem{Item: e.item, Producer: e.producer, Score: e.score, Seq: e.seq, Deliveries: e.deliveries})
+	}
+	spilled, err := q.spilled()
+	if err != nil {
+		q.logEvent(LogPersistence, nil, err)
+	}
+	for _, e := range spilled {
+		s.Items = append(s.Items, StateItem{Item: e.item, Producer: e.producer, Score: e.score, Seq: e.seq})
+	}
+	return
+}
+
+// ImportState creates a new queue from the state exported earlier.
+func ImportState(s State) (q *Queue) {
+	q = New(s.Limit)
+	q.seq = s.Seq
+	for _, id := range s.History {
+		q.history.Add(id)
+	}
+	for id, n := range s.Attempts {
+		q.attempts[id] = n
+	}
+	q.items.entries = make([]*entry, 0, len(s.Items))
+	for _, it := range s.Items {
+		e := &entry{item: it.Item, id: it.Item.Id(), producer: it.Producer, score: it.Score, seq: it.Seq, readyAt: it.ReadyAt, deliveries: it.Deliveries}
+		if e.readyAt.After(q.now()) {
+			q.push(e)
+			continue
+		}
+		e.index = len(q.items.entries)
+		q.items.entries = append(q.items.entries, e)
+		q.track(e)
+	}
+	// items are already in heap order, so this only guards
+	// against a tampered state
+	q.items.init()
+	return
+}
+
+// Encode writes the queue state to w with encoding/gob. Concrete
+// item and id types have to be registered with gob.Register. Unlike
+// Snapshot it doesn't need items to implement BinaryMarshaler.
+func (q *Queue) Encode(w io.Writer) error {
+	s := q.ExportState()
+	return gob.NewEncoder(w).Encode(&s)
+}
+
+// Decode reads the state written by Encode from r and puts its
+// items, history and retry attempts to the queue, taking the limit
+// too, like Restore does.
+func (q *Queue) Decode(r io.Reader) error {
+	var s State
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return err
+	}
+	entries := make([]*entry, len(s.Items))
+	for i, it := range s.Items {
+		entries[i] = &entry{item: it.Item, id: it.Item.Id(), producer: it.Producer, seq: it.Seq, readyAt: it.ReadyAt, deliveries: it.Deliveries}
+	}
+	q.cond.L.Lock()
+	for id, n := range s.Attempts {
+		q.attempts[id] = n
+	}
+	q.cond.L.Unlock()
+	return q.restore(s.Limit, s.Seq, s.History, entries)
+}
@@ -0,0 +1,106 @@
+package pqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"testing"
+)
+
+type stateTask struct {
+	Name     string
+	Priority int
+}
+
+func (st *stateTask) Less(other interface{}) bool {
+	return st.Priority < other.(*stateTask).Priority
+}
+
+func (st *stateTask) Id() interface{} {
+	return st.Name
+}
+
+func init() {
+	gob.Register(&stateTask{})
+}
+
+func TestExportImportState(t *testing.T) {
+	q := New(10)
+	for i, x := range []int{3, 1, 2, 1, 3, 2, 1} {
+		q.EnqueueQuota("producer", &stateTask{Name: string(rune('a' + i)), Priority: x}, 0)
+	}
+	q.EnqueueUnique(&stateTask{Name: "done", Priority: 0})
+	q.Dequeue()
+	q.Retry(&stateTask{Name: "h", Priority: 5})
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(q.ExportState()); err != nil {
+		t.Fatalf("Expected state to be encoded, given %v", err)
+	}
+	var s State
+	if err := gob.NewDecoder(&buf).Decode(&s); err != nil {
+		t.Fatalf("Expected state to be decoded, given %v", err)
+	}
+	r := ImportState(s)
+
+	if r.Limit != 10 || r.Len() != q.Len() {
+		t.Errorf("Expected limit and items to be restored")
+	}
+	if !r.IdExists("done") || r.Attempts("h") != 1 {
+		t.Errorf("Expected history and attempts to be restored")
+	}
+	if r.ProducerLen("producer") != q.ProducerLen("producer") {
+		t.Errorf("Expected producer counts to be restored")
+	}
+	for !q.IsEmpty() {
+		expected, given := q.Dequeue().(*stateTask), r.Dequeue().(*stateTask)
+		if expected.Name != given.Name {
+			t.Errorf("Expected to dequeue %s, given %s", expected.Name, given.Name)
+		}
+	}
+}
+
+func TestExportStateInflight(t *testing.T) {
+	q := New(0)
+	for _, name := range []string{"a", "b", "c"} {
+		q.Enqueue(&stateTask{Name: name, Priority: 1})
+	}
+	d, _ := q.Lease(context.Background())
+	q.Reserve()
+	r := ImportState(q.ExportState())
+	if r.Len() != 3 {
+		t.Errorf("Expected leased and reserved items pending again, given %d items", r.Len())
+	}
+	for r.Len() > 0 {
+		d2, _ := r.Lease(context.Background())
+		if d2.Item.Id() == d.Item.Id() && d2.Deliveries() != 2 {
+			t.Errorf("Expected delivery count kept, given %d", d2.Deliveries())
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	q := NewWithOptions(WithStableOrder())
+	for i, x := range []int{2, 1, 2} {
+		q.EnqueueUnique(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	q.Dequeue()
+	q.Retry(&stateTask{Name: "d", Priority: 3})
+
+	var buf bytes.Buffer
+	if err := q.Encode(&buf); err != nil {
+		t.Fatalf("Expected queue to be encoded, given %v", err)
+	}
+	r := NewWithOptions(WithStableOrder())
+	if err := r.Decode(&buf); err != nil {
+		t.Fatalf("Expected queue to be decoded, given %v", err)
+	}
+	if !r.IdExists("b") || r.Attempts("d") != 1 {
+		t.Errorf("Expected history and attempts to be decoded")
+	}
+	for _, name := range []string{"a", "c", "d"} {
+		if task := r.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+}
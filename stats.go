@@ -0,0 +1,95 @@
+package pqueue
+
+import "time"
+
+// Stats holds the queue counters, as returned by Stats. Counters
+// start from zero when the queue is created.
+type Stats struct {
+	// Enqueued counts items put to the queue.
+	Enqueued uint64
+	// Dequeued counts items taken from the queue.
+	Dequeued uint64
+	// Rejected counts items which didn't make it to the full queue.
+	Rejected uint64
+	// Duplicates counts items not enqueued by EnqueueUnique or
+	// EnqueueIfNotQueued, because their id has been already seen.
+	Duplicates uint64
+	// Expired counts Expirable items dropped at dequeue.
+	Expired uint64
+	// Len is the number of enqueued items.
+	Len int
+	// History is the size of the history, or -1 when the history
+	// store doesn't tell it.
+	History int
+	// Rates are the rates of the counted items over the last
+	// minutes.
+	Rates Rates
+}
+
+// Stats returns the queue counters, their rates and the current
+// size of the queue. ResetStats sets them back to zero.
+func (q *Queue) Stats() Stats {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	s := q.stats
+	s.Len = q.size()
+	s.History = q.historyLen()
+	s.Rates = q.rates.rates(q.now())
+	return s
+}
+
+// count updates the counters and fires the callbacks for given
+// event. Must be called with the queue locked.
+func (q *Queue) count(kind EventKind, item QueueItem) {
+	switch kind {
+	case Enqueued:
+		q.stats.Enqueued += 1
+		q.countRate(rateEnqueued)
+		q.hooks.fire(q.hooks.enqueue, item)
+	case Dequeued:
+		q.stats.Dequeued += 1
+		q.countRate(rateDequeued)
+		q.hooks.fire(q.hooks.dequeue, item)
+		if len(q.hooks.late) > 0 && isLate(item, q.now()) {
+			q.hooks.fire(q.hooks.late, item)
+		}
+	case Expired:
+		q.stats.Expired += 1
+	}
+}
+
+// OldestAge returns how long the item waiting longest in the queue
+// has been waiting, or 0 for empty queue. Delayed items start
+// waiting once they are ready.
+func (q *Queue) OldestAge() time.Duration {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	var oldest time.Time
+	for _, e := range q.items.list() {
+		since := e.waitingSince()
+		if oldest.IsZero() || since.Before(oldest) {
+			oldest = since
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return q.now().Sub(oldest)
+}
+
+// LenByPriority returns number of pending items, delayed ones
+// included, in every class given by bucket, eg. "high" and "low",
+// so it can be seen what kind of work is waiting. Classes without
+// items are left out.
+func (q *Queue) LenByPriority(bucket func(QueueItem) string) map[string]int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	lens := make(map[string]int)
+	for _, e := range q.items.list() {
+		lens[bucket(e.item)] += 1
+	}
+	for _, e := range q.delayed {
+		lens[bucket(e.item)] += 1
+	}
+	return lens
+}
@@ -0,0 +1,85 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	q := New(2)
+	tasks := []*DummyTask{NewDummyTask(1), NewDummyTask(2), NewDummyTask(3)}
+	q.EnqueueUnique(tasks[0])
+	q.EnqueueUnique(tasks[0])
+	q.EnqueueIfNotQueued(tasks[0])
+	q.Enqueue(tasks[1])
+	q.Enqueue(tasks[2])
+	q.Dequeue()
+	s := q.Stats()
+	// rates are tested by TestRates
+	s.Rates = Rates{}
+	want := Stats{Enqueued: 2, Dequeued: 1, Rejected: 1, Duplicates: 2, Len: 1, History: 2}
+	if s != want {
+		t.Errorf("Expected stats %+v, given %+v", want, s)
+	}
+}
+
+func TestOldestAge(t *testing.T) {
+	q := New(0)
+	if q.OldestAge() != 0 {
+		t.Errorf("Expected no age for empty queue")
+	}
+	q.Enqueue(NewDummyTask(2))
+	time.Sleep(2e7)
+	q.Enqueue(NewDummyTask(1))
+	if age := q.OldestAge(); age < 2e7 {
+		t.Errorf("Expected age of the first item, given %v", age)
+	}
+}
+
+func TestLenByPriority(t *testing.T) {
+	q := New(0)
+	for _, x := range []int{1, 5, 2, 8, 9} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	q.EnqueueAfter(NewDummyTask(3), time.Hour)
+	lens := q.LenByPriority(func(item QueueItem) string {
+		if item.(*DummyTask).priority < 5 {
+			return "high"
+		}
+		return "low"
+	})
+	if len(lens) != 2 || lens["high"] != 3 || lens["low"] != 3 {
+		t.Errorf("Expected 3 high and 3 low priority items, given %v", lens)
+	}
+}
+
+func TestRates(t *testing.T) {
+	clock := newFakeClock()
+	q := NewWithOptions(WithLimit(10), WithClock(clock))
+	for i := 0; i < 12; i++ {
+		q.Enqueue(NewDummyTask(i))
+	}
+	clock.Advance(4 * time.Minute)
+	for i := 0; i < 6; i++ {
+		q.Dequeue()
+	}
+	r := q.Stats().Rates
+	if r.Enqueued.M1 != 0 || r.Enqueued.M5 != 10.0/300 || r.Enqueued.M15 != 10.0/900 {
+		t.Errorf("Expected enqueue rates of 10 items 4 minutes ago, given %+v", r.Enqueued)
+	}
+	if r.Dequeued.M1 != 6.0/60 || r.Rejected.M5 != 2.0/300 {
+		t.Errorf("Expected dequeue and reject rates, given %+v", r)
+	}
+	clock.Advance(15 * time.Minute)
+	if r := q.Stats().Rates; r != (Rates{}) {
+		t.Errorf("Expected no rates after 15 minutes, given %+v", r)
+	}
+
+	q.ResetStats()
+	if s := q.Stats(); s.Enqueued != 0 || s.Dequeued != 0 || s.Rejected != 0 || s.Len != 4 {
+		t.Errorf("Expected counters reset, given %+v", s)
+	}
+	if h := q.Latency(); h.Count != 0 {
+		t.Errorf("Expected latency reset, given %+v", h)
+	}
+}
@@ -0,0 +1,153 @@
+package pqueue
+
+import "sort"
+
+// Storage keeps the ready items of a queue in order, in place of the
+// built-in binary heap, eg. to keep them on disk, in shards or in a
+// remote store, see WithStorage. Delayed items, history and the rest
+// of the bookkeeping stay with the queue. The queue is locked around
+// every call, so implementations don't have to be threadsafe.
+//
+// Elements are ordered by the less function given to Init, which
+// tells if element a goes before element b, following the queue
+// order, stable order, ranking and bumps included. An element stays
+// the same pointer while it's in the storage, so it can be used as a
+// map key, but it's only valid within the process.
+type Storage interface {
+	// Init empties the storage and sets how elements are ordered.
+	Init(less func(a, b *Element) bool)
+	// Push adds the element.
+	Push(e *Element)
+	// Pop takes the first element out, nil if there is none.
+	Pop() *Element
+	// Peek returns the first element, nil if there is none.
+	Peek() *Element
+	// Remove takes given element out.
+	Remove(e *Element)
+	// Fix puts given element back in order once its item changed.
+	Fix(e *Element)
+	// Len returns number of elements.
+	Len() int
+	// Each calls fn for every element, in no particular order,
+	// until fn returns false.
+	Each(fn func(e *Element) bool)
+}
+
+// Element is an item kept in Storage.
+type Element struct {
+	e *entry
+}
+
+// Item returns the item of the element.
+func (el *Element) Item() QueueItem {
+	return el.e.item
+}
+
+// WithStorage keeps the ready items in given storage instead of the
+// built-in heap. Some views of the queue which walk the heap, like
+// PeekN, fall back to walking all the elements, and Clone keeps its
+// items in the built-in heap.
+func WithStorage(st Storage) Option {
+	return func(q *Queue) {
+		q.items.ext = st
+		st.Init(q.items.elementLess)
+	}
+}
+
+// element returns the entry's handle used by Storage.
+func (e *entry) element() *Element {
+	if e.elem.e == nil {
+		e.elem.e = e
+	}
+	return &e.elem
+}
+
+func (s *sorter) elementLess(a, b *Element) bool {
+	return s.less(a.e, b.e)
+}
+
+// first returns the entry which goes first, nil if there is none.
+func (s *sorter) first() *entry {
+	if s.ext != nil {
+		if el := s.ext.Peek(); el != nil {
+			return el.e
+		}
+		return nil
+	}
+	if len(s.entries) == 0 {
+		return nil
+	}
+	return s.entries[0]
+}
+
+// each calls fn for every entry, in no particular order, until fn
+// returns false.
+func (s *sorter) each(fn func(e *entry) bool) {
+	if s.ext != nil {
+		s.ext.Each(func(el *Element) bool {
+			return fn(el.e)
+		})
+		return
+	}
+	for _, e := range s.entries {
+		if !fn(e) {
+			return
+		}
+	}
+}
+
+// list returns all the entries, in no particular order. The slice
+// is the heap itself, unless there is an external storage.
+func (s *sorter) list() []*entry {
+	if s.ext == nil {
+		return s.entries
+	}
+	entries := make([]*entry, 0, s.ext.Len())
+	s.each(func(e *entry) bool {
+		entries = append(entries, e)
+		return true
+	})
+	return entries
+}
+
+// reset replaces all the entries with given ones and puts them in
+// order. The heap takes the slice over.
+func (s *sorter) reset(entries []*entry) {
+	if s.ext != nil {
+		s.ext.Init(s.elementLess)
+		for _, e := range entries {
+			s.ext.Push(e.element())
+		}
+		return
+	}
+	s.entries = entries
+	s.init()
+}
+
+// removeEntry takes given entry out.
+func (s *sorter) removeEntry(e *entry) {
+	if s.ext != nil {
+		s.ext.Remove(e.element())
+		e.index = -1
+		return
+	}
+	s.remove(e.index)
+}
+
+// fixEntry puts given entry back in order once it has changed.
+func (s *sorter) fixEntry(e *entry) {
+	if s.ext != nil {
+		s.ext.Fix(e.element())
+		return
+	}
+	s.fix(e.index)
+}
+
+// sorted returns all the entries in order.
+func (s *sorter) sorted() []*entry {
+	entries := append([]*entry(nil), s.list()...)
+	sort.Slice(entries, func(i, j int) bool {
+		return s.less(entries[i], entries[j])
+	})
+	return entries
+}
@@ -0,0 +1,104 @@
+package pqueue
+
+import (
+	"slices"
+	"sort"
+	"testing"
+)
+
+// sliceStorage keeps elements in a sorted slice.
+type sliceStorage struct {
+	less  func(a, b *Element) bool
+	elems []*Element
+}
+
+func (s *sliceStorage) Init(less func(a, b *Element) bool) {
+	s.less, s.elems = less, nil
+}
+
+func (s *sliceStorage) Push(e *Element) {
+	i := sort.Search(len(s.elems), func(i int) bool {
+		return s.less(e, s.elems[i])
+	})
+	s.elems = slices.Insert(s.elems, i, e)
+}
+
+func (s *sliceStorage) Pop() *Element {
+	e := s.Peek()
+	if e != nil {
+		s.elems = s.elems[1:]
+	}
+	return e
+}
+
+func (s *sliceStorage) Peek() *Element {
+	if len(s.elems) == 0 {
+		return nil
+	}
+	return s.elems[0]
+}
+
+func (s *sliceStorage) Remove(e *Element) {
+	s.elems = slices.DeleteFunc(s.elems, func(x *Element) bool {
+		return x == e
+	})
+}
+
+func (s *sliceStorage) Fix(e *Element) {
+	s.Remove(e)
+	s.Push(e)
+}
+
+func (s *sliceStorage) Len() int {
+	return len(s.elems)
+}
+
+func (s *sliceStorage) Each(fn func(e *Element) bool) {
+	for _, e := range s.elems {
+		if !fn(e) {
+			return
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	st := new(sliceStorage)
+	q := NewWithOptions(WithStorage(st), WithStableOrder())
+	tasks := make(map[int]*DummyTask)
+	for _, x := range []int{5, 3, 8, 1, 9, 2, 7} {
+		tasks[x] = NewDummyTask(x)
+		q.Enqueue(tasks[x])
+	}
+	if st.Len() != 7 || q.Len() != 7 {
+		t.Fatalf("Expected items to be kept in the storage, given %d", st.Len())
+	}
+	if item, _ := q.Peek(); item != tasks[1] {
+		t.Errorf("Expected to peek the top item")
+	}
+	if worst, _ := q.PopWorst(); worst != tasks[9] {
+		t.Errorf("Expected to pop the worst item")
+	}
+	q.Remove(tasks[2])
+	q.UpdatePriority(tasks[8], func(item QueueItem) {
+		item.(*DummyTask).priority = 0
+	})
+	q.Bump(tasks[7])
+	if top := q.PeekN(2); len(top) != 2 || top[0] != tasks[7] || top[1] != tasks[8] {
+		t.Errorf("Expected bumped item ahead of the updated one")
+	}
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+	c := q.Clone()
+	for _, x := range []int{7, 0, 1, 3, 5} {
+		if task, _ := q.TryDequeue(); task == nil || task.(*DummyTask).priority != x {
+			t.Errorf("Expected priority %d, given %v", x, task)
+		}
+		if task, _ := c.TryDequeue(); task == nil || task.(*DummyTask).priority != x {
+			t.Errorf("Expected clone to have priority %d, given %v", x, task)
+		}
+	}
+	if st.Len() != 0 {
+		t.Errorf("Expected storage to be empty")
+	}
+}
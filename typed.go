@@ -0,0 +1,102 @@
+package pqueue
+
+import (
+	"context"
+	"time"
+)
+
+// TypedQueue is a priority queue holding items of a single type,
+// so dequeued items don't have to be type asserted:
+//
+//	q := pqueue.NewTyped[*CustomTask](0)
+//	q.Enqueue(&CustomTask{Name: "foo", priority: 1})
+//	task := q.Dequeue()
+//	println(task.Name)
+//
+// It's a thin wrapper around Queue, which does all the work.
+type TypedQueue[T QueueItem] struct {
+	q *Queue
+}
+
+// NewTyped creates and initializes a new typed priority queue,
+// taking a limit as a parameter. If 0 given, then queue will be
+// unlimited.
+func NewTyped[T QueueItem](max int) *TypedQueue[T] {
+	return &TypedQueue[T]{q: New(max)}
+}
+
+// Untyped returns the underlying queue, for features not wrapped
+// by TypedQueue. Only items of type T may be enqueued to it.
+func (t *TypedQueue[T]) Untyped() *Queue {
+	return t.q
+}
+
+// Enqueue puts given item to the queue.
+func (t *TypedQueue[T]) Enqueue(item T) error {
+	return t.q.Enqueue(item)
+}
+
+// EnqueueUnique puts item in queue only if it hasn't already been
+// in queue.
+func (t *TypedQueue[T]) EnqueueUnique(item T) error {
+	return t.q.EnqueueUnique(item)
+}
+
+// EnqueueIfNotQueued puts item in queue only if no item with the
+// same id is waiting in it right now.
+func (t *TypedQueue[T]) EnqueueIfNotQueued(item T) error {
+	return t.q.EnqueueIfNotQueued(item)
+}
+
+// Dequeue takes an item from the queue, blocking while it's empty.
+// Once the queue is closed and drained it returns zero value.
+func (t *TypedQueue[T]) Dequeue() (item T) {
+	item, _ = t.q.Dequeue().(T)
+	return
+}
+
+// DequeueContext takes an item from the queue, blocking while the
+// queue is empty until the context is done.
+func (t *TypedQueue[T]) DequeueContext(ctx context.Context) (item T, err error) {
+	x, err := t.q.DequeueContext(ctx)
+	item, _ = x.(T)
+	return
+}
+
+// DequeueTimeout takes an item from the queue, blocking while the
+// queue is empty for at most given duration.
+func (t *TypedQueue[T]) DequeueTimeout(d time.Duration) (item T, err error) {
+	x, err := t.q.DequeueTimeout(d)
+	item, _ = x.(T)
+	return
+}
+
+// TryDequeue takes an item from the queue without blocking.
+func (t *TypedQueue[T]) TryDequeue() (item T, ok bool) {
+	x, ok := t.q.TryDequeue()
+	item, _ = x.(T)
+	return
+}
+
+// Peek returns the item that would be dequeued next, without
+// taking it from the queue.
+func (t *TypedQueue[T]) Peek() (item T, ok bool) {
+	x, ok := t.q.Peek()
+	item, _ = x.(T)
+	return
+}
+
+// Len returns number of enqueued elements.
+func (t *TypedQueue[T]) Len() int {
+	return t.q.Len()
+}
+
+// IsEmpty returns true if queue is empty.
+func (t *TypedQueue[T]) IsEmpty() bool {
+	return t.q.IsEmpty()
+}
+
+// Close marks the end of the stream, see Queue.Close.
+func (t *TypedQueue[T]) Close() {
+	t.q.Close()
+}
@@ -0,0 +1,25 @@
+package pqueue
+
+import "testing"
+
+func TestTypedQueue(t *testing.T) {
+	q := NewTyped[*DummyTask](0)
+	for _, x := range []int{3, 1, 2} {
+		q.Enqueue(NewDummyTask(x))
+	}
+	if task, ok := q.Peek(); !ok || task.priority != 1 {
+		t.Errorf("Expected to peek the top task")
+	}
+	for _, x := range []int{1, 2, 3} {
+		if task := q.Dequeue(); task.priority != x {
+			t.Errorf("Expected priority to be %d, given %d", x, task.priority)
+		}
+	}
+	if _, ok := q.TryDequeue(); ok {
+		t.Errorf("Expected queue to be empty")
+	}
+	q.Close()
+	if task := q.Dequeue(); task != nil {
+		t.Errorf("Expected nil task from closed queue")
+	}
+}
@@ -0,0 +1,92 @@
+package pqueue
+
+import "fmt"
+
+// Validate checks the queue internals are consistent: the heap order
+// of pending items, unless they're kept in Storage, and of delayed
+// ones, the positions entries know of, the index of pending ids and
+// producers, and the history. It's meant for tests and debugging,
+// eg. after an item's Less panicked. It returns an error describing
+// the first problem found.
+func (q *Queue) Validate() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	active := make(map[*entry]bool, q.size())
+	producers := make(map[string]int)
+	for i, e := range q.items.list() {
+		if e.delayed {
+			return fmt.Errorf("pqueue: entry at %d is delayed", i)
+		}
+		if q.items.ext == nil && e.index != i {
+			return fmt.Errorf("pqueue: entry at %d has index %d", i, e.index)
+		}
+		if q.items.ext == nil && i > 0 && q.items.less(e, q.items.entries[(i-1)/2]) {
+			return fmt.Errorf("pqueue: entry at %d is less than its parent", i)
+		}
+		active[e] = true
+	}
+	for i, e := range q.delayed {
+		if e.index != i || !e.delayed {
+			return fmt.Errorf("pqueue: delayed entry at %d has index %d, delayed %v", i, e.index, e.delayed)
+		}
+		if i > 0 && q.delayed.Less(i, (i-1)/2) {
+			return fmt.Errorf("pqueue: delayed entry at %d is due before its parent", i)
+		}
+		active[e] = true
+	}
+	n := 0
+	var err error
+	q.eachPending(func(id interface{}, entries []*entry) {
+		if err != nil {
+			return
+		}
+		if len(entries) == 0 {
+			err = fmt.Errorf("pqueue: no entries for id %v", id)
+			return
+		}
+		for _, e := range entries {
+			if !active[e] || e.id != id {
+				err = fmt.Errorf("pqueue: id %v indexes entry which is not pending", id)
+				return
+			}
+			if e.producer != "" {
+				producers[e.producer] += 1
+			}
+		}
+		n += len(entries)
+	})
+	if err != nil {
+		return err
+	}
+	if n != len(active) {
+		return fmt.Errorf("pqueue: %d entries pending, %d indexed by id", len(active), n)
+	}
+	if len(producers) != len(q.producers) {
+		return fmt.Errorf("pqueue: %d producers pending, %d counted", len(producers), len(q.producers))
+	}
+	for p, n := range producers {
+		if q.producers[p] != n {
+			return fmt.Errorf("pqueue: producer %q has %d entries pending, %d counted", p, n, q.producers[p])
+		}
+	}
+	if h, ok := q.history.(*history); ok {
+		seen := 0
+		for id, el := range h.ids {
+			he := el.Value.(*historyEntry)
+			if !he.forgotten {
+				seen += 1
+			} else if len(he.pending) == 0 {
+				return fmt.Errorf("pqueue: forgotten id %v kept without pending entries", id)
+			}
+		}
+		if seen != h.order.Len() {
+			return fmt.Errorf("pqueue: %d ids in history, %d in its order", seen, h.order.Len())
+		}
+		for el := h.order.Front(); el != nil; el = el.Next() {
+			if h.ids[el.Value.(*historyEntry).id] != el {
+				return fmt.Errorf("pqueue: history id %v is not indexed", el.Value.(*historyEntry).id)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+// touchyTask panics when compared while touchy is set.
+type touchyTask struct {
+	DummyTask
+	touchy *bool
+}
+
+func (tt *touchyTask) Less(other interface{}) bool {
+	if *tt.touchy {
+		panic("touchy")
+	}
+	return tt.priority < other.(*touchyTask).priority
+}
+
+func (tt *touchyTask) Id() interface{} {
+	return tt
+}
+
+func TestValidate(t *testing.T) {
+	touchy := false
+	q := New(0)
+	for i := 0; i < 20; i++ {
+		q.Enqueue(&touchyTask{DummyTask{priority: 20 - i}, &touchy})
+	}
+	q.EnqueueAfter(&touchyTask{DummyTask{priority: 0}, &touchy}, time.Hour)
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue, given %v", err)
+	}
+
+	recovered := func(fn func()) (r interface{}) {
+		defer func() {
+			r = recover()
+		}()
+		fn()
+		return
+	}
+	touchy = true
+	if r := recovered(func() { q.Enqueue(&touchyTask{DummyTask{priority: 7}, &touchy}) }); r != "touchy" {
+		t.Errorf("Expected panic of Less to reach the caller, given %v", r)
+	}
+	if r := recovered(func() { q.Dequeue() }); r != "touchy" {
+		t.Errorf("Expected panic of Less to reach the caller, given %v", r)
+	}
+	touchy = false
+	if q.Len() != 21 {
+		t.Errorf("Expected the new item in and the dequeued one out, %d items left", q.Len())
+	}
+	// only the order may be broken, put it back
+	q.Rerank()
+	q.SetStableOrder(true)
+	if err := q.Validate(); err != nil {
+		t.Errorf("Expected valid queue once put back in order, given %v", err)
+	}
+	prev := 0
+	for i := 0; i < 19; i++ {
+		task := q.Dequeue().(*touchyTask)
+		if task.priority < prev {
+			t.Errorf("Expected items in order, given %d after %d", task.priority, prev)
+		}
+		prev = task.priority
+	}
+
+	q.mu.Lock()
+	q.items.entries[0].index = 5
+	q.mu.Unlock()
+	if q.Validate() == nil {
+		t.Errorf("Expected broken index to be found")
+	}
+}
@@ -0,0 +1,468 @@
+package pqueue
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// ErrCorruptWAL is returned when a write-ahead log record can't be
+// read back, other than the torn record at the end of the log.
+var ErrCorruptWAL = errors.New("Corrupt write-ahead log")
+
+const (
+	walEnqueue byte = iota + 1
+	walRemove
+	walHistory
+	walForget
+	walClearHistory
+	walLimit
+	walEnqueueAt
+)
+
+// WAL is a write-ahead log of the queue changes, opened with
+// OpenWAL. Every enqueued item, every item leaving the queue and
+// every history change is appended to the log, so the queue can
+// be brought back after a crash by opening the log again.
+type WAL struct {
+	q            *Queue
+	path         string
+	f            *os.File
+	records      int
+	compactAfter int
+	err          error
+	// aead encrypts records, when the queue is encrypted
+	aead cipher.AEAD
+}
+
+// OpenWAL replays the write-ahead log at given path into the queue,
+// then keeps appending every change of the queue to it. Items are
+// encoded with encoding.BinaryMarshaler, which they must implement,
+// and decoded with given decode function. History ids are encoded
+// with encoding/gob, so ids of other than basic types have to be
+// registered with gob.Register. With WithEncryption every record is
+// encrypted.
+//
+// The log is compacted, rewritten to hold just the current state,
+// when it's opened and then after every compactAfter records, when
+// compactAfter is above 0. Bloom filter history can't be listed,
+// so it doesn't survive compaction.
+//
+// Records are written to the operating system right away, but not
+// synced to the disk; call Sync for that. Once writing to the log
+// fails, nothing more is written to it and enqueueing returns the
+// error, see Err.
+func (q *Queue) OpenWAL(path string, decode func([]byte) (QueueItem, error), compactAfter int) (w *WAL, err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	aead, err := q.aead()
+	if err != nil {
+		return
+	}
+	if err = q.replayWAL(path, decode, aead); err != nil {
+		return
+	}
+	w = &WAL{q: q, path: path, compactAfter: compactAfter, aead: aead}
+	if err = w.compact(); err != nil {
+		return nil, err
+	}
+	q.wal = w
+	return
+}
+
+// replayWAL brings the queue to the state recorded in the log.
+func (q *Queue) replayWAL(path string, decode func([]byte) (QueueItem, error), aead cipher.AEAD) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	pending := make(map[uint64]*entry)
+	for {
+		rec, err := readWALRecord(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// log ends here, or with a record torn by the crash
+			break
+		} else if err != nil {
+			return err
+		}
+		if aead != nil {
+			if rec, err = unseal(aead, rec); err != nil {
+				return err
+			}
+			if len(rec) == 0 {
+				return ErrCorruptWAL
+			}
+		}
+		op, data := rec[0], rec[1:]
+		switch op {
+		case walEnqueue, walEnqueueAt:
+			var readyAt time.Time
+			if op == walEnqueueAt {
+				at, n := binary.Varint(data)
+				if n <= 0 {
+					return ErrCorruptWAL
+				}
+				readyAt = time.Unix(0, at)
+				data = data[n:]
+			}
+			seq, n := binary.Uvarint(data)
+			plen, m := binary.Uvarint(data[n:])
+			if n <= 0 || m <= 0 || uint64(len(data)-n-m) < plen {
+				return ErrCorruptWAL
+			}
+			data = data[n+m:]
+			item, err := decode(data[plen:])
+			if err != nil {
+				return err
+			}
+			e := &entry{item: item, id: item.Id(), producer: string(data[:plen]), seq: seq, readyAt: readyAt}
+			pending[seq] = e
+			q.history.Add(q.historyID(item))
+			if seq > q.seq {
+				q.seq = seq
+			}
+		case walRemove:
+			seq, n := binary.Uvarint(data)
+			if n <= 0 {
+				return ErrCorruptWAL
+			}
+			delete(pending, seq)
+		case walHistory, walForget:
+			var id interface{}
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&id); err != nil {
+				return ErrCorruptWAL
+			}
+			if op == walHistory {
+				q.history.Add(id)
+			} else {
+				q.history.Remove(id)
+			}
+		case walClearHistory:
+			q.history.Clear()
+		case walLimit:
+			limit, n := binary.Varint(data)
+			if n <= 0 {
+				return ErrCorruptWAL
+			}
+			q.Limit = int(limit)
+		default:
+			return ErrCorruptWAL
+		}
+	}
+	entries := make([]*entry, 0, len(pending))
+	for _, e := range pending {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+	for _, e := range entries {
+		if q.rank != nil {
+			e.score = q.rank(e.item, q.state())
+		}
+		q.push(e)
+	}
+	return nil
+}
+
+func readWALRecord(r io.Reader) ([]byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	rec := make([]byte, binary.LittleEndian.Uint32(header[:4]))
+	if _, err := io.ReadFull(r, rec); err != nil {
+		return nil, err
+	}
+	if len(rec) == 0 || crc32.ChecksumIEEE(rec) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return rec, nil
+}
+
+func appendWALRecord(w io.Writer, rec []byte) error {
+	buf := make([]byte, 8, 8+len(rec))
+	binary.LittleEndian.PutUint32(buf[:4], uint32(len(rec)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(rec))
+	_, err := w.Write(append(buf, rec...))
+	return err
+}
+
+// append writes the record, encrypted when the queue is.
+func (w *WAL) append(dst io.Writer, rec []byte) (err error) {
+	if w.aead != nil {
+		if rec, err = seal(w.aead, rec); err != nil {
+			return
+		}
+	}
+	return appendWALRecord(dst, rec)
+}
+
+func walEnqueueRecord(e *entry) ([]byte, error) {
+	m, ok := e.item.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, ErrNotMarshaler
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	rec := []byte{walEnqueue}
+	if !e.readyAt.IsZero() {
+		rec = binary.AppendVarint([]byte{walEnqueueAt}, e.readyAt.UnixNano())
+	}
+	rec = binary.AppendUvarint(rec, e.seq)
+	rec = binary.AppendUvarint(rec, uint64(len(e.producer)))
+	rec = append(rec, e.producer...)
+	return append(rec, data...), nil
+}
+
+func walIDRecord(op byte, id interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(op)
+	if err := gob.NewEncoder(&buf).Encode(&id); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// write appends the record to the log, compacting the log when
+// it's due. Must be called with the queue locked.
+func (w *WAL) write(rec []byte, err error) error {
+	if err = w.record(rec, err); err != nil {
+		return err
+	}
+	return w.compactDue()
+}
+
+// record appends the record to the log without compacting it, for
+// records of entries not in the queue yet, which compaction would
+// leave out. Must be called with the queue locked.
+func (w *WAL) record(rec []byte, err error) error {
+	if w.err != nil {
+		return w.err
+	}
+	if err == nil {
+		err = w.append(w.f, rec)
+	}
+	if err != nil {
+		return err
+	}
+	w.records += 1
+	return nil
+}
+
+// compactDue compacts the log once compactAfter records have been
+// appended since it was compacted last. Must be called with the
+// queue locked.
+func (w *WAL) compactDue() (err error) {
+	if w.compactAfter > 0 && w.records >= w.compactAfter {
+		err = w.compact()
+		w.fail(err)
+	}
+	return
+}
+
+// compact rewrites the log to hold just the current state of the
+// queue. Must be called with the queue locked.
+func (w *WAL) compact() (err error) {
+	q := w.q
+	tmp := w.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+		}
+	}()
+	bw := bufio.NewWriter(f)
+	if err = w.append(bw, binary.AppendVarint([]byte{walLimit}, int64(q.Limit))); err != nil {
+		return
+	}
+	q.historyEach(func(id interface{}) {
+		if err == nil {
+			var rec []byte
+			if rec, err = walIDRecord(walHistory, id); err == nil {
+				err = w.append(bw, rec)
+			}
+		}
+	})
+	if err != nil {
+		return
+	}
+	entries := append(append(append([]*entry(nil), q.items.list()...), q.delayed...), q.inflight()...)
+	spilled, err := q.spilled()
+	if err != nil {
+		return
+	}
+	entries = append(entries, spilled...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+	for _, e := range entries {
+		var rec []byte
+		if rec, err = walEnqueueRecord(e); err != nil {
+			return
+		}
+		if err = w.append(bw, rec); err != nil {
+			return
+		}
+	}
+	if err = bw.Flush(); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, w.path); err != nil {
+		return
+	}
+	if w.f != nil {
+		w.f.Close()
+	}
+	w.f = f
+	w.records = 0
+	return
+}
+
+// Compact rewrites the log to hold just the current state of the
+// queue.
+func (w *WAL) Compact() error {
+	w.q.cond.L.Lock()
+	defer w.q.cond.L.Unlock()
+	return w.compact()
+}
+
+// Sync commits the log to the disk.
+func (w *WAL) Sync() error {
+	w.q.cond.L.Lock()
+	defer w.q.cond.L.Unlock()
+	return w.f.Sync()
+}
+
+// Err returns the first error the log has run into while writing
+// records which couldn't be reported otherwise, eg. from Dequeue.
+// Once it's set, nothing more is written to the log.
+func (w *WAL) Err() error {
+	w.q.cond.L.Lock()
+	defer w.q.cond.L.Unlock()
+	return w.err
+}
+
+// Close stops logging the queue changes and closes the log.
+func (w *WAL) Close() error {
+	w.q.cond.L.Lock()
+	defer w.q.cond.L.Unlock()
+	if w.q.wal == w {
+		w.q.wal = nil
+	}
+	return w.f.Close()
+}
+
+// logEnqueue writes the entry to the log, if there is one. The log
+// isn't compacted meanwhile, so the entry isn't left out while it's
+// not in the queue yet, see compactWAL.
+func (q *Queue) logEnqueue(e *entry) error {
+	if q.wal == nil {
+		return nil
+	}
+	return q.wal.record(walEnqueueRecord(e))
+}
+
+// compactWAL compacts the log, if there is one and it's due, once the
+// logged entries are in the queue.
+func (q *Queue) compactWAL() {
+	if q.wal != nil {
+		q.wal.compactDue()
+	}
+}
+
+// logInflight writes the entry taken from the queue, but not done
+// with yet or put back, to the log again, so it's recovered after a
+// crash until logRemove tells it's done with. Must be called once
+// the entry is kept in flight or back in the queue, so compaction
+// keeps it too.
+func (q *Queue) logInflight(e *entry) {
+	if q.wal != nil {
+		q.wal.fail(q.wal.write(walEnqueueRecord(e)))
+	}
+}
+
+// inflight returns the entries taken from the queue, but not done
+// with yet, which the log keeps.
+func (q *Queue) inflight() (entries []*entry) {
+	for d := range q.leased {
+		entries = append(entries, d.e)
+	}
+	for e := range q.reservations {
+		entries = append(entries, e)
+	}
+	return
+}
+
+// logUpdate writes the entry whose item has been changed in place to
+// the log again, if there is one, under the same sequence number, so
+// the log replays the changed item.
+func (q *Queue) logUpdate(e *entry) {
+	if q.wal == nil {
+		return
+	}
+	rec, err := walEnqueueRecord(e)
+	if err != nil {
+		q.wal.fail(err)
+		return
+	}
+	q.logRemove(e)
+	q.wal.fail(q.wal.write(rec, nil))
+}
+
+// logRemove writes to the log, if there is one, that the entry
+// has left the queue.
+func (q *Queue) logRemove(e *entry) {
+	if q.wal != nil {
+		q.wal.fail(q.wal.write(binary.AppendUvarint([]byte{walRemove}, e.seq), nil))
+	}
+}
+
+// logHistory writes the history change to the log, if there is one.
+func (q *Queue) logHistory(op byte, id interface{}) {
+	if q.wal == nil {
+		return
+	}
+	if op == walClearHistory {
+		q.wal.fail(q.wal.write([]byte{op}, nil))
+	} else {
+		q.wal.fail(q.wal.write(walIDRecord(op, id)))
+	}
+}
+
+// logLimit writes the current limit to the log, if there is one.
+func (q *Queue) logLimit() {
+	if q.wal != nil {
+		q.wal.fail(q.wal.write(binary.AppendVarint([]byte{walLimit}, int64(q.Limit)), nil))
+	}
+}
+
+// fail keeps the first error that can't be returned to the caller.
+func (w *WAL) fail(err error) {
+	if err != nil && w.err == nil {
+		w.err = err
+		w.q.logEvent(LogPersistence, nil, err)
+	}
+}
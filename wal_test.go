@@ -0,0 +1,189 @@
+package pqueue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := NewWithOptions(WithStableOrder())
+	w, err := q.OpenWAL(path, decodeStateTask, 0)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	for i, x := range []int{3, 1, 2, 1, 2} {
+		q.EnqueueUnique(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	q.Dequeue()
+	q.Remove("c")
+	q.RemoveFromHistory("b")
+	q.ChangeLimit(7)
+	// the queue crashes here, without closing the log
+
+	r := NewWithOptions(WithStableOrder())
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	w.Close()
+	if r.Limit != 7 || r.IdExists("b") || !r.IdExists("c") {
+		t.Errorf("Expected limit and history to be recovered")
+	}
+	for _, name := range []string{"d", "e", "a"} {
+		if task := r.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+	if !r.IsEmpty() {
+		t.Errorf("Expected only pending items to be recovered")
+	}
+}
+
+func TestWALUpdatePriority(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := New(0)
+	w, err := q.OpenWAL(path, decodeStateTask, 0)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	for i, x := range []int{1, 2, 3} {
+		q.Enqueue(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	q.UpdatePriority("c", func(item QueueItem) {
+		item.(*stateTask).Priority = 0
+	})
+	w.Close()
+
+	r := New(0)
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	if r.Len() != 3 {
+		t.Errorf("Expected 3 items recovered, given %d", r.Len())
+	}
+	if task := r.Dequeue().(*stateTask); task.Name != "c" || task.Priority != 0 {
+		t.Errorf("Expected updated priority to be recovered, given %v", task)
+	}
+}
+
+func TestWALReprioritize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := New(0)
+	w, err := q.OpenWAL(path, decodeStateTask, 0)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	for i, x := range []int{1, 2, 3} {
+		q.Enqueue(&stateTask{Name: string(rune('a' + i)), Priority: x})
+	}
+	q.Reprioritize(func(item QueueItem) {
+		item.(*stateTask).Priority *= -1
+	})
+	w.Close()
+
+	r := New(0)
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	for _, name := range []string{"c", "b", "a"} {
+		if task := r.Dequeue().(*stateTask); task.Name != name {
+			t.Errorf("Expected to dequeue %s, given %s", name, task.Name)
+		}
+	}
+	if !r.IsEmpty() {
+		t.Errorf("Expected only the reprioritized items to be recovered")
+	}
+}
+
+func TestWALCompactionOnEnqueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := New(0)
+	w, err := q.OpenWAL(path, decodeStateTask, 2)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	for i := 0; i < 4; i += 1 {
+		q.Enqueue(&stateTask{Name: fmt.Sprint(i), Priority: i})
+	}
+	w.Close()
+	r := New(0)
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	if r.Len() != 4 {
+		t.Errorf("Expected items enqueued across compactions recovered, given %d", r.Len())
+	}
+}
+
+func TestWALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := New(0)
+	w, err := q.OpenWAL(path, decodeStateTask, 10)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	defer w.Close()
+	for i := 0; i < 100; i += 1 {
+		q.Enqueue(&stateTask{Name: "task", Priority: i})
+		q.Dequeue()
+	}
+	q.Enqueue(&stateTask{Name: "last", Priority: 1})
+	if w.Err() != nil {
+		t.Errorf("Expected no log errors, given %v", w.Err())
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() > 500 {
+		t.Errorf("Expected log to be compacted")
+	}
+	r := New(0)
+	if _, err := r.OpenWAL(path+".copy", decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected empty log to be opened, given %v", err)
+	}
+	if err := w.Sync(); err != nil {
+		t.Errorf("Expected log to be synced, given %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path+".copy", append(data, 1, 2, 3), 0644)
+	r = New(0)
+	if _, err := r.OpenWAL(path+".copy", decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected torn record to be skipped, given %v", err)
+	}
+	if r.Len() != 1 || r.Dequeue().(*stateTask).Name != "last" {
+		t.Errorf("Expected compacted log to be recovered")
+	}
+}
+
+func TestWALLeased(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	q := New(0)
+	w, err := q.OpenWAL(path, decodeStateTask, 3)
+	if err != nil {
+		t.Fatalf("Expected log to be opened, given %v", err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		q.Enqueue(&stateTask{Name: name, Priority: 1})
+	}
+	ctx := context.Background()
+	acked, _ := q.Lease(ctx)
+	acked.Ack()
+	nacked, _ := q.Lease(ctx)
+	nacked.Nack()
+	q.Lease(ctx)
+	q.Lease(ctx)
+	w.Close()
+
+	r := New(0)
+	if _, err := r.OpenWAL(path, decodeStateTask, 0); err != nil {
+		t.Fatalf("Expected log to be replayed, given %v", err)
+	}
+	if r.Len() != 2 {
+		t.Errorf("Expected the 2 items leased and not acked recovered, given %d", r.Len())
+	}
+	for r.Len() > 0 {
+		if item := r.Dequeue(); item.Id() == acked.Item.Id() {
+			t.Errorf("Expected acked item gone, given %v", item)
+		}
+	}
+}
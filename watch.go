@@ -0,0 +1,78 @@
+package pqueue
+
+import (
+	"context"
+	"slices"
+	"sort"
+)
+
+// lenWatch is a listener of queue length changes, see Watch.
+type lenWatch struct {
+	ch         chan int
+	thresholds []int
+	band       int
+}
+
+// Watch returns a channel that receives the queue length, delayed
+// items included, whenever it crosses one of given thresholds, eg. so
+// an autoscaler can react to growing backlog without polling Len.
+// Without thresholds every change of the length is sent. The current
+// length is sent right away. Only the latest length is kept for slow
+// receivers, older ones are dropped. The channel is closed once the
+// context is done.
+func (q *Queue) Watch(ctx context.Context, thresholds ...int) <-chan int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	w := &lenWatch{ch: make(chan int, 1), thresholds: slices.Clone(thresholds)}
+	sort.Ints(w.thresholds)
+	w.band = w.bandOf(q.size())
+	w.send(q.size())
+	q.lenWatches = append(q.lenWatches, w)
+	context.AfterFunc(ctx, func() {
+		q.cond.L.Lock()
+		defer q.cond.L.Unlock()
+		q.lenWatches = slices.DeleteFunc(q.lenWatches, func(x *lenWatch) bool {
+			return x == w
+		})
+		close(w.ch)
+	})
+	return w.ch
+}
+
+// bandOf returns number of thresholds at or below the length, or
+// the length itself when there are no thresholds.
+func (w *lenWatch) bandOf(n int) int {
+	if len(w.thresholds) == 0 {
+		return n
+	}
+	return sort.SearchInts(w.thresholds, n+1)
+}
+
+// send puts the length to the channel, replacing the one not
+// received yet.
+func (w *lenWatch) send(n int) {
+	select {
+	case w.ch <- n:
+	default:
+		select {
+		case <-w.ch:
+		default:
+		}
+		w.ch <- n
+	}
+}
+
+// watchLen tells the watchers about the new length once it crosses
+// their thresholds. Must be called with the queue locked.
+func (q *Queue) watchLen() {
+	if len(q.lenWatches) == 0 {
+		return
+	}
+	n := q.size()
+	for _, w := range q.lenWatches {
+		if band := w.bandOf(n); band != w.band {
+			w.band = band
+			w.send(n)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	q := New(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := q.Watch(ctx, 5, 2)
+	if n := <-ch; n != 0 {
+		t.Errorf("Expected current length first, given %d", n)
+	}
+	for i := 0; i < 6; i++ {
+		q.Enqueue(NewDummyTask(i))
+		if i == 1 {
+			if n := <-ch; n != 2 {
+				t.Errorf("Expected length crossing 2, given %d", n)
+			}
+		}
+	}
+	if n := <-ch; n != 5 {
+		t.Errorf("Expected length crossing 5, given %d", n)
+	}
+	q.Dequeue()
+	q.Dequeue()
+	if n := <-ch; n != 4 {
+		t.Errorf("Expected length falling below 5, given %d", n)
+	}
+	q.Clear(false)
+	if n := <-ch; n != 0 {
+		t.Errorf("Expected only the latest length, given %d", n)
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected channel to be closed")
+	}
+
+	all := q.Watch(context.Background())
+	<-all
+	q.Enqueue(NewDummyTask(1))
+	if n := <-all; n != 1 {
+		t.Errorf("Expected every change without thresholds, given %d", n)
+	}
+}
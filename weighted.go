@@ -0,0 +1,129 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errClass is returned for items of class out of range.
+var errClass = errors.New("Priority class out of range")
+
+// WeightedQueue serves items of several classes by their weights
+// instead of strict priority, so classes of low priority get their
+// share even while items of higher classes keep coming. With
+// weights 7, 2 and 1, of every ten items dequeued while all the
+// classes have items waiting, seven are of class 0, two of class 1
+// and one of class 2. Classes without items don't take their share.
+// Within a class items are dequeued in priority order.
+type WeightedQueue struct {
+	classes []*Queue
+	weights []int
+	class   func(QueueItem) int
+	notify  *notifier
+
+	// smooth weighted round robin state
+	mu      sync.Mutex
+	current []int
+}
+
+// NewWeighted creates a queue of as many classes as there are
+// weights, each one created by NewWithOptions with given options.
+// Class of every item is given by class.
+func NewWeighted(class func(QueueItem) int, weights []int, opts ...Option) *WeightedQueue {
+	w := &WeightedQueue{
+		classes: make([]*Queue, len(weights)),
+		weights: weights,
+		class:   class,
+		notify:  newNotifier(),
+		current: make([]int, len(weights)),
+	}
+	for i := range w.classes {
+		w.classes[i] = NewWithOptions(opts...)
+	}
+	return w
+}
+
+// Class returns the queue of given class.
+func (w *WeightedQueue) Class(class int) *Queue {
+	return w.classes[class]
+}
+
+// Enqueue puts given item to the queue of its class.
+func (w *WeightedQueue) Enqueue(item QueueItem) error {
+	c := w.class(item)
+	if c < 0 || c >= len(w.classes) {
+		return errClass
+	}
+	err := w.classes[c].Enqueue(item)
+	if err == nil {
+		w.notify.wake()
+	}
+	return err
+}
+
+// Dequeue takes an item of the class whose turn it is, waiting for
+// one while all the classes are empty. Once the queue is closed and
+// drained it returns nil.
+func (w *WeightedQueue) Dequeue() QueueItem {
+	item, _ := w.DequeueContext(context.Background())
+	return item
+}
+
+// DequeueContext is Dequeue giving up when the context is done,
+// with the context's error. Once the queue is closed and drained it
+// returns ErrClosed.
+func (w *WeightedQueue) DequeueContext(ctx context.Context) (QueueItem, error) {
+	return w.notify.wait(ctx, w.TryDequeue, func() bool {
+		return w.notify.isClosed() && w.IsEmpty()
+	})
+}
+
+// TryDequeue takes an item of the class whose turn it is, without
+// blocking. It returns false when all the classes are empty.
+func (w *WeightedQueue) TryDequeue() (QueueItem, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		best, total := -1, 0
+		for i, q := range w.classes {
+			if q.Len() == 0 {
+				continue
+			}
+			w.current[i] += w.weights[i]
+			total += w.weights[i]
+			if best < 0 || w.current[i] > w.current[best] {
+				best = i
+			}
+		}
+		if best < 0 {
+			return nil, false
+		}
+		w.current[best] -= total
+		if item, ok := w.classes[best].TryDequeue(); ok {
+			return item, true
+		}
+		// the class has been emptied meanwhile, pick again
+	}
+}
+
+// Len returns number of items in all the classes.
+func (w *WeightedQueue) Len() (n int) {
+	for _, q := range w.classes {
+		n += q.Len()
+	}
+	return
+}
+
+// IsEmpty returns true if all the classes are empty.
+func (w *WeightedQueue) IsEmpty() bool {
+	return w.Len() == 0
+}
+
+// Close closes all the classes.
+func (w *WeightedQueue) Close() {
+	for _, q := range w.classes {
+		q.Close()
+	}
+	w.notify.close()
+}
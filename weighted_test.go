@@ -0,0 +1,41 @@
+package pqueue
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWeightedShares(t *testing.T) {
+	w := NewWeighted(taskLevel, []int{7, 2, 1})
+	for i := 0; i < 100; i++ {
+		for c := 0; c < 3; c++ {
+			w.Enqueue(&stateTask{Name: string(rune(i)), Priority: c})
+		}
+	}
+	counts := make([]int, 3)
+	for i := 0; i < 100; i++ {
+		counts[w.Dequeue().(*stateTask).Priority]++
+	}
+	if counts[0] != 70 || counts[1] != 20 || counts[2] != 10 {
+		t.Errorf("Expected classes served 70/20/10, given %v", counts)
+	}
+}
+
+func TestWeightedEmptyClass(t *testing.T) {
+	w := NewWeighted(taskLevel, []int{7, 2, 1})
+	for i := 0; i < 3; i++ {
+		w.Enqueue(&stateTask{Name: string(rune(i)), Priority: 2})
+	}
+	if err := w.Enqueue(&stateTask{Priority: 3}); err == nil {
+		t.Errorf("Expected class out of range to be refused")
+	}
+	for i := 0; i < 3; i++ {
+		if _, ok := w.TryDequeue(); !ok {
+			t.Errorf("Expected the only class with items to be served")
+		}
+	}
+	w.Close()
+	if _, err := w.DequeueContext(context.Background()); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, given %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package pqueue
+
+import (
+	"context"
+	"sync"
+)
+
+// Work runs n workers which dequeue items and call handler for each
+// of them, until the context is done or the queue is closed and
+// drained. Items for which handler returns an error are put back to
+// the queue with Retry, so the retry policy applies. When the
+// context is done workers stop taking new items, but the running
+// handlers are waited for. Work returns once all the workers have
+// stopped, with the context's error, or nil when the queue has been
+// closed.
+func (q *Queue) Work(ctx context.Context, n int, handler func(QueueItem) error) error {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, err := q.DequeueContext(ctx)
+				if err != nil {
+					return
+				}
+				if handler(item) != nil {
+					q.Retry(item)
+				} else {
+					q.ResetAttempts(item.Id())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
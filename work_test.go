@@ -0,0 +1,49 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestWork(t *testing.T) {
+	q := New(0)
+	for x := 1; x <= 10; x++ {
+		q.Enqueue(NewDummyTask(x))
+	}
+	var mu sync.Mutex
+	handled := map[int]int{}
+	err := q.Work(context.Background(), 3, func(item QueueItem) error {
+		mu.Lock()
+		defer mu.Unlock()
+		task := item.(*DummyTask)
+		handled[task.priority]++
+		if task.priority == 5 && handled[5] == 1 {
+			return errors.New("failed")
+		}
+		if len(handled) == 10 && handled[5] == 2 {
+			q.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected Work to stop with closed queue, given %v", err)
+	}
+	if len(handled) != 10 || handled[5] != 2 {
+		t.Errorf("Expected all the items handled and failed one retried, given %v", handled)
+	}
+}
+
+func TestWorkCancel(t *testing.T) {
+	q := New(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Enqueue(NewDummyTask(1))
+	err := q.Work(ctx, 2, func(QueueItem) error {
+		cancel()
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected Work to stop with context error, given %v", err)
+	}
+}